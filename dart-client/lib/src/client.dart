@@ -324,8 +324,13 @@ class MediapodClient {
   String _parseError(String body) {
     try {
       final decoded = json.decode(body);
-      if (decoded is Map && decoded.containsKey('error')) {
-        return decoded['error'] as String;
+      if (decoded is Map) {
+        // Errors are RFC 9457 problem details
+        final message = decoded['detail'] ?? decoded['title'];
+        if (message is String) {
+          final code = decoded['code'];
+          return code is String ? '$message ($code)' : message;
+        }
       }
       return body;
     } catch (_) {
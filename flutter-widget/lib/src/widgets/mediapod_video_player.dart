@@ -177,7 +177,7 @@ class _MediapodVideoPlayerState extends State<MediapodVideoPlayer> {
 
     // Build HLS URL from VOD base URL and asset ID
     // Format: {vodBaseUrl}/{assetId}/hls/master.m3u8
-    // The processor uploads HLS files to: media-vod/{assetId}/hls/
+    // The processor uploads HLS files of public videos to: media-vod/public/{assetId}/hls/
     final url = '${widget.vodBaseUrl}/${widget.asset.id}/hls/master.m3u8';
     debugPrint('[VideoPlayer] Built HLS URL: $url');
     debugPrint('[VideoPlayer] Asset URLs: ${widget.asset.urls}');
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const defaultAPIURL = "http://localhost:8080"
+
+// Profile holds the settings of one API deployment
+type Profile struct {
+	APIURL string `json:"apiUrl"`
+	Token  string `json:"token,omitempty"`
+	Output string `json:"output,omitempty"` // table or json
+}
+
+// ConfigFile is the profile file, by default ~/.config/mediapod/config.json:
+//
+//	{
+//	  "defaultProfile": "prod",
+//	  "profiles": {
+//	    "prod": {"apiUrl": "https://media.example.com"},
+//	    "local": {"apiUrl": "http://localhost:8080", "output": "json"}
+//	  }
+//	}
+type ConfigFile struct {
+	DefaultProfile string             `json:"defaultProfile,omitempty"`
+	Profiles       map[string]Profile `json:"profiles"`
+}
+
+// configPath returns MEDIAPOD_CONFIG or the default profile file location
+func configPath() string {
+	if p := os.Getenv("MEDIAPOD_CONFIG"); p != "" {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "mediapod", "config.json")
+}
+
+func loadConfigFile(path string) (*ConfigFile, error) {
+	cfg := &ConfigFile{Profiles: map[string]Profile{}}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]Profile{}
+	}
+	return cfg, nil
+}
+
+// resolveProfile merges settings with precedence flags > environment >
+// profile file > defaults
+func resolveProfile(flagProfile, flagURL, flagToken, flagOutput string) (Profile, string, error) {
+	path := configPath()
+	file, err := loadConfigFile(path)
+	if err != nil {
+		return Profile{}, "", err
+	}
+
+	name := firstNonEmpty(flagProfile, os.Getenv("MEDIAPOD_PROFILE"), file.DefaultProfile, "default")
+	profile, found := file.Profiles[name]
+	if !found && (flagProfile != "" || os.Getenv("MEDIAPOD_PROFILE") != "") {
+		return Profile{}, "", fmt.Errorf("profile %q not found in %s", name, path)
+	}
+
+	profile.APIURL = firstNonEmpty(flagURL, os.Getenv("MEDIAPOD_API_URL"), profile.APIURL, defaultAPIURL)
+	profile.Token = firstNonEmpty(flagToken, os.Getenv("MEDIAPOD_AUTH_TOKEN"), profile.Token)
+	profile.Output = firstNonEmpty(flagOutput, os.Getenv("MEDIAPOD_OUTPUT"), profile.Output, "table")
+	if profile.Output != "table" && profile.Output != "json" {
+		return Profile{}, "", fmt.Errorf("invalid output %q: must be table or json", profile.Output)
+	}
+
+	return profile, name, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ancill/mediapod/services/media-api/pkg/client"
+)
+
+func runKeys(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("missing keys subcommand")
+	}
+
+	sub, args := args[0], args[1:]
+	switch sub {
+	case "list":
+		return runKeysList(ctx, e, args)
+	case "create":
+		return runKeysCreate(ctx, e, args)
+	case "delete":
+		return runKeysDelete(ctx, e, args)
+	default:
+		return usageErrorf("unknown keys subcommand %q", sub)
+	}
+}
+
+func printKeys(e *env, v interface{}, keys []client.APIKey) error {
+	return e.out.print(v, func(w io.Writer) {
+		row(w, "ID", "NAME", "PREFIX", "CREATED", "LAST USED")
+		for _, k := range keys {
+			row(w, k.ID, k.Name, k.Prefix+"…", formatTime(k.CreatedAt), formatOptionalTime(k.LastUsedAt))
+		}
+	})
+}
+
+func runKeysList(ctx context.Context, e *env, args []string) error {
+	if len(args) > 0 {
+		return usageErrorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+
+	list, err := e.client.ListAPIKeys(ctx)
+	if err != nil {
+		return err
+	}
+	return printKeys(e, list.Keys, list.Keys)
+}
+
+func runKeysCreate(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("keys create", flag.ContinueOnError)
+	name := fs.String("name", "", "who or what uses the key (required)")
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *name == "" && len(rest) == 1 {
+		*name = rest[0]
+	} else if len(rest) > 0 {
+		return usageErrorf("unexpected arguments: %s", strings.Join(rest, " "))
+	}
+	if *name == "" {
+		return usageErrorf("-name is required")
+	}
+
+	key, err := e.client.CreateAPIKey(ctx, client.CreateAPIKeyRequest{Name: *name})
+	if err != nil {
+		return err
+	}
+
+	if err := printKeys(e, key, []client.APIKey{*key}); err != nil {
+		return err
+	}
+	if !e.out.json {
+		status("API key (shown once): %s", key.Key)
+	}
+	return nil
+}
+
+func runKeysDelete(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("no API key IDs given")
+	}
+
+	failed := 0
+	for _, id := range args {
+		if err := e.client.DeleteAPIKey(ctx, id); err != nil {
+			failed++
+			status("failed: %s: %v", id, err)
+			continue
+		}
+		status("deleted %s", id)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d API keys failed", failed, len(args))
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+// Command mediapodctl manages Mediapod assets, webhooks and API keys from the
+// command line.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/ancill/mediapod/services/media-api/pkg/client"
+)
+
+// env is shared by all commands
+type env struct {
+	client  *client.Client
+	profile Profile
+	out     *printer
+}
+
+type command struct {
+	usage   string
+	summary string
+	run     func(ctx context.Context, e *env, args []string) error
+}
+
+var commands = map[string]command{
+	"upload":    {"upload [flags] <file|dir>...", "Upload files or whole directories", runUpload},
+	"list":      {"list [flags]", "List assets, newest first", runList},
+	"search":    {"search [flags] <text>", "Find assets by filename", runSearch},
+	"get":       {"get <assetId>", "Show asset details and URLs", runGet},
+	"jobs":      {"jobs <assetId>", "Show the processing jobs of an asset", runJobs},
+	"delete":    {"delete <assetId>...", "Delete assets", runDelete},
+	"reprocess": {"reprocess [flags] <assetId>...", "Process assets again", runReprocess},
+	"download":  {"download [flags] <assetId>...", "Download original files", runDownload},
+	"webhooks":  {"webhooks <list|get|create|update|delete|deliveries> ...", "Manage webhook subscriptions", runWebhooks},
+	"keys":      {"keys <list|create|delete> ...", "Manage API keys", runKeys},
+	"config":    {"config", "Show the resolved configuration", runConfig},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: mediapodctl [global flags] <command> [flags] [args]
+
+Global flags:
+  --profile <name>   profile from the config file (env MEDIAPOD_PROFILE)
+  --api-url <url>    API base URL (env MEDIAPOD_API_URL)
+  --token <token>    API key or other bearer token (env MEDIAPOD_AUTH_TOKEN)
+  -o <table|json>    output format (env MEDIAPOD_OUTPUT)
+
+Commands:
+`)
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-11s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nConfig file: %s (override with MEDIAPOD_CONFIG)\n", configPath())
+}
+
+func main() {
+	global := flag.NewFlagSet("mediapodctl", flag.ContinueOnError)
+	global.Usage = usage
+	profileName := global.String("profile", "", "")
+	apiURL := global.String("api-url", "", "")
+	token := global.String("token", "", "")
+	output := global.String("o", "", "")
+
+	if err := global.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+	if global.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := global.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	// -o is also accepted after the command name
+	args, cmdOutput := extractOutputFlag(global.Args()[1:])
+
+	profile, _, err := resolveProfile(*profileName, *apiURL, *token, firstNonEmpty(cmdOutput, *output))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+
+	var opts []client.Option
+	if profile.Token != "" {
+		opts = append(opts, client.WithAuthToken(profile.Token))
+	}
+	opts = append(opts, client.WithUserAgent("mediapodctl"))
+
+	e := &env{
+		client:  client.New(profile.APIURL, opts...),
+		profile: profile,
+		out:     &printer{json: profile.Output == "json", out: os.Stdout},
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := cmd.run(ctx, e, args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		var usageErr usageError
+		if errors.As(err, &usageErr) {
+			fmt.Fprintf(os.Stderr, "error: %s\nusage: mediapodctl %s\n", usageErr.msg, cmd.usage)
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// usageError reports invalid arguments of a command
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+func usageErrorf(format string, args ...interface{}) error {
+	return usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// extractOutputFlag removes "-o <format>" / "-o=<format>" from command arguments
+func extractOutputFlag(args []string) ([]string, string) {
+	var rest []string
+	output := ""
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--":
+			return append(rest, args[i:]...), output
+		case (args[i] == "-o" || args[i] == "--o" || args[i] == "--output") && i+1 < len(args):
+			output = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "-o=") || strings.HasPrefix(args[i], "--output="):
+			output = args[i][strings.Index(args[i], "=")+1:]
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return rest, output
+}
+
+// parseFlags parses flags that may appear before, between or after
+// positional arguments and returns the positional arguments
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(os.Stderr)
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func runConfig(ctx context.Context, e *env, args []string) error {
+	p := e.profile
+	if p.Token != "" {
+		p.Token = "********"
+	}
+	return e.out.print(p, func(w io.Writer) {
+		row(w, "API URL", p.APIURL)
+		row(w, "Token", firstNonEmpty(p.Token, "-"))
+		row(w, "Output", p.Output)
+		row(w, "Config file", configPath())
+	})
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ancill/mediapod/services/media-api/pkg/client"
+)
+
+func printAssets(e *env, assets []client.Asset) error {
+	if assets == nil {
+		assets = []client.Asset{}
+	}
+	return e.out.print(assets, func(w io.Writer) {
+		row(w, "ID", "KIND", "STATE", "VISIBILITY", "SIZE", "CREATED", "FILENAME")
+		for _, a := range assets {
+			row(w, a.ID, a.Kind, a.State, a.Visibility, formatSize(a.Size), formatTime(a.CreatedAt), a.Filename)
+		}
+	})
+}
+
+func runUpload(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("upload", flag.ContinueOnError)
+	kind := fs.String("kind", "", "asset kind (default: detected from the MIME type)")
+	mimeType := fs.String("mime", "", "MIME type (default: detected)")
+	private := fs.Bool("private", false, "upload as private assets")
+	wait := fs.Bool("wait", false, "wait until processing finishes")
+	jobs := fs.Int("j", 4, "number of parallel uploads")
+	hidden := fs.Bool("hidden", false, "include dot files when uploading directories")
+	paths, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return usageErrorf("no files given")
+	}
+	if *jobs < 1 {
+		return usageErrorf("-j must be at least 1")
+	}
+
+	files, err := collectFiles(paths, *hidden)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("no files to upload")
+	}
+
+	opts := client.UploadOptions{Kind: *kind, MimeType: *mimeType}
+	if *private {
+		opts.Visibility = client.VisibilityPrivate
+	}
+
+	results := make([]*client.Asset, len(files))
+	errs := make([]error, len(files))
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < *jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range queue {
+				results[idx], errs[idx] = uploadOne(ctx, e, files[idx], opts, *wait)
+			}
+		}()
+	}
+	for i := range files {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	var uploaded []client.Asset
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			failed++
+			status("failed: %s: %v", files[i], err)
+			continue
+		}
+		uploaded = append(uploaded, *results[i])
+	}
+
+	if err := printAssets(e, uploaded); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d uploads failed", failed, len(files))
+	}
+	return nil
+}
+
+func uploadOne(ctx context.Context, e *env, path string, opts client.UploadOptions, wait bool) (*client.Asset, error) {
+	start := time.Now()
+	asset, err := e.client.UploadFile(ctx, path, opts)
+	if err != nil {
+		return nil, err
+	}
+	status("uploaded %s -> %s (%s, %s)", path, asset.ID, formatSize(asset.Size), time.Since(start).Round(time.Millisecond))
+
+	if wait && !asset.IsReady() {
+		asset, err = e.client.WaitUntilReady(ctx, asset.ID, client.WaitOptions{})
+		if err != nil {
+			return nil, err
+		}
+		status("ready %s", asset.ID)
+	}
+	return asset, nil
+}
+
+// collectFiles expands directories to the regular files below them
+func collectFiles(paths []string, hidden bool) ([]string, error) {
+	var files []string
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, root)
+			continue
+		}
+
+		err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !hidden && path != root && strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.Type().IsRegular() {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func listFlags(name string) (*flag.FlagSet, *client.ListOptions) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	opts := &client.ListOptions{}
+	fs.StringVar(&opts.Kind, "kind", "", "filter by kind (image, video, audio, document)")
+	fs.StringVar(&opts.State, "state", "", "filter by state (uploading, processing, ready, failed)")
+	fs.StringVar(&opts.Visibility, "visibility", "", "filter by visibility (public, private)")
+	fs.IntVar(&opts.Limit, "limit", 50, "maximum number of assets")
+	fs.IntVar(&opts.Offset, "offset", 0, "number of assets to skip")
+	return fs, opts
+}
+
+func runList(ctx context.Context, e *env, args []string) error {
+	fs, opts := listFlags("list")
+	fs.StringVar(&opts.Query, "q", "", "filter by filename substring")
+	fs.StringVar(&opts.Tag, "tag", "", "filter by tag")
+	fs.StringVar(&opts.Sort, "sort", "", "order by created (default) or captured time")
+	fs.StringVar(&opts.Color, "color", "", "filter by a palette color near this hex color (rrggbb)")
+	fs.IntVar(&opts.ColorDistance, "color-distance", 0, "maximum delta-E from -color (default 15)")
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return usageErrorf("unexpected arguments: %s", strings.Join(rest, " "))
+	}
+
+	list, err := e.client.ListAssets(ctx, *opts)
+	if err != nil {
+		return err
+	}
+	return printAssets(e, list.Assets)
+}
+
+func runSearch(ctx context.Context, e *env, args []string) error {
+	fs, opts := listFlags("search")
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return usageErrorf("no search text given")
+	}
+	opts.Query = strings.Join(rest, " ")
+
+	list, err := e.client.ListAssets(ctx, *opts)
+	if err != nil {
+		return err
+	}
+	return printAssets(e, list.Assets)
+}
+
+func oneAssetID(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", usageErrorf("expected one asset ID")
+	}
+	return args[0], nil
+}
+
+func runGet(ctx context.Context, e *env, args []string) error {
+	assetID, err := oneAssetID(args)
+	if err != nil {
+		return err
+	}
+
+	asset, err := e.client.GetAsset(ctx, assetID)
+	if err != nil {
+		return err
+	}
+
+	return e.out.print(asset, func(w io.Writer) {
+		row(w, "ID", asset.ID)
+		row(w, "Filename", asset.Filename)
+		row(w, "Kind", asset.Kind)
+		row(w, "State", asset.State)
+		row(w, "Visibility", asset.Visibility)
+		row(w, "MIME type", asset.MimeType)
+		row(w, "Size", formatSize(asset.Size))
+		if asset.Width != nil && asset.Height != nil {
+			row(w, "Dimensions", fmt.Sprintf("%dx%d", *asset.Width, *asset.Height))
+		}
+		if asset.Image != nil {
+			format := asset.Image.Format
+			if asset.Image.FrameCount > 1 {
+				format += fmt.Sprintf(" (%d frames)", asset.Image.FrameCount)
+			}
+			row(w, "Format", format)
+			color := asset.Image.ColorSpace
+			if asset.Image.BitDepth > 0 {
+				color += fmt.Sprintf(", %d-bit", asset.Image.BitDepth)
+			}
+			if asset.Image.HasAlpha {
+				color += ", alpha"
+			}
+			if asset.Image.ColorProfile != "" {
+				color += ", " + asset.Image.ColorProfile
+			}
+			row(w, "Color", color)
+		}
+		if asset.Duration != nil {
+			row(w, "Duration", fmt.Sprintf("%.2fs", *asset.Duration))
+		}
+		row(w, "Object", asset.Bucket+"/"+asset.ObjectKey)
+		row(w, "Created", formatTime(asset.CreatedAt))
+
+		printURLs(w, "URL ", asset.URLs)
+	})
+}
+
+// printURLs prints asset URLs sorted by name, flattening nested groups such
+// as presets into "presets.<name>"
+func printURLs(w io.Writer, prefix string, urls map[string]interface{}) {
+	names := make([]string, 0, len(urls))
+	for name := range urls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if group, ok := urls[name].(map[string]interface{}); ok {
+			printURLs(w, prefix+name+".", group)
+			continue
+		}
+		row(w, prefix+name, fmt.Sprint(urls[name]))
+	}
+}
+
+func runJobs(ctx context.Context, e *env, args []string) error {
+	assetID, err := oneAssetID(args)
+	if err != nil {
+		return err
+	}
+
+	list, err := e.client.ListJobs(ctx, assetID)
+	if err != nil {
+		return err
+	}
+
+	return e.out.print(list.Jobs, func(w io.Writer) {
+		row(w, "ID", "TYPE", "STATE", "ATTEMPTS", "CREATED", "STARTED", "COMPLETED", "ERROR")
+		for _, j := range list.Jobs {
+			row(w, j.ID, j.Type, j.State, fmt.Sprintf("%d/%d", j.Attempts, j.MaxAttempts), formatTime(j.CreatedAt),
+				formatOptionalTime(j.StartedAt), formatOptionalTime(j.CompletedAt), orDash(j.Error))
+		}
+	})
+}
+
+// forEachAsset runs fn for every asset ID, reporting failures and
+// continuing with the rest
+func forEachAsset(args []string, fn func(assetID string) error) error {
+	if len(args) == 0 {
+		return usageErrorf("no asset IDs given")
+	}
+
+	failed := 0
+	for _, assetID := range args {
+		if err := fn(assetID); err != nil {
+			failed++
+			status("failed: %s: %v", assetID, err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d assets failed", failed, len(args))
+	}
+	return nil
+}
+
+func runDelete(ctx context.Context, e *env, args []string) error {
+	return forEachAsset(args, func(assetID string) error {
+		if err := e.client.DeleteAsset(ctx, assetID); err != nil {
+			return err
+		}
+		status("deleted %s", assetID)
+		return nil
+	})
+}
+
+func runReprocess(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("reprocess", flag.ContinueOnError)
+	wait := fs.Bool("wait", false, "wait until processing finishes")
+	ids, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	return forEachAsset(ids, func(assetID string) error {
+		resp, err := e.client.Reprocess(ctx, assetID)
+		if err != nil {
+			return err
+		}
+		status("queued %s (job %s)", assetID, resp.JobID)
+
+		if *wait {
+			if _, err := e.client.WaitUntilReady(ctx, assetID, client.WaitOptions{}); err != nil {
+				return err
+			}
+			status("ready %s", assetID)
+		}
+		return nil
+	})
+}
+
+func runDownload(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("download", flag.ContinueOnError)
+	dir := fs.String("dir", ".", "directory to save files to")
+	output := fs.String("out", "", "file to save a single asset to (- for stdout)")
+	force := fs.Bool("force", false, "overwrite existing files")
+	ids, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *output != "" && len(ids) != 1 {
+		return usageErrorf("-out needs exactly one asset ID")
+	}
+
+	return forEachAsset(ids, func(assetID string) error {
+		path := *output
+		if path == "" {
+			asset, err := e.client.GetAsset(ctx, assetID)
+			if err != nil {
+				return err
+			}
+			// Only the base name is used so stored filenames cannot escape dir
+			path = filepath.Join(*dir, filepath.Base(asset.Filename))
+		}
+		return downloadOne(ctx, e, assetID, path, *force)
+	})
+}
+
+func downloadOne(ctx context.Context, e *env, assetID, path string, force bool) error {
+	body, err := e.client.OpenOriginal(ctx, assetID)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if path == "-" {
+		_, err := io.Copy(os.Stdout, body)
+		return err
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return err
+	}
+
+	n, err := io.Copy(f, body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	status("downloaded %s -> %s (%s)", assetID, path, formatSize(n))
+	return nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ancill/mediapod/services/media-api/pkg/client"
+)
+
+func runWebhooks(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("missing webhooks subcommand")
+	}
+
+	sub, args := args[0], args[1:]
+	switch sub {
+	case "list":
+		return runWebhooksList(ctx, e, args)
+	case "get":
+		return runWebhooksGet(ctx, e, args)
+	case "create":
+		return runWebhooksCreate(ctx, e, args)
+	case "update":
+		return runWebhooksUpdate(ctx, e, args)
+	case "delete":
+		return runWebhooksDelete(ctx, e, args)
+	case "deliveries":
+		return runWebhooksDeliveries(ctx, e, args)
+	default:
+		return usageErrorf("unknown webhooks subcommand %q", sub)
+	}
+}
+
+func printWebhooks(e *env, v interface{}, webhooks []client.Webhook) error {
+	return e.out.print(v, func(w io.Writer) {
+		row(w, "ID", "ACTIVE", "EVENTS", "URL")
+		for _, h := range webhooks {
+			events := "all"
+			if len(h.Events) > 0 {
+				events = strings.Join(h.Events, ",")
+			}
+			row(w, h.ID, h.Active, events, h.URL)
+		}
+	})
+}
+
+func runWebhooksList(ctx context.Context, e *env, args []string) error {
+	if len(args) > 0 {
+		return usageErrorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+
+	list, err := e.client.ListWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+	return printWebhooks(e, list.Webhooks, list.Webhooks)
+}
+
+func runWebhooksGet(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 {
+		return usageErrorf("expected one webhook ID")
+	}
+
+	webhook, err := e.client.GetWebhook(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return printWebhooks(e, webhook, []client.Webhook{*webhook})
+}
+
+func splitEvents(s string) []string {
+	var events []string
+	for _, ev := range strings.Split(s, ",") {
+		if ev = strings.TrimSpace(ev); ev != "" {
+			events = append(events, ev)
+		}
+	}
+	return events
+}
+
+func runWebhooksCreate(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("webhooks create", flag.ContinueOnError)
+	url := fs.String("url", "", "endpoint URL (required)")
+	events := fs.String("events", "", "comma-separated events (default: all)")
+	secret := fs.String("secret", "", "signing secret (default: generated)")
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *url == "" && len(rest) == 1 {
+		*url = rest[0]
+	} else if len(rest) > 0 {
+		return usageErrorf("unexpected arguments: %s", strings.Join(rest, " "))
+	}
+	if *url == "" {
+		return usageErrorf("-url is required")
+	}
+
+	webhook, err := e.client.CreateWebhook(ctx, client.CreateWebhookRequest{
+		URL:    *url,
+		Secret: *secret,
+		Events: splitEvents(*events),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := printWebhooks(e, webhook, []client.Webhook{*webhook}); err != nil {
+		return err
+	}
+	if !e.out.json {
+		status("signing secret (shown once): %s", webhook.Secret)
+	}
+	return nil
+}
+
+func runWebhooksUpdate(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("webhooks update", flag.ContinueOnError)
+	url := fs.String("url", "", "new endpoint URL")
+	events := fs.String("events", "", "comma-separated events; \"all\" for every event")
+	active := fs.String("active", "", "true or false")
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return usageErrorf("expected one webhook ID")
+	}
+
+	var req client.UpdateWebhookRequest
+	if *url != "" {
+		req.URL = url
+	}
+	if *events != "" {
+		list := []string{}
+		if *events != "all" {
+			list = splitEvents(*events)
+		}
+		req.Events = &list
+	}
+	if *active != "" {
+		b, err := strconv.ParseBool(*active)
+		if err != nil {
+			return usageErrorf("-active must be true or false")
+		}
+		req.Active = &b
+	}
+
+	webhook, err := e.client.UpdateWebhook(ctx, rest[0], req)
+	if err != nil {
+		return err
+	}
+	return printWebhooks(e, webhook, []client.Webhook{*webhook})
+}
+
+func runWebhooksDelete(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("no webhook IDs given")
+	}
+
+	failed := 0
+	for _, id := range args {
+		if err := e.client.DeleteWebhook(ctx, id); err != nil {
+			failed++
+			status("failed: %s: %v", id, err)
+			continue
+		}
+		status("deleted %s", id)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d webhooks failed", failed, len(args))
+	}
+	return nil
+}
+
+func runWebhooksDeliveries(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("webhooks deliveries", flag.ContinueOnError)
+	var opts client.DeliveryListOptions
+	fs.StringVar(&opts.State, "state", "", "filter by state (pending, delivered, failed)")
+	fs.IntVar(&opts.Limit, "limit", 50, "maximum number of deliveries")
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return usageErrorf("expected one webhook ID")
+	}
+
+	list, err := e.client.ListWebhookDeliveries(ctx, rest[0], opts)
+	if err != nil {
+		return err
+	}
+
+	return e.out.print(list.Deliveries, func(w io.Writer) {
+		row(w, "ID", "EVENT", "STATE", "ATTEMPTS", "LAST STATUS", "CREATED", "LAST ERROR")
+		for _, d := range list.Deliveries {
+			lastStatus := "-"
+			if d.LastStatusCode != nil {
+				lastStatus = strconv.Itoa(*d.LastStatusCode)
+			}
+			row(w, d.ID, d.Event, d.State, fmt.Sprintf("%d/%d", d.Attempts, d.MaxAttempts), lastStatus,
+				formatTime(d.CreatedAt), orDash(d.LastError))
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/ancill/mediapod/services/media-api/internal/api"
+	"github.com/ancill/mediapod/services/media-api/internal/config"
+	"github.com/ancill/mediapod/services/media-api/internal/db"
+)
+
+// runCommand runs an administrative command against the database instead of
+// serving the API
+func runCommand(cfg *config.Config, args []string) error {
+	switch args[0] {
+	case "create-key":
+		return createKey(cfg, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q (available: create-key)", args[0])
+	}
+}
+
+// createKey stores a new API key and prints it. It works while
+// API_KEYS_REQUIRED locks the key routes, so it makes the first key of a
+// deployment.
+func createKey(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("create-key", flag.ContinueOnError)
+	name := flags.String("name", "", "who or what uses the key")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" || len(*name) > 100 {
+		return errors.New("-name must be 1 to 100 characters")
+	}
+
+	database, err := db.New(cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+	if err := database.RunMigrations(); err != nil {
+		return err
+	}
+
+	key, err := api.NewAPIKey(context.Background(), database.Pool(), *name)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\t%s\n", key.ID, key.Key)
+	return nil
+}
@@ -12,10 +12,9 @@ import (
 	"github.com/ancill/mediapod/services/media-api/internal/api"
 	"github.com/ancill/mediapod/services/media-api/internal/config"
 	"github.com/ancill/mediapod/services/media-api/internal/db"
+	"github.com/ancill/mediapod/services/media-api/internal/imagecache"
+	"github.com/ancill/mediapod/services/media-api/internal/outbox"
 	"github.com/ancill/mediapod/services/media-api/internal/storage"
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
 	"github.com/go-redis/redis/v8"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -32,6 +31,14 @@ func main() {
 		log.Fatal().Err(err).Msg("Failed to load configuration")
 	}
 
+	// Administrative commands, such as create-key, run instead of the server
+	if len(os.Args) > 1 {
+		if err := runCommand(cfg, os.Args[1:]); err != nil {
+			log.Fatal().Err(err).Msg("Command failed")
+		}
+		return
+	}
+
 	log.Info().Msg("Starting Mediapod API")
 
 	// Initialize database
@@ -66,50 +73,27 @@ func main() {
 	log.Info().Msg("Connected to Redis")
 	defer redisClient.Close()
 
+	// Start outbox relay (forwards queued jobs to Redis)
+	relay := outbox.NewRelay(database.Pool(), redisClient)
+	relay.Start()
+
+	// Initialize the cache of transformed images
+	imageCache, err := imagecache.New(cfg.ImageCache, store, redisClient)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize image cache")
+	}
+	imageCache.Start()
+
 	// Initialize API handler
-	handler := api.NewHandler(cfg, database, store, redisClient)
+	handler := api.NewHandler(cfg, database, store, redisClient, imageCache)
 
 	// Setup router
-	r := chi.NewRouter()
-
-	// Middleware
-	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
-
-	// CORS
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"}, // Configure this properly in production
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
-		MaxAge:           300,
-	}))
-
-	// Health check
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
-
-	// API routes
-	r.Route("/v1", func(r chi.Router) {
-		// Media endpoints
-		r.Post("/media/init-upload", handler.InitUpload)
-		r.Post("/media/complete", handler.CompleteUpload)
-		r.Get("/media/{assetId}", handler.GetAsset)
-		r.Get("/media", handler.ListAssets)
-		r.Delete("/media/{assetId}", handler.DeleteAsset)
-
-		// Image transformation proxy
-		r.Get("/image/{signature}/{ops}/{encodedSrc}", handler.ProxyImage)
-
-		// Video endpoints
-		r.Get("/video/{assetId}/master.m3u8", handler.GetVideoManifest)
-	})
+	r := api.NewRouter(handler)
+
+	// Keep the OpenAPI document in step with the router
+	for _, problem := range api.CheckRoutes(r) {
+		log.Warn().Msg("OpenAPI: " + problem)
+	}
 
 	// Start server
 	srv := &http.Server{
@@ -142,5 +126,8 @@ func main() {
 		log.Fatal().Err(err).Msg("Server forced to shutdown")
 	}
 
+	relay.Stop()
+	imageCache.Stop()
+
 	log.Info().Msg("Server exited")
 }
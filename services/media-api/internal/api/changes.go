@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// Change operations (must match worker)
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeState  = "state"
+	ChangeDelete = "delete"
+)
+
+const (
+	defaultChangesLimit = 100
+	maxChangesLimit     = 1000
+)
+
+// ChangeResponse is one entry of the changefeed
+type ChangeResponse struct {
+	Cursor    string         `json:"cursor"`
+	Op        string         `json:"op"`
+	AssetID   string         `json:"assetId"`
+	Asset     *AssetResponse `json:"asset,omitempty"` // omitted for deletes (tombstones)
+	ChangedAt time.Time      `json:"changedAt"`
+}
+
+// ChangesResponse is a page of the changefeed
+type ChangesResponse struct {
+	Changes    []ChangeResponse `json:"changes"`
+	NextCursor string           `json:"nextCursor"`
+	HasMore    bool             `json:"hasMore"`
+}
+
+// recordChange appends an asset change to the changefeed. It must run in the
+// transaction that makes the change; the advisory lock serializes writers so
+// changes commit in cursor order and readers never skip a late commit.
+func (h *Handler) recordChange(ctx context.Context, q querier, op string, assetID uuid.UUID) error {
+	if _, err := q.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('asset_changes'))"); err != nil {
+		return fmt.Errorf("failed to lock changefeed: %w", err)
+	}
+
+	var snapshot []byte
+	if op != ChangeDelete {
+		asset, err := h.loadAssetWith(ctx, q, assetID)
+		if err != nil {
+			return fmt.Errorf("failed to load asset: %w", err)
+		}
+		// URLs are rebuilt when the change is read since signed ones expire
+		asset.URLs = nil
+		if snapshot, err = json.Marshal(asset); err != nil {
+			return fmt.Errorf("failed to marshal asset: %w", err)
+		}
+	}
+
+	_, err := q.Exec(ctx, "INSERT INTO asset_changes (asset_id, op, asset) VALUES ($1, $2, $3)", assetID, op, snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to record asset change: %w", err)
+	}
+	return nil
+}
+
+// ListChanges handles GET /v1/changes?since=<cursor>&limit=<n>
+// Returns asset changes after the cursor in the order they were made. Start
+// without a cursor to read the whole catalog, then poll with nextCursor.
+func (h *Handler) ListChanges(w http.ResponseWriter, r *http.Request) {
+	var since int64
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		since, err = strconv.ParseInt(s, 10, 64)
+		if err != nil || since < 0 {
+			respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid cursor")
+			return
+		}
+	}
+
+	limit := defaultChangesLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxChangesLimit {
+			respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Invalid limit. Must be between 1 and %d", maxChangesLimit))
+			return
+		}
+		limit = n
+	}
+
+	ctx := context.Background()
+
+	// Fetch one extra row to know whether another page follows
+	rows, err := h.db.Pool().Query(ctx, `
+		SELECT seq, op, asset_id, asset, created_at FROM asset_changes
+		WHERE seq > $1
+		ORDER BY seq
+		LIMIT $2
+	`, since, limit+1)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list asset changes")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to list changes")
+		return
+	}
+	defer rows.Close()
+
+	response := ChangesResponse{
+		Changes:    []ChangeResponse{},
+		NextCursor: strconv.FormatInt(since, 10),
+	}
+	for rows.Next() {
+		if len(response.Changes) == limit {
+			response.HasMore = true
+			break
+		}
+
+		var seq int64
+		var change ChangeResponse
+		var assetID uuid.UUID
+		var snapshot []byte
+		if err := rows.Scan(&seq, &change.Op, &assetID, &snapshot, &change.ChangedAt); err != nil {
+			log.Error().Err(err).Msg("Failed to scan asset change")
+			respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to list changes")
+			return
+		}
+		change.Cursor = strconv.FormatInt(seq, 10)
+		change.AssetID = assetID.String()
+
+		if snapshot != nil {
+			var asset AssetResponse
+			if err := json.Unmarshal(snapshot, &asset); err != nil {
+				log.Error().Err(err).Int64("seq", seq).Msg("Failed to decode asset change")
+				respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to list changes")
+				return
+			}
+			asset.URLs = h.buildAssetURLs(&asset)
+			change.Asset = &asset
+		}
+
+		response.Changes = append(response.Changes, change)
+		response.NextCursor = change.Cursor
+	}
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Msg("Failed to list asset changes")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to list changes")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
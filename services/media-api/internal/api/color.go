@@ -0,0 +1,67 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// defaultColorDistance is the delta-E within which ListAssets matches a
+// palette color; around 2 is barely noticeable, above 50 nearly anything goes
+const defaultColorDistance = 15
+
+// PaletteColor is a color of an image or video poster, computed by the
+// worker, with its share of the pixels
+type PaletteColor struct {
+	Color      string  `json:"color"`
+	Percentage float64 `json:"percentage"`
+}
+
+// colorCondition is the SQL condition matching assets with a palette color
+// within a CIE76 delta-E of a CIELAB color; $%[1]d to $%[4]d are L, a, b and
+// the distance
+const colorCondition = `EXISTS (
+	SELECT 1 FROM jsonb_array_elements(m.palette) AS p
+	WHERE sqrt(
+		power((p->'lab'->>0)::float8 - $%[1]d, 2) +
+		power((p->'lab'->>1)::float8 - $%[2]d, 2) +
+		power((p->'lab'->>2)::float8 - $%[3]d, 2)
+	) <= $%[4]d
+)`
+
+// parseHexColor parses rrggbb, with or without a leading #
+func parseHexColor(value string) ([3]float64, error) {
+	hex := strings.TrimPrefix(value, "#")
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return [3]float64{}, fmt.Errorf("invalid color %q", value)
+	}
+	return [3]float64{float64(v >> 16 & 0xff), float64(v >> 8 & 0xff), float64(v & 0xff)}, nil
+}
+
+// rgbToLab converts an sRGB color (0-255 channels) to CIELAB under D65, as
+// the worker does for palettes
+func rgbToLab(rgb [3]float64) [3]float64 {
+	linear := func(v float64) float64 {
+		v /= 255
+		if v <= 0.04045 {
+			return v / 12.92
+		}
+		return math.Pow((v+0.055)/1.055, 2.4)
+	}
+	r, g, b := linear(rgb[0]), linear(rgb[1]), linear(rgb[2])
+
+	x := (0.4124564*r + 0.3575761*g + 0.1804375*b) / 0.95047
+	y := 0.2126729*r + 0.7151522*g + 0.0721750*b
+	z := (0.0193339*r + 0.1191920*g + 0.9503041*b) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return [3]float64{116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)}
+}
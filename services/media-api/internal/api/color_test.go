@@ -0,0 +1,54 @@
+package api
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseHexColor(t *testing.T) {
+	tests := []struct {
+		value string
+		rgb   [3]float64
+		ok    bool
+	}{
+		{"ff8000", [3]float64{255, 128, 0}, true},
+		{"#0a0B0c", [3]float64{10, 11, 12}, true},
+		{"000000", [3]float64{0, 0, 0}, true},
+		{"fff", [3]float64{}, false},
+		{"#ff80001", [3]float64{}, false},
+		{"gg0000", [3]float64{}, false},
+		{"+fffff", [3]float64{}, false},
+		{"", [3]float64{}, false},
+	}
+	for _, tt := range tests {
+		rgb, err := parseHexColor(tt.value)
+		if (err == nil) != tt.ok || rgb != tt.rgb {
+			t.Errorf("parseHexColor(%q) = %v, %v; want %v, ok %v", tt.value, rgb, err, tt.rgb, tt.ok)
+		}
+	}
+}
+
+// Must agree with the worker, which stores the Lab coordinates of palettes.
+// Reference values from Bruce Lindbloom's color calculator (sRGB, D65).
+func TestRGBToLab(t *testing.T) {
+	tests := []struct {
+		rgb [3]float64
+		lab [3]float64
+	}{
+		{[3]float64{0, 0, 0}, [3]float64{0, 0, 0}},
+		{[3]float64{255, 255, 255}, [3]float64{100, 0, 0}},
+		{[3]float64{128, 128, 128}, [3]float64{53.585, 0, 0}},
+		{[3]float64{255, 0, 0}, [3]float64{53.2408, 80.0925, 67.2032}},
+		{[3]float64{0, 255, 0}, [3]float64{87.7347, -86.1827, 83.1793}},
+		{[3]float64{0, 0, 255}, [3]float64{32.2970, 79.1875, -107.8602}},
+	}
+	for _, tt := range tests {
+		lab := rgbToLab(tt.rgb)
+		for i := range lab {
+			if math.Abs(lab[i]-tt.lab[i]) > 0.02 {
+				t.Errorf("rgbToLab(%v) = %.4f, want %.4f", tt.rgb, lab, tt.lab)
+				break
+			}
+		}
+	}
+}
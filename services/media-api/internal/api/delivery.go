@@ -0,0 +1,58 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ancill/mediapod/services/media-api/internal/delivery"
+)
+
+// authorizeDelivery checks the signed delivery token of a private asset.
+// It returns the token expiry (zero for public assets), or false after
+// writing an error response when the token is missing, forged or expired.
+func (h *Handler) authorizeDelivery(w http.ResponseWriter, r *http.Request, assetID, visibility string) (int64, bool) {
+	if visibility != VisibilityPrivate {
+		return 0, true
+	}
+
+	expiresAt, err := h.deliverySigner.Verify(assetID, r.URL.Query(), time.Now())
+	if err != nil {
+		if errors.Is(err, delivery.ErrExpiredToken) {
+			respondError(w, r, http.StatusForbidden, CodeURLExpired, "Delivery URL has expired")
+		} else {
+			respondError(w, r, http.StatusForbidden, CodeInvalidSignature, "Invalid or missing delivery token")
+		}
+		return 0, false
+	}
+
+	return expiresAt, true
+}
+
+// deliveryCacheControl returns the Cache-Control header for a delivery response.
+// Responses for signed URLs must not outlive the token or land in shared caches.
+func deliveryCacheControl(expiresAt int64, publicMaxAge int) string {
+	if expiresAt == 0 {
+		return fmt.Sprintf("public, max-age=%d", publicMaxAge)
+	}
+
+	remaining := expiresAt - time.Now().Unix()
+	if remaining < 0 {
+		remaining = 0
+	}
+	return fmt.Sprintf("private, max-age=%d", remaining)
+}
+
+// deliveryTTL returns how long presigned storage URLs handed out for a
+// delivery response should remain valid
+func (h *Handler) deliveryTTL(expiresAt int64) time.Duration {
+	if expiresAt == 0 {
+		return h.cfg.Delivery.SignedURLTTL
+	}
+	ttl := time.Until(time.Unix(expiresAt, 0))
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	return ttl
+}
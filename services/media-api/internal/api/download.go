@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ancill/mediapod/services/media-api/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// GetOriginal handles GET /v1/media/:assetId/original
+// Images (except SVG), videos and audio are served inline so browsers can
+// display them; anything else is an attachment, as with DownloadOriginal
+func (h *Handler) GetOriginal(w http.ResponseWriter, r *http.Request) {
+	h.serveOriginal(w, r, "inline")
+}
+
+// DownloadOriginal handles GET /v1/media/:assetId/download
+// The original is served as an attachment under its uploaded filename
+func (h *Handler) DownloadOriginal(w http.ResponseWriter, r *http.Request) {
+	h.serveOriginal(w, r, "attachment")
+}
+
+// serveOriginal either redirects to a presigned storage URL or streams the
+// original with Range and conditional request support.
+// ?redirect=true|false overrides the configured default.
+// Uploads come from clients, so the response must not let one run script on
+// the API's origin: browsers may not sniff the type, and the page is
+// sandboxed should it render anyway.
+func (h *Handler) serveOriginal(w http.ResponseWriter, r *http.Request, disposition string) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+
+	assetIDStr := chi.URLParam(r, "assetId")
+	assetID, err := uuid.Parse(assetIDStr)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid asset ID")
+		return
+	}
+
+	ctx := context.Background()
+
+	asset, err := h.loadAsset(ctx, assetID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		respondError(w, r, http.StatusNotFound, CodeAssetNotFound, "Asset not found")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("assetId", assetIDStr).Msg("Failed to get asset")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to fetch original")
+		return
+	}
+
+	if asset.State == "uploading" {
+		respondError(w, r, http.StatusConflict, CodeAssetNotReady, "Upload has not been completed")
+		return
+	}
+
+	expiresAt, ok := h.authorizeDelivery(w, r, asset.ID, asset.Visibility)
+	if !ok {
+		return
+	}
+
+	if !servedInline(asset.MimeType) {
+		disposition = "attachment"
+	}
+	contentDisposition := formatContentDisposition(disposition, asset.Filename)
+
+	redirect := h.cfg.Delivery.OriginalsRedirect
+	if v := r.URL.Query().Get("redirect"); v != "" {
+		redirect, _ = strconv.ParseBool(v)
+	}
+
+	if redirect {
+		params := url.Values{}
+		params.Set("response-content-disposition", contentDisposition)
+		params.Set("response-content-type", asset.MimeType)
+
+		presignedURL, err := h.storage.PresignedGetURLWithParams(ctx, asset.Bucket, asset.ObjectKey, h.deliveryTTL(expiresAt), params)
+		if err != nil {
+			log.Error().Err(err).Str("assetId", asset.ID).Msg("Failed to generate presigned URL for original")
+			respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to fetch original")
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, presignedURL, http.StatusFound)
+		return
+	}
+
+	obj, err := h.storage.GetObject(r.Context(), asset.Bucket, asset.ObjectKey)
+	if err != nil {
+		log.Error().Err(err).Str("assetId", asset.ID).Msg("Failed to open original")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to fetch original")
+		return
+	}
+	defer obj.Close()
+
+	info, err := obj.Stat()
+	if storage.IsNotFound(err) {
+		log.Error().Err(err).Str("assetId", asset.ID).Msg("Original not found in storage")
+		respondError(w, r, http.StatusNotFound, CodeObjectNotFound, "Original not found")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("assetId", asset.ID).Msg("Failed to stat original")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to fetch original")
+		return
+	}
+
+	// Large originals take longer than the server's write timeout to stream
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Warn().Err(err).Msg("Failed to clear write deadline")
+	}
+
+	w.Header().Set("Content-Type", asset.MimeType)
+	w.Header().Set("Content-Disposition", contentDisposition)
+	w.Header().Set("Cache-Control", deliveryCacheControl(expiresAt, 86400))
+	if info.ETag != "" {
+		w.Header().Set("ETag", strconv.Quote(info.ETag))
+	}
+
+	// ServeContent handles Range, If-Range, If-None-Match and If-Modified-Since
+	http.ServeContent(w, r, asset.Filename, info.LastModified, obj)
+}
+
+// servedInline reports whether an original of a media type may be displayed
+// by browsers: images, videos and audio, but not SVG, which can carry script
+func servedInline(mimeType string) bool {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil || mediaType == "image/svg+xml" {
+		return false
+	}
+	kind, _, _ := strings.Cut(mediaType, "/")
+	return kind == "image" || kind == "video" || kind == "audio"
+}
+
+// formatContentDisposition builds a Content-Disposition header value,
+// falling back to RFC 2231 encoding for non-ASCII filenames
+func formatContentDisposition(disposition, filename string) string {
+	if value := mime.FormatMediaType(disposition, map[string]string{"filename": filename}); value != "" {
+		return value
+	}
+	return fmt.Sprintf("%s; filename*=utf-8''%s", disposition, url.PathEscape(filename))
+}
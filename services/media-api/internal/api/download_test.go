@@ -0,0 +1,122 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ancill/mediapod/services/media-api/internal/config"
+	"github.com/ancill/mediapod/services/media-api/internal/storage"
+	"github.com/google/uuid"
+)
+
+// fakeStorage is an S3 endpoint serving every object with body
+func fakeStorage(t *testing.T, body string) *storage.MinIO {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("location") {
+			w.Write([]byte(`<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">us-east-1</LocationConstraint>`))
+			return
+		}
+		w.Header().Set("ETag", `"0123456789abcdef"`)
+		w.Header().Set("Last-Modified", "Tue, 02 Jan 2024 03:04:05 GMT")
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	t.Cleanup(server.Close)
+
+	store, err := storage.NewMinIO(config.MinIOConfig{Endpoint: strings.TrimPrefix(server.URL, "http://"), AccessKey: "api", SecretKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// Originals are uploaded by clients and must not run script on the API's
+// origin: only media is displayed inline, and nothing is sniffed or allowed
+// to script
+func TestServeOriginalHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		route       string
+		mimeType    string
+		disposition string
+	}{
+		{"image", "original", "image/png", "inline"},
+		{"video", "original", "video/mp4", "inline"},
+		{"audio with parameters", "original", "audio/ogg; codecs=opus", "inline"},
+		{"SVG", "original", "image/svg+xml", "attachment"},
+		{"HTML", "original", "text/html", "attachment"},
+		{"PDF", "original", "application/pdf", "attachment"},
+		{"invalid type", "original", "image/", "attachment"},
+		{"download of an image", "download", "image/png", "attachment"},
+	}
+	// Assets by ID, registered before the database serves them
+	ids := make([]string, len(tests))
+	mimeTypes := map[string]string{}
+	for i, tt := range tests {
+		ids[i] = uuid.NewString()
+		mimeTypes[ids[i]] = tt.mimeType
+	}
+	database, _ := newFakeDB(t, func(sql string) fakeResult {
+		for id, mimeType := range mimeTypes {
+			if strings.Contains(sql, id) {
+				return assetResult(&AssetResponse{
+					ID: id, Kind: "image", State: "ready", Visibility: VisibilityPublic,
+					Filename: "file", MimeType: mimeType, Bucket: "media-originals", ObjectKey: "uploads/" + id,
+				})
+			}
+		}
+		return fakeResult{err: errFakeQuery}
+	})
+	router := NewRouter(&Handler{
+		cfg:     &config.Config{Delivery: config.DeliveryConfig{SignedURLTTL: time.Hour}},
+		db:      database,
+		storage: fakeStorage(t, "<svg onload=alert(1)>"),
+	})
+
+	for i, tt := range tests {
+		id := ids[i]
+
+		for _, redirect := range []bool{false, true} {
+			name := tt.name
+			if redirect {
+				name += " redirected"
+			}
+			t.Run(name, func(t *testing.T) {
+				target := "/v1/media/" + id + "/" + tt.route + "?redirect=false"
+				if redirect {
+					target = "/v1/media/" + id + "/" + tt.route + "?redirect=true"
+				}
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+
+				if got := rec.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+					t.Errorf("X-Content-Type-Options = %q, want nosniff", got)
+				}
+				if got := rec.Header().Get("Content-Security-Policy"); got != "sandbox" {
+					t.Errorf("Content-Security-Policy = %q, want sandbox", got)
+				}
+
+				disposition := rec.Header().Get("Content-Disposition")
+				if redirect {
+					if rec.Code != http.StatusFound {
+						t.Fatalf("status = %d, want 302: %s", rec.Code, rec.Body)
+					}
+					location, err := url.Parse(rec.Header().Get("Location"))
+					if err != nil {
+						t.Fatal(err)
+					}
+					disposition = location.Query().Get("response-content-disposition")
+				} else if rec.Code != http.StatusOK {
+					t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+				}
+				if want := tt.disposition + `; filename=file`; disposition != want {
+					t.Errorf("Content-Disposition = %q, want %q", disposition, want)
+				}
+			})
+		}
+	}
+}
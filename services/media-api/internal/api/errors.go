@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ancill/mediapod/services/media-api/internal/openapi"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Error codes of problem responses. Clients branch on these, so they are
+// part of the API: add new ones, but never rename or repurpose one.
+const (
+	// 400 and 413: the request itself is wrong
+	CodeInvalidRequest   = "invalid_request"
+	CodeValidationFailed = "validation_failed"
+	CodeRequestTooLarge  = "request_too_large"
+	// CodeOperationNotAllowed rejects image options outside the allowlist or
+	// beyond IMAGE_MAX_DIMENSION
+	CodeOperationNotAllowed = "operation_not_allowed"
+
+	// 401: the API key is missing, unknown or revoked
+	CodeUnauthorized = "unauthorized"
+
+	// 403: a signed URL was forged, has expired or points outside our buckets
+	CodeInvalidSignature = "invalid_signature"
+	CodeURLExpired       = "url_expired"
+	CodeSourceNotAllowed = "source_not_allowed"
+
+	// 404 and 405
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeAssetNotFound    = "asset_not_found"
+	CodeWebhookNotFound  = "webhook_not_found"
+	CodeAPIKeyNotFound   = "api_key_not_found"
+	CodeObjectNotFound   = "object_not_found"
+	CodePresetNotFound   = "preset_not_found"
+
+	// 409 and 422: the request conflicts with the state of a resource
+	CodeAssetNotReady         = "asset_not_ready"
+	CodeInvalidState          = "invalid_state"
+	CodeIdempotencyInProgress = "idempotency_key_in_progress"
+	CodeIdempotencyKeyReused  = "idempotency_key_reused"
+
+	// 5xx
+	CodeInternal = "internal_error"
+	CodeUpstream = "upstream_error"
+)
+
+// ProblemContentType is the media type of error responses (RFC 9457)
+const ProblemContentType = "application/problem+json"
+
+// Problem is the body of every error response
+type Problem struct {
+	Type   string `json:"type" doc:"Always about:blank; use code to tell problems apart"`
+	Title  string `json:"title" doc:"HTTP status text"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty" doc:"Human-readable explanation; may change between releases"`
+	// Instance is the request path
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code" doc:"Stable machine-readable error code"`
+	// RequestID matches the request in the server logs
+	RequestID string `json:"requestId,omitempty"`
+	// Fields lists the invalid values of a rejected request body
+	Fields []openapi.FieldError `json:"fields,omitempty"`
+}
+
+func respondError(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	respondProblem(w, r, Problem{Status: status, Code: code, Detail: detail})
+}
+
+// respondValidationError rejects a request body with field-level details
+func respondValidationError(w http.ResponseWriter, r *http.Request, fields []openapi.FieldError) {
+	respondProblem(w, r, Problem{
+		Status: http.StatusBadRequest,
+		Code:   CodeValidationFailed,
+		Detail: "Invalid request body",
+		Fields: fields,
+	})
+}
+
+func respondProblem(w http.ResponseWriter, r *http.Request, p Problem) {
+	p.Type = "about:blank"
+	p.Title = http.StatusText(p.Status)
+	p.Instance = r.URL.Path
+	p.RequestID = middleware.GetReqID(r.Context())
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// NotFound answers requests for unknown routes
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	respondError(w, r, http.StatusNotFound, CodeNotFound, "No route for "+r.URL.Path)
+}
+
+// MethodNotAllowed answers requests with a method the route does not support
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	respondError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, r.Method+" is not supported for "+r.URL.Path)
+}
@@ -0,0 +1,247 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ancill/mediapod/services/media-api/internal/events"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// eventHeartbeatInterval keeps idle connections open through proxies
+	eventHeartbeatInterval = 15 * time.Second
+	// wsWriteTimeout bounds a single WebSocket write
+	wsWriteTimeout = 10 * time.Second
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// The API allows any origin (see CORS setup); events carry no more than GetAsset
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// StreamAssetEvents handles GET /v1/media/:assetId/events
+// Streams state changes and processing progress as Server-Sent Events.
+// Clients resume with the Last-Event-ID header (or ?lastEventId=).
+func (h *Handler) StreamAssetEvents(w http.ResponseWriter, r *http.Request) {
+	asset, ok := h.eventAsset(w, r)
+	if !ok {
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.Warn().Err(err).Msg("Failed to clear write deadline")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+
+	send := func(ev events.Event) error {
+		data, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		if ev.ID != "" {
+			fmt.Fprintf(w, "id: %s\n", ev.ID)
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	heartbeat := func() error {
+		if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	if err := h.streamEvents(r.Context(), asset, lastID, send, heartbeat); err != nil {
+		log.Warn().Err(err).Str("asset_id", asset.ID).Msg("Event stream ended")
+	}
+}
+
+// StreamAssetEventsWS handles GET /v1/media/:assetId/events/ws
+// WebSocket alternative to StreamAssetEvents; each message is a JSON event.
+// Clients resume with ?lastEventId=.
+func (h *Handler) StreamAssetEventsWS(w http.ResponseWriter, r *http.Request) {
+	asset, ok := h.eventAsset(w, r)
+	if !ok {
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an error response
+		log.Warn().Err(err).Msg("WebSocket upgrade failed")
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// Clients only send control frames; stop streaming once the connection closes
+	conn.SetReadLimit(512)
+	go func() {
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	send := func(ev events.Event) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		return conn.WriteJSON(ev)
+	}
+
+	heartbeat := func() error {
+		return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+	}
+
+	if err := h.streamEvents(ctx, asset, r.URL.Query().Get("lastEventId"), send, heartbeat); err != nil {
+		log.Warn().Err(err).Str("asset_id", asset.ID).Msg("Event stream ended")
+	}
+}
+
+// eventAsset resolves and authorizes the asset of an event stream request
+func (h *Handler) eventAsset(w http.ResponseWriter, r *http.Request) (*AssetResponse, bool) {
+	assetID, err := uuid.Parse(chi.URLParam(r, "assetId"))
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid asset ID")
+		return nil, false
+	}
+
+	asset, err := h.loadAsset(context.Background(), assetID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		respondError(w, r, http.StatusNotFound, CodeAssetNotFound, "Asset not found")
+		return nil, false
+	}
+	if err != nil {
+		log.Error().Err(err).Str("assetId", assetID.String()).Msg("Failed to get asset")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to stream events")
+		return nil, false
+	}
+
+	if _, ok := h.authorizeDelivery(w, r, asset.ID, asset.Visibility); !ok {
+		return nil, false
+	}
+
+	return asset, true
+}
+
+// streamEvents sends an asset's events until ctx is done. Events recorded
+// after lastID are replayed first; without lastID the stream starts with a
+// snapshot of the current state.
+func (h *Handler) streamEvents(ctx context.Context, asset *AssetResponse, lastID string,
+	send func(events.Event) error, heartbeat func() error) error {
+	// Subscribe before replaying so nothing published in between is lost;
+	// duplicates are dropped by comparing stream IDs
+	sub := h.redis.Subscribe(ctx, events.Channel(asset.ID))
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+	messages := sub.Channel()
+
+	sent := ""
+	if lastID != "" && events.ValidID(lastID) {
+		replayed, err := events.Replay(ctx, h.redis, asset.ID, lastID)
+		if err != nil {
+			return fmt.Errorf("failed to replay events: %w", err)
+		}
+		sent = lastID
+		for _, ev := range replayed {
+			if err := send(ev); err != nil {
+				return err
+			}
+			sent = ev.ID
+		}
+	} else {
+		latest, err := events.Latest(ctx, h.redis, asset.ID)
+		if err != nil {
+			return fmt.Errorf("failed to read latest event: %w", err)
+		}
+
+		snapshot := events.Event{
+			AssetID: asset.ID,
+			Type:    events.TypeState,
+			State:   asset.State,
+			At:      time.Now().UTC(),
+		}
+		if latest != nil {
+			snapshot.ID = latest.ID
+			sent = latest.ID
+			if latest.Type == events.TypeProgress && asset.State == "processing" {
+				snapshot.Progress = latest.Progress
+			}
+		}
+		if err := send(snapshot); err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(eventHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := heartbeat(); err != nil {
+				return err
+			}
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			ev, err := events.Decode(msg.Payload)
+			if err != nil {
+				log.Warn().Err(err).Msg("Failed to decode asset event")
+				continue
+			}
+			if !events.After(ev.ID, sent) {
+				continue
+			}
+			if err := send(*ev); err != nil {
+				return err
+			}
+			sent = ev.ID
+		}
+	}
+}
+
+// publishState announces an asset state change to event stream subscribers
+func (h *Handler) publishState(ctx context.Context, assetID uuid.UUID, state string) {
+	err := events.Publish(ctx, h.redis, events.Event{
+		AssetID: assetID.String(),
+		Type:    events.TypeState,
+		State:   state,
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("asset_id", assetID.String()).Msg("Failed to publish asset event")
+	}
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ancill/mediapod/services/media-api/internal/db"
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+// fakeResult is the answer of the fake database to a statement: rows of
+// text values (nil for NULL) under columns, or an error
+type fakeResult struct {
+	columns []fakeColumn
+	rows    [][]any
+	tag     string
+	err     error
+}
+
+type fakeColumn struct {
+	name string
+	oid  uint32
+}
+
+// Type OIDs of the columns the fake database returns
+const (
+	oidBool        = 16
+	oidInt8        = 20
+	oidInt4        = 23
+	oidText        = 25
+	oidFloat8      = 701
+	oidTextArray   = 1009
+	oidTimestamptz = 1184
+	oidUUID        = 2950
+	oidJSONB       = 3802
+)
+
+// fakeDB is a PostgreSQL server speaking just enough of the protocol for
+// pgx in simple protocol mode, where arguments are part of the SQL text.
+// Statements are answered by handle, which must be safe for concurrent use;
+// the text of every statement is recorded.
+type fakeDB struct {
+	handle func(sql string) fakeResult
+
+	mu         sync.Mutex
+	statements []string
+}
+
+func newFakeDB(t *testing.T, handle func(sql string) fakeResult) (*db.DB, *fakeDB) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeDB{handle: handle}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go fake.serve(conn)
+		}
+	}()
+
+	database, err := db.New("postgres://api@" + listener.Addr().String() + "/mediapod?sslmode=disable&default_query_exec_mode=simple_protocol")
+	if err != nil {
+		listener.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		database.Close()
+		listener.Close()
+	})
+	return database, fake
+}
+
+// Statements returns the statements run so far, whitespace collapsed
+func (f *fakeDB) Statements() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.statements...)
+}
+
+func (f *fakeDB) serve(conn net.Conn) {
+	defer conn.Close()
+	backend := pgproto3.NewBackend(conn, conn)
+	if _, err := backend.ReceiveStartupMessage(); err != nil {
+		return
+	}
+	backend.Send(&pgproto3.AuthenticationOk{})
+	backend.Send(&pgproto3.ParameterStatus{Name: "client_encoding", Value: "UTF8"})
+	backend.Send(&pgproto3.ParameterStatus{Name: "standard_conforming_strings", Value: "on"})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if backend.Flush() != nil {
+		return
+	}
+
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return
+		}
+		query, ok := msg.(*pgproto3.Query)
+		if !ok {
+			// Terminate, or a message this fake does not speak
+			return
+		}
+
+		sql := strings.Join(strings.Fields(query.String), " ")
+		if sql == "" || strings.HasPrefix(sql, "--") {
+			backend.Send(&pgproto3.EmptyQueryResponse{})
+		} else {
+			f.mu.Lock()
+			f.statements = append(f.statements, sql)
+			f.mu.Unlock()
+			f.respond(backend, f.answer(sql))
+		}
+		backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+		if backend.Flush() != nil {
+			return
+		}
+	}
+}
+
+// answer runs handle, taking care of transaction control
+func (f *fakeDB) answer(sql string) fakeResult {
+	switch sql {
+	case "begin", "BEGIN":
+		return fakeResult{tag: "BEGIN"}
+	case "commit", "COMMIT":
+		return fakeResult{tag: "COMMIT"}
+	case "rollback", "ROLLBACK":
+		return fakeResult{tag: "ROLLBACK"}
+	}
+	return f.handle(sql)
+}
+
+func (f *fakeDB) respond(backend *pgproto3.Backend, result fakeResult) {
+	if result.err != nil {
+		backend.Send(&pgproto3.ErrorResponse{Severity: "ERROR", Code: "XX000", Message: result.err.Error()})
+		return
+	}
+	if result.columns != nil {
+		fields := make([]pgproto3.FieldDescription, len(result.columns))
+		for i, c := range result.columns {
+			fields[i] = pgproto3.FieldDescription{Name: []byte(c.name), DataTypeOID: c.oid, DataTypeSize: -1, TypeModifier: -1}
+		}
+		backend.Send(&pgproto3.RowDescription{Fields: fields})
+		for _, row := range result.rows {
+			values := make([][]byte, len(row))
+			for i, v := range row {
+				if v != nil {
+					values[i] = []byte(v.(string))
+				}
+			}
+			backend.Send(&pgproto3.DataRow{Values: values})
+		}
+	}
+	tag := result.tag
+	if tag == "" {
+		tag = fmt.Sprintf("SELECT %d", len(result.rows))
+	}
+	backend.Send(&pgproto3.CommandComplete{CommandTag: []byte(tag)})
+}
+
+// errFakeQuery answers statements a test does not expect
+var errFakeQuery = errors.New("unexpected statement")
+
+// assetResult is the row of an asset selected with assetColumns. Of the
+// metadata, only the size, focal point and crop hints are set.
+func assetResult(asset *AssetResponse) fakeResult {
+	columns := []fakeColumn{
+		{"id", oidUUID}, {"kind", oidText}, {"state", oidText}, {"visibility", oidText},
+		{"filename", oidText}, {"mime_type", oidText}, {"size_bytes", oidInt8}, {"bucket", oidText},
+		{"object_key", oidText}, {"created_at", oidTimestamptz},
+		{"width", oidInt4}, {"height", oidInt4}, {"duration_seconds", oidFloat8},
+		{"focal_x", oidFloat8}, {"focal_y", oidFloat8}, {"crop_hints", oidJSONB},
+		{"blurhash", oidText}, {"thumbhash", oidText}, {"dominant_color", oidText}, {"palette", oidJSONB},
+		{"exif", oidJSONB}, {"format", oidText}, {"color_space", oidText}, {"color_profile", oidText},
+		{"bit_depth", oidInt4}, {"has_alpha", oidBool}, {"frame_count", oidInt4},
+		{"array", oidTextArray},
+	}
+	row := make([]any, len(columns))
+	row[0], row[1], row[2], row[3] = asset.ID, asset.Kind, asset.State, asset.Visibility
+	row[4], row[5], row[6], row[7] = asset.Filename, asset.MimeType, "1024", asset.Bucket
+	row[8], row[9] = asset.ObjectKey, "2024-01-02 03:04:05+00"
+	if asset.Width != nil && asset.Height != nil {
+		row[10], row[11] = strconv.Itoa(*asset.Width), strconv.Itoa(*asset.Height)
+	}
+	if asset.FocalPoint != nil {
+		row[13] = strconv.FormatFloat(asset.FocalPoint.X, 'f', -1, 64)
+		row[14] = strconv.FormatFloat(asset.FocalPoint.Y, 'f', -1, 64)
+	}
+	if asset.CropHints != nil {
+		hints, _ := json.Marshal(asset.CropHints)
+		row[15] = string(hints)
+	}
+	row[27] = "{}"
+	return fakeResult{columns: columns, rows: [][]any{row}}
+}
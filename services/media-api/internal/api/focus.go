@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ancill/mediapod/services/media-api/internal/imgproxy"
+	"github.com/ancill/mediapod/services/media-api/internal/openapi"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// FocusRequest replaces the focal point and crop hints of an image; omitted
+// fields are cleared
+type FocusRequest struct {
+	FocalPoint *imgproxy.FocalPoint `json:"focalPoint,omitempty"`
+	// CropHints are regions to crop to for outputs of their aspect ratio
+	CropHints []imgproxy.CropHint `json:"cropHints,omitempty" maxItems:"20"`
+}
+
+// SetFocus handles PUT /v1/media/:assetId/focus
+// Stores where crops of an image should look. Presets, srcsets and signed
+// image URLs with a crop or fill resize then use a matching crop hint, or
+// gravity fp:x:y on the focal point.
+func (h *Handler) SetFocus(w http.ResponseWriter, r *http.Request) {
+	assetIDStr := chi.URLParam(r, "assetId")
+	assetID, err := uuid.Parse(assetIDStr)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid asset ID")
+		return
+	}
+
+	var req FocusRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var fieldErrs []openapi.FieldError
+	for i, hint := range req.CropHints {
+		if err := hint.Validate(); err != nil {
+			fieldErrs = append(fieldErrs, openapi.FieldError{Field: "cropHints[" + strconv.Itoa(i) + "]", Message: err.Error()})
+		}
+	}
+	if len(fieldErrs) > 0 {
+		respondValidationError(w, r, fieldErrs)
+		return
+	}
+
+	var focalX, focalY *float64
+	if req.FocalPoint != nil {
+		focalX, focalY = &req.FocalPoint.X, &req.FocalPoint.Y
+	}
+	var cropHints []byte
+	if len(req.CropHints) > 0 {
+		if cropHints, err = json.Marshal(req.CropHints); err != nil {
+			log.Error().Err(err).Msg("Failed to marshal crop hints")
+			respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update focus")
+			return
+		}
+	}
+
+	ctx := context.Background()
+	tx, err := h.db.Pool().Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to begin transaction")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update focus")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var kind string
+	err = tx.QueryRow(ctx, "SELECT kind FROM assets WHERE id = $1 FOR UPDATE", assetID).Scan(&kind)
+	if errors.Is(err, pgx.ErrNoRows) {
+		respondError(w, r, http.StatusNotFound, CodeAssetNotFound, "Asset not found")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("assetId", assetIDStr).Msg("Failed to get asset")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update focus")
+		return
+	}
+	if kind != "image" {
+		respondError(w, r, http.StatusConflict, CodeInvalidState, "Only images have a focus")
+		return
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO asset_meta (asset_id, focal_x, focal_y, crop_hints)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (asset_id) DO UPDATE SET
+			focal_x = EXCLUDED.focal_x,
+			focal_y = EXCLUDED.focal_y,
+			crop_hints = EXCLUDED.crop_hints
+	`, assetID, focalX, focalY, cropHints)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to update asset focus")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update focus")
+		return
+	}
+
+	if err := h.recordChange(ctx, tx, ChangeUpdate, assetID); err != nil {
+		log.Error().Err(err).Msg("Failed to record asset update")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update focus")
+		return
+	}
+
+	asset, err := h.loadAssetWith(ctx, tx, assetID)
+	if err != nil {
+		log.Error().Err(err).Str("assetId", assetIDStr).Msg("Failed to get asset")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update focus")
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to commit asset focus")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update focus")
+		return
+	}
+
+	asset.URLs = h.buildAssetURLs(asset)
+	respondJSON(w, http.StatusOK, asset)
+}
+
+// withFocus points the crops and fill resizes of operations at the focus of
+// an image asset. Crop hints need the dimensions of the image.
+func withFocus(asset *AssetResponse, ops imgproxy.Operations) imgproxy.Operations {
+	var width, height int
+	if asset.Width != nil && asset.Height != nil {
+		width, height = *asset.Width, *asset.Height
+	}
+	return ops.WithFocus(imgproxy.Focus{Point: asset.FocalPoint, Hints: asset.CropHints}, width, height)
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ancill/mediapod/services/media-api/internal/config"
+	"github.com/ancill/mediapod/services/media-api/internal/imagecache"
+	"github.com/ancill/mediapod/services/media-api/internal/imgproxy"
+	"github.com/google/uuid"
+)
+
+// Crop hints become crops in pixels of the original, which may be larger
+// than IMAGE_MAX_DIMENSION; every URL the API hands out for them must still
+// be served
+func TestFocusCropsOfLargeImages(t *testing.T) {
+	var mu sync.Mutex
+	var upstream []string
+	fake := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		upstream = append(upstream, r.URL.Path)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write([]byte("jpeg"))
+	}))
+	defer fake.Close()
+
+	width, height := 8000, 6000
+	asset := &AssetResponse{
+		ID: uuid.NewString(), Kind: "image", State: "ready", Visibility: VisibilityPublic,
+		Filename: "panorama.jpg", MimeType: "image/jpeg", Bucket: "media-originals", ObjectKey: "2024/01/02/panorama.jpg",
+		Width: &width, Height: &height,
+		// 6400×3600 pixels, for 16:9 outputs
+		CropHints: []imgproxy.CropHint{{X: 0.1, Y: 0.2, Width: 0.8, Height: 0.6}},
+	}
+	database, _ := newFakeDB(t, func(sql string) fakeResult {
+		if strings.Contains(sql, "FROM assets a") && strings.Contains(sql, asset.ID) {
+			return assetResult(asset)
+		}
+		return fakeResult{err: errFakeQuery}
+	})
+
+	signer, err := imgproxy.NewSigner(testImgproxyKey, testImgproxySalt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const apiURL, imgproxyURL = "https://media.example.com", "https://img.example.com"
+	router := NewRouter(&Handler{
+		cfg: &config.Config{
+			PublicAPIURL:      apiURL,
+			PublicImgProxyURL: imgproxyURL,
+			ImgProxy: config.ImgProxyConfig{
+				BaseURL:       fake.URL,
+				MaxDimension:  4096,
+				SrcsetWidths:  []int{640, 1920},
+				SrcsetFormats: []string{"webp", "jpg"},
+				Presets: map[string]imgproxy.Operations{
+					"hero": {Resize: &imgproxy.ResizeOp{Type: "fill", Width: 1600, Height: 900}, Format: imgproxy.FormatAuto},
+				},
+			},
+		},
+		db:             database,
+		imgproxySigner: signer,
+		imgproxyPolicy: imgproxy.NewPolicy(imgproxy.DefaultAllowedOptions, 4096, []string{"media-originals"}),
+		imageCache:     &imagecache.Cache{},
+	})
+
+	serve := func(t *testing.T, method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Accept", "image/avif,image/webp,*/*")
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s %s: status %d: %s", method, target, rec.Code, rec.Body)
+		}
+		return rec
+	}
+	// fetch requests a signed image URL through the API's proxy and checks
+	// that imgproxy got the hint's crop
+	fetch := func(t *testing.T, url, prefix string) {
+		t.Helper()
+		path, ok := strings.CutPrefix(url, prefix)
+		if !ok {
+			t.Fatalf("URL %s outside %s", url, prefix)
+		}
+		mu.Lock()
+		upstream = nil
+		mu.Unlock()
+		serve(t, http.MethodGet, "/v1/image"+path, "")
+		mu.Lock()
+		defer mu.Unlock()
+		if len(upstream) != 1 || !strings.Contains(upstream[0], ":6400:3600:fp:0.5:0.5/") {
+			t.Errorf("imgproxy requests for %s = %v, want the hint's crop", url, upstream)
+		}
+	}
+
+	t.Run("srcset", func(t *testing.T) {
+		var srcset SrcsetResponse
+		json.Unmarshal(serve(t, http.MethodGet, "/v1/media/"+asset.ID+"/srcset?aspect=16:9", "").Body.Bytes(), &srcset)
+		if len(srcset.Candidates) != 4 {
+			t.Fatalf("candidates = %+v, want 2 widths × 2 formats", srcset.Candidates)
+		}
+		for _, candidate := range srcset.Candidates {
+			fetch(t, candidate.URL, imgproxyURL)
+		}
+	})
+
+	t.Run("image URL with format auto", func(t *testing.T) {
+		body := `{"operations": {"resize": {"type": "fill", "width": 1600, "height": 900}, "format": "auto"}}`
+		var image ImageURLResponse
+		json.Unmarshal(serve(t, http.MethodPost, "/v1/media/"+asset.ID+"/image-url", body).Body.Bytes(), &image)
+		if image.URL != image.ProxyURL {
+			t.Errorf("url = %s, want the proxy URL %s for f:auto", image.URL, image.ProxyURL)
+		}
+		fetch(t, image.ProxyURL, apiURL+"/v1/image")
+	})
+
+	t.Run("preset", func(t *testing.T) {
+		mu.Lock()
+		upstream = nil
+		mu.Unlock()
+		serve(t, http.MethodGet, "/v1/media/"+asset.ID+"/image/hero?redirect=false", "")
+		mu.Lock()
+		defer mu.Unlock()
+		if len(upstream) != 1 || !strings.Contains(upstream[0], ":6400:3600:fp:0.5:0.5/") || !strings.Contains(upstream[0], ":avif/") {
+			t.Errorf("imgproxy requests = %v, want the hint's crop as AVIF", upstream)
+		}
+	})
+
+	t.Run("explicit crop larger than the image", func(t *testing.T) {
+		body := `{"operations": {"crop": {"width": 8001, "height": 100}}}`
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/media/"+asset.ID+"/image-url", strings.NewReader(body)))
+		var problem Problem
+		json.Unmarshal(rec.Body.Bytes(), &problem)
+		if rec.Code != http.StatusBadRequest || len(problem.Fields) != 1 || problem.Fields[0].Field != "operations.crop.width" {
+			t.Errorf("status %d: %s; want a validation error on operations.crop.width", rec.Code, rec.Body)
+		}
+	})
+}
@@ -3,7 +3,10 @@ package api
 import (
 	"github.com/ancill/mediapod/services/media-api/internal/config"
 	"github.com/ancill/mediapod/services/media-api/internal/db"
+	"github.com/ancill/mediapod/services/media-api/internal/delivery"
+	"github.com/ancill/mediapod/services/media-api/internal/imagecache"
 	"github.com/ancill/mediapod/services/media-api/internal/imgproxy"
+	"github.com/ancill/mediapod/services/media-api/internal/render"
 	"github.com/ancill/mediapod/services/media-api/internal/storage"
 	"github.com/go-redis/redis/v8"
 )
@@ -14,19 +17,43 @@ type Handler struct {
 	storage        *storage.MinIO
 	redis          *redis.Client
 	imgproxySigner *imgproxy.Signer
+	imgproxyPolicy *imgproxy.Policy
+	deliverySigner *delivery.Signer
+	imageCache     *imagecache.Cache
+	renderer       *render.Renderer // nil when imgproxy renders
+	openapi        []byte
 }
 
-func NewHandler(cfg *config.Config, database *db.DB, store *storage.MinIO, redisClient *redis.Client) *Handler {
+func NewHandler(cfg *config.Config, database *db.DB, store *storage.MinIO, redisClient *redis.Client, imageCache *imagecache.Cache) *Handler {
 	signer, err := imgproxy.NewSigner(cfg.ImgProxy.Key, cfg.ImgProxy.Salt)
 	if err != nil {
 		panic(err) // Should have been validated in config
 	}
 
-	return &Handler{
+	deliverySigner, err := delivery.NewSigner(cfg.Delivery.TokenSecret)
+	if err != nil {
+		panic(err) // Derived from the imgproxy key when unset, which config requires
+	}
+
+	h := &Handler{
 		cfg:            cfg,
 		db:             database,
 		storage:        store,
 		redis:          redisClient,
 		imgproxySigner: signer,
+		imgproxyPolicy: imgproxy.NewPolicy(cfg.ImgProxy.AllowedOptions, cfg.ImgProxy.MaxDimension, cfg.ImgProxy.AllowedBuckets),
+		deliverySigner: deliverySigner,
+		imageCache:     imageCache,
+	}
+
+	if cfg.ImgProxy.Renderer == config.RendererBuiltin {
+		h.renderer = render.New(store)
 	}
+
+	h.openapi, err = h.buildOpenAPI()
+	if err != nil {
+		panic(err) // Generated from static types
+	}
+
+	return h
 }
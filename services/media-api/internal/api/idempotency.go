@@ -0,0 +1,190 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// IdempotencyHeader carries the client-chosen key of a mutating request
+	IdempotencyHeader = "Idempotency-Key"
+	// IdempotencyReplayedHeader marks responses replayed from an earlier request
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+
+	idempotencyKeyPrefix = "media:idempotency:"
+	maxIdempotencyKeyLen = 255
+	// idempotencyLockTTL bounds how long an interrupted request blocks its key
+	idempotencyLockTTL = 2 * time.Minute
+)
+
+// idempotencyRecord is stored in Redis for each key: first as a lock while
+// the request runs, then with the response to replay
+type idempotencyRecord struct {
+	Fingerprint string            `json:"fingerprint"`
+	Done        bool              `json:"done"`
+	Status      int               `json:"status,omitempty"`
+	Header      map[string]string `json:"header,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+}
+
+// replayedHeaders are the response headers stored with a record
+var replayedHeaders = []string{"Content-Type", "Location"}
+
+// Idempotency makes POST, PATCH and DELETE requests carrying an
+// Idempotency-Key header safe to retry. The first response for a key (other
+// than a server error) is stored for IDEMPOTENCY_TTL and replayed for later
+// requests with the same key and body; reusing a key with a different body
+// is rejected with 422, and a retry that arrives while the first request is
+// still running gets 409.
+func (h *Handler) Idempotency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyHeader)
+		if key == "" || (r.Method != http.MethodPost && r.Method != http.MethodPatch && r.Method != http.MethodDelete) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+		if err != nil {
+			respondError(w, r, http.StatusRequestEntityTooLarge, CodeRequestTooLarge, "Request body too large")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Keys are scoped to the route so the same key can be used per resource
+		ctx := context.Background()
+		redisKey := idempotencyKeyPrefix + hashParts(r.Method, r.URL.Path, key)
+		fingerprint := hashParts(r.Method, r.URL.Path, string(body))
+
+		lock, _ := json.Marshal(idempotencyRecord{Fingerprint: fingerprint})
+		acquired, err := h.redis.SetNX(ctx, redisKey, lock, idempotencyLockTTL).Result()
+		if err != nil {
+			// Without Redis the request runs without protection rather than failing
+			log.Error().Err(err).Msg("Failed to acquire idempotency key")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !acquired {
+			h.replayIdempotent(ctx, w, r, redisKey, fingerprint)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		if rec.status >= 500 {
+			// Let the client retry a failed request with the same key
+			h.redis.Del(ctx, redisKey)
+			return
+		}
+
+		record := idempotencyRecord{
+			Fingerprint: fingerprint,
+			Done:        true,
+			Status:      rec.status,
+			Header:      make(map[string]string),
+			Body:        rec.body.Bytes(),
+		}
+		for _, name := range replayedHeaders {
+			if v := w.Header().Get(name); v != "" {
+				record.Header[name] = v
+			}
+		}
+		data, err := json.Marshal(record)
+		if err == nil {
+			err = h.redis.Set(ctx, redisKey, data, h.cfg.IdempotencyTTL).Err()
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to store idempotent response")
+			h.redis.Del(ctx, redisKey)
+		}
+	})
+}
+
+// replayIdempotent answers a request whose key has been seen before
+func (h *Handler) replayIdempotent(ctx context.Context, w http.ResponseWriter, r *http.Request, redisKey, fingerprint string) {
+	data, err := h.redis.Get(ctx, redisKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// The first request failed and released the key in the meantime
+		w.Header().Set("Retry-After", "1")
+		respondError(w, r, http.StatusConflict, CodeIdempotencyInProgress, "A request with this Idempotency-Key is in progress")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read idempotency key")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to check Idempotency-Key")
+		return
+	}
+
+	var record idempotencyRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		log.Error().Err(err).Msg("Failed to decode idempotency record")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to check Idempotency-Key")
+		return
+	}
+
+	if record.Fingerprint != fingerprint {
+		respondError(w, r, http.StatusUnprocessableEntity, CodeIdempotencyKeyReused, "Idempotency-Key was already used with a different request")
+		return
+	}
+
+	if !record.Done {
+		w.Header().Set("Retry-After", "1")
+		respondError(w, r, http.StatusConflict, CodeIdempotencyInProgress, "A request with this Idempotency-Key is in progress")
+		return
+	}
+
+	for name, value := range record.Header {
+		w.Header().Set(name, value)
+	}
+	w.Header().Set(IdempotencyReplayedHeader, "true")
+	w.WriteHeader(record.Status)
+	w.Write(record.Body)
+}
+
+// responseRecorder passes a response through while keeping a copy
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// hashParts returns the hex SHA-256 of parts, separated so they cannot run together
+func hashParts(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -1,34 +1,318 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ancill/mediapod/services/media-api/internal/imagecache"
+	"github.com/ancill/mediapod/services/media-api/internal/imgproxy"
+	"github.com/ancill/mediapod/services/media-api/internal/openapi"
+	"github.com/ancill/mediapod/services/media-api/internal/render"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog/log"
 )
 
-// ProxyImage handles GET /v1/image/:signature/:ops/:encodedSrc
-// This endpoint acts as a reverse proxy to imgproxy with signed URLs
+// ImageURLRequest asks for a signed URL of a custom image transformation
+type ImageURLRequest struct {
+	Operations imgproxy.Operations `json:"operations"`
+	// ExpiresIn makes the URL expire after this many seconds (0: never, for public assets)
+	ExpiresIn int `json:"expiresIn,omitempty" minimum:"0" maximum:"31536000"`
+}
+
+// ImageURLResponse holds the signed URLs of an image transformation
+type ImageURLResponse struct {
+	URL string `json:"url" doc:"imgproxy URL"`
+	// ProxyURL serves the same image through the API
+	ProxyURL   string     `json:"proxyUrl"`
+	Operations string     `json:"operations" doc:"imgproxy processing options"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+}
+
+// ProxyImage handles GET /v1/image/:signature/*
+// The wildcard holds the imgproxy option chain followed by the encoded source.
+// The signature is verified here, and the options and source are checked
+// against IMAGE_ALLOWED_OPERATIONS, IMAGE_MAX_DIMENSION and
+// IMAGE_ALLOWED_BUCKETS before the request is proxied to imgproxy. Paths
+// without a watermark get the IMAGE_WATERMARK policy.
 func (h *Handler) ProxyImage(w http.ResponseWriter, r *http.Request) {
 	signature := chi.URLParam(r, "signature")
-	ops := chi.URLParam(r, "ops")
-	encodedSrc := chi.URLParam(r, "encodedSrc")
+	path := chi.URLParam(r, "*")
+
+	if signature == "" || !strings.Contains(path, "/") {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid image URL")
+		return
+	}
+
+	if !h.imgproxySigner.Verify(signature, "/"+path) {
+		respondError(w, r, http.StatusForbidden, CodeInvalidSignature, "Invalid image URL signature")
+		return
+	}
+
+	parsed, err := imgproxy.ParsePath(path)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid image URL")
+		return
+	}
+
+	// The signature covers exp, so it can be trusted
+	expiresAt := parsed.Expiry()
+	if expiresAt > 0 && time.Now().Unix() >= expiresAt {
+		respondError(w, r, http.StatusForbidden, CodeURLExpired, "Image URL has expired")
+		return
+	}
+
+	if err := h.imgproxyPolicy.Check(parsed); err != nil {
+		switch {
+		case errors.Is(err, imgproxy.ErrSourceNotAllowed):
+			respondError(w, r, http.StatusForbidden, CodeSourceNotAllowed, "Image source is not allowed")
+		case errors.Is(err, imgproxy.ErrOptionNotAllowed), errors.Is(err, imgproxy.ErrOutputTooLarge):
+			respondError(w, r, http.StatusBadRequest, CodeOperationNotAllowed, "Rejected image URL: "+err.Error())
+		default:
+			respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Rejected image URL: "+err.Error())
+		}
+		return
+	}
+
+	cacheControl := "public, max-age=31536000, immutable"
+	if expiresAt > 0 {
+		cacheControl = deliveryCacheControl(expiresAt, 0)
+	}
+
+	h.proxyImgproxy(w, r, signature+"/"+path, cacheControl)
+}
+
+// GetImagePreset handles GET /v1/media/:assetId/image/:preset
+// Renders an image asset with a named preset from configuration, so clients
+// need no imgproxy option syntax. Crops and fill resizes follow the focus of
+// the image. The response redirects to imgproxy, or proxies the image when
+// IMAGE_PRESETS_REDIRECT=false (?redirect= overrides).
+func (h *Handler) GetImagePreset(w http.ResponseWriter, r *http.Request) {
+	assetID, err := uuid.Parse(chi.URLParam(r, "assetId"))
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid asset ID")
+		return
+	}
+
+	preset := chi.URLParam(r, "preset")
+	ops, ok := h.cfg.ImgProxy.Presets[preset]
+	if !ok {
+		respondError(w, r, http.StatusNotFound, CodePresetNotFound, "Unknown image preset "+preset)
+		return
+	}
+
+	asset, ok := h.readyImage(w, r, assetID)
+	if !ok {
+		return
+	}
+
+	expiresAt, ok := h.authorizeDelivery(w, r, asset.ID, asset.Visibility)
+	if !ok {
+		return
+	}
+
+	ops = withFocus(asset, ops)
+
+	// Resolve f:auto here, since a redirect goes straight to imgproxy
+	if ops.Format == imgproxy.FormatAuto {
+		ops.Format = h.negotiateFormat(r, asset.ObjectKey)
+		if h.renderer == nil {
+			w.Header().Add("Vary", "Accept")
+		}
+	}
+
+	if h.cfg.ImgProxy.ClientHints {
+		w.Header().Set("Accept-CH", imgproxy.HintHeaders)
+		w.Header().Add("Vary", imgproxy.HintHeaders)
+		ops = ops.WithHints(imgproxy.ParseHints(r.Header), imgproxy.HintBounds{
+			MaxDPR:       h.cfg.ImgProxy.MaxDPR,
+			MaxDimension: h.cfg.ImgProxy.MaxDimension,
+			Widths:       h.cfg.ImgProxy.SrcsetWidths,
+		})
+	}
+
+	signedPath := h.signedImagePath(asset, ops.String(), expiresAt)
+
+	// Presets can be redefined, so their output is not immutable
+	cacheControl := deliveryCacheControl(expiresAt, 86400)
+
+	redirect := h.cfg.ImgProxy.PresetsRedirect
+	if v := r.URL.Query().Get("redirect"); v != "" {
+		redirect, _ = strconv.ParseBool(v)
+	}
+	if redirect {
+		w.Header().Set("Cache-Control", cacheControl)
+		http.Redirect(w, r, h.cfg.PublicImgProxyURL+signedPath, http.StatusFound)
+		return
+	}
+
+	h.proxyImgproxy(w, r, strings.TrimPrefix(signedPath, "/"), cacheControl)
+}
+
+// CreateImageURL handles POST /v1/media/:assetId/image-url
+// Signs a custom transformation of an image asset. URLs of private assets
+// always expire, after SIGNED_URL_TTL at most. The operations may pick a
+// watermark policy, or none, instead of IMAGE_WATERMARK. Crops and fill
+// resizes follow the focus of the image.
+func (h *Handler) CreateImageURL(w http.ResponseWriter, r *http.Request) {
+	assetID, err := uuid.Parse(chi.URLParam(r, "assetId"))
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid asset ID")
+		return
+	}
+
+	var req ImageURLRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var opErr *imgproxy.InvalidOperationError
+	err = req.Operations.ResolveWatermark(h.cfg.ImgProxy.Watermarks)
+	if err == nil {
+		err = req.Operations.Validate(h.cfg.ImgProxy.MaxDimension)
+	}
+	if errors.As(err, &opErr) {
+		field := "operations"
+		if opErr.Field != "" {
+			field += "." + opErr.Field
+		}
+		respondValidationError(w, r, []openapi.FieldError{{Field: field, Message: opErr.Message}})
+		return
+	}
+
+	asset, ok := h.readyImage(w, r, assetID)
+	if !ok {
+		return
+	}
+
+	var width, height int
+	if asset.Width != nil && asset.Height != nil {
+		width, height = *asset.Width, *asset.Height
+	}
+	if err := req.Operations.ValidateCrop(width, height); errors.As(err, &opErr) {
+		respondValidationError(w, r, []openapi.FieldError{{Field: "operations." + opErr.Field, Message: opErr.Message}})
+		return
+	}
+
+	ttl := time.Duration(req.ExpiresIn) * time.Second
+	if asset.Visibility == VisibilityPrivate && (ttl == 0 || ttl > h.cfg.Delivery.SignedURLTTL) {
+		ttl = h.cfg.Delivery.SignedURLTTL
+	}
+
+	req.Operations = withFocus(asset, req.Operations)
+	if req.Operations.Watermark == nil {
+		req.Operations.Watermark = h.cfg.ImgProxy.Watermark
+	}
+	operations := req.Operations.String()
+	response := ImageURLResponse{Operations: operations}
+
+	var expiresAt int64
+	if ttl > 0 {
+		expiry := time.Now().Add(ttl).UTC().Truncate(time.Second)
+		expiresAt = expiry.Unix()
+		response.ExpiresAt = &expiry
+	}
+
+	signedPath := h.signedImagePath(asset, operations, expiresAt)
+	response.URL = h.cfg.PublicImgProxyURL + signedPath
+	response.ProxyURL = h.cfg.PublicAPIURL + "/v1/image" + signedPath
+
+	// imgproxy does not know f:auto; only the API resolves it
+	if req.Operations.Format == imgproxy.FormatAuto {
+		response.URL = response.ProxyURL
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
 
-	if signature == "" || ops == "" || encodedSrc == "" {
-		respondError(w, http.StatusBadRequest, "Invalid image URL")
+// readyImage loads an image asset that is ready for delivery, writing an
+// error response when the asset is missing, not an image or not ready
+func (h *Handler) readyImage(w http.ResponseWriter, r *http.Request, assetID uuid.UUID) (*AssetResponse, bool) {
+	asset, err := h.loadAsset(context.Background(), assetID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		respondError(w, r, http.StatusNotFound, CodeAssetNotFound, "Asset not found")
+		return nil, false
+	}
+	if err != nil {
+		log.Error().Err(err).Str("assetId", assetID.String()).Msg("Failed to get asset")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to load image")
+		return nil, false
+	}
+
+	if asset.Kind != "image" {
+		respondError(w, r, http.StatusNotFound, CodeAssetNotFound, "Asset is not an image")
+		return nil, false
+	}
+	switch asset.State {
+	case "ready":
+	case "failed":
+		respondError(w, r, http.StatusConflict, CodeInvalidState, "Image processing failed")
+		return nil, false
+	default:
+		respondError(w, r, http.StatusConflict, CodeAssetNotReady, "Image is still "+asset.State)
+		return nil, false
+	}
+
+	return asset, true
+}
+
+// signedImagePath signs imgproxy operations for an asset's original, expiring
+// when expiresAt is set. The result is relative to the imgproxy base URL.
+// Operations without a watermark get the IMAGE_WATERMARK policy, so every
+// URL the API hands out carries it under the signature.
+func (h *Handler) signedImagePath(asset *AssetResponse, operations string, expiresAt int64) string {
+	if wm := h.cfg.ImgProxy.Watermark; wm != nil && !imgproxy.HasOption(operations, "watermark") {
+		if operations != "" {
+			operations += "/"
+		}
+		operations += wm.String()
+	}
+
+	sourceURL := fmt.Sprintf("s3://%s/%s", asset.Bucket, asset.ObjectKey)
+	if expiresAt > 0 {
+		return h.imgproxySigner.SignURLWithExpiry(operations, sourceURL, expiresAt)
+	}
+	return h.imgproxySigner.SignURL(operations, sourceURL)
+}
+
+// proxyImgproxy streams the imgproxy response for a signed path
+// ("{signature}/{options}/{source}") to the client, or renders the image
+// itself with IMAGE_RENDERER=builtin. Images are served from the image cache
+// when possible and written to it otherwise; X-Cache tells which happened.
+func (h *Handler) proxyImgproxy(w http.ResponseWriter, r *http.Request, signedPath, cacheControl string) {
+	signedPath, parsed, varyAccept := h.prepareImagePath(r, signedPath)
+	cacheKey := h.imageCacheKey(r, parsed, varyAccept)
+
+	if cacheKey != "" {
+		if entry, tier, ok := h.imageCache.Get(r.Context(), cacheKey); ok {
+			w.Header().Set("X-Cache-Tier", tier)
+			writeImage(w, entry, cacheControl, varyAccept, "HIT")
+			return
+		}
+	}
+
+	if h.renderer != nil {
+		h.renderImage(w, r, parsed, cacheKey, cacheControl, varyAccept)
 		return
 	}
 
 	// Construct imgproxy URL
-	imgproxyURL := fmt.Sprintf("%s/%s/%s/%s", h.cfg.ImgProxy.BaseURL, signature, ops, encodedSrc)
+	imgproxyURL := fmt.Sprintf("%s/%s", h.cfg.ImgProxy.BaseURL, signedPath)
 
 	// Create request to imgproxy
 	req, err := http.NewRequestWithContext(r.Context(), "GET", imgproxyURL, nil)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create imgproxy request")
-		respondError(w, http.StatusInternalServerError, "Failed to process image")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to process image")
 		return
 	}
 
@@ -42,27 +326,226 @@ func (h *Handler) ProxyImage(w http.ResponseWriter, r *http.Request) {
 	resp, err := client.Do(req)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to fetch from imgproxy")
-		respondError(w, http.StatusBadGateway, "Failed to fetch image")
+		respondError(w, r, http.StatusBadGateway, CodeUpstream, "Failed to fetch image")
 		return
 	}
 	defer resp.Body.Close()
 
-	// Copy response headers
+	if resp.StatusCode >= 400 {
+		respondImgProxyError(w, r, resp)
+		return
+	}
+
+	// Copy response headers; Vary is ours to set
 	for key, values := range resp.Header {
+		if key == "Vary" {
+			continue
+		}
 		for _, value := range values {
 			w.Header().Add(key, value)
 		}
 	}
-
-	// Add caching headers
-	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("Cache-Control", cacheControl)
+	if varyAccept {
+		w.Header().Add("Vary", "Accept")
+	}
+	if cacheKey != "" {
+		w.Header().Set("X-Cache", "MISS")
+	} else {
+		w.Header().Set("X-Cache", "BYPASS")
+	}
 
 	// Copy status code
 	w.WriteHeader(resp.StatusCode)
 
-	// Stream response body
-	_, err = io.Copy(w, resp.Body)
+	// Stream response body, keeping a copy for the cache
+	var body io.Writer = w
+	var capture *captureWriter
+	if cacheKey != "" && resp.StatusCode == http.StatusOK {
+		capture = &captureWriter{limit: h.imageCache.MaxObjectSize()}
+		body = io.MultiWriter(w, capture)
+	}
+	_, err = io.Copy(body, resp.Body)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to stream image response")
+		return
+	}
+
+	if capture != nil && !capture.overflow {
+		h.imageCache.Put(cacheKey, &imagecache.Entry{
+			ContentType: resp.Header.Get("Content-Type"),
+			Data:        capture.buf.Bytes(),
+		})
+	}
+}
+
+// renderImage serves an image from the built-in renderer
+func (h *Handler) renderImage(w http.ResponseWriter, r *http.Request, parsed *imgproxy.Path, cacheKey, cacheControl string, varyAccept bool) {
+	if parsed == nil {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid image URL")
+		return
+	}
+
+	data, contentType, err := h.renderer.Render(r.Context(), parsed)
+	switch {
+	case errors.Is(err, render.ErrSourceNotFound):
+		respondError(w, r, http.StatusNotFound, CodeObjectNotFound, "Source image not found")
+		return
+	case errors.Is(err, render.ErrUnsupported), errors.Is(err, render.ErrInvalidOptions),
+		errors.Is(err, render.ErrSourceTooLarge), errors.Is(err, render.ErrUnsupportedImage):
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Cannot render image: "+err.Error())
+		return
+	case err != nil:
+		log.Error().Err(err).Msg("Failed to render image")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to process image")
+		return
+	}
+
+	entry := &imagecache.Entry{ContentType: contentType, Data: data}
+	if cacheKey != "" {
+		h.imageCache.Put(cacheKey, entry)
+		writeImage(w, entry, cacheControl, varyAccept, "MISS")
+		return
+	}
+	writeImage(w, entry, cacheControl, varyAccept, "BYPASS")
+}
+
+// writeImage sends a cached or rendered image
+func writeImage(w http.ResponseWriter, entry *imagecache.Entry, cacheControl string, varyAccept bool, xCache string) {
+	if varyAccept {
+		w.Header().Add("Vary", "Accept")
+	}
+	w.Header().Set("Content-Type", entry.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(entry.Data)))
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("X-Cache", xCache)
+	w.WriteHeader(http.StatusOK)
+	w.Write(entry.Data)
+}
+
+// prepareImagePath resolves f:auto in a signed imgproxy path to the best
+// format for the Accept header and adds the IMAGE_WATERMARK policy to paths
+// without a watermark, signing the result again. It also returns the parsed
+// path (nil when it does not parse) and whether the response depends on
+// Accept: with auto, or without a format, when imgproxy negotiates by itself
+// (IMGPROXY_AUTO_AVIF/WEBP).
+func (h *Handler) prepareImagePath(r *http.Request, signedPath string) (string, *imgproxy.Path, bool) {
+	_, path, ok := strings.Cut(signedPath, "/")
+	if !ok {
+		return signedPath, nil, false
+	}
+	parsed, err := imgproxy.ParsePath(path)
+	if err != nil {
+		return signedPath, nil, false
+	}
+
+	changed := false
+	varyAccept := false
+	switch parsed.Format() {
+	case "":
+		// The built-in renderer keeps the source format
+		varyAccept = h.renderer == nil
+	case imgproxy.FormatAuto:
+		parsed.SetFormat(h.negotiateFormat(r, parsed.Source))
+		varyAccept = h.renderer == nil
+		changed = true
+	}
+
+	if wm := h.cfg.ImgProxy.Watermark; wm != nil && !parsed.HasOption("watermark") {
+		parsed.Options = append(parsed.Options, wm.Options()...)
+		changed = true
+	}
+
+	if changed {
+		path = parsed.String()
+		signedPath = h.imgproxySigner.Sign(path) + path
+	}
+	return signedPath, parsed, varyAccept
+}
+
+// negotiateFormat resolves f:auto for a request. The built-in renderer cannot
+// encode AVIF or WebP, so it only gets the JPEG/PNG fallback.
+func (h *Handler) negotiateFormat(r *http.Request, source string) string {
+	if h.renderer != nil {
+		return imgproxy.NegotiateFormat("", source)
+	}
+	return imgproxy.NegotiateFormat(r.Header.Get("Accept"), source)
+}
+
+// imageCacheKey returns the cache key of a parsed imgproxy path, or "" when
+// the image should not be cached. The key leaves out the signature and exp:,
+// so renewed URLs of private images share an entry, and names the rendition
+// by its signature. Without a format imgproxy picks one from Accept, which is
+// then part of the key too.
+func (h *Handler) imageCacheKey(r *http.Request, parsed *imgproxy.Path, varyAccept bool) string {
+	if parsed == nil || !h.imageCache.Enabled() {
+		return ""
+	}
+
+	rendition := parsed.Canonical()
+	if varyAccept && parsed.Format() == "" {
+		rendition += "@" + acceptedImageFormat(r.Header.Get("Accept"))
+	}
+
+	source, err := url.Parse(parsed.Source)
+	if err != nil || source.Scheme != "s3" {
+		return ""
+	}
+	return imagecache.Key(source.Host, strings.TrimPrefix(source.Path, "/"), h.imgproxySigner.Sign(rendition))
+}
+
+// invalidateImages drops the cached transforms of an original
+func (h *Handler) invalidateImages(ctx context.Context, bucket, objectKey string) {
+	if err := h.imageCache.Invalidate(ctx, bucket, objectKey); err != nil {
+		log.Warn().Err(err).Str("object_key", objectKey).Msg("Failed to invalidate cached images")
+	}
+}
+
+// acceptedImageFormat is the format imgproxy negotiates for an Accept header
+// when IMGPROXY_AUTO_AVIF and IMGPROXY_AUTO_WEBP are on
+func acceptedImageFormat(accept string) string {
+	switch {
+	case imgproxy.Accepts(accept, "image/avif"):
+		return "avif"
+	case imgproxy.Accepts(accept, "image/webp"):
+		return "webp"
+	default:
+		return ""
+	}
+}
+
+// captureWriter keeps a copy of up to limit bytes; beyond that it gives up
+type captureWriter struct {
+	buf      bytes.Buffer
+	limit    int64
+	overflow bool
+}
+
+func (c *captureWriter) Write(p []byte) (int, error) {
+	if !c.overflow {
+		if int64(c.buf.Len()+len(p)) > c.limit {
+			c.overflow = true
+			c.buf = bytes.Buffer{}
+		} else {
+			c.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// respondImgProxyError turns an imgproxy error into a problem response
+func respondImgProxyError(w http.ResponseWriter, r *http.Request, resp *http.Response) {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	log.Warn().Int("status", resp.StatusCode).Str("body", string(body)).Msg("imgproxy rejected image request")
+
+	switch {
+	case resp.StatusCode == http.StatusForbidden:
+		respondError(w, r, http.StatusForbidden, CodeInvalidSignature, "Invalid image URL signature")
+	case resp.StatusCode == http.StatusNotFound:
+		respondError(w, r, http.StatusNotFound, CodeObjectNotFound, "Source image not found")
+	case resp.StatusCode < 500:
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid image processing options")
+	default:
+		respondError(w, r, http.StatusBadGateway, CodeUpstream, "Failed to process image")
 	}
 }
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ancill/mediapod/services/media-api/internal/config"
+	"github.com/ancill/mediapod/services/media-api/internal/imagecache"
+	"github.com/ancill/mediapod/services/media-api/internal/imgproxy"
+)
+
+const (
+	testImgproxyKey  = "943b421c9eb07c830af81030552c86009268de4e532ba2ee2eab8247c6da0881"
+	testImgproxySalt = "520f986b998545b4785e0defbc4f3c1203f22de2374a3d53cb7a7fe9fea309c5"
+)
+
+func TestProxyImage(t *testing.T) {
+	var upstream []string
+	fake := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstream = append(upstream, r.URL.Path)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png"))
+	}))
+	defer fake.Close()
+
+	signer, err := imgproxy.NewSigner(testImgproxyKey, testImgproxySalt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSigner, err := imgproxy.NewSigner(strings.Repeat("ab", 32), testImgproxySalt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &Handler{
+		cfg:            &config.Config{ImgProxy: config.ImgProxyConfig{BaseURL: fake.URL}},
+		imgproxySigner: signer,
+		imgproxyPolicy: imgproxy.NewPolicy(imgproxy.DefaultAllowedOptions, 4096, []string{"media-originals"}),
+		imageCache:     &imagecache.Cache{},
+	}
+	router := NewRouter(h)
+
+	source := "s3://media-originals/a.jpg"
+	future := time.Now().Add(time.Hour).Unix()
+	past := time.Now().Add(-time.Minute).Unix()
+	valid := signer.SignURL("rs:fit:400:300", source)
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+		code   string
+	}{
+		{"valid", valid, http.StatusOK, ""},
+		{"valid with expiry", signer.SignURLWithExpiry("rs:fit:400:300", source, future), http.StatusOK, ""},
+		{"expired", signer.SignURLWithExpiry("rs:fit:400:300", source, past), http.StatusForbidden, CodeURLExpired},
+		{"tampered options", strings.Replace(valid, "400:300", "4000:3000", 1), http.StatusForbidden, CodeInvalidSignature},
+		{"expiry removed", strings.Replace(signer.SignURLWithExpiry("rs:fit:400:300", source, past), fmt.Sprintf("/exp:%d", past), "", 1), http.StatusForbidden, CodeInvalidSignature},
+		{"wrong key", otherSigner.SignURL("rs:fit:400:300", source), http.StatusForbidden, CodeInvalidSignature},
+		{"unsigned", "/insecure/rs:fit:400:300/" + base64.RawURLEncoding.EncodeToString([]byte(source)), http.StatusForbidden, CodeInvalidSignature},
+		{"missing path", "/" + strings.Split(valid, "/")[1] + "/", http.StatusBadRequest, CodeInvalidRequest},
+		{"missing source", "/" + signer.Sign("/rs:fit:1:1/") + "/rs:fit:1:1/", http.StatusBadRequest, CodeInvalidRequest},
+		{"option not allowed", signer.SignURL("z:4", source), http.StatusBadRequest, CodeOperationNotAllowed},
+		{"too large", signer.SignURL("rs:fit:5000:5000", source), http.StatusBadRequest, CodeOperationNotAllowed},
+		{"dpr too large", signer.SignURL("rs:fit:3000:100/dpr:2", source), http.StatusBadRequest, CodeOperationNotAllowed},
+		{"bad arguments", signer.SignURL("w:wide", source), http.StatusBadRequest, CodeInvalidRequest},
+		{"other bucket", signer.SignURL("rs:fit:400:300", "s3://secrets/a.jpg"), http.StatusForbidden, CodeSourceNotAllowed},
+		{"http source", signer.SignURL("rs:fit:400:300", "http://169.254.169.254/"), http.StatusForbidden, CodeSourceNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream = nil
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/image"+tt.url, nil))
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.code == "" {
+				if len(upstream) != 1 || upstream[0] != tt.url {
+					t.Errorf("imgproxy requests = %v, want [%s]", upstream, tt.url)
+				}
+				return
+			}
+
+			if len(upstream) > 0 {
+				t.Errorf("rejected request reached imgproxy: %v", upstream)
+			}
+			var problem Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("invalid problem body: %v", err)
+			}
+			if problem.Code != tt.code {
+				t.Errorf("code = %q, want %q", problem.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestProxyImageCacheControl(t *testing.T) {
+	fake := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png"))
+	}))
+	defer fake.Close()
+
+	signer, err := imgproxy.NewSigner(testImgproxyKey, testImgproxySalt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter(&Handler{
+		cfg:            &config.Config{ImgProxy: config.ImgProxyConfig{BaseURL: fake.URL}},
+		imgproxySigner: signer,
+		imgproxyPolicy: imgproxy.NewPolicy(imgproxy.DefaultAllowedOptions, 4096, []string{"media-originals"}),
+		imageCache:     &imagecache.Cache{},
+	})
+
+	get := func(url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/image"+url, nil))
+		return rec
+	}
+
+	rec := get(signer.SignURL("f:png", "s3://media-originals/a.jpg"))
+	if cc := rec.Header().Get("Cache-Control"); !strings.Contains(cc, "immutable") {
+		t.Errorf("Cache-Control of a permanent URL = %q, want immutable", cc)
+	}
+
+	rec = get(signer.SignURLWithExpiry("f:png", "s3://media-originals/a.jpg", time.Now().Add(time.Hour).Unix()))
+	if cc := rec.Header().Get("Cache-Control"); strings.Contains(cc, "immutable") {
+		t.Errorf("Cache-Control of an expiring URL = %q, want it bounded by the expiry", cc)
+	}
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// processingJobTypes maps asset kinds to the worker job that processes them.
+// Kinds without an entry are ready as soon as they are uploaded.
+var processingJobTypes = map[string]string{
+	"video": "transcode",
+	"image": "analyze_image",
+}
+
+// processedKinds lists the asset kinds that have a processing job
+func processedKinds() []string {
+	kinds := make([]string, 0, len(processingJobTypes))
+	for kind := range processingJobTypes {
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
+
+// JobResponse represents a processing job of an asset
+type JobResponse struct {
+	ID          string     `json:"id"`
+	Type        string     `json:"type"`
+	State       string     `json:"state"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"maxAttempts"`
+	Error       *string    `json:"error,omitempty"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// JobListResponse represents the processing jobs of an asset
+type JobListResponse struct {
+	Jobs  []JobResponse `json:"jobs"`
+	Total int           `json:"total"`
+}
+
+// ReprocessResponse represents the response after queueing reprocessing
+type ReprocessResponse struct {
+	State string `json:"state"`
+	JobID string `json:"jobId"`
+}
+
+// ListAssetJobs handles GET /v1/media/:assetId/jobs
+func (h *Handler) ListAssetJobs(w http.ResponseWriter, r *http.Request) {
+	assetID, err := uuid.Parse(chi.URLParam(r, "assetId"))
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid asset ID")
+		return
+	}
+
+	ctx := context.Background()
+
+	var exists bool
+	if err := h.db.Pool().QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM assets WHERE id = $1)", assetID).Scan(&exists); err != nil {
+		log.Error().Err(err).Msg("Failed to look up asset")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to list jobs")
+		return
+	}
+	if !exists {
+		respondError(w, r, http.StatusNotFound, CodeAssetNotFound, "Asset not found")
+		return
+	}
+
+	rows, err := h.db.Pool().Query(ctx, `
+		SELECT id, job_type, state, attempts, max_attempts, error_message, started_at, completed_at, created_at
+		FROM processing_jobs
+		WHERE asset_id = $1
+		ORDER BY created_at DESC
+	`, assetID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list jobs")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to list jobs")
+		return
+	}
+	defer rows.Close()
+
+	jobs := []JobResponse{}
+	for rows.Next() {
+		var job JobResponse
+		var id uuid.UUID
+		err := rows.Scan(&id, &job.Type, &job.State, &job.Attempts, &job.MaxAttempts,
+			&job.Error, &job.StartedAt, &job.CompletedAt, &job.CreatedAt)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan job row")
+			continue
+		}
+		job.ID = id.String()
+		jobs = append(jobs, job)
+	}
+
+	respondJSON(w, http.StatusOK, JobListResponse{Jobs: jobs, Total: len(jobs)})
+}
+
+// ReprocessAsset handles POST /v1/media/:assetId/reprocess
+// Queues the asset's processing job again, e.g. after a failure or a worker
+// upgrade. Only ready or failed assets of a kind that is processed qualify.
+func (h *Handler) ReprocessAsset(w http.ResponseWriter, r *http.Request) {
+	assetID, err := uuid.Parse(chi.URLParam(r, "assetId"))
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid asset ID")
+		return
+	}
+
+	ctx := context.Background()
+
+	tx, err := h.db.Pool().Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to begin transaction")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to reprocess asset")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var kind, state, bucket, objectKey string
+	err = tx.QueryRow(ctx, "SELECT kind, state, bucket, object_key FROM assets WHERE id = $1 FOR UPDATE", assetID).
+		Scan(&kind, &state, &bucket, &objectKey)
+	if errors.Is(err, pgx.ErrNoRows) {
+		respondError(w, r, http.StatusNotFound, CodeAssetNotFound, "Asset not found")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to look up asset")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to reprocess asset")
+		return
+	}
+
+	jobType, ok := processingJobTypes[kind]
+	if !ok {
+		respondError(w, r, http.StatusConflict, CodeInvalidState, "Assets of kind "+kind+" are not processed")
+		return
+	}
+	if state != "ready" && state != "failed" {
+		respondError(w, r, http.StatusConflict, CodeInvalidState, "Asset is "+state+"; only ready or failed assets can be reprocessed")
+		return
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE assets SET state = 'processing' WHERE id = $1", assetID); err != nil {
+		log.Error().Err(err).Msg("Failed to update asset state")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to reprocess asset")
+		return
+	}
+
+	if err := h.recordChange(ctx, tx, ChangeState, assetID); err != nil {
+		log.Error().Err(err).Msg("Failed to record asset state change")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to reprocess asset")
+		return
+	}
+
+	job := &Job{
+		ID:      uuid.New().String(),
+		AssetID: assetID.String(),
+		Type:    jobType,
+	}
+	if err := enqueueJob(ctx, tx, job); err != nil {
+		log.Error().Err(err).Str("asset_id", assetID.String()).Msg("Failed to enqueue reprocessing job")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to reprocess asset")
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to commit reprocessing")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to reprocess asset")
+		return
+	}
+
+	log.Info().
+		Str("job_id", job.ID).
+		Str("asset_id", job.AssetID).
+		Str("type", job.Type).
+		Msg("Enqueued reprocessing job")
+	h.publishState(ctx, assetID, "processing")
+
+	// Reprocessing picks up a replaced original, so its old transforms go
+	h.invalidateImages(ctx, bucket, objectKey)
+
+	respondJSON(w, http.StatusAccepted, ReprocessResponse{State: "processing", JobID: job.ID})
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// apiKeyPrefix starts every API key, so leaked keys are easy to recognize
+	apiKeyPrefix = "mpk_"
+	// apiKeyDisplayLen is how much of a key is stored in the clear to tell
+	// keys apart
+	apiKeyDisplayLen = 12
+	// apiKeyUsageResolution is how stale last_used_at may get, so busy keys
+	// write to the table once a minute rather than on every request
+	apiKeyUsageResolution = time.Minute
+)
+
+// CreateAPIKeyRequest represents the request to create an API key
+type CreateAPIKeyRequest struct {
+	// Name describes who or what uses the key
+	Name string `json:"name" minLength:"1" maxLength:"100"`
+}
+
+// APIKeyResponse represents an API key
+type APIKeyResponse struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Prefix string `json:"prefix" doc:"Start of the key, to tell keys apart"`
+	// Key is only returned when the key is created
+	Key string `json:"key,omitempty"`
+	// LastUsedAt is accurate to a minute
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// APIKeyListResponse represents all API keys
+type APIKeyListResponse struct {
+	Keys  []APIKeyResponse `json:"keys"`
+	Total int              `json:"total"`
+}
+
+// hashAPIKey returns the hex SHA-256 stored for a key. Keys are random, so
+// a plain hash is enough to keep the table from holding usable secrets.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// RequireAPIKey rejects requests without a valid API key when
+// API_KEYS_REQUIRED is set. Otherwise the API stays open and bearer tokens
+// are left to whatever authenticates in front of it.
+func (h *Handler) RequireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.cfg.APIKeysRequired {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, found := bearerToken(r)
+		if !found {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mediapod"`)
+			respondError(w, r, http.StatusUnauthorized, CodeUnauthorized, "API key required")
+			return
+		}
+
+		var keyID uuid.UUID
+		var stale bool
+		err := h.db.Pool().QueryRow(r.Context(), `
+			SELECT id, last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - make_interval(secs => $2)
+			FROM api_keys WHERE key_hash = $1
+		`, hashAPIKey(key), apiKeyUsageResolution.Seconds()).Scan(&keyID, &stale)
+		if errors.Is(err, pgx.ErrNoRows) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mediapod", error="invalid_token"`)
+			respondError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Invalid API key")
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to check API key")
+			respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to check API key")
+			return
+		}
+
+		// The usage time is informational, so failing to record it does not
+		// fail the request
+		if stale {
+			if _, err := h.db.Pool().Exec(r.Context(),
+				"UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1", keyID); err != nil {
+				log.Warn().Err(err).Str("key_id", keyID.String()).Msg("Failed to record API key usage")
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CreateAPIKey handles POST /v1/keys
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req CreateAPIKeyRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	apiKey, err := NewAPIKey(context.Background(), h.db.Pool(), req.Name)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create API key")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to create API key")
+		return
+	}
+
+	log.Info().Str("key_id", apiKey.ID).Str("name", apiKey.Name).Msg("API key created")
+	respondJSON(w, http.StatusCreated, apiKey)
+}
+
+// NewAPIKey generates an API key and stores its hash. Besides CreateAPIKey,
+// the server's create-key command uses it to make the first key of a
+// deployment that already requires one.
+func NewAPIKey(ctx context.Context, q querier, name string) (*APIKeyResponse, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	apiKey := &APIKeyResponse{Name: name, Prefix: key[:apiKeyDisplayLen], Key: key}
+	err := q.QueryRow(ctx, `
+		INSERT INTO api_keys (name, prefix, key_hash)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`, name, apiKey.Prefix, hashAPIKey(key)).Scan(&apiKey.ID, &apiKey.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store API key: %w", err)
+	}
+	return apiKey, nil
+}
+
+// ListAPIKeys handles GET /v1/keys
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.db.Pool().Query(context.Background(), `
+		SELECT id, name, prefix, last_used_at, created_at
+		FROM api_keys
+		ORDER BY created_at DESC
+	`)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list API keys")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to list API keys")
+		return
+	}
+	defer rows.Close()
+
+	keys := []APIKeyResponse{}
+	for rows.Next() {
+		var key APIKeyResponse
+		if err := rows.Scan(&key.ID, &key.Name, &key.Prefix, &key.LastUsedAt, &key.CreatedAt); err != nil {
+			log.Error().Err(err).Msg("Failed to scan API key row")
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	respondJSON(w, http.StatusOK, APIKeyListResponse{
+		Keys:  keys,
+		Total: len(keys),
+	})
+}
+
+// DeleteAPIKey handles DELETE /v1/keys/:keyId; requests with the key are
+// rejected from then on
+func (h *Handler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	keyID, err := uuid.Parse(chi.URLParam(r, "keyId"))
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid API key ID")
+		return
+	}
+
+	result, err := h.db.Pool().Exec(context.Background(), "DELETE FROM api_keys WHERE id = $1", keyID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to delete API key")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to delete API key")
+		return
+	}
+	if result.RowsAffected() == 0 {
+		respondError(w, r, http.StatusNotFound, CodeAPIKeyNotFound, "API key not found")
+		return
+	}
+
+	log.Info().Str("key_id", keyID.String()).Msg("API key deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
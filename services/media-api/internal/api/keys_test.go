@@ -0,0 +1,129 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ancill/mediapod/services/media-api/internal/config"
+	"github.com/google/uuid"
+)
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		header string
+		token  string
+		found  bool
+	}{
+		{"Bearer mpk_abc", "mpk_abc", true},
+		{"bearer mpk_abc", "mpk_abc", true},
+		{"Bearer  mpk_abc ", "mpk_abc", true},
+		{"Bearer ", "", false},
+		{"Bearer", "", false},
+		{"Basic dXNlcjpwYXNz", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/v1/media", nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		token, found := bearerToken(r)
+		if token != tt.token || found != tt.found {
+			t.Errorf("bearerToken(%q) = %q, %v; want %q, %v", tt.header, token, found, tt.token, tt.found)
+		}
+	}
+}
+
+func TestRequireAPIKeyDisabled(t *testing.T) {
+	h := &Handler{cfg: &config.Config{}}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// Bearer tokens are left to a proxy in front of the API
+	r := httptest.NewRequest(http.MethodGet, "/v1/media", nil)
+	r.Header.Set("Authorization", "Bearer proxy-token")
+	rec := httptest.NewRecorder()
+	h.RequireAPIKey(next).ServeHTTP(rec, r)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want 204", rec.Code)
+	}
+}
+
+func TestRequireAPIKeyMissing(t *testing.T) {
+	h := &Handler{cfg: &config.Config{APIKeysRequired: true}}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request without a key reached the handler")
+	})
+
+	rec := httptest.NewRecorder()
+	h.RequireAPIKey(next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/media", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", rec.Code)
+	}
+	if rec.Header().Get("WWW-Authenticate") == "" {
+		t.Error("missing WWW-Authenticate header")
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != ProblemContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, ProblemContentType)
+	}
+}
+
+// Keys are checked on every request, but their usage is only written when
+// last_used_at is older than apiKeyUsageResolution
+func TestRequireAPIKeyUsage(t *testing.T) {
+	keys := map[string]string{"mpk_stale": "t", "mpk_recent": "f"}
+	ids := map[string]string{"mpk_stale": uuid.NewString(), "mpk_recent": uuid.NewString()}
+	database, fake := newFakeDB(t, func(sql string) fakeResult {
+		switch {
+		case strings.HasPrefix(sql, "SELECT id, last_used_at IS NULL"):
+			for key, stale := range keys {
+				if strings.Contains(sql, hashAPIKey(key)) {
+					return fakeResult{columns: []fakeColumn{{"id", oidUUID}, {"stale", oidBool}}, rows: [][]any{{ids[key], stale}}}
+				}
+			}
+			return fakeResult{columns: []fakeColumn{{"id", oidUUID}, {"stale", oidBool}}}
+		case strings.HasPrefix(sql, "UPDATE api_keys SET last_used_at"):
+			return fakeResult{tag: "UPDATE 1"}
+		}
+		return fakeResult{err: errFakeQuery}
+	})
+	h := &Handler{cfg: &config.Config{APIKeysRequired: true}, db: database}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		key     string
+		status  int
+		updates int
+	}{
+		{"mpk_stale", http.StatusNoContent, 1},
+		{"mpk_recent", http.StatusNoContent, 0},
+		{"mpk_unknown", http.StatusUnauthorized, 0},
+	}
+	for _, tt := range tests {
+		before := len(fake.Statements())
+		r := httptest.NewRequest(http.MethodGet, "/v1/media", nil)
+		r.Header.Set("Authorization", "Bearer "+tt.key)
+		rec := httptest.NewRecorder()
+		h.RequireAPIKey(next).ServeHTTP(rec, r)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.key, rec.Code, tt.status)
+		}
+
+		var updates []string
+		for _, sql := range fake.Statements()[before:] {
+			if strings.HasPrefix(sql, "UPDATE") {
+				updates = append(updates, sql)
+			}
+		}
+		if len(updates) != tt.updates {
+			t.Errorf("%s: updates = %q, want %d", tt.key, updates, tt.updates)
+		} else if tt.updates == 1 && !strings.Contains(updates[0], ids[tt.key]) {
+			t.Errorf("%s: update %q does not name the key", tt.key, updates[0])
+		}
+	}
+}
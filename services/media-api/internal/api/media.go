@@ -3,32 +3,47 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/ancill/mediapod/services/media-api/internal/imgproxy"
+	"github.com/ancill/mediapod/services/media-api/internal/outbox"
+	"github.com/ancill/mediapod/services/media-api/internal/storage"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog/log"
 )
 
 // Job queue constants (must match worker)
 const JobQueueKey = "media:jobs:pending"
 
+// Asset visibility values
+const (
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+)
+
 // Job represents a processing job for the worker
 type Job struct {
 	ID      string `json:"id"`
 	AssetID string `json:"assetId"`
-	Type    string `json:"type"` // transcode, thumbnail, extract_meta
+	Type    string `json:"type"` // transcode, analyze_image, thumbnail, extract_meta
 }
 
 // InitUploadRequest represents the request to initialize an upload
 type InitUploadRequest struct {
-	MimeType string `json:"mime"`
-	Kind     string `json:"kind"` // image, video, audio, document
-	Filename string `json:"filename"`
-	Size     int64  `json:"size"`
+	MimeType string `json:"mime" minLength:"1" maxLength:"100"`
+	Kind     string `json:"kind" enum:"image,video,audio,document"`
+	Filename string `json:"filename" minLength:"1" maxLength:"255"`
+	Size     int64  `json:"size" minimum:"0"`
+	// Visibility is public (default) or private
+	Visibility string `json:"visibility,omitempty" enum:"public,private"`
 }
 
 // InitUploadResponse represents the response with presigned URL
@@ -41,9 +56,27 @@ type InitUploadResponse struct {
 	ExpiresIn    int               `json:"expiresIn"` // seconds
 }
 
+// enqueueJob records a processing job and queues it for the worker through the
+// outbox, so it is only dispatched if the surrounding transaction commits
+func enqueueJob(ctx context.Context, q querier, job *Job) error {
+	_, err := q.Exec(ctx, `
+		INSERT INTO processing_jobs (id, asset_id, job_type) VALUES ($1, $2, $3)
+	`, job.ID, job.AssetID, job.Type)
+	if err != nil {
+		return fmt.Errorf("failed to record job: %w", err)
+	}
+
+	jobData, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+
+	return outbox.Enqueue(ctx, q, JobQueueKey, jobData)
+}
+
 // CompleteUploadRequest represents the request to mark upload complete
 type CompleteUploadRequest struct {
-	AssetID string `json:"assetId"`
+	AssetID string `json:"assetId" format:"uuid"`
 }
 
 // CompleteUploadResponse represents the response after completing upload
@@ -52,40 +85,157 @@ type CompleteUploadResponse struct {
 	Message string `json:"message,omitempty"`
 }
 
+// UpdateAssetRequest represents a partial update of an asset
+type UpdateAssetRequest struct {
+	Visibility *string `json:"visibility,omitempty" enum:"public,private"`
+}
+
 // AssetResponse represents an asset
 type AssetResponse struct {
-	ID        string                 `json:"id"`
-	Kind      string                 `json:"kind"`
-	State     string                 `json:"state"`
-	Filename  string                 `json:"filename"`
-	MimeType  string                 `json:"mimeType"`
-	Size      int64                  `json:"size"`
-	Bucket    string                 `json:"bucket"`
-	ObjectKey string                 `json:"objectKey"`
-	Width     *int                   `json:"width,omitempty"`
-	Height    *int                   `json:"height,omitempty"`
-	Duration  *float64               `json:"duration,omitempty"`
+	ID         string   `json:"id"`
+	Kind       string   `json:"kind"`
+	State      string   `json:"state"`
+	Visibility string   `json:"visibility"`
+	Filename   string   `json:"filename"`
+	MimeType   string   `json:"mimeType"`
+	Size       int64    `json:"size"`
+	Bucket     string   `json:"bucket"`
+	ObjectKey  string   `json:"objectKey"`
+	Width      *int     `json:"width,omitempty"`
+	Height     *int     `json:"height,omitempty"`
+	Duration   *float64 `json:"duration,omitempty"`
+	// Image is probed by the worker before an image turns ready; width and
+	// height are as delivered, after EXIF rotation
+	Image *ImageInfo `json:"image,omitempty"`
+	// FocalPoint and CropHints steer the crops of images (see SetFocus)
+	FocalPoint *imgproxy.FocalPoint `json:"focalPoint,omitempty"`
+	CropHints  []imgproxy.CropHint  `json:"cropHints,omitempty"`
+	// Placeholder is computed by the worker shortly after upload
+	Placeholder *Placeholder `json:"placeholder,omitempty"`
+	// Palette is the image's or poster's colors, largest share first
+	Palette []PaletteColor `json:"palette,omitempty"`
+	// Metadata is read from the EXIF, IPTC and XMP metadata of images
+	Metadata *ImageMetadata `json:"metadata,omitempty"`
+	// Tags include the keywords of an image's metadata
+	Tags      []string               `json:"tags,omitempty"`
 	CreatedAt time.Time              `json:"createdAt"`
 	URLs      map[string]interface{} `json:"urls"`
 }
 
+// Placeholder is what clients draw while an image or video poster loads
+type Placeholder struct {
+	BlurHash string `json:"blurHash"`
+	// ThumbHash is base64 encoded; unlike BlurHash it keeps aspect ratio and alpha
+	ThumbHash string `json:"thumbHash"`
+	// DominantColor is #rrggbb
+	DominantColor string `json:"dominantColor"`
+}
+
+// AssetListResponse represents a page of assets
+type AssetListResponse struct {
+	Assets []AssetResponse `json:"assets"`
+	Total  int             `json:"total"`
+}
+
+// assetColumns is the column list read by scanAsset
+const assetColumns = `
+	a.id, a.kind, a.state, a.visibility, a.filename, a.mime_type, a.size_bytes, a.bucket, a.object_key, a.created_at,
+	m.width, m.height, m.duration_seconds, m.focal_x, m.focal_y, m.crop_hints,
+	m.blurhash, m.thumbhash, m.dominant_color, m.palette, m.exif,
+	m.format, m.color_space, m.color_profile, m.bit_depth, m.has_alpha, m.frame_count,
+	ARRAY(SELECT t.tag FROM asset_tags t WHERE t.asset_id = a.id ORDER BY t.tag)`
+
+// rowScanner is satisfied by pgx.Row and pgx.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanAsset scans a row selected with assetColumns
+func scanAsset(row rowScanner) (*AssetResponse, error) {
+	var asset AssetResponse
+	var focalX, focalY *float64
+	var cropHints, palette, metadata []byte
+	var blurHash, thumbHash, dominantColor *string
+	var format, colorSpace, colorProfile *string
+	var bitDepth, frameCount *int
+	var hasAlpha *bool
+	err := row.Scan(
+		&asset.ID, &asset.Kind, &asset.State, &asset.Visibility, &asset.Filename, &asset.MimeType,
+		&asset.Size, &asset.Bucket, &asset.ObjectKey, &asset.CreatedAt, &asset.Width, &asset.Height, &asset.Duration,
+		&focalX, &focalY, &cropHints, &blurHash, &thumbHash, &dominantColor, &palette,
+		&metadata, &format, &colorSpace, &colorProfile, &bitDepth, &hasAlpha, &frameCount, &asset.Tags,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if format != nil {
+		asset.Image = &ImageInfo{Format: *format}
+		if colorSpace != nil {
+			asset.Image.ColorSpace = *colorSpace
+		}
+		if colorProfile != nil {
+			asset.Image.ColorProfile = *colorProfile
+		}
+		if bitDepth != nil {
+			asset.Image.BitDepth = *bitDepth
+		}
+		if hasAlpha != nil {
+			asset.Image.HasAlpha = *hasAlpha
+		}
+		if frameCount != nil {
+			asset.Image.FrameCount = *frameCount
+		}
+	}
+	if focalX != nil && focalY != nil {
+		asset.FocalPoint = &imgproxy.FocalPoint{X: *focalX, Y: *focalY}
+	}
+	if cropHints != nil {
+		if err := json.Unmarshal(cropHints, &asset.CropHints); err != nil {
+			return nil, fmt.Errorf("invalid crop hints: %w", err)
+		}
+	}
+	if blurHash != nil && thumbHash != nil && dominantColor != nil {
+		asset.Placeholder = &Placeholder{BlurHash: *blurHash, ThumbHash: *thumbHash, DominantColor: *dominantColor}
+	}
+	if palette != nil {
+		if err := json.Unmarshal(palette, &asset.Palette); err != nil {
+			return nil, fmt.Errorf("invalid palette: %w", err)
+		}
+	}
+	if metadata != nil {
+		if err := json.Unmarshal(metadata, &asset.Metadata); err != nil {
+			return nil, fmt.Errorf("invalid metadata: %w", err)
+		}
+	}
+	return &asset, nil
+}
+
+// loadAsset fetches a single asset with its metadata
+func (h *Handler) loadAsset(ctx context.Context, assetID uuid.UUID) (*AssetResponse, error) {
+	return h.loadAssetWith(ctx, h.db.Pool(), assetID)
+}
+
+// loadAssetWith is loadAsset reading through q, so a transaction sees its own writes
+func (h *Handler) loadAssetWith(ctx context.Context, q querier, assetID uuid.UUID) (*AssetResponse, error) {
+	row := q.QueryRow(ctx, `
+		SELECT`+assetColumns+`
+		FROM assets a
+		LEFT JOIN asset_meta m ON a.id = m.asset_id
+		WHERE a.id = $1
+	`, assetID)
+	return scanAsset(row)
+}
+
 // InitUpload handles POST /v1/media/init-upload
 func (h *Handler) InitUpload(w http.ResponseWriter, r *http.Request) {
 	var req InitUploadRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid request body")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	// Validate request
-	if req.MimeType == "" || req.Kind == "" || req.Filename == "" {
-		respondError(w, http.StatusBadRequest, "Missing required fields: mime, kind, filename")
-		return
-	}
-
-	if req.Kind != "image" && req.Kind != "video" && req.Kind != "audio" && req.Kind != "document" {
-		respondError(w, http.StatusBadRequest, "Invalid kind. Must be: image, video, audio, or document")
-		return
+	if req.Visibility == "" {
+		req.Visibility = VisibilityPublic
 	}
 
 	// Generate asset ID and object key
@@ -95,14 +245,40 @@ func (h *Handler) InitUpload(w http.ResponseWriter, r *http.Request) {
 
 	// Create asset record in database
 	ctx := context.Background()
-	_, err := h.db.Pool().Exec(ctx, `
-		INSERT INTO assets (id, kind, state, bucket, object_key, filename, mime_type, size_bytes)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-	`, assetID, req.Kind, "uploading", h.storage.GetConfig().BucketOriginals, objectKey, req.Filename, req.MimeType, req.Size)
+	tx, err := h.db.Pool().Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to begin transaction")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to create asset")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO assets (id, kind, state, visibility, bucket, object_key, filename, mime_type, size_bytes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, assetID, req.Kind, "uploading", req.Visibility, h.storage.GetConfig().BucketOriginals, objectKey, req.Filename, req.MimeType, req.Size)
 
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create asset record")
-		respondError(w, http.StatusInternalServerError, "Failed to create asset")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to create asset")
+		return
+	}
+
+	if err := h.recordChange(ctx, tx, ChangeCreate, assetID); err != nil {
+		log.Error().Err(err).Msg("Failed to record asset creation")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to create asset")
+		return
+	}
+
+	if err := h.emitAssetEvent(ctx, tx, EventAssetCreated, assetID); err != nil {
+		log.Error().Err(err).Msg("Failed to emit asset creation")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to create asset")
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to commit asset record")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to create asset")
 		return
 	}
 
@@ -110,7 +286,7 @@ func (h *Handler) InitUpload(w http.ResponseWriter, r *http.Request) {
 	presignedURL, err := h.storage.PresignedPutURL(ctx, h.storage.GetConfig().BucketOriginals, objectKey, 15*time.Minute)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to generate presigned URL")
-		respondError(w, http.StatusInternalServerError, "Failed to generate upload URL")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to generate upload URL")
 		return
 	}
 
@@ -131,83 +307,93 @@ func (h *Handler) InitUpload(w http.ResponseWriter, r *http.Request) {
 // CompleteUpload handles POST /v1/media/complete
 func (h *Handler) CompleteUpload(w http.ResponseWriter, r *http.Request) {
 	var req CompleteUploadRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid request body")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	assetID, err := uuid.Parse(req.AssetID)
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid asset ID")
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid asset ID")
 		return
 	}
 
 	ctx := context.Background()
 
-	// Update asset state to processing
-	result, err := h.db.Pool().Exec(ctx, `
-		UPDATE assets SET state = 'processing' WHERE id = $1 AND state = 'uploading'
-	`, assetID)
-
+	// The state change, the job and the webhook events commit together, so an
+	// asset can never be left in processing without a job to move it on
+	tx, err := h.db.Pool().Begin(ctx)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to update asset state")
-		respondError(w, http.StatusInternalServerError, "Failed to update asset")
+		log.Error().Err(err).Msg("Failed to begin transaction")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update asset")
 		return
 	}
-
-	rowsAffected := result.RowsAffected()
-	if rowsAffected == 0 {
-		respondError(w, http.StatusNotFound, "Asset not found or already processed")
+	defer tx.Rollback(ctx)
+
+	// Videos need transcoding and images probing (dimensions, format) before
+	// they can be delivered; other types (audio, document) are served as-is
+	// for now
+	var kind, finalState string
+	err = tx.QueryRow(ctx, `
+		UPDATE assets SET state = CASE WHEN kind = ANY($2) THEN 'processing' ELSE 'ready' END
+		WHERE id = $1 AND state = 'uploading'
+		RETURNING kind, state
+	`, assetID, processedKinds()).Scan(&kind, &finalState)
+	if errors.Is(err, pgx.ErrNoRows) {
+		h.respondNotUploading(w, r, tx, assetID)
 		return
 	}
-
-	// Get asset kind to determine processing
-	var finalState string
-	var kind string
-	err = h.db.Pool().QueryRow(ctx, "SELECT kind FROM assets WHERE id = $1", assetID).Scan(&kind)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to get asset kind")
-		respondError(w, http.StatusInternalServerError, "Failed to get asset")
+		log.Error().Err(err).Msg("Failed to update asset state")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update asset")
 		return
 	}
 
-	if kind == "image" {
-		// Images can be marked ready immediately (imgproxy handles transformations)
-		finalState = "ready"
-		_, err = h.db.Pool().Exec(ctx, "UPDATE assets SET state = $1 WHERE id = $2", finalState, assetID)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to update asset state to ready")
-		}
-	} else if kind == "video" {
-		// Enqueue video transcoding job
-		finalState = "processing"
-		job := Job{
+	if err := h.recordChange(ctx, tx, ChangeState, assetID); err != nil {
+		log.Error().Err(err).Msg("Failed to record asset state change")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update asset")
+		return
+	}
+
+	events := []string{EventAssetUploaded}
+	var job *Job
+	if jobType, ok := processingJobTypes[kind]; ok {
+		job = &Job{
 			ID:      uuid.New().String(),
 			AssetID: assetID.String(),
-			Type:    "transcode",
-		}
-		jobData, err := json.Marshal(job)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to marshal job")
-		} else {
-			if err := h.redis.RPush(ctx, JobQueueKey, jobData).Err(); err != nil {
-				log.Error().Err(err).Msg("Failed to enqueue transcoding job")
-			} else {
-				log.Info().
-					Str("job_id", job.ID).
-					Str("asset_id", job.AssetID).
-					Str("type", job.Type).
-					Msg("Enqueued transcoding job")
-			}
+			Type:    jobType,
 		}
 	} else {
-		// Other types (audio, document) - mark as ready for now
-		finalState = "ready"
-		_, err = h.db.Pool().Exec(ctx, "UPDATE assets SET state = $1 WHERE id = $2", finalState, assetID)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to update asset state to ready")
+		events = append(events, EventAssetReady)
+	}
+	for _, event := range events {
+		if err := h.emitAssetEvent(ctx, tx, event, assetID); err != nil {
+			log.Error().Err(err).Str("event", event).Msg("Failed to emit asset event")
+			respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update asset")
+			return
 		}
 	}
+	if job != nil {
+		if err := enqueueJob(ctx, tx, job); err != nil {
+			log.Error().Err(err).Str("asset_id", assetID.String()).Str("type", job.Type).Msg("Failed to enqueue processing job")
+			respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to enqueue processing job")
+			return
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to commit upload completion")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update asset")
+		return
+	}
+
+	if job != nil {
+		log.Info().
+			Str("job_id", job.ID).
+			Str("asset_id", job.AssetID).
+			Str("type", job.Type).
+			Msg("Enqueued processing job")
+	}
+	h.publishState(ctx, assetID, finalState)
 
 	response := CompleteUploadResponse{
 		State:   finalState,
@@ -217,167 +403,400 @@ func (h *Handler) CompleteUpload(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, response)
 }
 
+// respondNotUploading explains why an asset could not leave the uploading
+// state: it does not exist, or its upload was already completed
+func (h *Handler) respondNotUploading(w http.ResponseWriter, r *http.Request, q querier, assetID uuid.UUID) {
+	var state string
+	err := q.QueryRow(context.Background(), "SELECT state FROM assets WHERE id = $1", assetID).Scan(&state)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		respondError(w, r, http.StatusNotFound, CodeAssetNotFound, "Asset not found")
+	case err != nil:
+		log.Error().Err(err).Msg("Failed to look up asset")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update asset")
+	default:
+		respondError(w, r, http.StatusConflict, CodeInvalidState, "Upload has already been completed; asset is "+state)
+	}
+}
+
 // GetAsset handles GET /v1/media/:assetId
 func (h *Handler) GetAsset(w http.ResponseWriter, r *http.Request) {
 	assetIDStr := chi.URLParam(r, "assetId")
 	assetID, err := uuid.Parse(assetIDStr)
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid asset ID")
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid asset ID")
 		return
 	}
 
 	ctx := context.Background()
 
-	var asset AssetResponse
-	var width, height *int
-	var duration *float64
+	asset, err := h.loadAsset(ctx, assetID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		respondError(w, r, http.StatusNotFound, CodeAssetNotFound, "Asset not found")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("assetId", assetIDStr).Msg("Failed to get asset")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get asset")
+		return
+	}
 
-	err = h.db.Pool().QueryRow(ctx, `
-		SELECT
-			a.id, a.kind, a.state, a.filename, a.mime_type, a.size_bytes, a.bucket, a.object_key, a.created_at,
-			m.width, m.height, m.duration_seconds
-		FROM assets a
-		LEFT JOIN asset_meta m ON a.id = m.asset_id
-		WHERE a.id = $1
-	`, assetID).Scan(
-		&asset.ID, &asset.Kind, &asset.State, &asset.Filename, &asset.MimeType,
-		&asset.Size, &asset.Bucket, &asset.ObjectKey, &asset.CreatedAt, &width, &height, &duration,
-	)
+	// Build URLs based on asset type
+	asset.URLs = h.buildAssetURLs(asset)
+
+	respondJSON(w, http.StatusOK, asset)
+}
 
+// UpdateAsset handles PATCH /v1/media/:assetId
+func (h *Handler) UpdateAsset(w http.ResponseWriter, r *http.Request) {
+	assetIDStr := chi.URLParam(r, "assetId")
+	assetID, err := uuid.Parse(assetIDStr)
 	if err != nil {
-		log.Error().Err(err).Str("assetId", assetIDStr).Msg("Failed to get asset")
-		respondError(w, http.StatusNotFound, "Asset not found")
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid asset ID")
+		return
+	}
+
+	var req UpdateAssetRequest
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	asset.Width = width
-	asset.Height = height
-	asset.Duration = duration
+	ctx := context.Background()
 
-	// Build URLs based on asset type
-	asset.URLs = h.buildAssetURLs(assetID.String(), asset.Kind, asset.State)
+	if req.Visibility != nil {
+		tx, err := h.db.Pool().Begin(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to begin transaction")
+			respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update asset")
+			return
+		}
+		defer tx.Rollback(ctx)
+
+		// Unchanged values are not recorded as a change
+		var kind string
+		err = tx.QueryRow(ctx, `
+			UPDATE assets SET visibility = $1 WHERE id = $2 AND visibility <> $1
+			RETURNING kind
+		`, *req.Visibility, assetID).Scan(&kind)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Msg("Failed to update asset visibility")
+			respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update asset")
+			return
+		}
+
+		if err == nil {
+			// Renditions move before the change commits, so a private asset is
+			// never left readable through the public prefix. A failed move
+			// leaves the visibility unchanged, and repeating the request
+			// finishes it.
+			if kind == "video" {
+				if err := h.moveRenditions(ctx, assetID.String(), *req.Visibility != VisibilityPrivate); err != nil {
+					log.Error().Err(err).Str("asset_id", assetID.String()).Msg("Failed to move video renditions")
+					respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update asset")
+					return
+				}
+			}
+			if err := h.recordChange(ctx, tx, ChangeUpdate, assetID); err != nil {
+				log.Error().Err(err).Msg("Failed to record asset update")
+				respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update asset")
+				return
+			}
+			if err := tx.Commit(ctx); err != nil {
+				log.Error().Err(err).Msg("Failed to commit asset update")
+				respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update asset")
+				return
+			}
+		}
+	}
+
+	asset, err := h.loadAsset(ctx, assetID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		respondError(w, r, http.StatusNotFound, CodeAssetNotFound, "Asset not found")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("assetId", assetIDStr).Msg("Failed to get asset")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update asset")
+		return
+	}
+	asset.URLs = h.buildAssetURLs(asset)
 
 	respondJSON(w, http.StatusOK, asset)
 }
 
 // ListAssets handles GET /v1/media
+// Filters: ?kind=, ?state=, ?visibility=, ?q= (filename substring); paging
+// with ?limit= (default 50, max 500) and ?offset=.
 func (h *Handler) ListAssets(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
+	query := r.URL.Query()
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(sql string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(sql, len(args)))
+	}
+
+	if v := query.Get("kind"); v != "" {
+		addCondition("a.kind = $%d", v)
+	}
+	if v := query.Get("state"); v != "" {
+		addCondition("a.state = $%d", v)
+	}
+	if v := query.Get("visibility"); v != "" {
+		addCondition("a.visibility = $%d", v)
+	}
+	if v := query.Get("q"); v != "" {
+		addCondition("a.filename ILIKE '%%' || $%d || '%%'", escapeLike(v))
+	}
+	if v := query.Get("tag"); v != "" {
+		addCondition("EXISTS (SELECT 1 FROM asset_tags t WHERE t.asset_id = a.id AND t.tag = $%d)", v)
+	}
+	if v := query.Get("color"); v != "" {
+		rgb, err := parseHexColor(v)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid color. Must be a hex color such as ff8800")
+			return
+		}
+		distance, ok := parseIntParam(w, r, query.Get("colorDistance"), defaultColorDistance, 1, 100, "colorDistance")
+		if !ok {
+			return
+		}
+		lab := rgbToLab(rgb)
+		args = append(args, lab[0], lab[1], lab[2], float64(distance))
+		conditions = append(conditions, fmt.Sprintf(colorCondition, len(args)-3, len(args)-2, len(args)-1, len(args)))
+	}
+
+	order := "a.created_at DESC, a.id"
+	switch query.Get("sort") {
+	case "", "created":
+	case "captured":
+		// Assets without a capture time follow, newest first
+		order = "m.captured_at DESC NULLS LAST, a.created_at DESC, a.id"
+	default:
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid sort. Must be created or captured")
+		return
+	}
+
+	limit, ok := parseIntParam(w, r, query.Get("limit"), 50, 1, maxListLimit, "limit")
+	if !ok {
+		return
+	}
+	offset, ok := parseIntParam(w, r, query.Get("offset"), 0, 0, 1<<31-1, "offset")
+	if !ok {
+		return
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit, offset)
 
-	// TODO: Add pagination, filtering, sorting
 	rows, err := h.db.Pool().Query(ctx, `
-		SELECT
-			a.id, a.kind, a.state, a.filename, a.mime_type, a.size_bytes, a.bucket, a.object_key, a.created_at,
-			m.width, m.height, m.duration_seconds
+		SELECT`+assetColumns+`
 		FROM assets a
 		LEFT JOIN asset_meta m ON a.id = m.asset_id
-		ORDER BY a.created_at DESC
-		LIMIT 50
-	`)
+		`+where+`
+		ORDER BY `+order+`
+		LIMIT $`+strconv.Itoa(len(args)-1)+` OFFSET $`+strconv.Itoa(len(args)), args...)
 
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to list assets")
-		respondError(w, http.StatusInternalServerError, "Failed to list assets")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to list assets")
 		return
 	}
 	defer rows.Close()
 
 	var assets []AssetResponse
 	for rows.Next() {
-		var asset AssetResponse
-		var width, height *int
-		var duration *float64
-
-		err := rows.Scan(
-			&asset.ID, &asset.Kind, &asset.State, &asset.Filename, &asset.MimeType,
-			&asset.Size, &asset.Bucket, &asset.ObjectKey, &asset.CreatedAt, &width, &height, &duration,
-		)
+		asset, err := scanAsset(rows)
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to scan asset row")
 			continue
 		}
 
-		asset.Width = width
-		asset.Height = height
-		asset.Duration = duration
-		asset.URLs = h.buildAssetURLs(asset.ID, asset.Kind, asset.State)
+		asset.URLs = h.buildAssetURLs(asset)
 
-		assets = append(assets, asset)
+		assets = append(assets, *asset)
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"assets": assets,
-		"total":  len(assets),
+	respondJSON(w, http.StatusOK, AssetListResponse{
+		Assets: assets,
+		Total:  len(assets),
 	})
 }
 
+// maxListLimit bounds the page size of ListAssets
+const maxListLimit = 500
+
+// parseIntParam parses an optional integer query parameter within [min, max],
+// writing a 400 response and returning false when it is invalid
+func parseIntParam(w http.ResponseWriter, r *http.Request, value string, def, min, max int, name string) (int, bool) {
+	if value == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Invalid %s. Must be between %d and %d", name, min, max))
+		return 0, false
+	}
+	return n, true
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
 // DeleteAsset handles DELETE /v1/media/:assetId
 func (h *Handler) DeleteAsset(w http.ResponseWriter, r *http.Request) {
 	assetIDStr := chi.URLParam(r, "assetId")
 	assetID, err := uuid.Parse(assetIDStr)
 	if err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid asset ID")
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid asset ID")
 		return
 	}
 
 	ctx := context.Background()
 
 	// Get asset info for deletion
-	var bucket, objectKey string
-	err = h.db.Pool().QueryRow(ctx, "SELECT bucket, object_key FROM assets WHERE id = $1", assetID).Scan(&bucket, &objectKey)
+	asset, err := h.loadAsset(ctx, assetID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		respondError(w, r, http.StatusNotFound, CodeAssetNotFound, "Asset not found")
+		return
+	}
 	if err != nil {
-		respondError(w, http.StatusNotFound, "Asset not found")
+		log.Error().Err(err).Str("assetId", assetIDStr).Msg("Failed to get asset")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to delete asset")
 		return
 	}
 
 	// Delete from storage
-	err = h.storage.DeleteObject(ctx, bucket, objectKey)
+	err = h.storage.DeleteObject(ctx, asset.Bucket, asset.ObjectKey)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to delete object from storage")
 		// Continue with database deletion even if storage deletion fails
 	}
 
-	// Delete from database (cascades to related tables)
-	_, err = h.db.Pool().Exec(ctx, "DELETE FROM assets WHERE id = $1", assetID)
+	// Delete from database (cascades to related tables) and leave a tombstone
+	tx, err := h.db.Pool().Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to begin transaction")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to delete asset")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, "DELETE FROM assets WHERE id = $1", assetID)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to delete asset from database")
-		respondError(w, http.StatusInternalServerError, "Failed to delete asset")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to delete asset")
 		return
 	}
 
+	if result.RowsAffected() > 0 {
+		if err := h.recordChange(ctx, tx, ChangeDelete, assetID); err != nil {
+			log.Error().Err(err).Msg("Failed to record asset deletion")
+			respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to delete asset")
+			return
+		}
+
+		if err := emitEvent(ctx, tx, EventAssetDeleted, assetID, assetEventData{AssetResponse: asset}); err != nil {
+			log.Error().Err(err).Msg("Failed to emit asset deletion")
+			respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to delete asset")
+			return
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to commit asset deletion")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to delete asset")
+		return
+	}
+
+	h.publishState(ctx, assetID, "deleted")
+	h.invalidateImages(ctx, asset.Bucket, asset.ObjectKey)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// buildAssetURLs constructs URLs for an asset
-func (h *Handler) buildAssetURLs(assetID, kind, state string) map[string]interface{} {
+// buildAssetURLs constructs URLs for an asset.
+// Private assets get short-lived signed URLs; public assets get permanent ones.
+func (h *Handler) buildAssetURLs(asset *AssetResponse) map[string]interface{} {
 	urls := make(map[string]interface{})
 
-	if state != "ready" {
+	if asset.State != "ready" {
 		return urls
 	}
 
-	switch kind {
+	var expiresAt int64
+	if asset.Visibility == VisibilityPrivate {
+		expiresAt = time.Now().Add(h.cfg.Delivery.SignedURLTTL).Unix()
+		urls["expiresAt"] = time.Unix(expiresAt, 0).UTC()
+	}
+
+	urls["original"] = h.deliveryURL(asset.ID, "original", expiresAt)
+	urls["download"] = h.deliveryURL(asset.ID, "download", expiresAt)
+
+	switch asset.Kind {
 	case "image":
-		// Provide a helper function for signing image URLs
-		urls["original"] = fmt.Sprintf("%s/v1/media/%s/original", h.cfg.PublicImgProxyURL, assetID)
-		urls["thumbnail"] = h.signImageURL(assetID, "rs:fit:400:400/q:80/f:webp")
-		urls["signedImage"] = "Call /v1/image endpoint with operations"
+		urls["thumbnail"] = h.signImageURL(asset, "rs:fit:400:400/q:80/f:webp", expiresAt)
+
+		// Named presets resolve to imgproxy URLs when requested
+		presets := make(map[string]string, len(h.cfg.ImgProxy.Presets))
+		for name := range h.cfg.ImgProxy.Presets {
+			presets[name] = h.deliveryURL(asset.ID, "image/"+name, expiresAt)
+		}
+		urls["presets"] = presets
 
 	case "video":
+		if expiresAt > 0 {
+			// Private videos are served through the API, which checks the token
+			// and hands out presigned segment URLs; their renditions are not
+			// under the public prefix of the buckets
+			urls["hls"] = h.deliverySigner.SignURL(
+				fmt.Sprintf("%s/v1/video/%s/master.m3u8", h.cfg.PublicAPIURL, asset.ID), asset.ID, expiresAt)
+			urls["poster"] = h.deliverySigner.SignURL(
+				fmt.Sprintf("%s/v1/video/%s/poster.jpg", h.cfg.PublicAPIURL, asset.ID), asset.ID, expiresAt)
+			break
+		}
+
 		// HLS manifest path: {PUBLIC_VOD_URL}/{assetId}/hls/master.m3u8
-		// The addprefix middleware on the VOD endpoint adds /media-vod prefix
-		urls["hls"] = fmt.Sprintf("%s/%s/hls/master.m3u8", h.cfg.PublicVODURL, assetID)
-		urls["poster"] = fmt.Sprintf("%s/%s/poster.jpg", h.cfg.PublicThumbsURL, assetID)
+		// The addprefix middleware on the VOD endpoint adds the /media-vod/public prefix
+		urls["hls"] = fmt.Sprintf("%s/%s/hls/master.m3u8", h.cfg.PublicVODURL, asset.ID)
+		urls["poster"] = fmt.Sprintf("%s/%s/poster.jpg", h.cfg.PublicThumbsURL, asset.ID)
 	}
 
 	return urls
 }
 
-// signImageURL creates a signed imgproxy URL for an asset
-func (h *Handler) signImageURL(assetID, operations string) string {
-	sourceURL := fmt.Sprintf("s3://media-originals/%s", assetID)
-	signedPath := h.imgproxySigner.SignURL(operations, sourceURL)
-	return fmt.Sprintf("%s%s", h.cfg.PublicImgProxyURL, signedPath)
+// moveRenditions moves the HLS files and poster of a video to the location
+// for its new visibility
+func (h *Handler) moveRenditions(ctx context.Context, assetID string, public bool) error {
+	from, to := storage.RenditionPrefix(assetID, !public), storage.RenditionPrefix(assetID, public)
+	cfg := h.storage.GetConfig()
+	for _, bucket := range []string{cfg.BucketVOD, cfg.BucketThumbs} {
+		if err := h.storage.MovePrefix(ctx, bucket, from, to); err != nil {
+			return fmt.Errorf("%s: %w", bucket, err)
+		}
+	}
+	return nil
+}
+
+// deliveryURL builds an API delivery URL for an asset, signed when expiresAt is set
+func (h *Handler) deliveryURL(assetID, endpoint string, expiresAt int64) string {
+	rawURL := fmt.Sprintf("%s/v1/media/%s/%s", h.cfg.PublicAPIURL, assetID, endpoint)
+	if expiresAt > 0 {
+		return h.deliverySigner.SignURL(rawURL, assetID, expiresAt)
+	}
+	return rawURL
+}
+
+// signImageURL creates a signed imgproxy URL for an asset, expiring when expiresAt is set
+func (h *Handler) signImageURL(asset *AssetResponse, operations string, expiresAt int64) string {
+	return h.cfg.PublicImgProxyURL + h.signedImagePath(asset, operations, expiresAt)
 }
 
 // Helper functions
@@ -386,7 +805,3 @@ func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(data)
 }
-
-func respondError(w http.ResponseWriter, status int, message string) {
-	respondJSON(w, status, map[string]string{"error": message})
-}
@@ -0,0 +1,56 @@
+package api
+
+import "time"
+
+// ImageMetadata is what the worker read from the EXIF, IPTC and XMP
+// metadata of an image. Descriptive text prefers XMP, then IPTC, then EXIF.
+type ImageMetadata struct {
+	Camera   *Camera   `json:"camera,omitempty"`
+	Lens     string    `json:"lens,omitempty"`
+	Exposure *Exposure `json:"exposure,omitempty"`
+	// CapturedAt is when the photo was taken; times recorded without an
+	// offset are taken as UTC
+	CapturedAt *time.Time `json:"capturedAt,omitempty"`
+	// Orientation is the EXIF orientation (1-8); 1 is upright
+	Orientation int      `json:"orientation,omitempty"`
+	GPS         *GPS     `json:"gps,omitempty"`
+	Artist      string   `json:"artist,omitempty"`
+	Copyright   string   `json:"copyright,omitempty"`
+	Title       string   `json:"title,omitempty"`
+	Headline    string   `json:"headline,omitempty"`
+	Caption     string   `json:"caption,omitempty"`
+	Keywords    []string `json:"keywords,omitempty"`
+}
+
+// Camera is the make and model of the capturing device
+type Camera struct {
+	Make  string `json:"make,omitempty"`
+	Model string `json:"model,omitempty"`
+}
+
+// Exposure is the capture settings of a photo
+type Exposure struct {
+	FNumber      float64 `json:"fNumber,omitempty"`
+	ExposureTime string  `json:"exposureTime,omitempty" doc:"Seconds, e.g. 1/250"`
+	ISO          int     `json:"iso,omitempty"`
+	FocalLength  float64 `json:"focalLength,omitempty" doc:"Millimeters"`
+}
+
+// GPS is where a photo was taken, in decimal degrees and meters
+type GPS struct {
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Altitude  *float64 `json:"altitude,omitempty"`
+}
+
+// ImageInfo is what the worker read from the header of an image before
+// marking it ready
+type ImageInfo struct {
+	Format       string `json:"format" enum:"jpeg,png,gif,webp,avif,heic,tiff,bmp"`
+	ColorSpace   string `json:"colorSpace,omitempty" enum:"rgb,gray,cmyk"`
+	ColorProfile string `json:"colorProfile,omitempty" doc:"Embedded ICC profile or declared color space, e.g. Display P3"`
+	BitDepth     int    `json:"bitDepth,omitempty" doc:"Bits per channel"`
+	HasAlpha     bool   `json:"hasAlpha"`
+	// FrameCount is above 1 for animations and multi-page TIFFs
+	FrameCount int `json:"frameCount,omitempty" doc:"Frames of animations or pages of TIFFs; omitted when unknown"`
+}
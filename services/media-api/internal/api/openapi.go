@@ -0,0 +1,464 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ancill/mediapod/services/media-api/internal/openapi"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxRequestBodySize limits JSON request bodies
+const maxRequestBodySize = 1 << 20
+
+// schemas holds the schemas of every type in apiRoutes. Request bodies are
+// validated against the same schemas the OpenAPI document publishes.
+var schemas = openapi.NewRegistry()
+
+func queryParam(name, typ, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: typ}}
+}
+
+// deliveryParams authorize access to private assets (see buildAssetURLs)
+var deliveryParams = []openapi.Parameter{
+	queryParam("exp", "integer", "Expiry of a signed URL (Unix time); required for private assets"),
+	queryParam("token", "string", "Signature of a signed URL; required for private assets"),
+}
+
+func errorResponses(statuses ...int) []openapi.ResponseSpec {
+	specs := make([]openapi.ResponseSpec, len(statuses))
+	for i, status := range statuses {
+		specs[i] = openapi.ResponseSpec{Status: status, Body: Problem{}, ContentType: ProblemContentType}
+	}
+	return specs
+}
+
+func responses(specs ...[]openapi.ResponseSpec) []openapi.ResponseSpec {
+	var all []openapi.ResponseSpec
+	for _, s := range specs {
+		all = append(all, s...)
+	}
+	return all
+}
+
+func okResponse(body interface{}) []openapi.ResponseSpec {
+	return []openapi.ResponseSpec{{Status: http.StatusOK, Body: body}}
+}
+
+// apiKeyAuth marks the routes NewRouter puts behind RequireAPIKey
+var apiKeyAuth = []string{"apiKey"}
+
+// apiRoutes documents every /v1 route registered by NewRouter. Paths are
+// relative to /v1; CheckRoutes reports drift between the two.
+var apiRoutes = []openapi.Route{
+	{
+		Method: http.MethodPost, Path: "/media/init-upload", ID: "initUpload", Tag: "media",
+		Security:  apiKeyAuth,
+		Summary:   "Create an asset and get a presigned upload URL",
+		Request:   InitUploadRequest{},
+		Responses: responses(okResponse(InitUploadResponse{}), errorResponses(400)),
+	},
+	{
+		Method: http.MethodPost, Path: "/media/complete", ID: "completeUpload", Tag: "media",
+		Security:  apiKeyAuth,
+		Summary:   "Mark an upload as complete and start processing",
+		Request:   CompleteUploadRequest{},
+		Responses: responses(okResponse(CompleteUploadResponse{}), errorResponses(400, 404, 409)),
+	},
+	{
+		Method: http.MethodGet, Path: "/media", ID: "listAssets", Tag: "media",
+		Security: apiKeyAuth,
+		Summary:  "List assets, newest first",
+		Params: []openapi.Parameter{
+			queryParam("kind", "string", "Filter by kind"),
+			queryParam("state", "string", "Filter by state"),
+			queryParam("visibility", "string", "Filter by visibility"),
+			queryParam("q", "string", "Filter by a filename substring"),
+			queryParam("tag", "string", "Filter by tag"),
+			queryParam("color", "string", "Filter by a palette color near this hex color (rrggbb)"),
+			queryParam("colorDistance", "integer", "Maximum CIE76 delta-E from color (default 15, max 100)"),
+			queryParam("sort", "string", "created (default) or captured: newest capture time first"),
+			queryParam("limit", "integer", "Maximum number of assets (default 50, max 500)"),
+			queryParam("offset", "integer", "Number of assets to skip"),
+		},
+		Responses: responses(okResponse(AssetListResponse{}), errorResponses(400)),
+	},
+	{
+		Method: http.MethodGet, Path: "/media/{assetId}", ID: "getAsset", Tag: "media",
+		Security:  apiKeyAuth,
+		Summary:   "Get an asset with its delivery URLs",
+		Responses: responses(okResponse(AssetResponse{}), errorResponses(400, 404)),
+	},
+	{
+		Method: http.MethodPatch, Path: "/media/{assetId}", ID: "updateAsset", Tag: "media",
+		Security:  apiKeyAuth,
+		Summary:   "Update an asset",
+		Request:   UpdateAssetRequest{},
+		Responses: responses(okResponse(AssetResponse{}), errorResponses(400, 404)),
+	},
+	{
+		Method: http.MethodDelete, Path: "/media/{assetId}", ID: "deleteAsset", Tag: "media",
+		Security:  apiKeyAuth,
+		Summary:   "Delete an asset and its original",
+		Responses: responses([]openapi.ResponseSpec{{Status: http.StatusNoContent}}, errorResponses(400, 404)),
+	},
+	{
+		Method: http.MethodGet, Path: "/media/{assetId}/jobs", ID: "listAssetJobs", Tag: "media",
+		Security:  apiKeyAuth,
+		Summary:   "List the processing jobs of an asset",
+		Responses: responses(okResponse(JobListResponse{}), errorResponses(400, 404)),
+	},
+	{
+		Method: http.MethodPost, Path: "/media/{assetId}/reprocess", ID: "reprocessAsset", Tag: "media",
+		Security: apiKeyAuth,
+		Summary:  "Queue the processing of an asset again",
+		Responses: responses([]openapi.ResponseSpec{
+			{Status: http.StatusAccepted, Body: ReprocessResponse{}},
+		}, errorResponses(400, 404, 409)),
+	},
+	{
+		Method: http.MethodGet, Path: "/media/{assetId}/image/{preset}", ID: "getImagePreset", Tag: "image",
+		Summary:     "Get an image rendered with a named preset",
+		Description: "Presets are configured with IMAGE_PRESETS; an asset's urls.presets lists them.",
+		Params:      append([]openapi.Parameter{queryParam("redirect", "boolean", "Redirect to imgproxy instead of proxying the image")}, deliveryParams...),
+		Responses: responses([]openapi.ResponseSpec{
+			{Status: http.StatusOK, ContentType: "image/*"},
+			{Status: http.StatusFound, Description: "Redirect to the rendered image"},
+		}, errorResponses(400, 403, 404, 409, 502)),
+	},
+	{
+		Method: http.MethodPost, Path: "/media/{assetId}/image-url", ID: "createImageURL", Tag: "image",
+		Security:    apiKeyAuth,
+		Summary:     "Sign a URL for a custom image transformation",
+		Description: "URLs of private assets always expire, after SIGNED_URL_TTL at most.",
+		Request:     ImageURLRequest{},
+		Responses:   responses(okResponse(ImageURLResponse{}), errorResponses(400, 404, 409)),
+	},
+	{
+		Method: http.MethodGet, Path: "/media/{assetId}/srcset", ID: "getSrcset", Tag: "image",
+		Summary: "Get a responsive image set with srcset strings and <picture> sources",
+		Params: []openapi.Parameter{
+			queryParam("widths", "string", "Comma-separated widths (default IMAGE_SRCSET_WIDTHS); wider than the original are dropped"),
+			queryParam("formats", "string", "Comma-separated formats in order of preference; the last is the <img> fallback"),
+			queryParam("sizes", "string", "Value of the sizes attribute (default 100vw)"),
+			queryParam("quality", "integer", "Output quality, 1-100"),
+			queryParam("aspect", "string", "Crop to an aspect ratio (width:height, e.g. 16:9), following the image's focus"),
+		},
+		Responses: responses(okResponse(SrcsetResponse{}), errorResponses(400, 404, 409)),
+	},
+	{
+		Method: http.MethodPut, Path: "/media/{assetId}/focus", ID: "setFocus", Tag: "image",
+		Security:    apiKeyAuth,
+		Summary:     "Set the focal point and crop hints of an image",
+		Description: "Crops and fill resizes of presets, srcsets and image-url then keep the focal point, or use a crop hint of the output's aspect ratio. Omitted fields are cleared.",
+		Request:     FocusRequest{},
+		Responses:   responses(okResponse(AssetResponse{}), errorResponses(400, 404, 409)),
+	},
+	{
+		Method: http.MethodGet, Path: "/media/{assetId}/original", ID: "getOriginal", Tag: "media",
+		Summary: "Get the original file, inline for images, videos and audio",
+		Params:  append([]openapi.Parameter{queryParam("redirect", "boolean", "Redirect to a presigned storage URL instead of streaming")}, deliveryParams...),
+		Responses: responses([]openapi.ResponseSpec{
+			{Status: http.StatusOK, ContentType: "application/octet-stream"},
+			{Status: http.StatusPartialContent, ContentType: "application/octet-stream"},
+			{Status: http.StatusFound, Description: "Redirect to a presigned storage URL"},
+		}, errorResponses(400, 403, 404, 409)),
+	},
+	{
+		Method: http.MethodGet, Path: "/media/{assetId}/download", ID: "downloadOriginal", Tag: "media",
+		Summary: "Download the original file as an attachment",
+		Params:  append([]openapi.Parameter{queryParam("redirect", "boolean", "Redirect to a presigned storage URL instead of streaming")}, deliveryParams...),
+		Responses: responses([]openapi.ResponseSpec{
+			{Status: http.StatusOK, ContentType: "application/octet-stream"},
+			{Status: http.StatusPartialContent, ContentType: "application/octet-stream"},
+			{Status: http.StatusFound, Description: "Redirect to a presigned storage URL"},
+		}, errorResponses(400, 403, 404, 409)),
+	},
+	{
+		Method: http.MethodGet, Path: "/media/{assetId}/events", ID: "streamAssetEvents", Tag: "media",
+		Summary:     "Stream state changes and processing progress",
+		Description: "Server-Sent Events; resume with the Last-Event-ID header.",
+		Params:      append([]openapi.Parameter{queryParam("lastEventId", "string", "Resume after this event")}, deliveryParams...),
+		Responses: responses([]openapi.ResponseSpec{
+			{Status: http.StatusOK, Body: eventSchema{}, ContentType: "text/event-stream"},
+		}, errorResponses(400, 403, 404)),
+	},
+	{
+		Method: http.MethodGet, Path: "/media/{assetId}/events/ws", ID: "streamAssetEventsWS", Tag: "media",
+		Summary:     "Stream state changes and processing progress over WebSocket",
+		Description: "Each message is a JSON event as sent by streamAssetEvents.",
+		Params:      append([]openapi.Parameter{queryParam("lastEventId", "string", "Resume after this event")}, deliveryParams...),
+		Responses: responses([]openapi.ResponseSpec{
+			{Status: http.StatusSwitchingProtocols, Description: "WebSocket connection established"},
+		}, errorResponses(400, 403, 404)),
+	},
+	{
+		Method: http.MethodGet, Path: "/image/{signature}/{path}", ID: "proxyImage", Tag: "image",
+		Summary:     "Get a transformed image",
+		Description: "path is an imgproxy option chain followed by the source and may contain slashes; signature, options and source are checked before proxying.",
+		Responses: responses([]openapi.ResponseSpec{
+			{Status: http.StatusOK, ContentType: "image/*"},
+		}, errorResponses(400, 403, 404, 502)),
+	},
+	{
+		Method: http.MethodGet, Path: "/video/{assetId}/master.m3u8", ID: "getVideoManifest", Tag: "video",
+		Summary: "Get the HLS master playlist of a video",
+		Params:  deliveryParams,
+		Responses: responses([]openapi.ResponseSpec{
+			{Status: http.StatusOK, ContentType: "application/vnd.apple.mpegurl"},
+		}, errorResponses(400, 403, 404, 409)),
+	},
+	{
+		Method: http.MethodGet, Path: "/video/{assetId}/{variant}/playlist.m3u8", ID: "getVideoPlaylist", Tag: "video",
+		Summary: "Get the HLS playlist of a video variant",
+		Params:  deliveryParams,
+		Responses: responses([]openapi.ResponseSpec{
+			{Status: http.StatusOK, ContentType: "application/vnd.apple.mpegurl"},
+		}, errorResponses(400, 403, 404, 409)),
+	},
+	{
+		Method: http.MethodGet, Path: "/video/{assetId}/poster.jpg", ID: "getVideoPoster", Tag: "video",
+		Summary: "Get the poster image of a video",
+		Params:  deliveryParams,
+		Responses: responses([]openapi.ResponseSpec{
+			{Status: http.StatusFound, Description: "Redirect to the poster image"},
+		}, errorResponses(400, 403, 404, 409)),
+	},
+	{
+		Method: http.MethodPost, Path: "/webhooks", ID: "createWebhook", Tag: "webhooks",
+		Security: apiKeyAuth,
+		Summary:  "Subscribe a webhook to asset events",
+		Request:  CreateWebhookRequest{},
+		Responses: responses([]openapi.ResponseSpec{
+			{Status: http.StatusCreated, Body: WebhookResponse{}},
+		}, errorResponses(400)),
+	},
+	{
+		Method: http.MethodGet, Path: "/webhooks", ID: "listWebhooks", Tag: "webhooks",
+		Security:  apiKeyAuth,
+		Summary:   "List webhook subscriptions",
+		Responses: okResponse(WebhookListResponse{}),
+	},
+	{
+		Method: http.MethodGet, Path: "/webhooks/{webhookId}", ID: "getWebhook", Tag: "webhooks",
+		Security:  apiKeyAuth,
+		Summary:   "Get a webhook subscription",
+		Responses: responses(okResponse(WebhookResponse{}), errorResponses(400, 404)),
+	},
+	{
+		Method: http.MethodPatch, Path: "/webhooks/{webhookId}", ID: "updateWebhook", Tag: "webhooks",
+		Security:  apiKeyAuth,
+		Summary:   "Update a webhook subscription",
+		Request:   UpdateWebhookRequest{},
+		Responses: responses(okResponse(WebhookResponse{}), errorResponses(400, 404)),
+	},
+	{
+		Method: http.MethodDelete, Path: "/webhooks/{webhookId}", ID: "deleteWebhook", Tag: "webhooks",
+		Security:  apiKeyAuth,
+		Summary:   "Delete a webhook subscription",
+		Responses: responses([]openapi.ResponseSpec{{Status: http.StatusNoContent}}, errorResponses(400, 404)),
+	},
+	{
+		Method: http.MethodGet, Path: "/webhooks/{webhookId}/deliveries", ID: "listWebhookDeliveries", Tag: "webhooks",
+		Security: apiKeyAuth,
+		Summary:  "List recent deliveries of a webhook",
+		Params: []openapi.Parameter{
+			queryParam("state", "string", "Filter by state: pending, delivered or failed"),
+			queryParam("limit", "integer", "Maximum number of deliveries (default 50)"),
+		},
+		Responses: responses(okResponse(WebhookDeliveryListResponse{}), errorResponses(400, 404)),
+	},
+	{
+		Method: http.MethodPost, Path: "/keys", ID: "createAPIKey", Tag: "keys",
+		Security: apiKeyAuth,
+		Summary:  "Create an API key",
+		Request:  CreateAPIKeyRequest{},
+		Responses: []openapi.ResponseSpec{
+			{Status: http.StatusCreated, Body: APIKeyResponse{}},
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/keys", ID: "listAPIKeys", Tag: "keys",
+		Security:  apiKeyAuth,
+		Summary:   "List API keys",
+		Responses: okResponse(APIKeyListResponse{}),
+	},
+	{
+		Method: http.MethodDelete, Path: "/keys/{keyId}", ID: "deleteAPIKey", Tag: "keys",
+		Security:  apiKeyAuth,
+		Summary:   "Revoke an API key",
+		Responses: responses([]openapi.ResponseSpec{{Status: http.StatusNoContent}}, errorResponses(400, 404)),
+	},
+	{
+		Method: http.MethodGet, Path: "/changes", ID: "listChanges", Tag: "changes",
+		Security: apiKeyAuth,
+		Summary:  "Read the catalog changefeed",
+		Params: []openapi.Parameter{
+			queryParam("since", "string", "Cursor to read after; omit to start from the beginning"),
+			queryParam("limit", "integer", "Maximum number of changes (default 100, max 1000)"),
+		},
+		Responses: responses(okResponse(ChangesResponse{}), errorResponses(400)),
+	},
+	{
+		Method: http.MethodGet, Path: "/openapi.json", ID: "getOpenAPI", Tag: "meta",
+		Summary: "Get this OpenAPI document",
+		Responses: []openapi.ResponseSpec{
+			{Status: http.StatusOK, ContentType: "application/json"},
+		},
+	},
+}
+
+// eventSchema documents the JSON data of streamed asset events
+type eventSchema struct {
+	ID       string   `json:"id,omitempty"`
+	AssetID  string   `json:"assetId"`
+	Type     string   `json:"type" enum:"state,progress"`
+	State    string   `json:"state,omitempty"`
+	Progress *float64 `json:"progress,omitempty"`
+	Message  string   `json:"message,omitempty"`
+	At       string   `json:"at" format:"date-time"`
+}
+
+// idempotencyParam is accepted by every mutating route (see Idempotency)
+var idempotencyParam = openapi.Parameter{
+	Name:        IdempotencyHeader,
+	In:          "header",
+	Description: "Makes the request safe to retry: the first response for a key is replayed for retries with the same body",
+	Schema:      &openapi.Schema{Type: "string"},
+}
+
+// withIdempotency adds the Idempotency-Key header and its error responses
+// to the mutating routes
+func withIdempotency(routes []openapi.Route) []openapi.Route {
+	result := make([]openapi.Route, len(routes))
+	for i, route := range routes {
+		if route.Method == http.MethodPost || route.Method == http.MethodPatch || route.Method == http.MethodDelete {
+			route.Params = append(append([]openapi.Parameter{}, route.Params...), idempotencyParam)
+			route.Responses = responses(route.Responses, errorResponses(http.StatusConflict, http.StatusUnprocessableEntity))
+		}
+		result[i] = route
+	}
+	return result
+}
+
+// withServerErrors adds the error responses any route can return: 500, and
+// 413 for routes with a request body
+func withServerErrors(routes []openapi.Route) []openapi.Route {
+	result := make([]openapi.Route, len(routes))
+	for i, route := range routes {
+		statuses := []int{http.StatusInternalServerError}
+		if route.Request != nil {
+			statuses = append(statuses, http.StatusRequestEntityTooLarge)
+		}
+		route.Responses = responses(route.Responses, errorResponses(statuses...))
+		result[i] = route
+	}
+	return result
+}
+
+// withAPIKeyErrors adds the 401 response to the routes that need an API key
+func withAPIKeyErrors(routes []openapi.Route) []openapi.Route {
+	result := make([]openapi.Route, len(routes))
+	for i, route := range routes {
+		if len(route.Security) > 0 {
+			route.Responses = responses(route.Responses, errorResponses(http.StatusUnauthorized))
+		}
+		result[i] = route
+	}
+	return result
+}
+
+// buildOpenAPI generates the OpenAPI document served at /v1/openapi.json
+func (h *Handler) buildOpenAPI() ([]byte, error) {
+	doc := openapi.Build(openapi.Info{
+		Title:       "Mediapod API",
+		Version:     "1.0.0",
+		Description: "Upload, process and deliver images and videos.",
+	}, []openapi.Server{{URL: strings.TrimSuffix(h.cfg.PublicAPIURL, "/") + "/v1"}}, withServerErrors(withAPIKeyErrors(withIdempotency(apiRoutes))), schemas)
+	doc.Components.SecuritySchemes = map[string]*openapi.SecurityScheme{
+		"apiKey": {
+			Type:        "http",
+			Scheme:      "bearer",
+			Description: "API key created with POST /keys; only enforced when API_KEYS_REQUIRED is set",
+		},
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// GetOpenAPI handles GET /v1/openapi.json
+func (h *Handler) GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Write(h.openapi)
+}
+
+// decodeJSON reads a JSON request body into v after validating it against
+// the schema of v's type. It writes a 400 response with field-level details
+// and returns false when the body is invalid.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			respondError(w, r, http.StatusRequestEntityTooLarge, CodeRequestTooLarge, "Request body too large")
+			return false
+		}
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body")
+		return false
+	}
+
+	fieldErrs, err := schemas.Validate(reflect.TypeOf(v).Elem(), body)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body: malformed JSON")
+		return false
+	}
+	if len(fieldErrs) > 0 {
+		respondValidationError(w, r, fieldErrs)
+		return false
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body")
+		return false
+	}
+	return true
+}
+
+// CheckRoutes compares the /v1 routes registered on router with apiRoutes
+// and describes every route missing from either
+func CheckRoutes(router chi.Routes) []string {
+	documented := make(map[string]bool)
+	for _, route := range apiRoutes {
+		documented[route.Method+" "+route.Path] = true
+	}
+
+	var problems []string
+	chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		path, found := strings.CutPrefix(route, "/v1")
+		if !found || method == http.MethodOptions || method == http.MethodHead {
+			return nil
+		}
+		// chi wildcards are documented as a {path} parameter
+		path = strings.TrimSuffix(strings.Replace(path, "/*", "/{path}", 1), "/")
+
+		key := method + " " + path
+		if documented[key] {
+			delete(documented, key)
+		} else {
+			problems = append(problems, "undocumented route: "+key)
+		}
+		return nil
+	})
+	for key := range documented {
+		problems = append(problems, "documented route not registered: "+key)
+	}
+
+	sort.Strings(problems)
+	return problems
+}
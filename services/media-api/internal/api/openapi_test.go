@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ancill/mediapod/services/media-api/internal/config"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRoutesMatchOpenAPI(t *testing.T) {
+	for _, problem := range CheckRoutes(NewRouter(&Handler{})) {
+		t.Error(problem)
+	}
+}
+
+func TestCheckRoutesReportsDrift(t *testing.T) {
+	r := chi.NewRouter()
+	r.Route("/v1", func(r chi.Router) {
+		r.Get("/media/{assetId}", func(http.ResponseWriter, *http.Request) {})
+		r.Get("/undocumented", func(http.ResponseWriter, *http.Request) {})
+	})
+
+	problems := CheckRoutes(r)
+	if !contains(problems, "undocumented route: GET /undocumented") {
+		t.Errorf("undocumented route not reported: %v", problems)
+	}
+	if !contains(problems, "documented route not registered: POST /media/init-upload") {
+		t.Errorf("unregistered route not reported: %v", problems)
+	}
+	if contains(problems, "documented route not registered: GET /media/{assetId}") {
+		t.Errorf("registered route reported: %v", problems)
+	}
+}
+
+// TestAPIKeyRoutes checks that the routes documented with apiKeyAuth are
+// the ones NewRouter puts behind RequireAPIKey
+func TestAPIKeyRoutes(t *testing.T) {
+	router := NewRouter(&Handler{cfg: &config.Config{APIKeysRequired: true}})
+	pathParam := regexp.MustCompile(`\{[^}]+\}`)
+
+	for _, route := range apiRoutes {
+		if len(route.Security) == 0 {
+			continue
+		}
+		path := "/v1" + pathParam.ReplaceAllString(route.Path, "00000000-0000-0000-0000-000000000000")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(route.Method, path, strings.NewReader("{}")))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s without an API key: status %d, want 401", route.Method, route.Path, rec.Code)
+		}
+	}
+
+	// No other route runs RequireAPIKey
+	documented := make(map[string]bool)
+	for _, route := range apiRoutes {
+		documented[route.Method+" "+route.Path] = len(route.Security) > 0
+	}
+	requireAPIKey := reflect.ValueOf((&Handler{}).RequireAPIKey).Pointer()
+	chi.Walk(router, func(method, route string, _ http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		path, found := strings.CutPrefix(route, "/v1")
+		if !found {
+			return nil
+		}
+		path = strings.Replace(path, "/*", "/{path}", 1)
+		secured := false
+		for _, mw := range middlewares {
+			secured = secured || reflect.ValueOf(mw).Pointer() == requireAPIKey
+		}
+		if secured != documented[method+" "+path] {
+			t.Errorf("%s %s: RequireAPIKey %v, documented %v", method, path, secured, documented[method+" "+path])
+		}
+		return nil
+	})
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	h := &Handler{cfg: &config.Config{PublicAPIURL: "https://media.example.com/"}}
+	data, err := h.buildOpenAPI()
+	if err != nil {
+		t.Fatalf("buildOpenAPI: %v", err)
+	}
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Servers []struct {
+			URL string `json:"url"`
+		} `json:"servers"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("document is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("openapi = %q, want 3.x", doc.OpenAPI)
+	}
+	if len(doc.Servers) != 1 || doc.Servers[0].URL != "https://media.example.com/v1" {
+		t.Errorf("servers = %+v, want https://media.example.com/v1", doc.Servers)
+	}
+
+	ids := make(map[string]bool)
+	for _, route := range apiRoutes {
+		raw, ok := doc.Paths[route.Path][strings.ToLower(route.Method)]
+		if !ok {
+			t.Errorf("%s %s missing from the document", route.Method, route.Path)
+			continue
+		}
+		var op struct {
+			OperationID string                     `json:"operationId"`
+			Responses   map[string]json.RawMessage `json:"responses"`
+		}
+		if err := json.Unmarshal(raw, &op); err != nil {
+			t.Fatalf("%s %s: %v", route.Method, route.Path, err)
+		}
+		if op.OperationID != route.ID {
+			t.Errorf("%s %s: operationId = %q, want %q", route.Method, route.Path, op.OperationID, route.ID)
+		}
+		if ids[route.ID] {
+			t.Errorf("duplicate operationId %q", route.ID)
+		}
+		ids[route.ID] = true
+		if len(op.Responses) == 0 {
+			t.Errorf("%s %s has no responses", route.Method, route.Path)
+		}
+	}
+
+	// Every schema reference resolves to a component
+	for _, ref := range strings.Split(string(data), `"$ref": "`)[1:] {
+		name, _, _ := strings.Cut(ref, `"`)
+		name, found := strings.CutPrefix(name, "#/components/schemas/")
+		if !found {
+			t.Errorf("unexpected reference %q", name)
+			continue
+		}
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("reference to undefined schema %q", name)
+		}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
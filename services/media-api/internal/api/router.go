@@ -0,0 +1,107 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/cors"
+)
+
+// NewRouter registers every route of the API. The /v1 routes must be listed
+// in apiRoutes as well; CheckRoutes reports drift between the two.
+func NewRouter(h *Handler) chi.Router {
+	r := chi.NewRouter()
+
+	// Middleware
+	r.Use(middleware.RequestID)
+	r.Use(middleware.RealIP)
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+
+	// CORS
+	r.Use(cors.Handler(cors.Options{
+		AllowedOrigins:   []string{"*"}, // Configure this properly in production
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Range", "If-None-Match", "Last-Event-ID", "Idempotency-Key"},
+		ExposedHeaders:   []string{"Link", "Content-Disposition", "Content-Range", "Accept-Ranges", "ETag", "Idempotent-Replayed", "X-Cache", "X-Cache-Tier"},
+		AllowCredentials: true,
+		MaxAge:           300,
+	}))
+
+	// Health check
+	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	})
+
+	r.NotFound(NotFound)
+	r.MethodNotAllowed(MethodNotAllowed)
+
+	// API routes
+	r.Route("/v1", func(r chi.Router) {
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Timeout(60 * time.Second))
+
+			// Management routes, which need an API key when API_KEYS_REQUIRED
+			// is set. Keys are checked first so Idempotency never replays a
+			// response to an unauthenticated request.
+			r.Group(func(r chi.Router) {
+				r.Use(h.RequireAPIKey)
+				r.Use(h.Idempotency)
+
+				// Media endpoints
+				r.Post("/media/init-upload", h.InitUpload)
+				r.Post("/media/complete", h.CompleteUpload)
+				r.Get("/media/{assetId}", h.GetAsset)
+				r.Patch("/media/{assetId}", h.UpdateAsset)
+				r.Get("/media", h.ListAssets)
+				r.Delete("/media/{assetId}", h.DeleteAsset)
+				r.Get("/media/{assetId}/jobs", h.ListAssetJobs)
+				r.Post("/media/{assetId}/reprocess", h.ReprocessAsset)
+				r.Post("/media/{assetId}/image-url", h.CreateImageURL)
+				r.Put("/media/{assetId}/focus", h.SetFocus)
+
+				// Webhook subscriptions
+				r.Post("/webhooks", h.CreateWebhook)
+				r.Get("/webhooks", h.ListWebhooks)
+				r.Get("/webhooks/{webhookId}", h.GetWebhook)
+				r.Patch("/webhooks/{webhookId}", h.UpdateWebhook)
+				r.Delete("/webhooks/{webhookId}", h.DeleteWebhook)
+				r.Get("/webhooks/{webhookId}/deliveries", h.ListWebhookDeliveries)
+
+				// API keys
+				r.Post("/keys", h.CreateAPIKey)
+				r.Get("/keys", h.ListAPIKeys)
+				r.Delete("/keys/{keyId}", h.DeleteAPIKey)
+
+				// Catalog changefeed
+				r.Get("/changes", h.ListChanges)
+			})
+
+			// Delivery routes, authorized by visibility and signed URLs
+			r.Get("/media/{assetId}/image/{preset}", h.GetImagePreset)
+			r.Get("/media/{assetId}/srcset", h.GetSrcset)
+
+			// Image transformation proxy
+			r.Get("/image/{signature}/*", h.ProxyImage)
+
+			// Video endpoints
+			r.Get("/video/{assetId}/master.m3u8", h.GetVideoManifest)
+			r.Get("/video/{assetId}/{variant}/playlist.m3u8", h.GetVideoPlaylist)
+			r.Get("/video/{assetId}/poster.jpg", h.GetVideoPoster)
+
+			// API description
+			r.Get("/openapi.json", h.GetOpenAPI)
+		})
+
+		// Long-running downloads and event streams (no request timeout)
+		r.Get("/media/{assetId}/original", h.GetOriginal)
+		r.Get("/media/{assetId}/download", h.DownloadOriginal)
+		r.Get("/media/{assetId}/events", h.StreamAssetEvents)
+		r.Get("/media/{assetId}/events/ws", h.StreamAssetEventsWS)
+	})
+
+	return r
+}
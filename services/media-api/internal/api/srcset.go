@@ -0,0 +1,262 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ancill/mediapod/services/media-api/internal/imgproxy"
+	"github.com/ancill/mediapod/services/media-api/internal/render"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	maxSrcsetWidths = 20
+	maxSizesLength  = 500
+)
+
+// SrcsetCandidate is one rendition of a responsive image set
+type SrcsetCandidate struct {
+	URL    string `json:"url"`
+	Format string `json:"format"`
+	Width  int    `json:"width"`
+	// Height is omitted when neither ?aspect= nor the dimensions of the
+	// original are known
+	Height int `json:"height,omitempty"`
+}
+
+// PictureSource describes a <source> element of a <picture>
+type PictureSource struct {
+	Type   string `json:"type"`
+	Srcset string `json:"srcset"`
+	Sizes  string `json:"sizes"`
+}
+
+// SrcsetResponse is a ready-made responsive image set. Sources are the
+// <source> elements in order of preference; Src, Srcset and Sizes belong on
+// the fallback <img>.
+type SrcsetResponse struct {
+	AssetID    string            `json:"assetId"`
+	Width      *int              `json:"width,omitempty" doc:"Width of the original, when known"`
+	Height     *int              `json:"height,omitempty" doc:"Height of the original, when known"`
+	Src        string            `json:"src"`
+	Srcset     string            `json:"srcset"`
+	Sizes      string            `json:"sizes"`
+	Sources    []PictureSource   `json:"sources"`
+	Candidates []SrcsetCandidate `json:"candidates"`
+	ExpiresAt  *time.Time        `json:"expiresAt,omitempty"`
+}
+
+// GetSrcset handles GET /v1/media/:assetId/srcset
+// Builds signed renditions of an image for every width and format, keeping
+// the aspect ratio and never exceeding the width of the original.
+// Defaults come from IMAGE_SRCSET_WIDTHS and IMAGE_SRCSET_FORMATS, less the
+// formats the renderer cannot encode; ?widths=, ?formats=, ?sizes= (default
+// 100vw) and ?quality= override them. ?aspect=
+// crops the renditions to an aspect ratio, following the focus of the image.
+func (h *Handler) GetSrcset(w http.ResponseWriter, r *http.Request) {
+	assetID, err := uuid.Parse(chi.URLParam(r, "assetId"))
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid asset ID")
+		return
+	}
+
+	query := r.URL.Query()
+
+	widths := h.cfg.ImgProxy.SrcsetWidths
+	if v := query.Get("widths"); v != "" {
+		widths, err = parseSrcsetWidths(v, h.cfg.ImgProxy.MaxDimension)
+		if err != nil {
+			respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid widths: "+err.Error())
+			return
+		}
+	}
+
+	formats := h.srcsetFormats()
+	if v := query.Get("formats"); v != "" {
+		formats = strings.Split(v, ",")
+		for _, format := range formats {
+			if !h.encodes(format) {
+				respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Unsupported format "+format)
+				return
+			}
+		}
+	}
+
+	sizes := query.Get("sizes")
+	if sizes == "" {
+		sizes = "100vw"
+	}
+	if len(sizes) > maxSizesLength {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("Invalid sizes. Must be at most %d characters", maxSizesLength))
+		return
+	}
+
+	quality, ok := parseIntParam(w, r, query.Get("quality"), 0, 1, 100, "quality")
+	if !ok {
+		return
+	}
+
+	var aspect float64
+	if v := query.Get("aspect"); v != "" {
+		if aspect, err = parseAspect(v); err != nil {
+			respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid aspect. Use width:height, e.g. 16:9")
+			return
+		}
+	}
+
+	asset, ok := h.readyImage(w, r, assetID)
+	if !ok {
+		return
+	}
+
+	response := SrcsetResponse{
+		AssetID:    asset.ID,
+		Width:      asset.Width,
+		Height:     asset.Height,
+		Sizes:      sizes,
+		Sources:    []PictureSource{},
+		Candidates: []SrcsetCandidate{},
+	}
+
+	var expiresAt int64
+	if asset.Visibility == VisibilityPrivate {
+		expiry := time.Now().Add(h.cfg.Delivery.SignedURLTTL).UTC().Truncate(time.Second)
+		expiresAt = expiry.Unix()
+		response.ExpiresAt = &expiry
+	}
+
+	widths = srcsetWidths(widths, asset.Width)
+
+	for i, format := range formats {
+		entries := make([]string, 0, len(widths))
+		for _, width := range widths {
+			ops := imgproxy.Operations{
+				Resize:  &imgproxy.ResizeOp{Type: "fit", Width: width},
+				Quality: quality,
+				Format:  format,
+			}
+			height := 0
+			switch {
+			case aspect > 0:
+				height = max(1, int(math.Round(float64(width)/aspect)))
+				ops.Resize = &imgproxy.ResizeOp{Type: "fill", Width: width, Height: height}
+				ops = withFocus(asset, ops)
+			case asset.Width != nil && asset.Height != nil && *asset.Width > 0:
+				height = int(math.Round(float64(width) * float64(*asset.Height) / float64(*asset.Width)))
+			}
+			candidate := SrcsetCandidate{
+				URL:    h.signImageURL(asset, ops.String(), expiresAt),
+				Format: format,
+				Width:  width,
+				Height: height,
+			}
+			response.Candidates = append(response.Candidates, candidate)
+			entries = append(entries, fmt.Sprintf("%s %dw", candidate.URL, width))
+		}
+		srcset := strings.Join(entries, ", ")
+
+		// The last format is the fallback every browser understands
+		if i == len(formats)-1 {
+			response.Srcset = srcset
+			response.Src = response.Candidates[len(response.Candidates)-1].URL
+			break
+		}
+		response.Sources = append(response.Sources, PictureSource{
+			Type:   imgproxy.FormatContentType(format),
+			Srcset: srcset,
+			Sizes:  sizes,
+		})
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// encodes reports whether the image renderer produces format. The built-in
+// renderer would serve JPEG in place of WebP and AVIF, which must not be
+// advertised under their types.
+func (h *Handler) encodes(format string) bool {
+	if imgproxy.FormatContentType(format) == "" {
+		return false
+	}
+	return h.renderer == nil || render.CanEncode(format)
+}
+
+// srcsetFormats returns the IMAGE_SRCSET_FORMATS the renderer produces,
+// falling back to JPEG when it produces none of them
+func (h *Handler) srcsetFormats() []string {
+	var formats []string
+	for _, format := range h.cfg.ImgProxy.SrcsetFormats {
+		if h.encodes(format) {
+			formats = append(formats, format)
+		}
+	}
+	if len(formats) == 0 {
+		return []string{"jpg"}
+	}
+	return formats
+}
+
+// srcsetWidths drops the widths that would upscale the original, offering the
+// original width as the largest candidate instead
+func srcsetWidths(widths []int, originalWidth *int) []int {
+	if originalWidth == nil || *originalWidth <= 0 {
+		return widths
+	}
+
+	var result []int
+	capped := false
+	for _, width := range widths {
+		if width < *originalWidth {
+			result = append(result, width)
+		} else {
+			capped = true
+		}
+	}
+	if capped {
+		result = append(result, *originalWidth)
+	}
+	return result
+}
+
+// parseAspect parses an aspect ratio given as width:height
+func parseAspect(value string) (float64, error) {
+	w, h, ok := strings.Cut(value, ":")
+	if !ok {
+		return 0, fmt.Errorf("missing height")
+	}
+	width, errW := strconv.ParseFloat(w, 64)
+	height, errH := strconv.ParseFloat(h, 64)
+	if errW != nil || errH != nil || width <= 0 || height <= 0 || math.IsInf(width/height, 0) {
+		return 0, fmt.Errorf("invalid aspect ratio %q", value)
+	}
+	return width / height, nil
+}
+
+// parseSrcsetWidths parses the ?widths= list of GetSrcset
+func parseSrcsetWidths(value string, maxDimension int) ([]int, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) > maxSrcsetWidths {
+		return nil, fmt.Errorf("at most %d are allowed", maxSrcsetWidths)
+	}
+
+	seen := make(map[int]bool)
+	var widths []int
+	for _, part := range parts {
+		width, err := strconv.Atoi(part)
+		if err != nil || width < 1 || width > maxDimension {
+			return nil, fmt.Errorf("%q must be between 1 and %d", part, maxDimension)
+		}
+		if !seen[width] {
+			seen[width] = true
+			widths = append(widths, width)
+		}
+	}
+	sort.Ints(widths)
+	return widths, nil
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ancill/mediapod/services/media-api/internal/config"
+	"github.com/ancill/mediapod/services/media-api/internal/imgproxy"
+	"github.com/ancill/mediapod/services/media-api/internal/render"
+	"github.com/google/uuid"
+)
+
+// Srcsets only offer the formats the renderer encodes: the built-in renderer
+// would serve JPEG for a <source type="image/avif">
+func TestSrcsetFormats(t *testing.T) {
+	asset := &AssetResponse{
+		ID: uuid.NewString(), Kind: "image", State: "ready", Visibility: VisibilityPublic,
+		Filename: "photo.jpg", MimeType: "image/jpeg", Bucket: "media-originals", ObjectKey: "2024/01/02/photo.jpg",
+	}
+	database, _ := newFakeDB(t, func(sql string) fakeResult {
+		if strings.Contains(sql, "FROM assets a") {
+			return assetResult(asset)
+		}
+		return fakeResult{err: errFakeQuery}
+	})
+	signer, err := imgproxy.NewSigner(testImgproxyKey, testImgproxySalt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		renderer *render.Renderer
+		defaults []string
+		query    string
+		status   int
+		formats  []string
+	}{
+		{"imgproxy", nil, []string{"avif", "webp", "jpg"}, "", http.StatusOK, []string{"avif", "webp", "jpg"}},
+		{"imgproxy with formats", nil, []string{"jpg"}, "?formats=webp,png", http.StatusOK, []string{"webp", "png"}},
+		{"builtin", render.New(nil), []string{"avif", "webp", "jpg"}, "", http.StatusOK, []string{"jpg"}},
+		{"builtin without a format it encodes", render.New(nil), []string{"avif", "webp"}, "", http.StatusOK, []string{"jpg"}},
+		{"builtin with formats", render.New(nil), []string{"jpg"}, "?formats=png,jpg", http.StatusOK, []string{"png", "jpg"}},
+		{"builtin with WebP", render.New(nil), []string{"jpg"}, "?formats=webp,jpg", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := NewRouter(&Handler{
+				cfg: &config.Config{
+					PublicImgProxyURL: "https://img.example.com",
+					ImgProxy:          config.ImgProxyConfig{MaxDimension: 4096, SrcsetWidths: []int{640}, SrcsetFormats: tt.defaults},
+				},
+				db:             database,
+				imgproxySigner: signer,
+				renderer:       tt.renderer,
+			})
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/media/"+asset.ID+"/srcset"+tt.query, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var srcset SrcsetResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &srcset); err != nil {
+				t.Fatal(err)
+			}
+			var formats []string
+			for _, candidate := range srcset.Candidates {
+				formats = append(formats, candidate.Format)
+			}
+			if strings.Join(formats, ",") != strings.Join(tt.formats, ",") {
+				t.Errorf("formats = %v, want %v", formats, tt.formats)
+			}
+			if len(srcset.Sources) != len(tt.formats)-1 {
+				t.Errorf("sources = %+v, want one per format but the fallback", srcset.Sources)
+			}
+		})
+	}
+}
@@ -1,78 +1,205 @@
 package api
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"regexp"
+	"strings"
 
+	"github.com/ancill/mediapod/services/media-api/internal/storage"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog/log"
 )
 
+// variantPattern matches HLS variant directories produced by the worker (v0, v1, ...)
+var variantPattern = regexp.MustCompile(`^v[0-9]+$`)
+
 // GetVideoManifest handles GET /v1/video/:assetId/master.m3u8
 func (h *Handler) GetVideoManifest(w http.ResponseWriter, r *http.Request) {
-	assetIDStr := chi.URLParam(r, "assetId")
-	assetID, err := uuid.Parse(assetIDStr)
-	if err != nil {
-		respondError(w, http.StatusBadRequest, "Invalid asset ID")
+	assetID, visibility, ok := h.readyVideo(w, r)
+	if !ok {
+		return
+	}
+
+	expiresAt, ok := h.authorizeDelivery(w, r, assetID.String(), visibility)
+	if !ok {
 		return
 	}
 
 	ctx := context.Background()
 
-	// Verify asset exists and is a video
-	var kind, state string
-	err = h.db.Pool().QueryRow(ctx, "SELECT kind, state FROM assets WHERE id = $1", assetID).Scan(&kind, &state)
-	if err != nil {
-		respondError(w, http.StatusNotFound, "Asset not found")
+	// Variant playlists are referenced relatively and resolve to GetVideoPlaylist;
+	// for private videos they need the delivery token to be carried along
+	rewrite := func(line string) string {
+		if expiresAt == 0 {
+			return line
+		}
+		return h.deliverySigner.SignURL(line, assetID.String(), expiresAt)
+	}
+
+	prefix := storage.RenditionPrefix(assetID.String(), visibility != VisibilityPrivate)
+	h.serveHLSPlaylist(ctx, w, r, prefix+"hls/master.m3u8", expiresAt, rewrite)
+}
+
+// GetVideoPlaylist handles GET /v1/video/:assetId/:variant/playlist.m3u8
+func (h *Handler) GetVideoPlaylist(w http.ResponseWriter, r *http.Request) {
+	variant := chi.URLParam(r, "variant")
+	if !variantPattern.MatchString(variant) {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid variant")
 		return
 	}
 
-	if kind != "video" {
-		respondError(w, http.StatusBadRequest, "Asset is not a video")
+	assetID, visibility, ok := h.readyVideo(w, r)
+	if !ok {
 		return
 	}
 
-	if state != "ready" {
-		respondError(w, http.StatusAccepted, "Video is still processing")
+	expiresAt, ok := h.authorizeDelivery(w, r, assetID.String(), visibility)
+	if !ok {
 		return
 	}
 
-	// Construct path to HLS manifest in MinIO
-	manifestPath := fmt.Sprintf("%s/hls/master.m3u8", assetID.String())
+	ctx := context.Background()
+	prefix := storage.RenditionPrefix(assetID.String(), visibility != VisibilityPrivate) + "hls/" + variant
+
+	// Segments are served straight from storage: public videos through the
+	// public VOD endpoint, private videos through presigned URLs
+	rewrite := func(line string) string {
+		if expiresAt == 0 {
+			return fmt.Sprintf("%s/%s/hls/%s/%s", h.cfg.PublicVODURL, assetID.String(), variant, line)
+		}
+		segmentURL, err := h.storage.PresignedGetURL(ctx, h.storage.GetConfig().BucketVOD, prefix+"/"+line, h.deliveryTTL(expiresAt))
+		if err != nil {
+			log.Error().Err(err).Str("segment", line).Msg("Failed to presign HLS segment")
+			return line
+		}
+		return segmentURL
+	}
 
-	// Get presigned URL for the manifest (short-lived, 1 hour)
-	presignedURL, err := h.storage.PresignedGetURL(ctx, h.storage.GetConfig().BucketVOD, manifestPath, 3600)
+	h.serveHLSPlaylist(ctx, w, r, prefix+"/playlist.m3u8", expiresAt, rewrite)
+}
+
+// GetVideoPoster handles GET /v1/video/:assetId/poster.jpg
+func (h *Handler) GetVideoPoster(w http.ResponseWriter, r *http.Request) {
+	assetID, visibility, ok := h.readyVideo(w, r)
+	if !ok {
+		return
+	}
+
+	expiresAt, ok := h.authorizeDelivery(w, r, assetID.String(), visibility)
+	if !ok {
+		return
+	}
+
+	if expiresAt == 0 {
+		http.Redirect(w, r, fmt.Sprintf("%s/%s/poster.jpg", h.cfg.PublicThumbsURL, assetID.String()), http.StatusFound)
+		return
+	}
+
+	posterURL, err := h.storage.PresignedGetURL(context.Background(), h.storage.GetConfig().BucketThumbs,
+		storage.RenditionPrefix(assetID.String(), visibility != VisibilityPrivate)+"poster.jpg", h.deliveryTTL(expiresAt))
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to generate presigned URL for manifest")
-		respondError(w, http.StatusInternalServerError, "Failed to fetch video manifest")
+		log.Error().Err(err).Msg("Failed to generate presigned URL for poster")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to fetch video poster")
 		return
 	}
 
-	// Fetch and proxy the manifest
-	resp, err := http.Get(presignedURL)
+	w.Header().Set("Cache-Control", deliveryCacheControl(expiresAt, 3600))
+	http.Redirect(w, r, posterURL, http.StatusFound)
+}
+
+// readyVideo resolves the assetId URL parameter to a ready video asset and
+// returns its visibility, writing an error response when it is not one
+func (h *Handler) readyVideo(w http.ResponseWriter, r *http.Request) (uuid.UUID, string, bool) {
+	assetIDStr := chi.URLParam(r, "assetId")
+	assetID, err := uuid.Parse(assetIDStr)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid asset ID")
+		return uuid.Nil, "", false
+	}
+
+	// Verify asset exists and is a video
+	var kind, state, visibility string
+	err = h.db.Pool().QueryRow(context.Background(), "SELECT kind, state, visibility FROM assets WHERE id = $1", assetID).
+		Scan(&kind, &state, &visibility)
+	if errors.Is(err, pgx.ErrNoRows) {
+		respondError(w, r, http.StatusNotFound, CodeAssetNotFound, "Asset not found")
+		return uuid.Nil, "", false
+	}
+	if err != nil {
+		log.Error().Err(err).Str("assetId", assetIDStr).Msg("Failed to get asset")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to fetch video")
+		return uuid.Nil, "", false
+	}
+
+	// Only videos have HLS renditions, so for other kinds the resource does not exist
+	if kind != "video" {
+		respondError(w, r, http.StatusNotFound, CodeAssetNotFound, "Asset is not a video")
+		return uuid.Nil, "", false
+	}
+
+	switch state {
+	case "ready":
+	case "failed":
+		respondError(w, r, http.StatusConflict, CodeInvalidState, "Video processing failed")
+		return uuid.Nil, "", false
+	default:
+		w.Header().Set("Retry-After", "10")
+		respondError(w, r, http.StatusConflict, CodeAssetNotReady, "Video is still "+state)
+		return uuid.Nil, "", false
+	}
+
+	return assetID, visibility, true
+}
+
+// serveHLSPlaylist streams an HLS playlist from the VOD bucket, passing every
+// URI line through rewrite
+func (h *Handler) serveHLSPlaylist(ctx context.Context, w http.ResponseWriter, r *http.Request, objectKey string, expiresAt int64, rewrite func(string) string) {
+	obj, err := h.storage.GetObject(ctx, h.storage.GetConfig().BucketVOD, objectKey)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to fetch manifest from storage")
-		respondError(w, http.StatusInternalServerError, "Failed to fetch video manifest")
+		log.Error().Err(err).Str("object_key", objectKey).Msg("Failed to open playlist")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to fetch video manifest")
 		return
 	}
-	defer resp.Body.Close()
+	defer obj.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		respondError(w, resp.StatusCode, "Failed to fetch video manifest")
+	if _, err := obj.Stat(); storage.IsNotFound(err) {
+		log.Error().Err(err).Str("object_key", objectKey).Msg("Playlist not found in storage")
+		respondError(w, r, http.StatusNotFound, CodeObjectNotFound, "Video manifest not found")
+		return
+	} else if err != nil {
+		log.Error().Err(err).Str("object_key", objectKey).Msg("Failed to stat playlist")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to fetch video manifest")
+		return
+	}
+
+	var out strings.Builder
+	scanner := bufio.NewScanner(obj)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			line = rewrite(line)
+		}
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		log.Error().Err(err).Str("object_key", objectKey).Msg("Failed to read playlist")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to fetch video manifest")
 		return
 	}
 
 	// Set appropriate headers for HLS
 	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
-	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Header().Set("Cache-Control", deliveryCacheControl(expiresAt, 3600))
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 
-	// Stream the manifest
-	_, err = io.Copy(w, resp.Body)
-	if err != nil {
+	if _, err := w.Write([]byte(out.String())); err != nil {
 		log.Error().Err(err).Msg("Failed to stream manifest")
 	}
 }
@@ -0,0 +1,501 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ancill/mediapod/services/media-api/internal/openapi"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog/log"
+)
+
+// Asset lifecycle events (must match worker)
+const (
+	EventAssetCreated  = "asset.created"
+	EventAssetUploaded = "asset.uploaded"
+	EventAssetReady    = "asset.ready"
+	EventAssetFailed   = "asset.failed"
+	EventAssetDeleted  = "asset.deleted"
+)
+
+var webhookEvents = []string{
+	EventAssetCreated, EventAssetUploaded, EventAssetReady, EventAssetFailed, EventAssetDeleted,
+}
+
+// querier is satisfied by both the connection pool and a transaction
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// WebhookEvent is the JSON body delivered to webhook endpoints
+type WebhookEvent struct {
+	Event      string      `json:"event"`
+	OccurredAt time.Time   `json:"occurredAt"`
+	Data       interface{} `json:"data"`
+}
+
+// CreateWebhookRequest represents the request to subscribe a webhook
+type CreateWebhookRequest struct {
+	URL string `json:"url" format:"uri" maxLength:"2000"`
+	// Secret is used to sign deliveries; generated when omitted
+	Secret string `json:"secret,omitempty"`
+	// Events to deliver; empty subscribes to all events
+	Events []string `json:"events,omitempty" enum:"asset.created,asset.uploaded,asset.ready,asset.failed,asset.deleted"`
+}
+
+// UpdateWebhookRequest represents a partial update of a webhook
+type UpdateWebhookRequest struct {
+	URL    *string   `json:"url,omitempty" format:"uri" maxLength:"2000"`
+	Events *[]string `json:"events,omitempty" enum:"asset.created,asset.uploaded,asset.ready,asset.failed,asset.deleted"`
+	Active *bool     `json:"active,omitempty"`
+}
+
+// WebhookResponse represents a webhook subscription
+type WebhookResponse struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Active bool     `json:"active"`
+	// Secret is only returned when the webhook is created
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// WebhookListResponse represents all webhook subscriptions
+type WebhookListResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+	Total    int               `json:"total"`
+}
+
+// WebhookDeliveryListResponse represents the recent deliveries of a webhook
+type WebhookDeliveryListResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+	Total      int                       `json:"total"`
+}
+
+// WebhookDeliveryResponse represents a delivery and its attempt log
+type WebhookDeliveryResponse struct {
+	ID             string                   `json:"id"`
+	Event          string                   `json:"event"`
+	AssetID        *string                  `json:"assetId,omitempty"`
+	State          string                   `json:"state"`
+	Attempts       int                      `json:"attempts"`
+	MaxAttempts    int                      `json:"maxAttempts"`
+	NextAttemptAt  *time.Time               `json:"nextAttemptAt,omitempty"`
+	LastStatusCode *int                     `json:"lastStatusCode,omitempty"`
+	LastError      *string                  `json:"lastError,omitempty"`
+	DeliveredAt    *time.Time               `json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time                `json:"createdAt"`
+	Payload        json.RawMessage          `json:"payload"`
+	AttemptLog     []WebhookAttemptResponse `json:"attemptLog"`
+}
+
+// WebhookAttemptResponse represents a single delivery attempt
+type WebhookAttemptResponse struct {
+	Attempt      int       `json:"attempt"`
+	StatusCode   *int      `json:"statusCode,omitempty"`
+	ResponseBody *string   `json:"responseBody,omitempty"`
+	Error        *string   `json:"error,omitempty"`
+	DurationMs   int       `json:"durationMs"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// assetEventData is the asset snapshot webhook events carry, the same shape
+// as the worker's. It has no urls: deliveries are retried for hours, past
+// the expiry of signed URLs, and the worker cannot sign them. Receivers get
+// current URLs from GetAsset.
+type assetEventData struct {
+	*AssetResponse
+	URLs map[string]interface{} `json:"urls,omitempty"`
+}
+
+// emitEvent queues a delivery of an event for every active webhook subscribed
+// to it. It must run in the transaction that makes the change, which fails
+// along with the insert.
+func emitEvent(ctx context.Context, q querier, event string, assetID uuid.UUID, data interface{}) error {
+	payload, err := json.Marshal(WebhookEvent{
+		Event:      event,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook event: %w", err)
+	}
+
+	_, err = q.Exec(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event, asset_id, payload)
+		SELECT id, $1, $2, $3 FROM webhooks
+		WHERE active AND (cardinality(events) = 0 OR $1 = ANY(events))
+	`, event, assetID, payload)
+	if err != nil {
+		return fmt.Errorf("failed to queue webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// emitAssetEvent emits an event carrying the current state of an asset
+func (h *Handler) emitAssetEvent(ctx context.Context, q querier, event string, assetID uuid.UUID) error {
+	asset, err := h.loadAssetWith(ctx, q, assetID)
+	if err != nil {
+		return fmt.Errorf("failed to load asset: %w", err)
+	}
+	return emitEvent(ctx, q, event, assetID, assetEventData{AssetResponse: asset})
+}
+
+// CreateWebhook handles POST /v1/webhooks
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req CreateWebhookRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Events == nil {
+		req.Events = []string{}
+	}
+	if fieldErrs := append(validateWebhookURL(req.URL, h.cfg.WebhookAllowPrivateNetworks), validateWebhookEvents(req.Events)...); len(fieldErrs) > 0 {
+		respondValidationError(w, r, fieldErrs)
+		return
+	}
+
+	if req.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Error().Err(err).Msg("Failed to generate webhook secret")
+			respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to create webhook")
+			return
+		}
+		req.Secret = hex.EncodeToString(secret)
+	}
+
+	ctx := context.Background()
+
+	webhook := WebhookResponse{URL: req.URL, Events: req.Events, Secret: req.Secret}
+	err := h.db.Pool().QueryRow(ctx, `
+		INSERT INTO webhooks (url, secret, events)
+		VALUES ($1, $2, $3)
+		RETURNING id, active, created_at, updated_at
+	`, req.URL, req.Secret, req.Events).Scan(&webhook.ID, &webhook.Active, &webhook.CreatedAt, &webhook.UpdatedAt)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create webhook")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to create webhook")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, webhook)
+}
+
+// ListWebhooks handles GET /v1/webhooks
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	rows, err := h.db.Pool().Query(ctx, `
+		SELECT id, url, events, active, created_at, updated_at
+		FROM webhooks
+		ORDER BY created_at DESC
+	`)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list webhooks")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to list webhooks")
+		return
+	}
+	defer rows.Close()
+
+	webhooks := []WebhookResponse{}
+	for rows.Next() {
+		var webhook WebhookResponse
+		if err := rows.Scan(&webhook.ID, &webhook.URL, &webhook.Events, &webhook.Active, &webhook.CreatedAt, &webhook.UpdatedAt); err != nil {
+			log.Error().Err(err).Msg("Failed to scan webhook row")
+			continue
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	respondJSON(w, http.StatusOK, WebhookListResponse{
+		Webhooks: webhooks,
+		Total:    len(webhooks),
+	})
+}
+
+// GetWebhook handles GET /v1/webhooks/:webhookId
+func (h *Handler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhookId"))
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid webhook ID")
+		return
+	}
+
+	webhook, err := h.loadWebhook(context.Background(), webhookID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		respondError(w, r, http.StatusNotFound, CodeWebhookNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get webhook")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to get webhook")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, webhook)
+}
+
+// UpdateWebhook handles PATCH /v1/webhooks/:webhookId
+func (h *Handler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhookId"))
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid webhook ID")
+		return
+	}
+
+	var req UpdateWebhookRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var fieldErrs []openapi.FieldError
+	if req.URL != nil {
+		fieldErrs = append(fieldErrs, validateWebhookURL(*req.URL, h.cfg.WebhookAllowPrivateNetworks)...)
+	}
+	if req.Events != nil {
+		fieldErrs = append(fieldErrs, validateWebhookEvents(*req.Events)...)
+	}
+	if len(fieldErrs) > 0 {
+		respondValidationError(w, r, fieldErrs)
+		return
+	}
+
+	ctx := context.Background()
+
+	result, err := h.db.Pool().Exec(ctx, `
+		UPDATE webhooks SET
+			url = COALESCE($2, url),
+			events = COALESCE($3, events),
+			active = COALESCE($4, active)
+		WHERE id = $1
+	`, webhookID, req.URL, req.Events, req.Active)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to update webhook")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update webhook")
+		return
+	}
+	if result.RowsAffected() == 0 {
+		respondError(w, r, http.StatusNotFound, CodeWebhookNotFound, "Webhook not found")
+		return
+	}
+
+	webhook, err := h.loadWebhook(ctx, webhookID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get webhook")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to update webhook")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, webhook)
+}
+
+// DeleteWebhook handles DELETE /v1/webhooks/:webhookId
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhookId"))
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid webhook ID")
+		return
+	}
+
+	result, err := h.db.Pool().Exec(context.Background(), "DELETE FROM webhooks WHERE id = $1", webhookID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to delete webhook")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to delete webhook")
+		return
+	}
+	if result.RowsAffected() == 0 {
+		respondError(w, r, http.StatusNotFound, CodeWebhookNotFound, "Webhook not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries handles GET /v1/webhooks/:webhookId/deliveries
+// Supports ?state=pending|delivered|failed and ?limit= (default 50, max 200)
+func (h *Handler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhookId"))
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, CodeInvalidRequest, "Invalid webhook ID")
+		return
+	}
+
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 200 {
+			limit = n
+		}
+	}
+
+	var state *string
+	if v := r.URL.Query().Get("state"); v != "" {
+		state = &v
+	}
+
+	ctx := context.Background()
+
+	if _, err := h.loadWebhook(ctx, webhookID); errors.Is(err, pgx.ErrNoRows) {
+		respondError(w, r, http.StatusNotFound, CodeWebhookNotFound, "Webhook not found")
+		return
+	} else if err != nil {
+		log.Error().Err(err).Msg("Failed to get webhook")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to list deliveries")
+		return
+	}
+
+	rows, err := h.db.Pool().Query(ctx, `
+		SELECT id, event, asset_id, state, attempts, max_attempts, next_attempt_at,
+			last_status_code, last_error, delivered_at, created_at, payload
+		FROM webhook_deliveries
+		WHERE webhook_id = $1 AND ($2::text IS NULL OR state = $2)
+		ORDER BY created_at DESC
+		LIMIT $3
+	`, webhookID, state, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list webhook deliveries")
+		respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to list deliveries")
+		return
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDeliveryResponse{}
+	index := make(map[string]int)
+	var ids []string
+	for rows.Next() {
+		var d WebhookDeliveryResponse
+		var nextAttemptAt time.Time
+		err := rows.Scan(&d.ID, &d.Event, &d.AssetID, &d.State, &d.Attempts, &d.MaxAttempts, &nextAttemptAt,
+			&d.LastStatusCode, &d.LastError, &d.DeliveredAt, &d.CreatedAt, &d.Payload)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to scan webhook delivery row")
+			continue
+		}
+		if d.State == "pending" {
+			d.NextAttemptAt = &nextAttemptAt
+		}
+		d.AttemptLog = []WebhookAttemptResponse{}
+
+		ids = append(ids, d.ID)
+		index[d.ID] = len(deliveries)
+		deliveries = append(deliveries, d)
+	}
+	rows.Close()
+
+	if len(ids) > 0 {
+		attemptRows, err := h.db.Pool().Query(ctx, `
+			SELECT delivery_id, attempt, status_code, response_body, error, duration_ms, created_at
+			FROM webhook_delivery_attempts
+			WHERE delivery_id = ANY($1::uuid[])
+			ORDER BY attempt
+		`, ids)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to list webhook delivery attempts")
+			respondError(w, r, http.StatusInternalServerError, CodeInternal, "Failed to list deliveries")
+			return
+		}
+		defer attemptRows.Close()
+
+		for attemptRows.Next() {
+			var deliveryID string
+			var a WebhookAttemptResponse
+			if err := attemptRows.Scan(&deliveryID, &a.Attempt, &a.StatusCode, &a.ResponseBody, &a.Error, &a.DurationMs, &a.CreatedAt); err != nil {
+				log.Error().Err(err).Msg("Failed to scan webhook attempt row")
+				continue
+			}
+			if i, ok := index[deliveryID]; ok {
+				deliveries[i].AttemptLog = append(deliveries[i].AttemptLog, a)
+			}
+		}
+	}
+
+	respondJSON(w, http.StatusOK, WebhookDeliveryListResponse{
+		Deliveries: deliveries,
+		Total:      len(deliveries),
+	})
+}
+
+// loadWebhook fetches a webhook without its secret
+func (h *Handler) loadWebhook(ctx context.Context, webhookID uuid.UUID) (*WebhookResponse, error) {
+	var webhook WebhookResponse
+	err := h.db.Pool().QueryRow(ctx, `
+		SELECT id, url, events, active, created_at, updated_at
+		FROM webhooks WHERE id = $1
+	`, webhookID).Scan(&webhook.ID, &webhook.URL, &webhook.Events, &webhook.Active, &webhook.CreatedAt, &webhook.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// validateWebhookURL rejects webhook URLs that cannot be delivered to. Hosts
+// that are obviously internal are rejected up front unless private networks
+// are allowed; the worker checks the resolved address of every delivery.
+func validateWebhookURL(rawURL string, allowPrivateNetworks bool) []openapi.FieldError {
+	if rawURL == "" {
+		return []openapi.FieldError{{Field: "url", Message: "is required"}}
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return []openapi.FieldError{{Field: "url", Message: "must be an absolute http or https URL"}}
+	}
+	if allowPrivateNetworks {
+		return nil
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return []openapi.FieldError{{Field: "url", Message: "must not point at localhost"}}
+	}
+	if ip := net.ParseIP(host); ip != nil && !isPublicAddress(ip) {
+		return []openapi.FieldError{{Field: "url", Message: "must not point at a loopback, link-local or private address"}}
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which some
+// clouds use for metadata services
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPublicAddress reports whether webhooks may be delivered to ip (must match
+// the worker)
+func isPublicAddress(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsPrivate() ||
+		ip.IsUnspecified() || sharedAddressSpace.Contains(ip))
+}
+
+// validateWebhookEvents rejects unknown event names
+func validateWebhookEvents(events []string) []openapi.FieldError {
+	var fieldErrs []openapi.FieldError
+	for i, event := range events {
+		known := false
+		for _, e := range webhookEvents {
+			if event == e {
+				known = true
+				break
+			}
+		}
+		if !known {
+			fieldErrs = append(fieldErrs, openapi.FieldError{Field: "events[" + strconv.Itoa(i) + "]", Message: "unknown event " + event})
+		}
+	}
+	return fieldErrs
+}
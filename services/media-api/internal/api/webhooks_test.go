@@ -0,0 +1,142 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ancill/mediapod/services/media-api/internal/config"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{"https://example.com/hooks/mediapod", true},
+		{"http://93.184.216.34:8080/hook", true},
+		{"https://internal.example.com/hook", true}, // resolved and checked by the worker
+		{"", false},
+		{"ftp://example.com/hook", false},
+		{"/hooks/mediapod", false},
+		{"http://localhost:3000/hook", false},
+		{"http://LOCALHOST./hook", false},
+		{"http://api.localhost/hook", false},
+		{"http://127.0.0.1/hook", false},
+		{"http://[::1]:8080/hook", false},
+		{"http://169.254.169.254/latest/meta-data/", false},
+		{"http://10.0.0.5/hook", false},
+		{"http://192.168.1.10/hook", false},
+		{"http://[fd00::1]/hook", false},
+		{"http://0.0.0.0/hook", false},
+	}
+	for _, tt := range tests {
+		if got := len(validateWebhookURL(tt.url, false)) == 0; got != tt.valid {
+			t.Errorf("validateWebhookURL(%q) valid = %v, want %v", tt.url, got, tt.valid)
+		}
+	}
+}
+
+func TestValidateWebhookURLAllowPrivateNetworks(t *testing.T) {
+	for _, url := range []string{"http://localhost:3000/hook", "http://127.0.0.1/hook", "http://10.0.0.5/hook"} {
+		if errs := validateWebhookURL(url, true); len(errs) > 0 {
+			t.Errorf("validateWebhookURL(%q) with private networks allowed: %v", url, errs)
+		}
+	}
+}
+
+// Webhook events are queued in the transaction of the change they report:
+// both commit or neither does
+func TestCompleteUploadWebhookEvents(t *testing.T) {
+	asset := &AssetResponse{
+		ID: uuid.NewString(), Kind: "document", State: "ready", Visibility: VisibilityPublic,
+		Filename: "report.pdf", MimeType: "application/pdf", Bucket: "media-originals", ObjectKey: "2024/01/02/report.pdf",
+	}
+	// payloadPattern matches the bytea literal of the payload in the
+	// statement text
+	payloadPattern := regexp.MustCompile(`'\\x([0-9a-f]+)'`)
+
+	for _, tt := range []struct {
+		name      string
+		insertErr error
+		status    int
+	}{
+		{"queued", nil, http.StatusOK},
+		{"insert failed", errors.New("webhook_deliveries is locked"), http.StatusInternalServerError},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			database, fake := newFakeDB(t, func(sql string) fakeResult {
+				switch {
+				case strings.HasPrefix(sql, "UPDATE assets SET state"):
+					return fakeResult{columns: []fakeColumn{{"kind", oidText}, {"state", oidText}}, rows: [][]any{{"document", "ready"}}}
+				case strings.HasPrefix(sql, "SELECT pg_advisory_xact_lock"):
+					return fakeResult{tag: "SELECT 1"}
+				case strings.Contains(sql, "FROM assets a"):
+					return assetResult(asset)
+				case strings.HasPrefix(sql, "INSERT INTO asset_changes"):
+					return fakeResult{tag: "INSERT 0 1"}
+				case strings.HasPrefix(sql, "INSERT INTO webhook_deliveries"):
+					return fakeResult{tag: "INSERT 0 1", err: tt.insertErr}
+				}
+				return fakeResult{err: errFakeQuery}
+			})
+			rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+			defer rdb.Close()
+			router := NewRouter(&Handler{cfg: &config.Config{}, db: database, redis: rdb})
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/v1/media/complete", strings.NewReader(`{"assetId": "`+asset.ID+`"}`))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+
+			statements := fake.Statements()
+			last := statements[len(statements)-1]
+			if tt.insertErr != nil {
+				if last != "rollback" {
+					t.Errorf("last statement = %q, want rollback", last)
+				}
+				return
+			}
+			if last != "commit" {
+				t.Fatalf("last statement = %q, want commit", last)
+			}
+
+			var events []string
+			for _, sql := range statements {
+				if !strings.HasPrefix(sql, "INSERT INTO webhook_deliveries") {
+					continue
+				}
+				match := payloadPattern.FindStringSubmatch(sql)
+				if match == nil {
+					t.Fatalf("no payload in %s", sql)
+				}
+				payload, _ := hex.DecodeString(match[1])
+				var event struct {
+					Event string                     `json:"event"`
+					Data  map[string]json.RawMessage `json:"data"`
+				}
+				if err := json.Unmarshal(payload, &event); err != nil {
+					t.Fatal(err)
+				}
+				if _, ok := event.Data["urls"]; ok || string(event.Data["id"]) != `"`+asset.ID+`"` {
+					t.Errorf("%s data = %s, want the asset without urls", event.Event, payload)
+				}
+				events = append(events, event.Event)
+			}
+			if strings.Join(events, ",") != "asset.uploaded,asset.ready" {
+				t.Errorf("events = %v, want asset.uploaded and asset.ready", events)
+			}
+		})
+	}
+}
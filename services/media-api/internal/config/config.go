@@ -3,6 +3,14 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ancill/mediapod/services/media-api/internal/delivery"
+	"github.com/ancill/mediapod/services/media-api/internal/imgproxy"
 )
 
 type Config struct {
@@ -11,9 +19,20 @@ type Config struct {
 	MinIO             MinIOConfig
 	Redis             RedisConfig
 	ImgProxy          ImgProxyConfig
+	ImageCache        ImageCacheConfig
+	Delivery          DeliveryConfig
+	PublicAPIURL      string
 	PublicImgProxyURL string
 	PublicVODURL      string
 	PublicThumbsURL   string
+	// IdempotencyTTL is how long responses are kept for Idempotency-Key replays
+	IdempotencyTTL time.Duration
+	// WebhookAllowPrivateNetworks accepts webhook URLs on loopback, link-local
+	// and private addresses, for local development
+	WebhookAllowPrivateNetworks bool
+	// APIKeysRequired makes the management routes reject requests without a
+	// valid API key; delivery routes stay open
+	APIKeysRequired bool
 }
 
 type MinIOConfig struct {
@@ -27,22 +46,82 @@ type MinIOConfig struct {
 	BucketImages    string
 	BucketVOD       string
 	BucketThumbs    string
+	// BucketCache holds the image cache; it has no anonymous access, unlike
+	// the images bucket
+	BucketCache string
 }
 
 type RedisConfig struct {
 	URL string
 }
 
+// Image renderers
+const (
+	RendererImgProxy = "imgproxy"
+	RendererBuiltin  = "builtin"
+)
+
 type ImgProxyConfig struct {
 	Key     string
 	Salt    string
 	BaseURL string
+	// Renderer is RendererImgProxy, or RendererBuiltin to render the common
+	// subset of options in the API itself; URLs are signed the same way
+	Renderer string
+	// Presets are the named operations served by /v1/media/{assetId}/image/{preset}
+	Presets map[string]imgproxy.Operations
+	// PresetsRedirect makes preset requests redirect to imgproxy instead of
+	// proxying the image through the API
+	PresetsRedirect bool
+	// MaxDimension bounds the width and height of custom transformations
+	MaxDimension int
+	// SrcsetWidths and SrcsetFormats are the default candidates of responsive
+	// image sets; the last format is the fallback for <img>
+	SrcsetWidths  []int
+	SrcsetFormats []string
+	// AllowedOptions are the processing options /v1/image accepts, and
+	// AllowedBuckets the buckets its sources may come from
+	AllowedOptions []string
+	AllowedBuckets []string
+	// ClientHints adapts presets to Sec-CH-DPR, Sec-CH-Width and
+	// Sec-CH-Viewport-Width, with the DPR capped at MaxDPR
+	ClientHints bool
+	MaxDPR      float64
+	// Watermarks are the named watermark policies operations refer to, and
+	// Watermark the one added to every image URL the API signs or serves
+	// whose operations set none (nil: no watermark)
+	Watermarks map[string]imgproxy.WatermarkOp
+	Watermark  *imgproxy.WatermarkOp
+}
+
+// ImageCacheConfig controls the cache of transformed images
+type ImageCacheConfig struct {
+	Enabled bool
+	// Dir holds the local disk tier, bounded to DiskSize bytes (0 disables it)
+	Dir      string
+	DiskSize int64
+	// Bucket is the shared tier ("" disables it)
+	Bucket string
+	// MaxObjectSize is the largest image, in bytes, that is cached
+	MaxObjectSize int64
+}
+
+// DeliveryConfig controls signed delivery URLs for private assets
+type DeliveryConfig struct {
+	// TokenSecret signs delivery tokens; without DELIVERY_TOKEN_SECRET it is
+	// derived from the imgproxy key
+	TokenSecret  string
+	SignedURLTTL time.Duration
+	// OriginalsRedirect makes original downloads redirect to presigned storage
+	// URLs instead of streaming through the API
+	OriginalsRedirect bool
 }
 
 func Load() (*Config, error) {
 	cfg := &Config{
 		Port:              getEnv("PORT", "8080"),
 		DatabaseURL:       getEnv("DATABASE_URL", ""),
+		PublicAPIURL:      getEnv("PUBLIC_API_URL", ""),
 		PublicImgProxyURL: getEnv("PUBLIC_IMGPROXY_URL", ""),
 		PublicVODURL:      getEnv("PUBLIC_VOD_URL", ""),
 		PublicThumbsURL:   getEnv("PUBLIC_THUMBS_URL", ""),
@@ -57,15 +136,36 @@ func Load() (*Config, error) {
 			BucketImages:    "media-images",
 			BucketVOD:       "media-vod",
 			BucketThumbs:    "media-thumbs",
+			BucketCache:     "media-cache",
 		},
 		Redis: RedisConfig{
 			URL: getEnv("REDIS_URL", "redis://localhost:6379/0"),
 		},
 		ImgProxy: ImgProxyConfig{
-			Key:     getEnv("IMGPROXY_KEY", ""),
-			Salt:    getEnv("IMGPROXY_SALT", ""),
-			BaseURL: getEnv("IMGPROXY_BASE_URL", "http://imgproxy:8080"),
+			Key:             getEnv("IMGPROXY_KEY", ""),
+			Salt:            getEnv("IMGPROXY_SALT", ""),
+			BaseURL:         getEnv("IMGPROXY_BASE_URL", "http://imgproxy:8080"),
+			Renderer:        getEnv("IMAGE_RENDERER", RendererImgProxy),
+			PresetsRedirect: getEnv("IMAGE_PRESETS_REDIRECT", "true") == "true",
+			MaxDimension:    getEnvInt("IMAGE_MAX_DIMENSION", 4096),
+			ClientHints:     getEnv("IMAGE_CLIENT_HINTS", "true") == "true",
+			MaxDPR:          getEnvFloat("IMAGE_MAX_DPR", 3),
+		},
+		ImageCache: ImageCacheConfig{
+			Enabled:       getEnv("IMAGE_CACHE_ENABLED", "true") == "true",
+			Dir:           getEnv("IMAGE_CACHE_DIR", filepath.Join(os.TempDir(), "mediapod-image-cache")),
+			DiskSize:      int64(getEnvInt("IMAGE_CACHE_DISK_MB", 1024)) << 20,
+			MaxObjectSize: int64(getEnvInt("IMAGE_CACHE_MAX_OBJECT_MB", 10)) << 20,
+		},
+		Delivery: DeliveryConfig{
+			TokenSecret:       getEnv("DELIVERY_TOKEN_SECRET", ""),
+			SignedURLTTL:      time.Duration(getEnvInt("SIGNED_URL_TTL", 3600)) * time.Second,
+			OriginalsRedirect: getEnv("ORIGINALS_REDIRECT", "false") == "true",
 		},
+		IdempotencyTTL: time.Duration(getEnvInt("IDEMPOTENCY_TTL", 86400)) * time.Second,
+
+		WebhookAllowPrivateNetworks: getEnv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "false") == "true",
+		APIKeysRequired:             getEnv("API_KEYS_REQUIRED", "false") == "true",
 	}
 
 	// Validate required fields
@@ -77,12 +177,254 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("IMGPROXY_KEY and IMGPROXY_SALT are required")
 	}
 
+	if cfg.Delivery.TokenSecret == "" {
+		cfg.Delivery.TokenSecret = delivery.DeriveSecret(cfg.ImgProxy.Key)
+	}
+
+	switch cfg.ImgProxy.Renderer {
+	case RendererImgProxy:
+	case RendererBuiltin:
+		// Without imgproxy, image URLs point at the API's /v1/image
+		cfg.PublicImgProxyURL = strings.TrimSuffix(cfg.PublicAPIURL, "/") + "/v1/image"
+	default:
+		return nil, fmt.Errorf("IMAGE_RENDERER must be %s or %s", RendererImgProxy, RendererBuiltin)
+	}
+
+	watermarks, err := loadWatermarks()
+	if err != nil {
+		return nil, err
+	}
+	cfg.ImgProxy.Watermarks = watermarks
+
+	presets, err := loadPresets()
+	if err != nil {
+		return nil, err
+	}
+	for name, ops := range presets {
+		if err := ops.ResolveWatermark(cfg.ImgProxy.Watermarks); err != nil {
+			return nil, fmt.Errorf("preset %q: %w", name, err)
+		}
+		presets[name] = ops
+	}
+	cfg.ImgProxy.Presets = presets
+
+	if name := getEnv("IMAGE_WATERMARK", ""); name != "" && name != imgproxy.WatermarkNone {
+		policy, ok := cfg.ImgProxy.Watermarks[name]
+		if !ok {
+			return nil, fmt.Errorf("IMAGE_WATERMARK: unknown watermark policy %q", name)
+		}
+		cfg.ImgProxy.Watermark = &policy
+	}
+
+	if cfg.ImgProxy.MaxDimension <= 0 {
+		return nil, fmt.Errorf("IMAGE_MAX_DIMENSION must be positive")
+	}
+
+	if cfg.ImgProxy.MaxDPR < 1 {
+		return nil, fmt.Errorf("IMAGE_MAX_DPR must be at least 1")
+	}
+
+	cfg.ImgProxy.SrcsetWidths, err = parseWidths(getEnv("IMAGE_SRCSET_WIDTHS", "320,640,960,1280,1920,2560"), cfg.ImgProxy.MaxDimension)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IMAGE_SRCSET_WIDTHS: %w", err)
+	}
+
+	cfg.ImgProxy.SrcsetFormats, err = parseFormats(getEnv("IMAGE_SRCSET_FORMATS", "avif,webp,jpg"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid IMAGE_SRCSET_FORMATS: %w", err)
+	}
+
+	cfg.ImgProxy.AllowedOptions, err = parseOptions(getEnv("IMAGE_ALLOWED_OPERATIONS", strings.Join(imgproxy.DefaultAllowedOptions, ",")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid IMAGE_ALLOWED_OPERATIONS: %w", err)
+	}
+
+	cfg.ImgProxy.AllowedBuckets = parseList(getEnv("IMAGE_ALLOWED_BUCKETS", ""))
+	if len(cfg.ImgProxy.AllowedBuckets) == 0 {
+		cfg.ImgProxy.AllowedBuckets = []string{cfg.MinIO.BucketOriginals, cfg.MinIO.BucketImages, cfg.MinIO.BucketThumbs}
+	}
+
+	if err := checkWatermarks(&cfg.ImgProxy); err != nil {
+		return nil, err
+	}
+
+	if getEnv("IMAGE_CACHE_STORAGE", "true") == "true" {
+		cfg.ImageCache.Bucket = cfg.MinIO.BucketCache
+	}
+
+	if cfg.ImageCache.DiskSize < 0 || cfg.ImageCache.MaxObjectSize <= 0 {
+		return nil, fmt.Errorf("IMAGE_CACHE_DISK_MB must not be negative and IMAGE_CACHE_MAX_OBJECT_MB must be positive")
+	}
+
+	if cfg.Delivery.SignedURLTTL <= 0 {
+		return nil, fmt.Errorf("SIGNED_URL_TTL must be positive")
+	}
+
+	if cfg.IdempotencyTTL <= 0 {
+		return nil, fmt.Errorf("IDEMPOTENCY_TTL must be positive")
+	}
+
 	return cfg, nil
 }
 
+// loadPresets returns the default image presets merged with those from
+// IMAGE_PRESETS_FILE and IMAGE_PRESETS (JSON objects of name to operations)
+func loadPresets() (map[string]imgproxy.Operations, error) {
+	presets := make(map[string]imgproxy.Operations)
+	for name, ops := range imgproxy.DefaultPresets {
+		presets[name] = ops
+	}
+
+	var sources [][]byte
+	if path := getEnv("IMAGE_PRESETS_FILE", ""); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read IMAGE_PRESETS_FILE: %w", err)
+		}
+		sources = append(sources, data)
+	}
+	if value := getEnv("IMAGE_PRESETS", ""); value != "" {
+		sources = append(sources, []byte(value))
+	}
+
+	for _, data := range sources {
+		parsed, err := imgproxy.ParsePresets(data)
+		if err != nil {
+			return nil, err
+		}
+		for name, ops := range parsed {
+			presets[name] = ops
+		}
+	}
+	return presets, nil
+}
+
+// loadWatermarks reads the watermark policies from IMAGE_WATERMARKS_FILE and
+// IMAGE_WATERMARKS (JSON objects of name to watermark)
+func loadWatermarks() (map[string]imgproxy.WatermarkOp, error) {
+	policies := make(map[string]imgproxy.WatermarkOp)
+
+	var sources [][]byte
+	if path := getEnv("IMAGE_WATERMARKS_FILE", ""); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read IMAGE_WATERMARKS_FILE: %w", err)
+		}
+		sources = append(sources, data)
+	}
+	if value := getEnv("IMAGE_WATERMARKS", ""); value != "" {
+		sources = append(sources, []byte(value))
+	}
+
+	for _, data := range sources {
+		parsed, err := imgproxy.ParseWatermarks(data)
+		if err != nil {
+			return nil, err
+		}
+		for name, policy := range parsed {
+			policies[name] = policy
+		}
+	}
+	return policies, nil
+}
+
+// checkWatermarks makes sure the watermark policies can be served: /v1/image
+// must accept the options they render to, and the built-in renderer has no
+// default watermark image
+func checkWatermarks(cfg *ImgProxyConfig) error {
+	if len(cfg.Watermarks) == 0 {
+		return nil
+	}
+
+	allowed := make(map[string]bool)
+	for _, option := range cfg.AllowedOptions {
+		name, _ := imgproxy.OptionName(option)
+		allowed[name] = true
+	}
+	if !allowed["watermark"] || !allowed["watermark_url"] {
+		return fmt.Errorf("IMAGE_ALLOWED_OPERATIONS must include watermark and watermark_url when IMAGE_WATERMARKS is set")
+	}
+
+	if cfg.Renderer == RendererBuiltin {
+		for name, policy := range cfg.Watermarks {
+			if policy.Image == "" {
+				return fmt.Errorf("watermark policy %q needs an image with IMAGE_RENDERER=builtin", name)
+			}
+		}
+	}
+	return nil
+}
+
+// parseWidths parses a comma-separated list of image widths in ascending order
+func parseWidths(value string, maxDimension int) ([]int, error) {
+	var widths []int
+	for _, part := range strings.Split(value, ",") {
+		width, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || width <= 0 || width > maxDimension {
+			return nil, fmt.Errorf("width %q must be between 1 and %d", part, maxDimension)
+		}
+		widths = append(widths, width)
+	}
+	sort.Ints(widths)
+	return widths, nil
+}
+
+// parseFormats parses a comma-separated list of image output formats
+func parseFormats(value string) ([]string, error) {
+	var formats []string
+	for _, part := range strings.Split(value, ",") {
+		format := strings.TrimSpace(part)
+		if imgproxy.FormatContentType(format) == "" {
+			return nil, fmt.Errorf("unsupported format %q", format)
+		}
+		formats = append(formats, format)
+	}
+	return formats, nil
+}
+
+// parseOptions parses a comma-separated list of imgproxy processing options
+func parseOptions(value string) ([]string, error) {
+	options := parseList(value)
+	for _, option := range options {
+		if _, ok := imgproxy.OptionName(option); !ok {
+			return nil, fmt.Errorf("unknown processing option %q", option)
+		}
+	}
+	return options, nil
+}
+
+// parseList splits a comma-separated list, dropping empty entries
+func parseList(value string) []string {
+	var items []string
+	for _, part := range strings.Split(value, ",") {
+		if item := strings.TrimSpace(part); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
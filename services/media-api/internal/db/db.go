@@ -57,6 +57,16 @@ func (db *DB) RunMigrations() error {
 		file    string
 	}{
 		{1, "migrations/001_initial_schema.sql"},
+		{2, "migrations/002_asset_visibility.sql"},
+		{3, "migrations/003_webhooks.sql"},
+		{4, "migrations/004_outbox.sql"},
+		{5, "migrations/005_asset_changes.sql"},
+		{6, "migrations/006_asset_focus.sql"},
+		{7, "migrations/007_asset_placeholders.sql"},
+		{8, "migrations/008_asset_palette.sql"},
+		{9, "migrations/009_asset_captured_at.sql"},
+		{10, "migrations/010_asset_image_probe.sql"},
+		{11, "migrations/011_api_keys.sql"},
 	}
 
 	for _, m := range migrations {
@@ -0,0 +1,5 @@
+-- Per-asset visibility: private assets are only delivered through expiring signed URLs
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS visibility VARCHAR(10) NOT NULL DEFAULT 'public'
+    CHECK (visibility IN ('public', 'private'));
+
+CREATE INDEX IF NOT EXISTS idx_assets_visibility ON assets(visibility);
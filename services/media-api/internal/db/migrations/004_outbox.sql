@@ -0,0 +1,23 @@
+-- Transactional outbox: rows are written in the same transaction as the state
+-- change that produces them and forwarded to Redis by the API's outbox relay
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    topic VARCHAR(100) NOT NULL, -- Redis list the payload is pushed to
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    dispatched_at TIMESTAMP
+);
+
+CREATE INDEX idx_outbox_pending ON outbox(id) WHERE dispatched_at IS NULL;
+
+-- Wake the relay as soon as an outbox row is committed
+CREATE OR REPLACE FUNCTION notify_outbox()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('outbox', NEW.topic);
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER outbox_notify AFTER INSERT ON outbox
+    FOR EACH ROW EXECUTE FUNCTION notify_outbox();
@@ -0,0 +1,33 @@
+-- Asset changefeed: an ordered log of catalog changes for downstream sync.
+-- Writers take pg_advisory_xact_lock(hashtext('asset_changes')) before
+-- inserting, so rows become visible in seq order.
+CREATE TABLE IF NOT EXISTS asset_changes (
+    seq BIGSERIAL PRIMARY KEY,
+    asset_id UUID NOT NULL, -- no FK: tombstones outlive the asset
+    op VARCHAR(10) NOT NULL CHECK (op IN ('create', 'update', 'state', 'delete')),
+    asset JSONB, -- snapshot after the change; NULL for deletes
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_asset_changes_asset_id ON asset_changes(asset_id);
+
+-- Seed the log with the existing catalog so consumers can start from zero
+INSERT INTO asset_changes (asset_id, op, asset)
+SELECT a.id, 'create', jsonb_strip_nulls(jsonb_build_object(
+    'id', a.id,
+    'kind', a.kind,
+    'state', a.state,
+    'visibility', a.visibility,
+    'filename', a.filename,
+    'mimeType', a.mime_type,
+    'size', a.size_bytes,
+    'bucket', a.bucket,
+    'objectKey', a.object_key,
+    'width', m.width,
+    'height', m.height,
+    'duration', m.duration_seconds,
+    'createdAt', to_char(a.created_at, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')
+))
+FROM assets a
+LEFT JOIN asset_meta m ON a.id = m.asset_id
+ORDER BY a.created_at, a.id;
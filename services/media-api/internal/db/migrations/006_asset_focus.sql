@@ -0,0 +1,7 @@
+-- Focal points and crop hints chosen by editors for smart cropping, in
+-- fractions of the image. Crop hints are a JSON array of {x, y, width, height}.
+ALTER TABLE asset_meta ADD COLUMN IF NOT EXISTS focal_x DOUBLE PRECISION
+    CHECK (focal_x BETWEEN 0 AND 1);
+ALTER TABLE asset_meta ADD COLUMN IF NOT EXISTS focal_y DOUBLE PRECISION
+    CHECK (focal_y BETWEEN 0 AND 1);
+ALTER TABLE asset_meta ADD COLUMN IF NOT EXISTS crop_hints JSONB;
@@ -0,0 +1,5 @@
+-- Placeholders computed by the worker for images and video posters: a
+-- BlurHash, a base64 ThumbHash and the dominant color as #rrggbb.
+ALTER TABLE asset_meta ADD COLUMN IF NOT EXISTS blurhash TEXT;
+ALTER TABLE asset_meta ADD COLUMN IF NOT EXISTS thumbhash TEXT;
+ALTER TABLE asset_meta ADD COLUMN IF NOT EXISTS dominant_color TEXT;
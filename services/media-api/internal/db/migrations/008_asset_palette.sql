@@ -0,0 +1,4 @@
+-- Color palettes computed by the worker: a JSON array of
+-- {color: "#rrggbb", percentage, lab: [l, a, b]}, largest first. The CIELAB
+-- coordinates let assets be searched by color.
+ALTER TABLE asset_meta ADD COLUMN IF NOT EXISTS palette JSONB;
@@ -0,0 +1,5 @@
+-- Capture time read by the worker from image metadata (also in exif), so
+-- assets can be listed in the order they were taken.
+ALTER TABLE asset_meta ADD COLUMN IF NOT EXISTS captured_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_asset_meta_captured_at ON asset_meta(captured_at DESC);
@@ -0,0 +1,8 @@
+-- Header probe of images, read by the worker before an image turns ready.
+-- Width and height go in the existing columns.
+ALTER TABLE asset_meta ADD COLUMN IF NOT EXISTS format TEXT;
+ALTER TABLE asset_meta ADD COLUMN IF NOT EXISTS color_space TEXT;
+ALTER TABLE asset_meta ADD COLUMN IF NOT EXISTS color_profile TEXT;
+ALTER TABLE asset_meta ADD COLUMN IF NOT EXISTS bit_depth INTEGER;
+ALTER TABLE asset_meta ADD COLUMN IF NOT EXISTS has_alpha BOOLEAN;
+ALTER TABLE asset_meta ADD COLUMN IF NOT EXISTS frame_count INTEGER;
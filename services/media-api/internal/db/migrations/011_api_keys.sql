@@ -0,0 +1,9 @@
+-- API keys authorize management requests; only a hash of each key is stored
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) NOT NULL, -- start of the key, to tell keys apart
+    key_hash CHAR(64) NOT NULL UNIQUE, -- hex SHA-256 of the key
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
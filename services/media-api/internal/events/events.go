@@ -0,0 +1,171 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Key layout (must match worker). Every event is appended to a capped
+// per-asset stream so clients can resume, then announced on a pub/sub
+// channel for live subscribers.
+const (
+	keyPrefix    = "media:events:"
+	StreamMaxLen = 200
+	StreamTTL    = 24 * time.Hour
+)
+
+// Event types
+const (
+	TypeState    = "state"
+	TypeProgress = "progress"
+)
+
+// Event is a state change or progress update of an asset
+type Event struct {
+	ID       string    `json:"id,omitempty"`
+	AssetID  string    `json:"assetId"`
+	Type     string    `json:"type"`
+	State    string    `json:"state,omitempty"`
+	Progress *float64  `json:"progress,omitempty"`
+	Message  string    `json:"message,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// Channel is the pub/sub channel for an asset's events
+func Channel(assetID string) string {
+	return keyPrefix + assetID
+}
+
+// StreamKey is the stream holding an asset's recent events
+func StreamKey(assetID string) string {
+	return keyPrefix + assetID + ":log"
+}
+
+// Publish records an event in the asset's stream and announces it
+func Publish(ctx context.Context, rdb *redis.Client, ev Event) error {
+	if ev.At.IsZero() {
+		ev.At = time.Now().UTC()
+	}
+	ev.ID = ""
+
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	id, err := rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: StreamKey(ev.AssetID),
+		MaxLen: StreamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{"event": data},
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to append event: %w", err)
+	}
+	rdb.Expire(ctx, StreamKey(ev.AssetID), StreamTTL)
+
+	ev.ID = id
+	data, err = json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	if err := rdb.Publish(ctx, Channel(ev.AssetID), data).Err(); err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	return nil
+}
+
+// Replay returns the events recorded after afterID, oldest first
+func Replay(ctx context.Context, rdb *redis.Client, assetID, afterID string) ([]Event, error) {
+	msgs, err := rdb.XRange(ctx, StreamKey(assetID), "("+afterID, "+").Result()
+	if err != nil {
+		return nil, err
+	}
+	return decodeMessages(msgs), nil
+}
+
+// Latest returns the most recent event of an asset, or nil if there is none
+func Latest(ctx context.Context, rdb *redis.Client, assetID string) (*Event, error) {
+	msgs, err := rdb.XRevRangeN(ctx, StreamKey(assetID), "+", "-", 1).Result()
+	if err != nil {
+		return nil, err
+	}
+	events := decodeMessages(msgs)
+	if len(events) == 0 {
+		return nil, nil
+	}
+	return &events[0], nil
+}
+
+// Decode parses an event announced on a pub/sub channel
+func Decode(payload string) (*Event, error) {
+	var ev Event
+	if err := json.Unmarshal([]byte(payload), &ev); err != nil {
+		return nil, err
+	}
+	return &ev, nil
+}
+
+func decodeMessages(msgs []redis.XMessage) []Event {
+	events := make([]Event, 0, len(msgs))
+	for _, msg := range msgs {
+		raw, ok := msg.Values["event"].(string)
+		if !ok {
+			continue
+		}
+		var ev Event
+		if err := json.Unmarshal([]byte(raw), &ev); err != nil {
+			continue
+		}
+		ev.ID = msg.ID
+		events = append(events, ev)
+	}
+	return events
+}
+
+// ValidID reports whether id is a stream ID ("<ms>-<seq>")
+func ValidID(id string) bool {
+	_, _, ok := parseID(id)
+	return ok
+}
+
+// After reports whether stream ID a was recorded after b.
+// An empty b sorts before every ID.
+func After(a, b string) bool {
+	if b == "" {
+		return true
+	}
+	aMs, aSeq, okA := parseID(a)
+	bMs, bSeq, okB := parseID(b)
+	if !okA || !okB {
+		return a > b
+	}
+	if aMs != bMs {
+		return aMs > bMs
+	}
+	return aSeq > bSeq
+}
+
+func parseID(id string) (uint64, uint64, bool) {
+	msPart, seqPart, found := strings.Cut(id, "-")
+	if !found {
+		return 0, 0, false
+	}
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return ms, seq, true
+}
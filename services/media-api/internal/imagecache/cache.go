@@ -0,0 +1,228 @@
+// Package imagecache keeps transformed images so that repeat requests are
+// served without imgproxy. A bounded LRU on local disk sits in front of the
+// cache bucket, which all API instances share.
+package imagecache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ancill/mediapod/services/media-api/internal/config"
+	"github.com/ancill/mediapod/services/media-api/internal/storage"
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog/log"
+)
+
+// Tiers reported for cache hits
+const (
+	TierDisk    = "disk"
+	TierStorage = "storage"
+)
+
+const (
+	// keyPrefix is where entries live in the cache bucket
+	keyPrefix = "transforms/"
+	// invalidateChannel tells other API instances to drop disk entries
+	invalidateChannel = "media:image-cache:invalidate"
+	// putTimeout bounds the write-through to the bucket
+	putTimeout = 30 * time.Second
+)
+
+// Entry is a cached image
+type Entry struct {
+	ContentType string
+	Data        []byte
+}
+
+// Cache is the two-tier cache of transformed images. Either tier may be
+// disabled by configuration; with both disabled every lookup misses.
+type Cache struct {
+	disk          *diskLRU
+	storage       *storage.MinIO
+	bucket        string
+	maxObjectSize int64
+	redis         *redis.Client
+
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func New(cfg config.ImageCacheConfig, store *storage.MinIO, redisClient *redis.Client) (*Cache, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Cache{
+		maxObjectSize: cfg.MaxObjectSize,
+		redis:         redisClient,
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+	if !cfg.Enabled {
+		return c, nil
+	}
+
+	if cfg.DiskSize > 0 {
+		disk, err := newDiskLRU(cfg.Dir, cfg.DiskSize)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		c.disk = disk
+	}
+	if cfg.Bucket != "" {
+		c.storage = store
+		c.bucket = cfg.Bucket
+	}
+	return c, nil
+}
+
+// Key is the cache key of a rendition of a source object. name identifies the
+// rendition; the cache bucket must not be readable anonymously, as it holds
+// renditions of private images too.
+func Key(sourceBucket, sourceKey, name string) string {
+	return sourcePrefix(sourceBucket, sourceKey) + name
+}
+
+func sourcePrefix(sourceBucket, sourceKey string) string {
+	return keyPrefix + sourceBucket + "/" + sourceKey + "/"
+}
+
+// Enabled reports whether any tier is active
+func (c *Cache) Enabled() bool {
+	return c.disk != nil || c.storage != nil
+}
+
+// MaxObjectSize is the size of the largest image worth caching
+func (c *Cache) MaxObjectSize() int64 {
+	return c.maxObjectSize
+}
+
+// Get looks an image up on disk, then in the bucket, and returns the tier it
+// was found in. Bucket hits are copied to disk.
+func (c *Cache) Get(ctx context.Context, key string) (*Entry, string, bool) {
+	if c.disk != nil {
+		if entry, ok := c.disk.get(key); ok {
+			return entry, TierDisk, true
+		}
+	}
+	if c.storage == nil {
+		return nil, "", false
+	}
+
+	obj, err := c.storage.GetObject(ctx, c.bucket, key)
+	if err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("Failed to read image cache")
+		return nil, "", false
+	}
+	defer obj.Close()
+
+	info, err := obj.Stat()
+	if err != nil {
+		if !storage.IsNotFound(err) {
+			log.Warn().Err(err).Str("key", key).Msg("Failed to read image cache")
+		}
+		return nil, "", false
+	}
+	if info.Size > c.maxObjectSize {
+		return nil, "", false
+	}
+
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("Failed to read image cache")
+		return nil, "", false
+	}
+
+	entry := &Entry{ContentType: info.ContentType, Data: data}
+	if c.disk != nil {
+		if err := c.disk.put(key, entry); err != nil {
+			log.Warn().Err(err).Msg("Failed to write image cache")
+		}
+	}
+	return entry, TierStorage, true
+}
+
+// Put stores an image on disk and writes it through to the bucket in the
+// background. Images larger than MaxObjectSize are ignored.
+func (c *Cache) Put(key string, entry *Entry) {
+	if int64(len(entry.Data)) > c.maxObjectSize {
+		return
+	}
+
+	if c.disk != nil {
+		if err := c.disk.put(key, entry); err != nil {
+			log.Warn().Err(err).Msg("Failed to write image cache")
+		}
+	}
+
+	if c.storage != nil {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), putTimeout)
+			defer cancel()
+			if err := c.storage.PutObject(ctx, c.bucket, key, entry.Data, entry.ContentType); err != nil {
+				log.Warn().Err(err).Str("key", key).Msg("Failed to write image cache to storage")
+			}
+		}()
+	}
+}
+
+// Invalidate drops every cached rendition of a source object, here, in the
+// bucket and on the disks of the other API instances. A transform that is
+// in flight may still store its result afterwards.
+func (c *Cache) Invalidate(ctx context.Context, sourceBucket, sourceKey string) error {
+	prefix := sourcePrefix(sourceBucket, sourceKey)
+
+	if c.disk != nil {
+		c.disk.removePrefix(prefix)
+	}
+	if c.redis != nil && c.Enabled() {
+		if err := c.redis.Publish(ctx, invalidateChannel, prefix).Err(); err != nil {
+			log.Warn().Err(err).Msg("Failed to announce image cache invalidation")
+		}
+	}
+	if c.storage != nil {
+		if err := c.storage.DeletePrefix(ctx, c.bucket, prefix); err != nil {
+			return fmt.Errorf("failed to invalidate image cache: %w", err)
+		}
+	}
+	return nil
+}
+
+// Start listens for invalidations from other API instances
+func (c *Cache) Start() {
+	if c.disk == nil || c.redis == nil {
+		return
+	}
+	c.wg.Add(1)
+	go c.listen()
+}
+
+// Stop ends the listener and waits for pending writes to the bucket
+func (c *Cache) Stop() {
+	c.cancel()
+	c.wg.Wait()
+}
+
+func (c *Cache) listen() {
+	defer c.wg.Done()
+
+	sub := c.redis.Subscribe(c.ctx, invalidateChannel)
+	defer sub.Close()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			c.disk.removePrefix(msg.Payload)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package imagecache
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// diskLRU is a size-bounded cache of entries in a local directory. Each file
+// starts with two header lines, the cache key and the content type, followed
+// by the image. The recency order lives in memory and is rebuilt from file
+// modification times on startup.
+type diskLRU struct {
+	dir     string
+	maxSize int64
+
+	mu      sync.Mutex
+	size    int64
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+}
+
+type diskEntry struct {
+	key  string
+	size int64
+}
+
+func newDiskLRU(dir string, maxSize int64) (*diskLRU, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create image cache directory: %w", err)
+	}
+
+	d := &diskLRU{
+		dir:     dir,
+		maxSize: maxSize,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// load indexes the files left by a previous run, oldest last
+func (d *diskLRU) load() error {
+	type found struct {
+		key     string
+		size    int64
+		modTime time.Time
+	}
+	var files []found
+
+	err := filepath.WalkDir(d.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		if strings.HasSuffix(path, ".tmp") {
+			os.Remove(path)
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		key, _, err := readHeader(path)
+		if err != nil || d.path(key) != path {
+			log.Warn().Str("path", path).Msg("Removing unreadable image cache file")
+			os.Remove(path)
+			return nil
+		}
+		files = append(files, found{key: key, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load image cache: %w", err)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+	for _, f := range files {
+		d.entries[f.key] = d.order.PushBack(&diskEntry{key: f.key, size: f.size})
+		d.size += f.size
+	}
+	d.mu.Lock()
+	d.evict()
+	d.mu.Unlock()
+
+	log.Info().Int("entries", len(files)).Int64("bytes", d.size).Msg("Loaded image cache")
+	return nil
+}
+
+// path is the file of a key, spread over 256 subdirectories
+func (d *diskLRU) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(d.dir, name[:2], name)
+}
+
+func (d *diskLRU) get(key string) (*Entry, bool) {
+	d.mu.Lock()
+	elem, ok := d.entries[key]
+	if ok {
+		d.order.MoveToFront(elem)
+	}
+	d.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	path := d.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		d.remove(key)
+		return nil, false
+	}
+	_, contentType, body, ok := splitFile(data)
+	if !ok {
+		d.remove(key)
+		return nil, false
+	}
+	// Keep the recency order across restarts
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	return &Entry{ContentType: contentType, Data: body}, true
+}
+
+func (d *diskLRU) put(key string, entry *Entry) error {
+	path := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create image cache directory: %w", err)
+	}
+
+	// Write to a temporary file and rename, so readers never see a partial file
+	f, err := os.CreateTemp(filepath.Dir(path), "*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create image cache file: %w", err)
+	}
+	tmp := f.Name()
+	_, err = fmt.Fprintf(f, "%s\n%s\n", key, entry.ContentType)
+	if err == nil {
+		_, err = f.Write(entry.Data)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write image cache file: %w", err)
+	}
+
+	size := int64(len(key) + len(entry.ContentType) + 2 + len(entry.Data))
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if elem, ok := d.entries[key]; ok {
+		d.size -= elem.Value.(*diskEntry).size
+		elem.Value.(*diskEntry).size = size
+		d.order.MoveToFront(elem)
+	} else {
+		d.entries[key] = d.order.PushFront(&diskEntry{key: key, size: size})
+	}
+	d.size += size
+	d.evict()
+	return nil
+}
+
+// evict drops the least recently used entries until the cache fits.
+// Callers hold d.mu.
+func (d *diskLRU) evict() {
+	for d.size > d.maxSize {
+		elem := d.order.Back()
+		if elem == nil {
+			return
+		}
+		entry := elem.Value.(*diskEntry)
+		d.order.Remove(elem)
+		delete(d.entries, entry.key)
+		d.size -= entry.size
+		os.Remove(d.path(entry.key))
+	}
+}
+
+func (d *diskLRU) remove(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if elem, ok := d.entries[key]; ok {
+		d.order.Remove(elem)
+		delete(d.entries, key)
+		d.size -= elem.Value.(*diskEntry).size
+	}
+	os.Remove(d.path(key))
+}
+
+// removePrefix drops every entry whose key starts with prefix
+func (d *diskLRU) removePrefix(prefix string) int {
+	d.mu.Lock()
+	var keys []string
+	for key := range d.entries {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, key := range keys {
+		d.remove(key)
+	}
+	return len(keys)
+}
+
+// readHeader returns the key and content type stored at the start of a file
+func readHeader(path string) (key, contentType string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	head, err := io.ReadAll(io.LimitReader(f, 4096))
+	if err != nil {
+		return "", "", err
+	}
+	key, contentType, _, ok := splitFile(head)
+	if !ok {
+		return "", "", fmt.Errorf("missing header")
+	}
+	return key, contentType, nil
+}
+
+// splitFile splits a cache file into its header lines and the image
+func splitFile(data []byte) (key, contentType string, body []byte, ok bool) {
+	keyLine, rest, ok := bytes.Cut(data, []byte("\n"))
+	if !ok {
+		return "", "", nil, false
+	}
+	typeLine, body, ok := bytes.Cut(rest, []byte("\n"))
+	if !ok {
+		return "", "", nil, false
+	}
+	return string(keyLine), string(typeLine), body, true
+}
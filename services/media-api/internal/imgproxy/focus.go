@@ -0,0 +1,90 @@
+package imgproxy
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// FocalPoint is the point of an image that crops keep, in fractions of its
+// width and height from the top left corner
+type FocalPoint struct {
+	X float64 `json:"x" minimum:"0" maximum:"1"`
+	Y float64 `json:"y" minimum:"0" maximum:"1"`
+}
+
+// String renders the point as gravity arguments (fp:x:y)
+func (p *FocalPoint) String() string {
+	return "fp:" + strconv.FormatFloat(p.X, 'f', -1, 64) + ":" + strconv.FormatFloat(p.Y, 'f', -1, 64)
+}
+
+// CropHint is an editor's choice of region for outputs of its aspect ratio,
+// in fractions of the image
+type CropHint struct {
+	X      float64 `json:"x" minimum:"0" maximum:"1"`
+	Y      float64 `json:"y" minimum:"0" maximum:"1"`
+	Width  float64 `json:"width" minimum:"0" maximum:"1"`
+	Height float64 `json:"height" minimum:"0" maximum:"1"`
+}
+
+// Validate checks that the hint is a non-empty region inside the image
+func (c *CropHint) Validate() error {
+	if c.Width <= 0 || c.Height <= 0 || c.X < 0 || c.Y < 0 || c.X+c.Width > 1.0001 || c.Y+c.Height > 1.0001 {
+		return fmt.Errorf("crop hint must be a non-empty region inside the image")
+	}
+	return nil
+}
+
+// Focus is what editors stored about the composition of an image
+type Focus struct {
+	Point *FocalPoint
+	Hints []CropHint
+}
+
+// hintTolerance is how far, relatively, the aspect ratio of a crop hint may
+// be from that of the output for the hint to apply
+const hintTolerance = 0.02
+
+// WithFocus points crops and fill resizes at the focus of a width×height
+// image. A crop hint with the aspect ratio of a filled output becomes the
+// crop, and the focal point replaces automatic gravities (none, ce or sm).
+// Explicit crops and directional gravities are kept; fit resizes keep the
+// whole image and are left alone.
+func (o Operations) WithFocus(focus Focus, width, height int) Operations {
+	fills := o.Resize != nil && (o.Resize.Type == "fill" || o.Resize.Type == "fill-down" || o.Resize.Type == "auto")
+	if !fills && o.Crop == nil {
+		return o
+	}
+
+	if fills && o.Crop == nil && width > 0 && height > 0 && o.Resize.Width > 0 && o.Resize.Height > 0 {
+		aspect := float64(o.Resize.Width) / float64(o.Resize.Height)
+		for _, hint := range focus.Hints {
+			cropWidth, cropHeight := hint.Width*float64(width), hint.Height*float64(height)
+			if math.Abs(cropWidth/cropHeight-aspect)/aspect > hintTolerance {
+				continue
+			}
+			// Centered on its own center, a crop of the hint's size is the hint
+			o.Crop = &CropOp{
+				Width:      int(math.Round(cropWidth)),
+				Height:     int(math.Round(cropHeight)),
+				FocalPoint: &FocalPoint{X: hint.X + hint.Width/2, Y: hint.Y + hint.Height/2},
+			}
+			return o
+		}
+	}
+
+	if focus.Point == nil {
+		return o
+	}
+	if o.Crop != nil && o.Crop.FocalPoint == nil && (o.Crop.Gravity == "ce" || o.Crop.Gravity == "sm") {
+		crop := *o.Crop
+		crop.Gravity = ""
+		crop.FocalPoint = focus.Point
+		o.Crop = &crop
+	}
+	if o.FocalPoint == nil && (o.Gravity == "" || o.Gravity == "ce" || o.Gravity == "sm") {
+		o.Gravity = ""
+		o.FocalPoint = focus.Point
+	}
+	return o
+}
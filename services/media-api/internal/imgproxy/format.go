@@ -0,0 +1,52 @@
+package imgproxy
+
+import (
+	"path"
+	"strconv"
+	"strings"
+)
+
+// FormatAuto asks for the best format the client accepts. imgproxy does not
+// know it; the API resolves it with NegotiateFormat before signing.
+const FormatAuto = "auto"
+
+// NegotiateFormat picks the output format for an Accept header: AVIF, then
+// WebP, falling back to PNG for sources that may be transparent and JPEG
+// otherwise
+func NegotiateFormat(accept, source string) string {
+	switch {
+	case Accepts(accept, "image/avif"):
+		return "avif"
+	case Accepts(accept, "image/webp"):
+		return "webp"
+	}
+
+	switch strings.ToLower(path.Ext(source)) {
+	case ".png", ".gif", ".webp", ".avif", ".svg", ".heic":
+		return "png"
+	default:
+		return "jpg"
+	}
+}
+
+// Accepts reports whether an Accept header explicitly lists a media type
+// with a non-zero quality. Wildcards do not count: browsers send image/*
+// whether or not they decode AVIF.
+func Accepts(accept, mediaType string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(params[0]), mediaType) {
+			continue
+		}
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if name == "q" {
+				if q, err := strconv.ParseFloat(value, 64); err == nil && q <= 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package imgproxy
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// HintHeaders are the Client Hints image delivery asks for (Accept-CH) and
+// varies on
+const HintHeaders = "Sec-CH-DPR, Sec-CH-Width, Sec-CH-Viewport-Width"
+
+// Hints are the Client Hints of an image request; zero means absent
+type Hints struct {
+	// DPR is the device pixel ratio
+	DPR float64
+	// Width is the layout width of the image in physical pixels
+	Width int
+	// ViewportWidth is the layout viewport width in CSS pixels
+	ViewportWidth int
+}
+
+// ParseHints reads the Sec-CH-* headers, falling back to the legacy DPR,
+// Width and Viewport-Width headers. Malformed values are ignored.
+func ParseHints(header http.Header) Hints {
+	value := func(names ...string) float64 {
+		for _, name := range names {
+			if v := strings.TrimSpace(header.Get(name)); v != "" {
+				f, err := strconv.ParseFloat(v, 64)
+				if err != nil || f <= 0 || math.IsInf(f, 0) {
+					return 0
+				}
+				return f
+			}
+		}
+		return 0
+	}
+
+	return Hints{
+		DPR:           value("Sec-CH-DPR", "DPR"),
+		Width:         int(math.Ceil(value("Sec-CH-Width", "Width"))),
+		ViewportWidth: int(math.Ceil(value("Sec-CH-Viewport-Width", "Viewport-Width"))),
+	}
+}
+
+// HintBounds limit how far hints may change operations
+type HintBounds struct {
+	MaxDPR       float64
+	MaxDimension int
+	// Widths are the breakpoints width hints round up to, in ascending order,
+	// so that only a few renditions exist per image
+	Widths []int
+}
+
+// WithHints adapts operations to the client: a narrower layout shrinks the
+// output width (never growing it beyond what the operations ask for) and the
+// DPR multiplies it, unless the operations set their own. The DPR is rounded
+// to halves and capped at MaxDPR and MaxDimension. Sec-CH-Width already
+// counts physical pixels, so it is not multiplied again.
+func (o Operations) WithHints(hints Hints, bounds HintBounds) Operations {
+	width := o.Width
+	if o.Resize != nil {
+		width = o.Resize.Width
+	}
+	if width <= 0 {
+		// Nothing to scale against
+		return o
+	}
+
+	target := width
+	physical := false
+	switch {
+	case hints.Width > 0:
+		target = snapWidth(hints.Width, bounds.Widths)
+		physical = true
+	case hints.ViewportWidth > 0:
+		target = snapWidth(hints.ViewportWidth, bounds.Widths)
+	}
+	if target > 0 && target < width {
+		o.scaleWidth(width, target)
+		width = target
+	}
+
+	if physical || hints.DPR <= 1 || o.DPR > 0 {
+		return o
+	}
+	dpr := math.Min(math.Round(hints.DPR*2)/2, bounds.MaxDPR)
+	dpr = math.Min(dpr, math.Floor(float64(bounds.MaxDimension)/float64(width)*2)/2)
+	if dpr > 1 {
+		o.DPR = dpr
+	}
+	return o
+}
+
+// scaleWidth shrinks the output from width to target, keeping its aspect ratio
+func (o *Operations) scaleWidth(width, target int) {
+	scale := func(v int) int {
+		return int(math.Round(float64(v) * float64(target) / float64(width)))
+	}
+
+	if o.Resize != nil {
+		resize := *o.Resize
+		resize.Width = target
+		resize.Height = scale(resize.Height)
+		o.Resize = &resize
+		return
+	}
+	o.Width = target
+	o.Height = scale(o.Height)
+}
+
+// snapWidth rounds a width up to the next breakpoint; widths beyond the last
+// one are left alone (0) since no breakpoint can shrink the output
+func snapWidth(width int, breakpoints []int) int {
+	for _, bp := range breakpoints {
+		if bp >= width {
+			return bp
+		}
+	}
+	return 0
+}
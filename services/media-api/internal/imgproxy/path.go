@@ -0,0 +1,348 @@
+package imgproxy
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// optionAliases maps the short names of imgproxy processing options to their
+// full names (https://docs.imgproxy.net/usage/processing)
+var optionAliases = map[string]string{
+	"dpr":  "dpr", // has no short name
+	"rs":   "resize",
+	"s":    "size",
+	"rt":   "resizing_type",
+	"w":    "width",
+	"h":    "height",
+	"mw":   "min-width",
+	"mh":   "min-height",
+	"z":    "zoom",
+	"el":   "enlarge",
+	"ex":   "extend",
+	"exar": "extend_aspect_ratio",
+	"g":    "gravity",
+	"c":    "crop",
+	"t":    "trim",
+	"pd":   "padding",
+	"ar":   "auto_rotate",
+	"rot":  "rotate",
+	"bg":   "background",
+	"bl":   "blur",
+	"sh":   "sharpen",
+	"pix":  "pixelate",
+	"wm":   "watermark",
+	"wmu":  "watermark_url",
+	"sm":   "strip_metadata",
+	"kcr":  "keep_copyright",
+	"scp":  "strip_color_profile",
+	"q":    "quality",
+	"fq":   "format_quality",
+	"mb":   "max_bytes",
+	"f":    "format",
+	"ext":  "format",
+	"exp":  "expires",
+	"cb":   "cachebuster",
+	"fn":   "filename",
+	"att":  "return_attachment",
+	"pr":   "preset",
+}
+
+// DefaultAllowedOptions are the processing options accepted from clients
+// unless configuration says otherwise. Options that can blow up the output
+// (zoom, min-width, ...) or reach outside the request (preset) are left out;
+// watermark_url sources are held to the allowed buckets.
+var DefaultAllowedOptions = []string{
+	"resize", "size", "resizing_type", "width", "height", "dpr", "enlarge",
+	"extend", "gravity", "crop", "padding", "auto_rotate", "rotate",
+	"background", "blur", "sharpen", "strip_metadata", "quality", "format",
+	"watermark", "watermark_url", "expires",
+}
+
+// OptionName returns the full name of a processing option given by its full
+// or short name, and whether imgproxy knows it
+func OptionName(name string) (string, bool) {
+	if full, ok := optionAliases[name]; ok {
+		return full, true
+	}
+	for _, full := range optionAliases {
+		if full == name {
+			return name, true
+		}
+	}
+	return name, false
+}
+
+// HasOption reports whether an option chain such as "rs:fit:400:400/wm:1"
+// contains the option, given by its full name
+func HasOption(operations, name string) bool {
+	for _, segment := range strings.Split(operations, "/") {
+		short, _, _ := strings.Cut(segment, ":")
+		if full, _ := OptionName(short); full == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Option is one processing option of an imgproxy path, e.g. rs:fit:400:400
+type Option struct {
+	Name string // full name
+	Args []string
+}
+
+// Path is a parsed imgproxy path: the processing options followed by the
+// source URL
+type Path struct {
+	Options []Option
+	Source  string
+}
+
+// Errors returned by ParsePath and Policy.Check
+var (
+	ErrInvalidPath       = errors.New("invalid image path")
+	ErrOptionNotAllowed  = errors.New("processing option not allowed")
+	ErrSourceNotAllowed  = errors.New("image source not allowed")
+	ErrOutputTooLarge    = errors.New("output dimensions too large")
+	ErrInvalidOptionArgs = errors.New("invalid processing option arguments")
+)
+
+// ParsePath splits an imgproxy path (without signature or leading slash)
+// into options and the source URL. Sources are base64url encoded, optionally
+// followed by .{extension}, or plain/{escaped URL}[@{extension}].
+func ParsePath(path string) (*Path, error) {
+	segments := strings.Split(path, "/")
+	p := &Path{}
+
+	for i, segment := range segments {
+		if segment == "plain" {
+			source := strings.Join(segments[i+1:], "/")
+			if at := strings.LastIndex(source, "@"); at >= 0 {
+				p.Options = append(p.Options, Option{Name: "format", Args: []string{source[at+1:]}})
+				source = source[:at]
+			}
+			unescaped, err := url.PathUnescape(source)
+			if err != nil || unescaped == "" {
+				return nil, fmt.Errorf("%w: bad plain source", ErrInvalidPath)
+			}
+			p.Source = unescaped
+			return p, nil
+		}
+
+		if i == len(segments)-1 {
+			encoded, extension, _ := strings.Cut(segment, ".")
+			decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+			if err != nil || len(decoded) == 0 {
+				return nil, fmt.Errorf("%w: bad encoded source", ErrInvalidPath)
+			}
+			if extension != "" {
+				p.Options = append(p.Options, Option{Name: "format", Args: []string{extension}})
+			}
+			p.Source = string(decoded)
+			return p, nil
+		}
+
+		if segment == "" {
+			return nil, fmt.Errorf("%w: empty option", ErrInvalidPath)
+		}
+		parts := strings.Split(segment, ":")
+		name, _ := OptionName(parts[0])
+		p.Options = append(p.Options, Option{Name: name, Args: parts[1:]})
+	}
+
+	return nil, fmt.Errorf("%w: missing source", ErrInvalidPath)
+}
+
+// Expiry returns the Unix time of the expires option, or zero
+func (p *Path) Expiry() int64 {
+	for _, opt := range p.Options {
+		if opt.Name == "expires" && len(opt.Args) > 0 {
+			if expiresAt, err := strconv.ParseInt(opt.Args[0], 10, 64); err == nil {
+				return expiresAt
+			}
+		}
+	}
+	return 0
+}
+
+// String renders the path with full option names and an encoded source
+func (p *Path) String() string {
+	return p.render("")
+}
+
+// Canonical renders the path like String but leaves out expires, so that
+// equivalent paths render the same
+func (p *Path) Canonical() string {
+	return p.render("expires")
+}
+
+func (p *Path) render(skip string) string {
+	segments := make([]string, 0, len(p.Options)+1)
+	for _, opt := range p.Options {
+		if opt.Name == skip {
+			continue
+		}
+		segments = append(segments, strings.Join(append([]string{opt.Name}, opt.Args...), ":"))
+	}
+	segments = append(segments, base64URLEncode([]byte(p.Source)))
+	return "/" + strings.Join(segments, "/")
+}
+
+// Format returns the output format of the path, or "" when it has none
+func (p *Path) Format() string {
+	format := ""
+	for _, opt := range p.Options {
+		if opt.Name == "format" && len(opt.Args) > 0 {
+			format = opt.Args[0] // the last one wins, as in imgproxy
+		}
+	}
+	return format
+}
+
+// HasOption reports whether the path has the option, given by its full name
+func (p *Path) HasOption(name string) bool {
+	for _, opt := range p.Options {
+		if opt.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SetFormat replaces the output format of the path
+func (p *Path) SetFormat(format string) {
+	options := p.Options[:0]
+	for _, opt := range p.Options {
+		if opt.Name != "format" {
+			options = append(options, opt)
+		}
+	}
+	p.Options = append(options, Option{Name: "format", Args: []string{format}})
+}
+
+// Policy limits what signed image paths may ask imgproxy to do
+type Policy struct {
+	// AllowedOptions holds full option names
+	AllowedOptions map[string]bool
+	// MaxDimension bounds the output width and height set by sizing options,
+	// after dpr. Crops pick a region of the source and are clamped to it, so
+	// only the source limits them.
+	MaxDimension int
+	// AllowedBuckets are the buckets sources may be read from (s3:// only)
+	AllowedBuckets map[string]bool
+}
+
+// NewPolicy creates a policy from lists of option names (full or short)
+// and bucket names
+func NewPolicy(options []string, maxDimension int, buckets []string) *Policy {
+	p := &Policy{
+		AllowedOptions: make(map[string]bool),
+		MaxDimension:   maxDimension,
+		AllowedBuckets: make(map[string]bool),
+	}
+	for _, name := range options {
+		name, _ = OptionName(name)
+		p.AllowedOptions[name] = true
+	}
+	for _, bucket := range buckets {
+		p.AllowedBuckets[bucket] = true
+	}
+	return p
+}
+
+// Check returns an error wrapping ErrOptionNotAllowed, ErrInvalidOptionArgs,
+// ErrOutputTooLarge or ErrSourceNotAllowed when the path breaks the policy.
+// Watermark sources count as sources.
+func (p *Policy) Check(path *Path) error {
+	var width, height float64
+	dpr := 1.0
+
+	for _, opt := range path.Options {
+		if !p.AllowedOptions[opt.Name] {
+			return fmt.Errorf("%w: %s", ErrOptionNotAllowed, opt.Name)
+		}
+
+		// Positions of the width and height arguments of sizing options
+		var widthArg, heightArg = -1, -1
+		switch opt.Name {
+		case "resize":
+			widthArg, heightArg = 1, 2
+		case "size":
+			widthArg, heightArg = 0, 1
+		case "width":
+			widthArg = 0
+		case "height":
+			heightArg = 0
+		case "dpr":
+			v, err := floatArg(opt, 0)
+			if err != nil {
+				return err
+			}
+			if v > 0 {
+				dpr = v
+			}
+		case "watermark_url":
+			var source string
+			if len(opt.Args) > 0 {
+				decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(opt.Args[0], "="))
+				if err != nil {
+					return fmt.Errorf("%w: %s", ErrInvalidOptionArgs, opt.Name)
+				}
+				source = string(decoded)
+			}
+			if !p.sourceAllowed(source) {
+				return fmt.Errorf("%w: %s", ErrSourceNotAllowed, source)
+			}
+		}
+
+		if widthArg >= 0 {
+			v, err := floatArg(opt, widthArg)
+			if err != nil {
+				return err
+			}
+			if v > width {
+				width = v
+			}
+		}
+		if heightArg >= 0 {
+			v, err := floatArg(opt, heightArg)
+			if err != nil {
+				return err
+			}
+			if v > height {
+				height = v
+			}
+		}
+	}
+
+	limit := float64(p.MaxDimension)
+	if width*dpr > limit || height*dpr > limit {
+		return fmt.Errorf("%w: at most %dx%d pixels", ErrOutputTooLarge, p.MaxDimension, p.MaxDimension)
+	}
+
+	if !p.sourceAllowed(path.Source) {
+		return fmt.Errorf("%w: %s", ErrSourceNotAllowed, path.Source)
+	}
+	return nil
+}
+
+// sourceAllowed reports whether source is an s3:// object in an allowed bucket
+func (p *Policy) sourceAllowed(source string) bool {
+	u, err := url.Parse(source)
+	return err == nil && u.Scheme == "s3" && p.AllowedBuckets[u.Host] && strings.Trim(u.Path, "/") != ""
+}
+
+// floatArg parses an optional numeric argument; missing or empty ones are zero
+func floatArg(opt Option, i int) (float64, error) {
+	if i >= len(opt.Args) || opt.Args[i] == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(opt.Args[i], 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidOptionArgs, opt.Name)
+	}
+	return v, nil
+}
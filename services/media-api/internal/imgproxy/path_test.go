@@ -0,0 +1,177 @@
+package imgproxy
+
+import (
+	"errors"
+	"testing"
+)
+
+func encodeSource(source string) string {
+	return base64URLEncode([]byte(source))
+}
+
+func TestParsePath(t *testing.T) {
+	source := "s3://media-originals/2024/photo.jpg"
+	tests := []struct {
+		name    string
+		path    string
+		options []Option
+		source  string
+		err     error
+	}{
+		{
+			name:    "encoded source",
+			path:    "rs:fit:400:300/q:80/" + encodeSource(source),
+			options: []Option{{"resize", []string{"fit", "400", "300"}}, {"quality", []string{"80"}}},
+			source:  source,
+		},
+		{
+			name:    "encoded source with extension",
+			path:    "w:100/" + encodeSource(source) + ".webp",
+			options: []Option{{"width", []string{"100"}}, {"format", []string{"webp"}}},
+			source:  source,
+		},
+		{
+			name:    "padded source",
+			path:    "w:100/" + encodeSource("s3://b/k") + "==",
+			options: []Option{{"width", []string{"100"}}},
+			source:  "s3://b/k",
+		},
+		{
+			name:    "plain source",
+			path:    "size:10:20/plain/s3://media-originals/a%20b.jpg@png",
+			options: []Option{{"size", []string{"10", "20"}}, {"format", []string{"png"}}},
+			source:  "s3://media-originals/a b.jpg",
+		},
+		{
+			name:    "full option names",
+			path:    "resize:fill:10:10/expires:1700000000/" + encodeSource(source),
+			options: []Option{{"resize", []string{"fill", "10", "10"}}, {"expires", []string{"1700000000"}}},
+			source:  source,
+		},
+		{name: "source only", path: encodeSource(source), source: source},
+		{name: "empty", path: "", err: ErrInvalidPath},
+		{name: "empty option", path: "rs:fit:1:1//" + encodeSource(source), err: ErrInvalidPath},
+		{name: "bad base64", path: "w:1/not*base64", err: ErrInvalidPath},
+		{name: "empty plain source", path: "w:1/plain/", err: ErrInvalidPath},
+		{name: "bad escape", path: "w:1/plain/s3://b/%zz", err: ErrInvalidPath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParsePath(tt.path)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("ParsePath error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePath: %v", err)
+			}
+			if p.Source != tt.source {
+				t.Errorf("Source = %q, want %q", p.Source, tt.source)
+			}
+			if len(p.Options) != len(tt.options) {
+				t.Fatalf("Options = %v, want %v", p.Options, tt.options)
+			}
+			for i, opt := range tt.options {
+				if p.Options[i].Name != opt.Name || len(p.Options[i].Args) != len(opt.Args) {
+					t.Fatalf("Options[%d] = %v, want %v", i, p.Options[i], opt)
+				}
+				for j, arg := range opt.Args {
+					if p.Options[i].Args[j] != arg {
+						t.Errorf("Options[%d] = %v, want %v", i, p.Options[i], opt)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPathCanonical(t *testing.T) {
+	a, err := ParsePath("rs:fit:400:300/exp:1700000000/" + encodeSource("s3://b/k.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ParsePath("resize:fit:400:300/expires:1800000000/" + encodeSource("s3://b/k.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Canonical() != b.Canonical() {
+		t.Errorf("Canonical differs: %q and %q", a.Canonical(), b.Canonical())
+	}
+	if a.String() == b.String() {
+		t.Error("String leaves out expires")
+	}
+
+	reparsed, err := ParsePath(a.String()[1:])
+	if err != nil || reparsed.String() != a.String() {
+		t.Errorf("String does not round-trip: %q", a.String())
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	policy := NewPolicy(DefaultAllowedOptions, 4096, []string{"media-originals", "media-thumbs"})
+	source := encodeSource("s3://media-originals/a.jpg")
+
+	tests := []struct {
+		name string
+		path string
+		err  error // nil when allowed
+	}{
+		{"resize", "rs:fit:800:600/q:80/" + source, nil},
+		{"full names", "resize:fit:800:600/quality:80/" + source, nil},
+		{"at the limit", "s:4096:4096/" + source, nil},
+		{"dpr at the limit", "w:2048/dpr:2/" + source, nil},
+		{"empty dimensions", "rs:fit::/" + source, nil},
+		{"watermark from an allowed bucket", "wm:1:ce/wmu:" + encodeSource("s3://media-thumbs/logo.png") + "/" + source, nil},
+
+		{"short name not allowed", "z:2/" + source, ErrOptionNotAllowed},
+		{"full name not allowed", "zoom:2/" + source, ErrOptionNotAllowed},
+		{"preset not allowed", "pr:large/" + source, ErrOptionNotAllowed},
+		{"unknown option", "foo:1/" + source, ErrOptionNotAllowed},
+
+		{"too wide", "w:4097/" + source, ErrOutputTooLarge},
+		{"resize too tall", "rs:fill:100:5000/" + source, ErrOutputTooLarge},
+		{"crop larger than the limit", "c:5000:5000/" + source, nil},
+		{"crop with a resize too large", "c:1000:1000/rs:fit:5000:5000/" + source, ErrOutputTooLarge},
+		{"dpr over the limit", "rs:fit:3000:100/dpr:2/" + source, ErrOutputTooLarge},
+
+		{"negative width", "w:-1/" + source, ErrInvalidOptionArgs},
+		{"non-numeric size", "s:big:10/" + source, ErrInvalidOptionArgs},
+		{"non-numeric dpr", "dpr:x/" + source, ErrInvalidOptionArgs},
+		{"bad watermark source", "wmu:***/" + source, ErrInvalidOptionArgs},
+
+		{"other bucket", "w:100/" + encodeSource("s3://secrets/a.jpg"), ErrSourceNotAllowed},
+		{"http source", "w:100/" + encodeSource("http://169.254.169.254/latest/meta-data"), ErrSourceNotAllowed},
+		{"local file", "w:100/" + encodeSource("local:///etc/passwd"), ErrSourceNotAllowed},
+		{"bucket without key", "w:100/" + encodeSource("s3://media-originals/"), ErrSourceNotAllowed},
+		{"plain source from another bucket", "w:100/plain/s3://secrets/a.jpg", ErrSourceNotAllowed},
+		{"watermark from another bucket", "wmu:" + encodeSource("s3://secrets/logo.png") + "/" + source, ErrSourceNotAllowed},
+		{"watermark without source", "wmu/" + source, ErrSourceNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParsePath(tt.path)
+			if err != nil {
+				t.Fatalf("ParsePath: %v", err)
+			}
+			err = policy.Check(parsed)
+			if tt.err == nil {
+				if err != nil {
+					t.Errorf("Check: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Check = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestNewPolicyAcceptsShortNames(t *testing.T) {
+	policy := NewPolicy([]string{"rs", "q"}, 100, []string{"b"})
+	if !policy.AllowedOptions["resize"] || !policy.AllowedOptions["quality"] {
+		t.Errorf("AllowedOptions = %v", policy.AllowedOptions)
+	}
+}
@@ -0,0 +1,38 @@
+package imgproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// DefaultPresets are the named presets available unless configuration
+// redefines them
+var DefaultPresets = map[string]Operations{
+	"avatar-sm": {Resize: &ResizeOp{Type: "fill", Width: 64, Height: 64}, Gravity: "sm", Quality: 80, Format: "webp"},
+	"avatar":    {Resize: &ResizeOp{Type: "fill", Width: 256, Height: 256}, Gravity: "sm", Quality: 80, Format: "webp"},
+	"thumbnail": {Resize: &ResizeOp{Type: "fit", Width: 400, Height: 400}, Quality: 80, Format: "webp"},
+	"card":      {Resize: &ResizeOp{Type: "fill", Width: 800, Height: 450}, Gravity: "sm", Quality: 80, Format: "webp"},
+	"hero":      {Resize: &ResizeOp{Type: "fit", Width: 1920, Height: 1080}, Quality: 82, Format: "webp"},
+}
+
+// presetNamePattern keeps preset names safe to use as a URL path segment
+var presetNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// ParsePresets decodes a JSON object mapping preset names to Operations
+func ParsePresets(data []byte) (map[string]Operations, error) {
+	var presets map[string]Operations
+	if err := json.Unmarshal(data, &presets); err != nil {
+		return nil, fmt.Errorf("invalid presets: %w", err)
+	}
+
+	for name, ops := range presets {
+		if !presetNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid preset name %q: use lowercase letters, digits, - and _", name)
+		}
+		if ops.String() == "" {
+			return nil, fmt.Errorf("preset %q has no operations", name)
+		}
+	}
+	return presets, nil
+}
@@ -6,6 +6,8 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -61,30 +63,136 @@ func (s *Signer) SignURLWithExpiry(operations, sourceURL string, expiryUnix int6
 	return fmt.Sprintf("/%s%s", signature, path)
 }
 
-// ParseOperations is a helper to build operation strings
+// Operations is a helper to build operation strings. It is also the JSON
+// format of image presets and custom transformations; the tags hold the
+// static limits, Validate the configurable ones.
 type Operations struct {
-	Resize     *ResizeOp
-	Width      int
-	Height     int
-	Quality    int
-	Format     string
-	Background string
-	Blur       int
-	Sharpen    float64
-	Gravity    string
-	Crop       *CropOp
+	Resize     *ResizeOp `json:"resize,omitempty"`
+	Width      int       `json:"width,omitempty" minimum:"0"`
+	Height     int       `json:"height,omitempty" minimum:"0"`
+	DPR        float64   `json:"dpr,omitempty" minimum:"0" maximum:"8" doc:"Device pixel ratio; multiplies the output dimensions"`
+	Quality    int       `json:"quality,omitempty" minimum:"0" maximum:"100"`
+	Format     string    `json:"format,omitempty" enum:"jpg,png,webp,avif,gif,auto" doc:"auto picks AVIF, WebP, PNG or JPEG from the Accept header"`
+	Background string    `json:"background,omitempty" doc:"Hex color (e.g. ff0000) or r:g:b"`
+	Blur       int       `json:"blur,omitempty" minimum:"0" maximum:"100"`
+	Sharpen    float64   `json:"sharpen,omitempty" minimum:"0" maximum:"10"`
+	Gravity    string    `json:"gravity,omitempty" enum:"no,so,ea,we,noea,nowe,soea,sowe,ce,sm"`
+	// FocalPoint sets the gravity to fp:x:y, overriding Gravity
+	FocalPoint *FocalPoint `json:"focalPoint,omitempty"`
+	Crop       *CropOp     `json:"crop,omitempty"`
+	// Watermark overrides the IMAGE_WATERMARK delivery policy
+	Watermark *WatermarkOp `json:"watermark,omitempty"`
 }
 
 type ResizeOp struct {
-	Type   string // fit, fill, auto, force
-	Width  int
-	Height int
+	Type   string `json:"type" enum:"fit,fill,fill-down,force,auto"`
+	Width  int    `json:"width" minimum:"0"`
+	Height int    `json:"height" minimum:"0"`
 }
 
 type CropOp struct {
-	Width   int
-	Height  int
-	Gravity string
+	Width   int    `json:"width" minimum:"0"`
+	Height  int    `json:"height" minimum:"0"`
+	Gravity string `json:"gravity,omitempty" enum:"no,so,ea,we,noea,nowe,soea,sowe,ce,sm"`
+	// FocalPoint centers the crop on a point, overriding Gravity
+	FocalPoint *FocalPoint `json:"focalPoint,omitempty"`
+}
+
+// formatContentTypes are the output formats of Operations.Format
+var formatContentTypes = map[string]string{
+	"jpg":  "image/jpeg",
+	"png":  "image/png",
+	"webp": "image/webp",
+	"avif": "image/avif",
+	"gif":  "image/gif",
+}
+
+// FormatContentType returns the media type of an output format, or "" for
+// unsupported formats
+func FormatContentType(format string) string {
+	return formatContentTypes[format]
+}
+
+// InvalidOperationError describes an operation outside the allowed limits
+type InvalidOperationError struct {
+	Field   string
+	Message string
+}
+
+func (e *InvalidOperationError) Error() string {
+	if e.Field == "" {
+		return "invalid operations: " + e.Message
+	}
+	return fmt.Sprintf("invalid operation %s: %s", e.Field, e.Message)
+}
+
+var backgroundPattern = regexp.MustCompile(`^([0-9a-fA-F]{3}|[0-9a-fA-F]{6}|\d{1,3}:\d{1,3}:\d{1,3})$`)
+
+// Validate checks that the operations are non-empty, that no output
+// dimension exceeds maxDimension pixels and that the background and a
+// spelled-out watermark are valid. Watermark policies must be resolved
+// first. Crops are in pixels of the source; ValidateCrop checks them.
+func (o *Operations) Validate(maxDimension int) error {
+	if o.String() == "" {
+		return &InvalidOperationError{Message: "at least one operation is required"}
+	}
+
+	type dimension struct {
+		field string
+		value int
+	}
+	dimensions := []dimension{{"width", o.Width}, {"height", o.Height}}
+	if o.Resize != nil {
+		dimensions = append(dimensions, dimension{"resize.width", o.Resize.Width}, dimension{"resize.height", o.Resize.Height})
+	}
+	// The output is dpr times larger
+	limit := maxDimension
+	if o.DPR > 1 {
+		limit = int(float64(maxDimension) / o.DPR)
+	}
+	for _, d := range dimensions {
+		if d.value > limit {
+			return &InvalidOperationError{Field: d.field, Message: fmt.Sprintf("must be at most %d", limit)}
+		}
+	}
+
+	if o.Background != "" && !backgroundPattern.MatchString(o.Background) {
+		return &InvalidOperationError{Field: "background", Message: "must be a hex color or r:g:b"}
+	}
+
+	type focalPoint struct {
+		field string
+		point *FocalPoint
+	}
+	points := []focalPoint{{"focalPoint", o.FocalPoint}}
+	if o.Crop != nil {
+		points = append(points, focalPoint{"crop.focalPoint", o.Crop.FocalPoint})
+	}
+	for _, p := range points {
+		if p.point != nil && (p.point.X < 0 || p.point.X > 1 || p.point.Y < 0 || p.point.Y > 1) {
+			return &InvalidOperationError{Field: p.field, Message: "x and y must be between 0 and 1"}
+		}
+	}
+
+	if o.Watermark != nil && o.Watermark.Policy == "" {
+		return o.Watermark.validate("watermark.")
+	}
+	return nil
+}
+
+// ValidateCrop checks that the crop fits a width×height source. Unknown
+// dimensions (0) are not checked.
+func (o *Operations) ValidateCrop(width, height int) error {
+	if o.Crop == nil {
+		return nil
+	}
+	if width > 0 && o.Crop.Width > width {
+		return &InvalidOperationError{Field: "crop.width", Message: fmt.Sprintf("must be at most %d, the width of the image", width)}
+	}
+	if height > 0 && o.Crop.Height > height {
+		return &InvalidOperationError{Field: "crop.height", Message: fmt.Sprintf("must be at most %d, the height of the image", height)}
+	}
+	return nil
 }
 
 func (o *Operations) String() string {
@@ -96,6 +204,10 @@ func (o *Operations) String() string {
 		parts = append(parts, fmt.Sprintf("w:%d/h:%d", o.Width, o.Height))
 	}
 
+	if o.DPR > 0 && o.DPR != 1 {
+		parts = append(parts, "dpr:"+strconv.FormatFloat(o.DPR, 'f', -1, 64))
+	}
+
 	if o.Quality > 0 {
 		parts = append(parts, fmt.Sprintf("q:%d", o.Quality))
 	}
@@ -116,18 +228,26 @@ func (o *Operations) String() string {
 		parts = append(parts, fmt.Sprintf("sh:%f", o.Sharpen))
 	}
 
-	if o.Gravity != "" {
+	if o.FocalPoint != nil {
+		parts = append(parts, "g:"+o.FocalPoint.String())
+	} else if o.Gravity != "" {
 		parts = append(parts, fmt.Sprintf("g:%s", o.Gravity))
 	}
 
 	if o.Crop != nil {
-		if o.Crop.Gravity != "" {
+		if o.Crop.FocalPoint != nil {
+			parts = append(parts, fmt.Sprintf("c:%d:%d:%s", o.Crop.Width, o.Crop.Height, o.Crop.FocalPoint))
+		} else if o.Crop.Gravity != "" {
 			parts = append(parts, fmt.Sprintf("c:%d:%d:%s", o.Crop.Width, o.Crop.Height, o.Crop.Gravity))
 		} else {
 			parts = append(parts, fmt.Sprintf("c:%d:%d", o.Crop.Width, o.Crop.Height))
 		}
 	}
 
+	if o.Watermark != nil {
+		parts = append(parts, o.Watermark.String())
+	}
+
 	return strings.Join(parts, "/")
 }
 
@@ -135,3 +255,17 @@ func (o *Operations) String() string {
 func base64URLEncode(data []byte) string {
 	return strings.TrimRight(base64.URLEncoding.EncodeToString(data), "=")
 }
+
+// Sign returns the signature of path, the part of an imgproxy URL after the
+// signature (starting with "/")
+func (s *Signer) Sign(path string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(s.salt)
+	mac.Write([]byte(path))
+	return base64URLEncode(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of path
+func (s *Signer) Verify(signature, path string) bool {
+	return hmac.Equal([]byte(signature), []byte(s.Sign(path)))
+}
@@ -0,0 +1,183 @@
+package imgproxy
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	testKey  = "943b421c9eb07c830af81030552c86009268de4e532ba2ee2eab8247c6da0881"
+	testSalt = "520f986b998545b4785e0defbc4f3c1203f22de2374a3d53cb7a7fe9fea309c5"
+)
+
+func newTestSigner(t *testing.T) *Signer {
+	t.Helper()
+	s, err := NewSigner(testKey, testSalt)
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+	return s
+}
+
+// The expected signature was computed outside Go, the way imgproxy checks it:
+//
+//	(xxd -r -p <<< $SALT; printf %s "$PATH") |
+//	  openssl dgst -sha256 -mac HMAC -macopt hexkey:$KEY -binary | basenc --base64url | tr -d =
+func TestSignMatchesImgproxy(t *testing.T) {
+	s := newTestSigner(t)
+	path := "/rs:fill:300:400:0/g:sm/aHR0cDovL2V4YW1w/bGUuY29tL2ltYWdl/cy9jdXJpb3NpdHku/anBn.png"
+	if got, want := s.Sign(path), "90UxdwGRAI2bpLSHKkZculJau5ahfxfS0h3fMuQAf40"; got != want {
+		t.Errorf("Sign = %q, want %q", got, want)
+	}
+}
+
+func TestNewSignerRejectsInvalidHex(t *testing.T) {
+	if _, err := NewSigner("not hex", testSalt); err == nil {
+		t.Error("invalid key accepted")
+	}
+	if _, err := NewSigner(testKey, "abc"); err == nil {
+		t.Error("invalid salt accepted")
+	}
+}
+
+// splitSigned splits a signed URL into its signature and signed path
+func splitSigned(t *testing.T, signed string) (string, string) {
+	t.Helper()
+	signature, path, ok := strings.Cut(strings.TrimPrefix(signed, "/"), "/")
+	if !ok {
+		t.Fatalf("malformed signed URL %q", signed)
+	}
+	return signature, "/" + path
+}
+
+func TestVerify(t *testing.T) {
+	s := newTestSigner(t)
+	signature, path := splitSigned(t, s.SignURL("rs:fit:800:800/q:80", "s3://media-originals/a/b.jpg"))
+
+	otherKey, _ := NewSigner(strings.Repeat("ab", 32), testSalt)
+	otherSalt, _ := NewSigner(testKey, strings.Repeat("ab", 32))
+
+	tests := []struct {
+		name      string
+		signer    *Signer
+		signature string
+		path      string
+		want      bool
+	}{
+		{"valid", s, signature, path, true},
+		{"tampered options", s, signature, strings.Replace(path, "800:800", "8000:8000", 1), false},
+		{"tampered source", s, signature, path[:len(path)-2] + "xx", false},
+		{"appended option", s, signature, "/bl:10" + path, false},
+		{"tampered signature", s, "A" + signature[1:], path, false},
+		{"truncated signature", s, signature[:len(signature)-1], path, false},
+		{"empty signature", s, "", path, false},
+		{"wrong key", otherKey, signature, path, false},
+		{"wrong salt", otherSalt, signature, path, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.signer.Verify(tt.signature, tt.path); got != tt.want {
+				t.Errorf("Verify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignURLWithExpiry(t *testing.T) {
+	s := newTestSigner(t)
+	signature, path := splitSigned(t, s.SignURLWithExpiry("rs:fit:100:100", "s3://media-originals/a.jpg", 1700000000))
+
+	if !s.Verify(signature, path) {
+		t.Fatal("signature does not verify")
+	}
+	parsed, err := ParsePath(strings.TrimPrefix(path, "/"))
+	if err != nil {
+		t.Fatalf("ParsePath: %v", err)
+	}
+	if got := parsed.Expiry(); got != 1700000000 {
+		t.Errorf("Expiry = %d, want 1700000000", got)
+	}
+	if parsed.Source != "s3://media-originals/a.jpg" {
+		t.Errorf("Source = %q", parsed.Source)
+	}
+
+	// Changing the expiry breaks the signature
+	if s.Verify(signature, strings.Replace(path, "exp:1700000000", "exp:1900000000", 1)) {
+		t.Error("extended expiry verifies")
+	}
+}
+
+func TestOperationsValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		ops   Operations
+		field string // "" when valid
+	}{
+		{"resize", Operations{Resize: &ResizeOp{Type: "fit", Width: 4096, Height: 4096}}, ""},
+		{"empty", Operations{}, "-"},
+		{"too wide", Operations{Width: 4097}, "width"},
+		{"resize too tall", Operations{Resize: &ResizeOp{Type: "fill", Width: 100, Height: 5000}}, "resize.height"},
+		{"crop larger than the limit", Operations{Crop: &CropOp{Width: 5000, Height: 5000}}, ""},
+		{"dpr shrinks the limit", Operations{Width: 2049, DPR: 2}, "width"},
+		{"dpr within the limit", Operations{Width: 2048, DPR: 2}, ""},
+		{"hex background", Operations{Width: 10, Background: "ff00aa"}, ""},
+		{"rgb background", Operations{Width: 10, Background: "255:0:10"}, ""},
+		{"bad background", Operations{Width: 10, Background: "red"}, "background"},
+		{"focal point outside", Operations{Width: 10, FocalPoint: &FocalPoint{X: 1.5, Y: 0.5}}, "focalPoint"},
+		{"crop focal point outside", Operations{Crop: &CropOp{Width: 10, Height: 10, FocalPoint: &FocalPoint{X: 0.5, Y: -0.1}}}, "crop.focalPoint"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.ops.Validate(4096)
+			if tt.field == "" {
+				if err != nil {
+					t.Errorf("Validate: %v", err)
+				}
+				return
+			}
+			opErr, ok := err.(*InvalidOperationError)
+			if !ok {
+				t.Fatalf("Validate = %v, want an InvalidOperationError", err)
+			}
+			if tt.field != "-" && opErr.Field != tt.field {
+				t.Errorf("field = %q, want %q", opErr.Field, tt.field)
+			}
+		})
+	}
+}
+
+func TestOperationsValidateCrop(t *testing.T) {
+	tests := []struct {
+		name  string
+		crop  *CropOp
+		field string // "" when valid
+	}{
+		{"no crop", nil, ""},
+		{"whole image", &CropOp{Width: 6000, Height: 4000}, ""},
+		{"full height", &CropOp{Width: 1000}, ""},
+		{"too wide", &CropOp{Width: 6001, Height: 100}, "crop.width"},
+		{"too tall", &CropOp{Width: 100, Height: 4001}, "crop.height"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops := Operations{Crop: tt.crop}
+			err := ops.ValidateCrop(6000, 4000)
+			if tt.field == "" {
+				if err != nil {
+					t.Errorf("ValidateCrop: %v", err)
+				}
+				return
+			}
+			opErr, ok := err.(*InvalidOperationError)
+			if !ok || opErr.Field != tt.field {
+				t.Errorf("ValidateCrop = %v, want an error on %s", err, tt.field)
+			}
+		})
+	}
+
+	// Without the size of the image the crop is not checked
+	ops := Operations{Crop: &CropOp{Width: 9000, Height: 9000}}
+	if err := ops.ValidateCrop(0, 0); err != nil {
+		t.Errorf("ValidateCrop of an unknown size: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package imgproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// WatermarkNone is the policy name that turns watermarking off
+const WatermarkNone = "none"
+
+// watermarkPositions are the positions of imgproxy's watermark option; re
+// repeats the watermark across the image
+var watermarkPositions = map[string]bool{
+	"ce": true, "no": true, "so": true, "ea": true, "we": true,
+	"noea": true, "nowe": true, "soea": true, "sowe": true, "re": true,
+}
+
+// WatermarkOp overlays a watermark (imgproxy wm, and wmu for Image). It
+// either names a policy from configuration or spells the watermark out.
+type WatermarkOp struct {
+	Policy   string  `json:"policy,omitempty" doc:"Named watermark policy (IMAGE_WATERMARKS), or none; the other fields are then ignored"`
+	Opacity  float64 `json:"opacity,omitempty" minimum:"0" maximum:"1" doc:"Default 1"`
+	Position string  `json:"position,omitempty" enum:"ce,no,so,ea,we,noea,nowe,soea,sowe,re" doc:"re tiles the watermark"`
+	XOffset  int     `json:"xOffset,omitempty" doc:"Offset from the position, or the spacing of tiles"`
+	YOffset  int     `json:"yOffset,omitempty"`
+	Scale    float64 `json:"scale,omitempty" minimum:"0" maximum:"1" doc:"Watermark width relative to the image; 0 keeps its size"`
+	Image    string  `json:"image,omitempty" doc:"s3:// URL of the watermark image; default: imgproxy's IMGPROXY_WATERMARK_*"`
+}
+
+// Options returns the processing options of the watermark
+func (w *WatermarkOp) Options() []Option {
+	if w.Policy == WatermarkNone {
+		return []Option{{Name: "watermark", Args: []string{"0"}}}
+	}
+
+	opacity := w.Opacity
+	if opacity == 0 {
+		opacity = 1
+	}
+	position := w.Position
+	if position == "" {
+		position = "ce"
+	}
+	options := []Option{{Name: "watermark", Args: []string{
+		strconv.FormatFloat(opacity, 'f', -1, 64),
+		position,
+		strconv.Itoa(w.XOffset),
+		strconv.Itoa(w.YOffset),
+		strconv.FormatFloat(w.Scale, 'f', -1, 64),
+	}}}
+	if w.Image != "" {
+		options = append(options, Option{Name: "watermark_url", Args: []string{base64URLEncode([]byte(w.Image))}})
+	}
+	return options
+}
+
+// String renders the watermark as imgproxy options, e.g. wm:0.5:soea:10:10:0.2
+func (w *WatermarkOp) String() string {
+	var parts []string
+	for _, opt := range w.Options() {
+		name := opt.Name
+		switch name {
+		case "watermark":
+			name = "wm"
+		case "watermark_url":
+			name = "wmu"
+		}
+		parts = append(parts, strings.Join(append([]string{name}, opt.Args...), ":"))
+	}
+	return strings.Join(parts, "/")
+}
+
+// validate checks a spelled-out watermark; field prefixes the field names
+// of errors
+func (w *WatermarkOp) validate(field string) error {
+	switch {
+	case w.Opacity < 0 || w.Opacity > 1:
+		return &InvalidOperationError{Field: field + "opacity", Message: "must be between 0 and 1"}
+	case w.Position != "" && !watermarkPositions[w.Position]:
+		return &InvalidOperationError{Field: field + "position", Message: "unknown position"}
+	case w.Scale < 0 || w.Scale > 1:
+		return &InvalidOperationError{Field: field + "scale", Message: "must be between 0 and 1"}
+	}
+	if w.Image != "" {
+		u, err := url.Parse(w.Image)
+		if err != nil || u.Scheme != "s3" || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+			return &InvalidOperationError{Field: field + "image", Message: "must be an s3://bucket/key URL"}
+		}
+	}
+	return nil
+}
+
+// ResolveWatermark replaces a policy reference in the operations with the
+// named policy. none stays a reference, since it renders on its own.
+func (o *Operations) ResolveWatermark(policies map[string]WatermarkOp) error {
+	if o.Watermark == nil || o.Watermark.Policy == "" || o.Watermark.Policy == WatermarkNone {
+		return nil
+	}
+	policy, ok := policies[o.Watermark.Policy]
+	if !ok {
+		return &InvalidOperationError{Field: "watermark.policy", Message: fmt.Sprintf("unknown watermark policy %q", o.Watermark.Policy)}
+	}
+	o.Watermark = &policy
+	return nil
+}
+
+// ParseWatermarks decodes a JSON object mapping policy names to watermarks
+func ParseWatermarks(data []byte) (map[string]WatermarkOp, error) {
+	var policies map[string]WatermarkOp
+	if err := json.Unmarshal(data, &policies); err != nil {
+		return nil, fmt.Errorf("invalid watermark policies: %w", err)
+	}
+
+	for name, policy := range policies {
+		if !presetNamePattern.MatchString(name) || name == WatermarkNone {
+			return nil, fmt.Errorf("invalid watermark policy name %q: use lowercase letters, digits, - and _", name)
+		}
+		if policy.Policy != "" {
+			return nil, fmt.Errorf("watermark policy %q cannot refer to another policy", name)
+		}
+		if err := policy.validate(""); err != nil {
+			return nil, fmt.Errorf("watermark policy %q: %w", name, err)
+		}
+	}
+	return policies, nil
+}
@@ -0,0 +1,176 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is the OpenAPI version of generated documents
+const Version = "3.1.0"
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Servers    []Server                        `json:"servers,omitempty"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how requests authenticate
+type SecurityScheme struct {
+	Type        string `json:"type"`             // e.g. http
+	Scheme      string `json:"scheme,omitempty"` // e.g. bearer
+	Description string `json:"description,omitempty"`
+}
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path, query, header
+	Required    bool    `json:"required,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Route describes one API operation. Path uses OpenAPI {param} syntax and
+// is relative to the server URL; path parameters are declared automatically.
+type Route struct {
+	Method      string
+	Path        string
+	ID          string
+	Summary     string
+	Description string
+	Tag         string
+	Params      []Parameter // query and header parameters
+	Request     interface{} // zero value of the JSON request body, if any
+	Responses   []ResponseSpec
+	Security    []string // names of security schemes, any of which authorizes the request
+}
+
+// ResponseSpec describes one response of a Route
+type ResponseSpec struct {
+	Status      int
+	Description string
+	Body        interface{} // zero value of the JSON body, if any
+	ContentType string      // for non-JSON bodies
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// Build generates a document for routes, registering every request and
+// response type in reg
+func Build(info Info, servers []Server, routes []Route, reg *Registry) *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Servers: servers,
+		Paths:   make(map[string]map[string]Operation),
+	}
+
+	for _, route := range routes {
+		op := Operation{
+			OperationID: route.ID,
+			Summary:     route.Summary,
+			Description: route.Description,
+			Responses:   make(map[string]*Response),
+		}
+		if route.Tag != "" {
+			op.Tags = []string{route.Tag}
+		}
+		for _, name := range route.Security {
+			op.Security = append(op.Security, map[string][]string{name: {}})
+		}
+
+		for _, m := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:     m[1],
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+		op.Parameters = append(op.Parameters, route.Params...)
+
+		if route.Request != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content: map[string]MediaType{
+					"application/json": {Schema: reg.Schema(reflect.TypeOf(route.Request))},
+				},
+			}
+		}
+
+		for _, resp := range route.Responses {
+			r := &Response{Description: resp.Description}
+			if r.Description == "" {
+				r.Description = http.StatusText(resp.Status)
+			}
+			switch {
+			case resp.Body != nil:
+				contentType := resp.ContentType
+				if contentType == "" {
+					contentType = "application/json"
+				}
+				r.Content = map[string]MediaType{
+					contentType: {Schema: reg.Schema(reflect.TypeOf(resp.Body))},
+				}
+			case resp.ContentType != "":
+				r.Content = map[string]MediaType{
+					resp.ContentType: {Schema: &Schema{Type: "string", Format: "binary"}},
+				}
+			}
+			op.Responses[strconv.Itoa(resp.Status)] = r
+		}
+
+		if doc.Paths[route.Path] == nil {
+			doc.Paths[route.Path] = make(map[string]Operation)
+		}
+		doc.Paths[route.Path][strings.ToLower(route.Method)] = op
+	}
+
+	doc.Components.Schemas = reg.Components()
+	return doc
+}
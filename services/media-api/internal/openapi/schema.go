@@ -0,0 +1,170 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Schema is the subset of JSON Schema (2020-12, as used by OpenAPI 3.1)
+// generated from Go types
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+const refPrefix = "#/components/schemas/"
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// Registry generates schemas from Go types. Named structs are emitted once
+// as components and referenced with $ref.
+type Registry struct {
+	mu      sync.RWMutex
+	schemas map[string]*Schema
+}
+
+func NewRegistry() *Registry {
+	return &Registry{schemas: make(map[string]*Schema)}
+}
+
+// Components returns the generated component schemas by name
+func (r *Registry) Components() map[string]*Schema {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.schemas
+}
+
+// Schema returns the schema of t, registering named structs as components
+func (r *Registry) Schema(t reflect.Type) *Schema {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.schema(t)
+}
+
+func (r *Registry) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: r.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t)
+		}
+		if _, ok := r.schemas[t.Name()]; !ok {
+			// Reserve the name first so recursive types terminate
+			r.schemas[t.Name()] = &Schema{}
+			*r.schemas[t.Name()] = *r.structSchema(t)
+		}
+		return &Schema{Ref: refPrefix + t.Name()}
+	default:
+		// interface{} and anything else accepts any JSON value
+		return &Schema{}
+	}
+}
+
+// structSchema builds an object schema from the json tags of t. Fields
+// without omitempty are required. Constraints come from the enum, format,
+// minimum, maximum, minLength, maxLength, maxItems and doc tags.
+func (r *Registry) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := r.schema(field.Type)
+		if prop.Ref == "" {
+			applyTags(prop, field.Tag)
+		}
+		s.Properties[name] = prop
+
+		if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	return s
+}
+
+func applyTags(s *Schema, tag reflect.StructTag) {
+	target := s
+	if s.Type == "array" && s.Items != nil && s.Items.Ref == "" {
+		// enum and string limits describe the elements of a list
+		target = s.Items
+	}
+
+	if v := tag.Get("enum"); v != "" {
+		target.Enum = strings.Split(v, ",")
+	}
+	if v := tag.Get("format"); v != "" {
+		target.Format = v
+	}
+	if v := tag.Get("doc"); v != "" {
+		s.Description = v
+	}
+	if v, err := strconv.ParseFloat(tag.Get("minimum"), 64); err == nil {
+		s.Minimum = &v
+	}
+	if v, err := strconv.ParseFloat(tag.Get("maximum"), 64); err == nil {
+		s.Maximum = &v
+	}
+	if v, err := strconv.Atoi(tag.Get("minLength")); err == nil {
+		target.MinLength = &v
+	}
+	if v, err := strconv.Atoi(tag.Get("maxLength")); err == nil {
+		target.MaxLength = &v
+	}
+	if v, err := strconv.Atoi(tag.Get("maxItems")); err == nil {
+		s.MaxItems = &v
+	}
+}
@@ -0,0 +1,194 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// FieldError describes why a value in a request body is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Validate checks a JSON document against the schema of t. It returns an
+// error when data is not JSON at all, and field errors for schema violations.
+func (r *Registry) Validate(t reflect.Type, data []byte) ([]FieldError, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+
+	schema := r.Schema(t)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var errs []FieldError
+	r.validate(schema, value, "", &errs)
+	return errs, nil
+}
+
+func (r *Registry) validate(s *Schema, value interface{}, path string, errs *[]FieldError) {
+	if s.Ref != "" {
+		s = r.schemas[strings.TrimPrefix(s.Ref, refPrefix)]
+	}
+
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{Field: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		for _, name := range s.Required {
+			if v, ok := obj[name]; !ok || v == nil {
+				*errs = append(*errs, FieldError{Field: joinPath(path, name), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			v := obj[name]
+			if v == nil {
+				// null is treated as absent
+				continue
+			}
+			if prop, ok := s.Properties[name]; ok {
+				r.validate(prop, v, joinPath(path, name), errs)
+			} else if s.AdditionalProperties != nil {
+				r.validate(s.AdditionalProperties, v, joinPath(path, name), errs)
+			}
+		}
+
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		if s.MaxItems != nil && len(items) > *s.MaxItems {
+			fail("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, v := range items {
+				r.validate(s.Items, v, path+"["+strconv.Itoa(i)+"]", errs)
+			}
+		}
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		n := utf8.RuneCountInString(str)
+		if s.MinLength != nil && n < *s.MinLength {
+			if *s.MinLength == 1 {
+				fail("must not be empty")
+			} else {
+				fail("must be at least %d characters", *s.MinLength)
+			}
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fail("must be at most %d characters", *s.MaxLength)
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, str) {
+			fail("must be one of: %s", strings.Join(s.Enum, ", "))
+		}
+		if msg := checkFormat(s.Format, str); msg != "" {
+			fail(msg)
+		}
+
+	case "integer", "number":
+		num, ok := value.(json.Number)
+		if !ok {
+			fail("must be a number")
+			return
+		}
+		f, err := num.Float64()
+		if err != nil {
+			fail("must be a number")
+			return
+		}
+		if s.Type == "integer" {
+			if _, err := num.Int64(); err != nil {
+				fail("must be an integer")
+				return
+			}
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			fail("must be at least %s", formatNumber(*s.Minimum))
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			fail("must be at most %s", formatNumber(*s.Maximum))
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean")
+		}
+	}
+}
+
+func checkFormat(format, value string) string {
+	switch format {
+	case "uri":
+		u, err := url.Parse(value)
+		if err != nil || !u.IsAbs() || u.Host == "" {
+			return "must be an absolute URL"
+		}
+	case "uuid":
+		if _, err := uuid.Parse(value); err != nil {
+			return "must be a UUID"
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return "must be an RFC 3339 date-time"
+		}
+	}
+	return ""
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
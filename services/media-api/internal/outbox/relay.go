@@ -0,0 +1,206 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// notifyChannel is signalled by a trigger whenever an outbox row is inserted
+	notifyChannel = "outbox"
+	// PollInterval is the fallback when no notification arrives
+	PollInterval = 5 * time.Second
+	// BatchSize is the maximum number of rows forwarded per transaction
+	BatchSize = 100
+	// Retention is how long dispatched rows are kept for troubleshooting
+	Retention = 7 * 24 * time.Hour
+)
+
+// Execer is satisfied by both the connection pool and a transaction
+type Execer interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+}
+
+// Enqueue writes a message for the Redis list topic. Call it inside the
+// transaction that makes the message necessary so both commit or neither does.
+func Enqueue(ctx context.Context, q Execer, topic string, payload []byte) error {
+	_, err := q.Exec(ctx, "INSERT INTO outbox (topic, payload) VALUES ($1, $2)", topic, payload)
+	if err != nil {
+		return fmt.Errorf("failed to write outbox message: %w", err)
+	}
+	return nil
+}
+
+// Relay forwards committed outbox rows to Redis. Delivery is at-least-once:
+// a crash between the push and marking the row dispatched pushes it again.
+type Relay struct {
+	db     *pgxpool.Pool
+	redis  *redis.Client
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewRelay(db *pgxpool.Pool, redisClient *redis.Client) *Relay {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Relay{
+		db:     db,
+		redis:  redisClient,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (r *Relay) Start() {
+	r.wg.Add(1)
+	go r.run()
+}
+
+func (r *Relay) Stop() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+func (r *Relay) run() {
+	defer r.wg.Done()
+
+	log.Info().Msg("Outbox relay started")
+
+	lastCleanup := time.Time{}
+	for {
+		// Drain everything that is pending, then wait for the next notification
+		for {
+			n, err := r.dispatch(r.ctx)
+			if err != nil {
+				if r.ctx.Err() == nil {
+					log.Error().Err(err).Msg("Failed to dispatch outbox messages")
+				}
+				break
+			}
+			if n < BatchSize {
+				break
+			}
+		}
+
+		if time.Since(lastCleanup) > time.Hour {
+			r.cleanup(r.ctx)
+			lastCleanup = time.Now()
+		}
+
+		if err := r.wait(r.ctx); err != nil && r.ctx.Err() == nil {
+			log.Warn().Err(err).Msg("Outbox notification listener failed, polling")
+			select {
+			case <-r.ctx.Done():
+			case <-time.After(PollInterval):
+			}
+		}
+
+		if r.ctx.Err() != nil {
+			log.Info().Msg("Outbox relay stopping")
+			return
+		}
+	}
+}
+
+// wait blocks until an outbox row is inserted or PollInterval passes
+func (r *Relay) wait(ctx context.Context) error {
+	conn, err := r.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), "UNLISTEN "+notifyChannel)
+
+	waitCtx, cancel := context.WithTimeout(ctx, PollInterval)
+	defer cancel()
+
+	_, err = conn.Conn().WaitForNotification(waitCtx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil
+	}
+	return err
+}
+
+// dispatch forwards one batch of pending rows and returns how many it forwarded
+func (r *Relay) dispatch(ctx context.Context) (int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT id, topic, payload::text FROM outbox
+		WHERE dispatched_at IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	type message struct {
+		id      int64
+		topic   string
+		payload string
+	}
+	var messages []message
+	for rows.Next() {
+		var m message
+		if err := rows.Scan(&m.id, &m.topic, &m.payload); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		messages = append(messages, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var dispatched []int64
+	var pushErr error
+	for _, m := range messages {
+		if pushErr = r.redis.RPush(ctx, m.topic, m.payload).Err(); pushErr != nil {
+			break
+		}
+		dispatched = append(dispatched, m.id)
+	}
+
+	if len(dispatched) > 0 {
+		_, err := tx.Exec(ctx, "UPDATE outbox SET dispatched_at = CURRENT_TIMESTAMP WHERE id = ANY($1)", dispatched)
+		if err != nil {
+			return 0, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return 0, err
+		}
+		log.Debug().Int("count", len(dispatched)).Msg("Dispatched outbox messages")
+	}
+
+	if pushErr != nil {
+		return len(dispatched), fmt.Errorf("failed to push outbox message: %w", pushErr)
+	}
+	return len(dispatched), nil
+}
+
+// cleanup removes dispatched rows older than Retention
+func (r *Relay) cleanup(ctx context.Context) {
+	_, err := r.db.Exec(ctx, "DELETE FROM outbox WHERE dispatched_at < $1", time.Now().Add(-Retention))
+	if err != nil && ctx.Err() == nil {
+		log.Warn().Err(err).Msg("Failed to clean up outbox")
+	}
+}
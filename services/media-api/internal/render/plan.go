@@ -0,0 +1,404 @@
+package render
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ancill/mediapod/services/media-api/internal/imgproxy"
+	"golang.org/x/image/draw"
+)
+
+// plan is what a processing path asks for, in imgproxy's semantics
+type plan struct {
+	resizeType string
+	width      int
+	height     int
+	dpr        float64
+	enlarge    bool
+	gravity    gravity
+	crop       *cropSpec
+	quality    int
+	format     string
+	background color.Color
+	watermark  *watermarkSpec
+	// watermarkSource is the watermark_url source
+	watermarkSource string
+}
+
+// cropSpec is a crop of the source; sizes below 1 are fractions of it and 0
+// keeps the full dimension
+type cropSpec struct {
+	width   float64
+	height  float64
+	gravity gravity // the plan's gravity when unset
+}
+
+// gravity is a gravity type, with the focal point of fp
+type gravity struct {
+	typ  string
+	x, y float64
+}
+
+// gravities are the gravity types the renderer places crops with. Smart
+// gravity has no detector here and falls back to the center.
+var gravities = map[string]bool{
+	"no": true, "so": true, "ea": true, "we": true,
+	"noea": true, "nowe": true, "soea": true, "sowe": true,
+	"ce": true, "sm": true,
+}
+
+// parsePlan reads the options of a path. Options the renderer does not
+// implement fail with ErrUnsupported.
+func parsePlan(options []imgproxy.Option) (*plan, error) {
+	p := &plan{
+		resizeType: "fit",
+		dpr:        1,
+		gravity:    gravity{typ: "ce"},
+		quality:    defaultQuality,
+		background: color.White,
+	}
+
+	for _, opt := range options {
+		args := opt.Args
+		arg := func(i int) string {
+			if i < len(args) {
+				return args[i]
+			}
+			return ""
+		}
+
+		var err error
+		switch opt.Name {
+		case "resize":
+			if err = p.setResizeType(arg(0)); err == nil {
+				err = p.setSize(arg(1), arg(2), arg(3), arg(4))
+			}
+		case "size":
+			err = p.setSize(arg(0), arg(1), arg(2), arg(3))
+		case "resizing_type":
+			err = p.setResizeType(arg(0))
+		case "width":
+			err = setInt(&p.width, arg(0))
+		case "height":
+			err = setInt(&p.height, arg(0))
+		case "dpr":
+			var dpr float64
+			if dpr, err = strconv.ParseFloat(arg(0), 64); err == nil && dpr > 0 {
+				p.dpr = dpr
+			}
+		case "enlarge":
+			err = setBool(&p.enlarge, arg(0))
+		case "gravity":
+			p.gravity, err = parseGravity(args)
+		case "crop":
+			c := &cropSpec{}
+			c.width, err = parseCropSize(arg(0))
+			if err == nil {
+				c.height, err = parseCropSize(arg(1))
+			}
+			if err == nil && arg(2) != "" {
+				c.gravity, err = parseGravity(args[2:])
+			}
+			p.crop = c
+		case "quality":
+			err = setInt(&p.quality, arg(0))
+			if p.quality == 0 {
+				p.quality = defaultQuality
+			}
+		case "format":
+			p.format = arg(0)
+		case "background":
+			p.background, err = parseBackground(args)
+		case "watermark":
+			p.watermark, err = parseWatermark(args)
+		case "watermark_url":
+			var source []byte
+			source, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(arg(0), "="))
+			p.watermarkSource = string(source)
+		case "expires", "strip_metadata", "cachebuster":
+			// Checked by the API, or nothing to do: no metadata is written
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnsupported, opt.Name)
+		}
+		if errors.Is(err, ErrUnsupported) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid %s", ErrInvalidOptions, opt.Name)
+		}
+	}
+
+	// There is no default watermark image as in imgproxy
+	if p.watermark != nil {
+		if p.watermarkSource == "" {
+			return nil, fmt.Errorf("%w: watermark without watermark_url", ErrUnsupported)
+		}
+		p.watermark.source = p.watermarkSource
+	}
+	return p, nil
+}
+
+func (p *plan) setResizeType(value string) error {
+	switch value {
+	case "":
+	case "fit", "fill", "fill-down", "force", "auto":
+		p.resizeType = value
+	default:
+		return fmt.Errorf("unknown resizing type %q", value)
+	}
+	return nil
+}
+
+func (p *plan) setSize(width, height, enlarge, extend string) error {
+	if err := setInt(&p.width, width); err != nil {
+		return err
+	}
+	if err := setInt(&p.height, height); err != nil {
+		return err
+	}
+	if err := setBool(&p.enlarge, enlarge); err != nil {
+		return err
+	}
+	var ext bool
+	if err := setBool(&ext, extend); err != nil {
+		return err
+	}
+	if ext {
+		return fmt.Errorf("%w: extend", ErrUnsupported)
+	}
+	return nil
+}
+
+// parseGravity reads the arguments of a gravity: a type, or fp:x:y
+func parseGravity(args []string) (gravity, error) {
+	if len(args) == 0 {
+		return gravity{}, fmt.Errorf("missing gravity")
+	}
+	if args[0] == "fp" {
+		if len(args) != 3 {
+			return gravity{}, fmt.Errorf("invalid focal point")
+		}
+		x, errX := strconv.ParseFloat(args[1], 64)
+		y, errY := strconv.ParseFloat(args[2], 64)
+		if errX != nil || errY != nil || x < 0 || x > 1 || y < 0 || y > 1 {
+			return gravity{}, fmt.Errorf("invalid focal point")
+		}
+		return gravity{typ: "fp", x: x, y: y}, nil
+	}
+	if len(args) > 1 {
+		return gravity{}, fmt.Errorf("%w: gravity offsets", ErrUnsupported)
+	}
+	if !gravities[args[0]] {
+		return gravity{}, fmt.Errorf("unsupported gravity %q", args[0])
+	}
+	return gravity{typ: args[0]}, nil
+}
+
+// setInt parses an optional non-negative integer; empty leaves dst alone
+func setInt(dst *int, value string) error {
+	if value == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid number %q", value)
+	}
+	*dst = n
+	return nil
+}
+
+// setBool parses an optional imgproxy boolean (1, t, true, 0, f, false)
+func setBool(dst *bool, value string) error {
+	if value == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*dst = b
+	return nil
+}
+
+func parseCropSize(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid crop size %q", value)
+	}
+	return v, nil
+}
+
+// parseBackground reads bg:r:g:b or bg:hex
+func parseBackground(args []string) (color.Color, error) {
+	switch len(args) {
+	case 1:
+		hex := strings.TrimPrefix(args[0], "#")
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) != 6 {
+			return nil, fmt.Errorf("invalid color %q", args[0])
+		}
+		return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+	case 3:
+		var rgb [3]uint8
+		for i, arg := range args {
+			v, err := strconv.ParseUint(arg, 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid color component %q", arg)
+			}
+			rgb[i] = uint8(v)
+		}
+		return color.RGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 0xff}, nil
+	}
+	return nil, fmt.Errorf("invalid background")
+}
+
+// apply crops and resizes src
+func (p *plan) apply(src image.Image) image.Image {
+	region := src.Bounds()
+
+	// Crops apply to the source, before resizing
+	if p.crop != nil {
+		w := cropDimension(p.crop.width, region.Dx())
+		h := cropDimension(p.crop.height, region.Dy())
+		g := p.crop.gravity
+		if g.typ == "" {
+			g = p.gravity
+		}
+		region = place(region, w, h, g)
+	}
+
+	sw, sh := float64(region.Dx()), float64(region.Dy())
+	w := math.Round(float64(p.width) * p.dpr)
+	h := math.Round(float64(p.height) * p.dpr)
+	if w == 0 && h == 0 {
+		return subImage(src, region)
+	}
+
+	resizeType := p.resizeType
+	if resizeType == "auto" {
+		// Fill when source and target share an orientation, fit otherwise
+		resizeType = "fit"
+		if w > 0 && h > 0 && (w >= h) == (sw >= sh) {
+			resizeType = "fill"
+		}
+	}
+
+	var outW, outH float64
+	switch resizeType {
+	case "force":
+		outW, outH = orDefault(w, sw), orDefault(h, sh)
+		if !p.enlarge {
+			outW, outH = math.Min(outW, sw), math.Min(outH, sh)
+		}
+
+	case "fill", "fill-down":
+		scale := math.Max(ratio(w, sw), ratio(h, sh))
+		if !p.enlarge || resizeType == "fill-down" {
+			scale = math.Min(scale, 1)
+		}
+		scaledW, scaledH := sw*scale, sh*scale
+		outW, outH = math.Min(orDefault(w, scaledW), scaledW), math.Min(orDefault(h, scaledH), scaledH)
+		if resizeType == "fill-down" && w > 0 && h > 0 && (outW < w || outH < h) {
+			// Too small to fill: keep the requested aspect ratio instead
+			aspect := w / h
+			outW, outH = math.Min(scaledW, scaledH*aspect), math.Min(scaledH, scaledW/aspect)
+		}
+		// The part of the region that ends up in the output
+		region = place(region, int(math.Round(outW/scale)), int(math.Round(outH/scale)), p.gravity)
+
+	default: // fit
+		scale := math.Inf(1)
+		if w > 0 {
+			scale = w / sw
+		}
+		if h > 0 {
+			scale = math.Min(scale, h/sh)
+		}
+		if !p.enlarge {
+			scale = math.Min(scale, 1)
+		}
+		outW, outH = sw*scale, sh*scale
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, max(1, int(math.Round(outW))), max(1, int(math.Round(outH)))))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, region, draw.Src, nil)
+	return dst
+}
+
+// ratio is target/source, or 0 when the target is unset
+func ratio(target, source float64) float64 {
+	if target == 0 {
+		return 0
+	}
+	return target / source
+}
+
+func orDefault(v, def float64) float64 {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+func cropDimension(v float64, full int) int {
+	switch {
+	case v == 0:
+		return full
+	case v < 1:
+		return max(1, int(math.Round(v*float64(full))))
+	default:
+		return min(int(v), full)
+	}
+}
+
+// place positions a w×h rectangle inside r according to gravity. Focal
+// points are centered as far as the rectangle stays inside r.
+func place(r image.Rectangle, w, h int, g gravity) image.Rectangle {
+	w, h = min(max(w, 1), r.Dx()), min(max(h, 1), r.Dy())
+	x := r.Min.X + (r.Dx()-w)/2
+	y := r.Min.Y + (r.Dy()-h)/2
+
+	if g.typ == "fp" {
+		x = r.Min.X + min(max(int(math.Round(g.x*float64(r.Dx())-float64(w)/2)), 0), r.Dx()-w)
+		y = r.Min.Y + min(max(int(math.Round(g.y*float64(r.Dy())-float64(h)/2)), 0), r.Dy()-h)
+		return image.Rect(x, y, x+w, y+h)
+	}
+
+	switch g.typ {
+	case "we", "nowe", "sowe":
+		x = r.Min.X
+	case "ea", "noea", "soea":
+		x = r.Max.X - w
+	}
+	switch g.typ {
+	case "no", "noea", "nowe":
+		y = r.Min.Y
+	case "so", "soea", "sowe":
+		y = r.Max.Y - h
+	}
+	return image.Rect(x, y, x+w, y+h)
+}
+
+// subImage returns the region of img, without copying when possible
+func subImage(img image.Image, region image.Rectangle) image.Image {
+	if region == img.Bounds() {
+		return img
+	}
+	if s, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return s.SubImage(region)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, region.Dx(), region.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, region.Min, draw.Src)
+	return dst
+}
@@ -0,0 +1,93 @@
+package render
+
+import (
+	"errors"
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/ancill/mediapod/services/media-api/internal/imgproxy"
+)
+
+// parseOptions returns the options of a processing path such as "rs:fit:10:10"
+func parseOptions(t *testing.T, options string) []imgproxy.Option {
+	t.Helper()
+	path, err := imgproxy.ParsePath(strings.TrimPrefix(options+"/plain/s3://media-originals/photo.jpg", "/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path.Options
+}
+
+func TestPlanApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     image.Rectangle
+		options string
+		// want is the bounds of the output; crops without a resize keep the
+		// coordinates of the source
+		want image.Rectangle
+	}{
+		{"no options", image.Rect(0, 0, 1000, 500), "", image.Rect(0, 0, 1000, 500)},
+		{"fit", image.Rect(0, 0, 1000, 500), "rs:fit:400:300", image.Rect(0, 0, 400, 200)},
+		{"fit width", image.Rect(0, 0, 1000, 500), "w:400", image.Rect(0, 0, 400, 200)},
+		{"fit height", image.Rect(0, 0, 1000, 500), "h:300", image.Rect(0, 0, 600, 300)},
+		{"fit without enlarging", image.Rect(0, 0, 1000, 500), "rs:fit:2000:2000", image.Rect(0, 0, 1000, 500)},
+		{"fit enlarged", image.Rect(0, 0, 1000, 500), "rs:fit:2000:2000:1", image.Rect(0, 0, 2000, 1000)},
+		{"fit rounds half up", image.Rect(0, 0, 1000, 500), "rs:fit:3:3", image.Rect(0, 0, 3, 2)},
+		{"fit keeps a pixel", image.Rect(0, 0, 1000, 10), "w:10", image.Rect(0, 0, 10, 1)},
+		{"fit with dpr", image.Rect(0, 0, 1000, 500), "w:200/dpr:2", image.Rect(0, 0, 400, 200)},
+		{"fill", image.Rect(0, 0, 1000, 500), "rs:fill:300:300", image.Rect(0, 0, 300, 300)},
+		{"fill fractional scale", image.Rect(0, 0, 1000, 500), "rs:fill:333:100", image.Rect(0, 0, 333, 100)},
+		{"fill without enlarging", image.Rect(0, 0, 1000, 500), "rs:fill:2000:2000", image.Rect(0, 0, 1000, 500)},
+		{"fill-down keeps the aspect ratio", image.Rect(0, 0, 1000, 500), "rs:fill-down:2000:500", image.Rect(0, 0, 1000, 250)},
+		{"force", image.Rect(0, 0, 1000, 500), "rs:force:300:300", image.Rect(0, 0, 300, 300)},
+		{"auto fills the same orientation", image.Rect(0, 0, 1000, 500), "rs:auto:300:200", image.Rect(0, 0, 300, 200)},
+		{"auto fits the other orientation", image.Rect(0, 0, 1000, 500), "rs:auto:200:300", image.Rect(0, 0, 200, 100)},
+		{"crop fractions", image.Rect(0, 0, 1000, 500), "c:0.5:0.5", image.Rect(250, 125, 750, 375)},
+		{"crop fractions rounded", image.Rect(0, 0, 1000, 500), "c:0.333:0.333", image.Rect(333, 166, 666, 333)},
+		{"crop with gravity", image.Rect(0, 0, 1000, 500), "c:100:100:nowe", image.Rect(0, 0, 100, 100)},
+		{"crop with the plan's gravity", image.Rect(0, 0, 1000, 500), "g:soea/c:100:100", image.Rect(900, 400, 1000, 500)},
+		{"crop focal point kept inside", image.Rect(0, 0, 1000, 500), "c:300:300:fp:1:0", image.Rect(700, 0, 1000, 300)},
+		{"crop focal point", image.Rect(0, 0, 1000, 500), "c:200:100:fp:0.5:0.3", image.Rect(400, 100, 600, 200)},
+		{"crop larger than the source", image.Rect(0, 0, 1000, 500), "c:2000:2000", image.Rect(0, 0, 1000, 500)},
+		{"crop one dimension", image.Rect(0, 0, 1000, 500), "c:0:100", image.Rect(0, 200, 1000, 300)},
+		{"crop then fill", image.Rect(0, 0, 1000, 500), "c:400:400/rs:fill:100:50", image.Rect(0, 0, 100, 50)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parsePlan(parseOptions(t, tt.options))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.apply(image.NewRGBA(tt.src)).Bounds(); got != tt.want {
+				t.Errorf("bounds = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePlanErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+		err     error
+	}{
+		{"unknown option", "blur:5", ErrUnsupported},
+		{"extend", "rs:fit:10:10:0:1", ErrUnsupported},
+		{"gravity offsets", "g:no:10:10", ErrUnsupported},
+		{"watermark without image", "wm:0.5", ErrUnsupported},
+		{"resizing type", "rs:stretch:10:10", ErrInvalidOptions},
+		{"negative width", "w:-1", ErrInvalidOptions},
+		{"focal point outside", "g:fp:1.5:0.5", ErrInvalidOptions},
+		{"crop size", "c:abc:10", ErrInvalidOptions},
+		{"background", "bg:fff", ErrInvalidOptions},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parsePlan(parseOptions(t, tt.options)); !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
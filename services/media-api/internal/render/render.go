@@ -0,0 +1,199 @@
+// Package render is the built-in alternative to imgproxy: it renders the
+// common subset of imgproxy processing paths in Go, so small deployments and
+// tests can run without the imgproxy container.
+package render
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/url"
+	"runtime"
+	"strings"
+
+	"github.com/ancill/mediapod/services/media-api/internal/imgproxy"
+	"github.com/ancill/mediapod/services/media-api/internal/storage"
+	_ "golang.org/x/image/webp" // registers the WebP decoder
+)
+
+const (
+	// defaultQuality matches imgproxy's IMGPROXY_QUALITY default
+	defaultQuality = 80
+	// maxSourceBytes and maxSourcePixels bound the originals that are decoded
+	maxSourceBytes  = 100 << 20
+	maxSourcePixels = 50_000_000
+)
+
+// Errors returned by Render
+var (
+	ErrUnsupported      = errors.New("not supported by the built-in renderer")
+	ErrInvalidOptions   = errors.New("invalid processing options")
+	ErrSourceNotFound   = errors.New("source image not found")
+	ErrSourceTooLarge   = errors.New("source image too large")
+	ErrUnsupportedImage = errors.New("source is not a supported image")
+)
+
+// Renderer renders parsed imgproxy paths from sources in storage
+type Renderer struct {
+	storage *storage.MinIO
+	// slots bounds concurrent renders, which are CPU and memory heavy
+	slots chan struct{}
+}
+
+func New(store *storage.MinIO) *Renderer {
+	return &Renderer{
+		storage: store,
+		slots:   make(chan struct{}, runtime.NumCPU()),
+	}
+}
+
+// Render produces the image a path describes. Output formats the renderer
+// cannot encode (WebP, AVIF) fall back to JPEG, or PNG for images with
+// transparency; without a format the source format is kept. The returned
+// content type always matches the data.
+func (r *Renderer) Render(ctx context.Context, path *imgproxy.Path) ([]byte, string, error) {
+	p, err := parsePlan(path.Options)
+	if err != nil {
+		return nil, "", err
+	}
+
+	select {
+	case r.slots <- struct{}{}:
+		defer func() { <-r.slots }()
+	case <-ctx.Done():
+		return nil, "", ctx.Err()
+	}
+
+	src, sourceFormat, err := r.load(ctx, path.Source)
+	if err != nil {
+		return nil, "", err
+	}
+
+	img := p.apply(src)
+
+	if p.watermark != nil {
+		mark, _, err := r.load(ctx, p.watermark.source)
+		if err != nil {
+			return nil, "", fmt.Errorf("watermark: %w", err)
+		}
+		img = p.watermark.overlay(img, mark)
+	}
+
+	format, err := outputFormat(p.format, sourceFormat, isOpaque(img))
+	if err != nil {
+		return nil, "", err
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case "jpg":
+		err = jpeg.Encode(&buf, flatten(img, p), &jpeg.Options{Quality: p.quality})
+	case "png":
+		err = png.Encode(&buf, img)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), imgproxy.FormatContentType(format), nil
+}
+
+// CanEncode reports whether the renderer can produce format, rather than
+// falling back to another one
+func CanEncode(format string) bool {
+	switch strings.ToLower(format) {
+	case "jpg", "jpeg", "png", "gif":
+		return true
+	}
+	return false
+}
+
+// outputFormat is the format an image is encoded in: the requested one, or
+// the source format when none is. WebP and AVIF fall back to JPEG, or PNG
+// when the image is not opaque.
+func outputFormat(requested, sourceFormat string, opaque bool) (string, error) {
+	format := strings.ToLower(requested)
+	if format == "" {
+		format = sourceFormat
+	}
+	if format == "jpeg" {
+		format = "jpg"
+	}
+	switch format {
+	case "jpg", "png", "gif":
+	case "webp", "avif":
+		format = "jpg"
+		if !opaque {
+			format = "png"
+		}
+	default:
+		return "", fmt.Errorf("%w: format %s", ErrUnsupported, format)
+	}
+	return format, nil
+}
+
+// load reads and decodes an s3:// source, returning its format
+func (r *Renderer) load(ctx context.Context, source string) (image.Image, string, error) {
+	u, err := url.Parse(source)
+	if err != nil || u.Scheme != "s3" {
+		return nil, "", fmt.Errorf("%w: only s3:// sources", ErrUnsupported)
+	}
+
+	obj, err := r.storage.GetObject(ctx, u.Host, strings.TrimPrefix(u.Path, "/"))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read source image: %w", err)
+	}
+	defer obj.Close()
+
+	data, err := io.ReadAll(io.LimitReader(obj, maxSourceBytes+1))
+	if storage.IsNotFound(err) {
+		return nil, "", ErrSourceNotFound
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read source image: %w", err)
+	}
+	if len(data) > maxSourceBytes {
+		return nil, "", ErrSourceTooLarge
+	}
+
+	// Check the resolution before allocating the pixels
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedImage
+	}
+	if cfg.Width*cfg.Height > maxSourcePixels {
+		return nil, "", ErrSourceTooLarge
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedImage
+	}
+	return img, format, nil
+}
+
+// flatten draws img over the background color, since JPEG has no alpha
+func flatten(img image.Image, p *plan) image.Image {
+	if isOpaque(img) {
+		return img
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(p.background), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Over)
+	return dst
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package render
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOutputFormat(t *testing.T) {
+	tests := []struct {
+		requested string
+		source    string
+		opaque    bool
+		want      string
+		err       error
+	}{
+		{"", "jpeg", true, "jpg", nil},
+		{"", "png", false, "png", nil},
+		{"", "gif", false, "gif", nil},
+		{"png", "jpeg", true, "png", nil},
+		{"JPEG", "png", false, "jpg", nil},
+		{"webp", "jpeg", true, "jpg", nil},
+		{"webp", "png", false, "png", nil},
+		{"avif", "png", true, "jpg", nil},
+		{"avif", "png", false, "png", nil},
+		// WebP sources are decoded, but kept formats have to be encoded too
+		{"", "webp", true, "jpg", nil},
+		{"bmp", "jpeg", true, "", ErrUnsupported},
+		{"", "tiff", true, "", ErrUnsupported},
+	}
+	for _, tt := range tests {
+		got, err := outputFormat(tt.requested, tt.source, tt.opaque)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("outputFormat(%q, %q, %v) = %q, %v; want %q, %v", tt.requested, tt.source, tt.opaque, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestCanEncode(t *testing.T) {
+	for format, want := range map[string]bool{"jpg": true, "JPEG": true, "png": true, "gif": true, "webp": false, "avif": false, "": false} {
+		if got := CanEncode(format); got != want {
+			t.Errorf("CanEncode(%q) = %v, want %v", format, got, want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+
+	"golang.org/x/image/draw"
+)
+
+// watermarkSpec is a watermark option, in imgproxy's semantics
+type watermarkSpec struct {
+	opacity  float64
+	position string
+	xOffset  int
+	yOffset  int
+	// scale is the watermark width relative to the image; 0 keeps its size
+	scale  float64
+	source string
+}
+
+// parseWatermark reads wm:opacity:position:x_offset:y_offset:scale. An
+// opacity of 0 turns the watermark off and returns nil.
+func parseWatermark(args []string) (*watermarkSpec, error) {
+	arg := func(i int) string {
+		if i < len(args) {
+			return args[i]
+		}
+		return ""
+	}
+
+	opacity, err := strconv.ParseFloat(arg(0), 64)
+	if err != nil || opacity < 0 {
+		return nil, fmt.Errorf("invalid opacity %q", arg(0))
+	}
+	if opacity == 0 {
+		return nil, nil
+	}
+
+	wm := &watermarkSpec{opacity: math.Min(opacity, 1), position: "ce"}
+	if position := arg(1); position != "" {
+		if position != "re" && (!gravities[position] || position == "sm") {
+			return nil, fmt.Errorf("unsupported position %q", position)
+		}
+		wm.position = position
+	}
+	for i, dst := range []*int{&wm.xOffset, &wm.yOffset} {
+		if v := arg(2 + i); v != "" {
+			if *dst, err = strconv.Atoi(v); err != nil {
+				return nil, fmt.Errorf("invalid offset %q", v)
+			}
+		}
+	}
+	if v := arg(4); v != "" {
+		if wm.scale, err = strconv.ParseFloat(v, 64); err != nil || wm.scale < 0 {
+			return nil, fmt.Errorf("invalid scale %q", v)
+		}
+	}
+	return wm, nil
+}
+
+// overlay draws mark over img at the watermark's position, or tiles it with
+// re, where the offsets are the spacing between tiles
+func (wm *watermarkSpec) overlay(img, mark image.Image) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	if wm.scale > 0 {
+		mb := mark.Bounds()
+		w := max(1, int(math.Round(float64(dst.Bounds().Dx())*wm.scale)))
+		h := max(1, int(math.Round(float64(w)*float64(mb.Dy())/float64(mb.Dx()))))
+		scaled := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.CatmullRom.Scale(scaled, scaled.Bounds(), mark, mb, draw.Src, nil)
+		mark = scaled
+	}
+
+	mb := mark.Bounds()
+	mask := image.NewUniform(color.Alpha{A: uint8(math.Round(wm.opacity * 0xff))})
+
+	if wm.position == "re" {
+		stepX, stepY := max(1, mb.Dx()+wm.xOffset), max(1, mb.Dy()+wm.yOffset)
+		for y := 0; y < dst.Bounds().Dy(); y += stepY {
+			for x := 0; x < dst.Bounds().Dx(); x += stepX {
+				r := image.Rect(x, y, x+mb.Dx(), y+mb.Dy())
+				draw.DrawMask(dst, r, mark, mb.Min, mask, image.Point{}, draw.Over)
+			}
+		}
+		return dst
+	}
+
+	// Offsets point away from the edge the watermark sits on
+	dx, dy := wm.xOffset, wm.yOffset
+	switch wm.position {
+	case "ea", "noea", "soea":
+		dx = -dx
+	}
+	switch wm.position {
+	case "so", "soea", "sowe":
+		dy = -dy
+	}
+	r := place(dst.Bounds(), mb.Dx(), mb.Dy(), gravity{typ: wm.position}).Add(image.Pt(dx, dy))
+	draw.DrawMask(dst, r, mark, mb.Min, mask, image.Point{}, draw.Over)
+	return dst
+}
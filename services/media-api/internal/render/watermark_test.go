@@ -0,0 +1,104 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// markedPixels returns the pixels of img the black watermark covers
+func markedPixels(img image.Image) map[image.Point]bool {
+	marked := map[image.Point]bool{}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if r, _, _, _ := img.At(x, y).RGBA(); r == 0 {
+				marked[image.Pt(x-b.Min.X, y-b.Min.Y)] = true
+			}
+		}
+	}
+	return marked
+}
+
+// rects returns the points of rectangles
+func rects(rs ...image.Rectangle) map[image.Point]bool {
+	points := map[image.Point]bool{}
+	for _, r := range rs {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				points[image.Pt(x, y)] = true
+			}
+		}
+	}
+	return points
+}
+
+func TestWatermarkOverlay(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want map[image.Point]bool
+	}{
+		{"center", []string{"1"}, rects(image.Rect(4, 4, 6, 6))},
+		{"north west", []string{"1", "nowe", "1", "2"}, rects(image.Rect(1, 2, 3, 4))},
+		{"south east offsets point inwards", []string{"1", "soea", "1", "2"}, rects(image.Rect(7, 6, 9, 8))},
+		{"scaled to the image", []string{"1", "nowe", "0", "0", "0.4"}, rects(image.Rect(0, 0, 4, 4))},
+		{"tiled", []string{"1", "re"}, rects(image.Rect(0, 0, 10, 10))},
+		{"tiled with spacing", []string{"1", "re", "3", "1"}, rects(
+			image.Rect(0, 0, 2, 2), image.Rect(5, 0, 7, 2),
+			image.Rect(0, 3, 2, 5), image.Rect(5, 3, 7, 5),
+			image.Rect(0, 6, 2, 8), image.Rect(5, 6, 7, 8),
+			image.Rect(0, 9, 2, 10), image.Rect(5, 9, 7, 10),
+		)},
+		{"tiled once", []string{"1", "re", "8", "8"}, rects(image.Rect(0, 0, 2, 2))},
+		// Negative spacing overlaps tiles, down to a step of one pixel
+		{"tiled with overlap", []string{"1", "re", "-5", "-5"}, rects(image.Rect(0, 0, 10, 10))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wm, err := parseWatermark(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// A white image, offset to check the output starts at the origin
+			img := image.NewRGBA(image.Rect(5, 5, 15, 15))
+			draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+			mark := image.NewRGBA(image.Rect(0, 0, 2, 2))
+			draw.Draw(mark, mark.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+			got := markedPixels(wm.overlay(img, mark))
+			if len(got) != len(tt.want) {
+				t.Fatalf("watermark covers %d pixels, want %d", len(got), len(tt.want))
+			}
+			for p := range tt.want {
+				if !got[p] {
+					t.Errorf("pixel %v not covered", p)
+				}
+			}
+		})
+	}
+}
+
+func TestParseWatermark(t *testing.T) {
+	tests := []struct {
+		args  []string
+		valid bool
+		off   bool
+	}{
+		{[]string{"0.5", "re", "10", "-10", "0.2"}, true, false},
+		{[]string{"0"}, true, true},
+		{[]string{"2"}, true, false},
+		{[]string{""}, false, false},
+		{[]string{"-1"}, false, false},
+		{[]string{"1", "sm"}, false, false},
+		{[]string{"1", "ce", "x"}, false, false},
+		{[]string{"1", "ce", "0", "0", "-1"}, false, false},
+	}
+	for _, tt := range tests {
+		wm, err := parseWatermark(tt.args)
+		if (err == nil) != tt.valid || (err == nil && (wm == nil) != tt.off) {
+			t.Errorf("parseWatermark(%q) = %+v, %v", tt.args, wm, err)
+		}
+	}
+}
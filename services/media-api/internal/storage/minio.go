@@ -1,8 +1,12 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/ancill/mediapod/services/media-api/internal/config"
@@ -10,6 +14,20 @@ import (
 	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
+// PublicPrefix is the only prefix of the VOD and thumbs buckets that is
+// anonymously readable; PUBLIC_VOD_URL and PUBLIC_THUMBS_URL point at it
+const PublicPrefix = "public/"
+
+// RenditionPrefix is the key prefix of an asset's renditions (HLS files,
+// poster) in the VOD and thumbs buckets. Renditions of private assets stay
+// outside PublicPrefix, where only presigned URLs reach them.
+func RenditionPrefix(assetID string, public bool) string {
+	if public {
+		return PublicPrefix + assetID + "/"
+	}
+	return assetID + "/"
+}
+
 type MinIO struct {
 	client        *minio.Client
 	presignClient *minio.Client // Client configured with public endpoint for presigned URLs
@@ -68,6 +86,27 @@ func (m *MinIO) PresignedGetURL(ctx context.Context, bucket, objectKey string, e
 	return presignedURL.String(), nil
 }
 
+// PresignedGetURLWithParams generates a presigned download URL with response
+// header overrides such as response-content-disposition
+func (m *MinIO) PresignedGetURLWithParams(ctx context.Context, bucket, objectKey string, expires time.Duration, params url.Values) (string, error) {
+	presignedURL, err := m.presignClient.PresignedGetObject(ctx, bucket, objectKey, expires, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate presigned URL: %w", err)
+	}
+
+	return presignedURL.String(), nil
+}
+
+// GetObject opens an object for reading through the internal endpoint
+func (m *MinIO) GetObject(ctx context.Context, bucket, objectKey string) (*minio.Object, error) {
+	obj, err := m.client.GetObject(ctx, bucket, objectKey, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+
+	return obj, nil
+}
+
 // ObjectInfo retrieves object metadata
 func (m *MinIO) ObjectInfo(ctx context.Context, bucket, objectKey string) (*minio.ObjectInfo, error) {
 	info, err := m.client.StatObject(ctx, bucket, objectKey, minio.StatObjectOptions{})
@@ -88,6 +127,30 @@ func (m *MinIO) DeleteObject(ctx context.Context, bucket, objectKey string) erro
 	return nil
 }
 
+// PutObject uploads data as an object
+func (m *MinIO) PutObject(ctx context.Context, bucket, objectKey string, data []byte, contentType string) error {
+	_, err := m.client.PutObject(ctx, bucket, objectKey, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put object: %w", err)
+	}
+
+	return nil
+}
+
+// DeletePrefix deletes every object whose key starts with prefix
+func (m *MinIO) DeletePrefix(ctx context.Context, bucket, prefix string) error {
+	objects := m.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true})
+	for result := range m.client.RemoveObjects(ctx, bucket, objects, minio.RemoveObjectsOptions{}) {
+		if result.Err != nil {
+			return fmt.Errorf("failed to delete objects: %w", result.Err)
+		}
+	}
+
+	return nil
+}
+
 // CopyObject copies an object within MinIO
 func (m *MinIO) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
 	src := minio.CopySrcOptions{
@@ -108,6 +171,35 @@ func (m *MinIO) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, ds
 	return nil
 }
 
+// MovePrefix moves every object whose key starts with from to the same key
+// under to. Objects are copied before they are deleted, so an interrupted move
+// can be repeated.
+func (m *MinIO) MovePrefix(ctx context.Context, bucket, from, to string) error {
+	for object := range m.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: from, Recursive: true}) {
+		if object.Err != nil {
+			return fmt.Errorf("failed to list objects: %w", object.Err)
+		}
+		dstKey := to + strings.TrimPrefix(object.Key, from)
+		if err := m.CopyObject(ctx, bucket, object.Key, bucket, dstKey); err != nil {
+			return err
+		}
+		if err := m.DeleteObject(ctx, bucket, object.Key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IsNotFound reports whether err means the object or its bucket does not exist
+func IsNotFound(err error) bool {
+	var resp minio.ErrorResponse
+	if errors.As(err, &resp) {
+		return resp.Code == "NoSuchKey" || resp.Code == "NoSuchBucket"
+	}
+	return false
+}
+
 // GetClient returns the underlying MinIO client
 func (m *MinIO) GetClient() *minio.Client {
 	return m.client
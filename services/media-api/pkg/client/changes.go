@@ -0,0 +1,36 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListChanges reads the catalog changefeed after cursor ("" for the
+// beginning). Store NextCursor and keep reading while HasMore is true.
+func (c *Client) ListChanges(ctx context.Context, cursor string, limit int) (*ChangeList, error) {
+	query := url.Values{}
+	if cursor != "" {
+		query.Set("since", cursor)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var list ChangeList
+	if err := c.do(ctx, http.MethodGet, "/v1/changes", query, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// OpenAPI fetches the API's OpenAPI document
+func (c *Client) OpenAPI(ctx context.Context) (json.RawMessage, error) {
+	var doc json.RawMessage
+	if err := c.do(ctx, http.MethodGet, "/v1/openapi.json", nil, nil, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
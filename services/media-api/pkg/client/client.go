@@ -0,0 +1,313 @@
+// Package client is the Go client for the Mediapod API.
+//
+//	c := client.New("https://media.yourdomain.com")
+//	asset, err := c.UploadFile(ctx, "photo.jpg", client.UploadOptions{Kind: client.KindImage})
+//	asset, err = c.WaitUntilReady(ctx, asset.ID, client.WaitOptions{})
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client calls the Mediapod API. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	authToken  string
+	userAgent  string
+	httpClient *http.Client
+	retry      RetryPolicy
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for API calls and uploads
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithAuthToken sends the token, such as an API key, as a bearer token with
+// every API call
+func WithAuthToken(token string) Option {
+	return func(c *Client) { c.authToken = token }
+}
+
+// WithUserAgent sets the User-Agent header of API calls
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// WithRetryPolicy sets how failed requests are retried
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) { c.retry = policy }
+}
+
+// RetryPolicy controls retries of API calls and storage uploads. Requests
+// are retried on network errors, 429 and 5xx responses.
+type RetryPolicy struct {
+	MaxAttempts int           // including the first attempt; 1 disables retries
+	BaseDelay   time.Duration // doubled after every attempt
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy is used unless WithRetryPolicy is given
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+}
+
+// New creates a client for the API at baseURL, e.g. https://media.yourdomain.com
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		userAgent:  "mediapod-go",
+		httpClient: http.DefaultClient,
+		retry:      DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Error codes of API errors (see Error.Code)
+const (
+	CodeInvalidRequest        = "invalid_request"
+	CodeValidationFailed      = "validation_failed"
+	CodeRequestTooLarge       = "request_too_large"
+	CodeOperationNotAllowed   = "operation_not_allowed"
+	CodeUnauthorized          = "unauthorized"
+	CodeInvalidSignature      = "invalid_signature"
+	CodeURLExpired            = "url_expired"
+	CodeSourceNotAllowed      = "source_not_allowed"
+	CodeNotFound              = "not_found"
+	CodeMethodNotAllowed      = "method_not_allowed"
+	CodeAssetNotFound         = "asset_not_found"
+	CodeWebhookNotFound       = "webhook_not_found"
+	CodeAPIKeyNotFound        = "api_key_not_found"
+	CodeObjectNotFound        = "object_not_found"
+	CodePresetNotFound        = "preset_not_found"
+	CodeAssetNotReady         = "asset_not_ready"
+	CodeInvalidState          = "invalid_state"
+	CodeIdempotencyInProgress = "idempotency_key_in_progress"
+	CodeIdempotencyKeyReused  = "idempotency_key_reused"
+	CodeInternal              = "internal_error"
+	CodeUpstream              = "upstream_error"
+)
+
+// Error is returned for API responses with an error status
+type Error struct {
+	StatusCode int
+	// Code is the machine-readable error code; empty for responses that did
+	// not come from the API (e.g. a proxy in between)
+	Code    string
+	Message string
+	// RequestID identifies the request in the server logs
+	RequestID string
+	// Fields lists the invalid values of a rejected request body
+	Fields []FieldError
+}
+
+// FieldError describes why a value in a request body is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("mediapod: %s (status %d", e.Message, e.StatusCode)
+	if e.Code != "" {
+		msg += ", " + e.Code
+	}
+	msg += ")"
+	for _, f := range e.Fields {
+		msg += fmt.Sprintf("; %s: %s", f.Field, f.Message)
+	}
+	return msg
+}
+
+// IsNotFound reports whether err is an API 404 response
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// ErrorCode returns the error code of an API error, or "" for other errors
+func ErrorCode(err error) string {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return ""
+}
+
+// do sends a JSON API request and decodes a JSON response into out (if non-nil).
+// Requests are retried according to the retry policy; mutating requests carry
+// an Idempotency-Key so a retry never applies a change twice.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("mediapod: failed to encode request: %w", err)
+		}
+	}
+
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	idempotencyKey := ""
+	if method != http.MethodGet {
+		idempotencyKey = newIdempotencyKey()
+	}
+
+	resp, err := c.send(ctx, c.retry.MaxAttempts, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		c.setHeaders(req)
+		if in != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if idempotencyKey != "" {
+			req.Header.Set("Idempotency-Key", idempotencyKey)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return parseError(resp)
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("mediapod: failed to decode response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) setHeaders(req *http.Request) {
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.authToken)
+	}
+}
+
+// send performs a request built by newRequest, retrying up to attempts
+// times on network errors and retryable statuses
+func (c *Client) send(ctx context.Context, attempts int, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, fmt.Errorf("mediapod: failed to build request: %w", err)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		switch {
+		case err != nil:
+			lastErr = err
+		case retryable(resp) && attempt < attempts:
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			lastErr = fmt.Errorf("status %d", resp.StatusCode)
+		default:
+			return resp, nil
+		}
+
+		if attempt >= attempts || ctx.Err() != nil {
+			return nil, fmt.Errorf("mediapod: request failed after %d attempt(s): %w", attempt, lastErr)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.retry.delay(attempt)):
+		}
+	}
+}
+
+// delay returns the backoff after the given (1-based) attempt
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay << (attempt - 1)
+	if p.MaxDelay > 0 && (d > p.MaxDelay || d <= 0) {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// retryable reports whether a response is worth retrying. 409 with
+// Retry-After means an earlier attempt with the same Idempotency-Key is
+// still running.
+func retryable(resp *http.Response) bool {
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return true
+	case resp.StatusCode == http.StatusConflict:
+		return resp.Header.Get("Retry-After") != ""
+	}
+	return false
+}
+
+// newIdempotencyKey returns a random key for one logical request
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // crypto/rand does not fail on supported platforms
+	}
+	return hex.EncodeToString(b)
+}
+
+func parseError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	var problem struct {
+		Title     string       `json:"title"`
+		Detail    string       `json:"detail"`
+		Code      string       `json:"code"`
+		RequestID string       `json:"requestId"`
+		Fields    []FieldError `json:"fields"`
+	}
+	if json.Unmarshal(data, &problem) == nil && problem.Code != "" {
+		apiErr.Code = problem.Code
+		apiErr.Message = problem.Detail
+		if apiErr.Message == "" {
+			apiErr.Message = problem.Title
+		}
+		apiErr.RequestID = problem.RequestID
+		apiErr.Fields = problem.Fields
+	} else if len(data) > 0 {
+		apiErr.Message = strings.TrimSpace(string(data))
+	} else {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
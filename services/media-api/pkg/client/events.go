@@ -0,0 +1,93 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrStopStream can be returned by a StreamEvents callback to end the
+// stream without an error
+var ErrStopStream = errors.New("mediapod: stop stream")
+
+// StreamEvents follows the state changes and processing progress of an asset
+// (fetched with GetAsset, so private assets can be authorized) and calls fn
+// for every event until ctx ends, fn returns an error, or the server closes
+// the stream. Pass the ID of the last event seen to resume after it.
+func (c *Client) StreamEvents(ctx context.Context, asset *Asset, lastEventID string, fn func(Event) error) error {
+	query := url.Values{}
+	if signed, err := url.Parse(asset.URL("original")); err == nil {
+		// Delivery tokens are per asset, so any signed URL authorizes the stream
+		if exp, token := signed.Query().Get("exp"), signed.Query().Get("token"); exp != "" && token != "" {
+			query.Set("exp", exp)
+			query.Set("token", token)
+		}
+	}
+
+	u := c.baseURL + "/v1/media/" + url.PathEscape(asset.ID) + "/events"
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("mediapod: failed to build request: %w", err)
+	}
+	c.setHeaders(req)
+	req.Header.Set("Accept", "text/event-stream")
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("mediapod: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return parseError(resp)
+	}
+
+	var data strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data.Len() == 0 {
+				continue
+			}
+			var ev Event
+			if err := json.Unmarshal([]byte(data.String()), &ev); err != nil {
+				return fmt.Errorf("mediapod: failed to decode event: %w", err)
+			}
+			data.Reset()
+			if err := fn(ev); err != nil {
+				if errors.Is(err, ErrStopStream) {
+					return nil
+				}
+				return err
+			}
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+		// id: and event: lines are repeated in the JSON payload; comments are heartbeats
+	}
+
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("mediapod: event stream failed: %w", err)
+	}
+	return nil
+}
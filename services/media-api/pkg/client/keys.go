@@ -0,0 +1,31 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// CreateAPIKey creates an API key. The returned key carries the secret,
+// which is not returned again.
+func (c *Client) CreateAPIKey(ctx context.Context, req CreateAPIKeyRequest) (*APIKey, error) {
+	var key APIKey
+	if err := c.do(ctx, http.MethodPost, "/v1/keys", nil, req, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ListAPIKeys lists API keys
+func (c *Client) ListAPIKeys(ctx context.Context) (*APIKeyList, error) {
+	var list APIKeyList
+	if err := c.do(ctx, http.MethodGet, "/v1/keys", nil, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// DeleteAPIKey revokes an API key
+func (c *Client) DeleteAPIKey(ctx context.Context, keyID string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/keys/"+url.PathEscape(keyID), nil, nil, nil)
+}
@@ -0,0 +1,272 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InitUpload creates an asset and returns the presigned URL to upload it to
+func (c *Client) InitUpload(ctx context.Context, req InitUploadRequest) (*InitUploadResponse, error) {
+	var resp InitUploadResponse
+	if err := c.do(ctx, http.MethodPost, "/v1/media/init-upload", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CompleteUpload marks an upload as complete and starts processing
+func (c *Client) CompleteUpload(ctx context.Context, assetID string) (*CompleteUploadResponse, error) {
+	var resp CompleteUploadResponse
+	body := map[string]string{"assetId": assetID}
+	if err := c.do(ctx, http.MethodPost, "/v1/media/complete", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetAsset fetches an asset with its delivery URLs
+func (c *Client) GetAsset(ctx context.Context, assetID string) (*Asset, error) {
+	var asset Asset
+	if err := c.do(ctx, http.MethodGet, "/v1/media/"+url.PathEscape(assetID), nil, nil, &asset); err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// UpdateAsset applies a partial update to an asset
+func (c *Client) UpdateAsset(ctx context.Context, assetID string, req UpdateAssetRequest) (*Asset, error) {
+	var asset Asset
+	if err := c.do(ctx, http.MethodPatch, "/v1/media/"+url.PathEscape(assetID), nil, req, &asset); err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// ListOptions filters and pages ListAssets
+type ListOptions struct {
+	Kind       string
+	State      string
+	Visibility string
+	Query      string // filename substring
+	Tag        string
+	// Sort is created (default) or captured, by the capture time of images
+	Sort string
+	// Color matches assets with a palette color within ColorDistance (CIE76
+	// delta-E, default 15) of a hex color such as ff8800
+	Color         string
+	ColorDistance int
+	Limit         int // default 50, max 500
+	Offset        int
+}
+
+// ListAssets lists assets, newest first
+func (c *Client) ListAssets(ctx context.Context, opts ListOptions) (*AssetList, error) {
+	query := url.Values{}
+	for key, value := range map[string]string{
+		"kind":       opts.Kind,
+		"state":      opts.State,
+		"visibility": opts.Visibility,
+		"q":          opts.Query,
+		"tag":        opts.Tag,
+		"sort":       opts.Sort,
+		"color":      opts.Color,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if opts.ColorDistance > 0 {
+		query.Set("colorDistance", strconv.Itoa(opts.ColorDistance))
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Offset > 0 {
+		query.Set("offset", strconv.Itoa(opts.Offset))
+	}
+
+	var list AssetList
+	if err := c.do(ctx, http.MethodGet, "/v1/media", query, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// ListJobs lists the processing jobs of an asset, newest first
+func (c *Client) ListJobs(ctx context.Context, assetID string) (*JobList, error) {
+	var list JobList
+	if err := c.do(ctx, http.MethodGet, "/v1/media/"+url.PathEscape(assetID)+"/jobs", nil, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Reprocess queues the processing of a ready or failed asset again
+func (c *Client) Reprocess(ctx context.Context, assetID string) (*ReprocessResponse, error) {
+	var resp ReprocessResponse
+	if err := c.do(ctx, http.MethodPost, "/v1/media/"+url.PathEscape(assetID)+"/reprocess", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CreateImageURL signs a custom transformation of an image asset on the
+// server, for clients without the imgproxy key. A zero expiresIn gives a
+// permanent URL for public assets; private assets always get expiring URLs.
+func (c *Client) CreateImageURL(ctx context.Context, assetID string, ops Operations, expiresIn time.Duration) (*ImageURL, error) {
+	req := struct {
+		Operations Operations `json:"operations"`
+		ExpiresIn  int        `json:"expiresIn,omitempty"`
+	}{ops, int(expiresIn / time.Second)}
+
+	var resp ImageURL
+	if err := c.do(ctx, http.MethodPost, "/v1/media/"+url.PathEscape(assetID)+"/image-url", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SrcsetOptions overrides the server defaults of GetSrcset
+type SrcsetOptions struct {
+	Widths  []int
+	Formats []string // in order of preference; the last is the <img> fallback
+	Sizes   string   // default 100vw
+	Quality int
+	Aspect  string // e.g. "16:9" crops to an aspect ratio, following the focus
+}
+
+// GetSrcset returns a responsive image set for an image asset
+func (c *Client) GetSrcset(ctx context.Context, assetID string, opts SrcsetOptions) (*Srcset, error) {
+	query := url.Values{}
+	if len(opts.Widths) > 0 {
+		widths := make([]string, len(opts.Widths))
+		for i, w := range opts.Widths {
+			widths[i] = strconv.Itoa(w)
+		}
+		query.Set("widths", strings.Join(widths, ","))
+	}
+	if len(opts.Formats) > 0 {
+		query.Set("formats", strings.Join(opts.Formats, ","))
+	}
+	if opts.Sizes != "" {
+		query.Set("sizes", opts.Sizes)
+	}
+	if opts.Quality > 0 {
+		query.Set("quality", strconv.Itoa(opts.Quality))
+	}
+	if opts.Aspect != "" {
+		query.Set("aspect", opts.Aspect)
+	}
+
+	var srcset Srcset
+	if err := c.do(ctx, http.MethodGet, "/v1/media/"+url.PathEscape(assetID)+"/srcset", query, nil, &srcset); err != nil {
+		return nil, err
+	}
+	return &srcset, nil
+}
+
+// SetFocus replaces the focal point and crop hints of an image asset, which
+// steer the crops of presets, srcsets and CreateImageURL. A nil point and no
+// hints clear them.
+func (c *Client) SetFocus(ctx context.Context, assetID string, point *FocalPoint, hints []CropHint) (*Asset, error) {
+	req := struct {
+		FocalPoint *FocalPoint `json:"focalPoint,omitempty"`
+		CropHints  []CropHint  `json:"cropHints,omitempty"`
+	}{point, hints}
+
+	var asset Asset
+	if err := c.do(ctx, http.MethodPut, "/v1/media/"+url.PathEscape(assetID)+"/focus", nil, req, &asset); err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// DeleteAsset deletes an asset and its original
+func (c *Client) DeleteAsset(ctx context.Context, assetID string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/media/"+url.PathEscape(assetID), nil, nil, nil)
+}
+
+// OpenOriginal streams the original file of an asset. The caller must close
+// the returned reader. Private assets are read through their signed URL.
+func (c *Client) OpenOriginal(ctx context.Context, assetID string) (io.ReadCloser, error) {
+	asset, err := c.GetAsset(ctx, assetID)
+	if err != nil {
+		return nil, err
+	}
+
+	originalURL := asset.URL("download")
+	if originalURL == "" {
+		return nil, fmt.Errorf("mediapod: asset %s has no download URL", assetID)
+	}
+
+	resp, err := c.send(ctx, c.retry.MaxAttempts, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, originalURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", c.userAgent)
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, parseError(resp)
+	}
+	return resp.Body, nil
+}
+
+// WaitOptions configures WaitUntilReady
+type WaitOptions struct {
+	// PollInterval between status checks (default 2s)
+	PollInterval time.Duration
+	// Timeout bounds the wait in addition to ctx (default: none)
+	Timeout time.Duration
+}
+
+// ErrAssetFailed is returned by WaitUntilReady when processing failed
+var ErrAssetFailed = fmt.Errorf("mediapod: asset processing failed")
+
+// WaitUntilReady polls an asset until it is ready. It returns the asset
+// together with ErrAssetFailed if processing failed, and ctx's error if ctx
+// ends (or Timeout passes) first.
+func (c *Client) WaitUntilReady(ctx context.Context, assetID string, opts WaitOptions) (*Asset, error) {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 2 * time.Second
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		asset, err := c.GetAsset(ctx, assetID)
+		if err != nil {
+			return nil, err
+		}
+
+		switch asset.State {
+		case StateReady:
+			return asset, nil
+		case StateFailed:
+			return asset, ErrAssetFailed
+		}
+
+		select {
+		case <-ctx.Done():
+			return asset, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
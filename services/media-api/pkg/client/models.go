@@ -0,0 +1,394 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Asset kinds
+const (
+	KindImage    = "image"
+	KindVideo    = "video"
+	KindAudio    = "audio"
+	KindDocument = "document"
+)
+
+// Asset states
+const (
+	StateUploading  = "uploading"
+	StateProcessing = "processing"
+	StateReady      = "ready"
+	StateFailed     = "failed"
+)
+
+// Asset visibility values
+const (
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+)
+
+// InitUploadRequest represents the request to initialize an upload
+type InitUploadRequest struct {
+	MimeType   string `json:"mime"`
+	Kind       string `json:"kind"`
+	Filename   string `json:"filename"`
+	Size       int64  `json:"size"`
+	Visibility string `json:"visibility,omitempty"`
+}
+
+// InitUploadResponse carries the presigned URL to upload the original to
+type InitUploadResponse struct {
+	AssetID      string            `json:"assetId"`
+	Bucket       string            `json:"bucket"`
+	ObjectKey    string            `json:"objectKey"`
+	PresignedURL string            `json:"presignedUrl"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	ExpiresIn    int               `json:"expiresIn"` // seconds
+}
+
+// CompleteUploadResponse represents the response after completing upload
+type CompleteUploadResponse struct {
+	State   string `json:"state"`
+	Message string `json:"message,omitempty"`
+}
+
+// UpdateAssetRequest represents a partial update of an asset
+type UpdateAssetRequest struct {
+	Visibility *string `json:"visibility,omitempty"`
+}
+
+// Asset represents an asset with its delivery URLs
+type Asset struct {
+	ID          string                 `json:"id"`
+	Kind        string                 `json:"kind"`
+	State       string                 `json:"state"`
+	Visibility  string                 `json:"visibility"`
+	Filename    string                 `json:"filename"`
+	MimeType    string                 `json:"mimeType"`
+	Size        int64                  `json:"size"`
+	Bucket      string                 `json:"bucket"`
+	ObjectKey   string                 `json:"objectKey"`
+	Width       *int                   `json:"width,omitempty"`
+	Height      *int                   `json:"height,omitempty"`
+	Duration    *float64               `json:"duration,omitempty"`
+	Image       *ImageInfo             `json:"image,omitempty"`
+	FocalPoint  *FocalPoint            `json:"focalPoint,omitempty"`
+	CropHints   []CropHint             `json:"cropHints,omitempty"`
+	Placeholder *Placeholder           `json:"placeholder,omitempty"`
+	Palette     []PaletteColor         `json:"palette,omitempty"`
+	Metadata    *ImageMetadata         `json:"metadata,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	CreatedAt   time.Time              `json:"createdAt"`
+	URLs        map[string]interface{} `json:"urls"`
+}
+
+// ImageInfo is what the worker read from the header of an image before it
+// turned ready
+type ImageInfo struct {
+	Format       string `json:"format"`                 // jpeg, png, gif, webp, avif, heic, tiff or bmp
+	ColorSpace   string `json:"colorSpace,omitempty"`   // rgb, gray or cmyk
+	ColorProfile string `json:"colorProfile,omitempty"` // e.g. sRGB or Display P3
+	BitDepth     int    `json:"bitDepth,omitempty"`     // bits per channel
+	HasAlpha     bool   `json:"hasAlpha"`
+	FrameCount   int    `json:"frameCount,omitempty"` // above 1 for animations and multi-page TIFFs
+}
+
+// FocalPoint is the point of an image that crops keep, in fractions of its
+// width and height from the top left corner
+type FocalPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// CropHint is a region of an image, in fractions of it, to crop to for
+// outputs of the same aspect ratio
+type CropHint struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// Placeholder is what to draw while an image or video poster loads. It is
+// set by the worker shortly after upload.
+type Placeholder struct {
+	BlurHash string `json:"blurHash"`
+	// ThumbHash is base64 encoded
+	ThumbHash string `json:"thumbHash"`
+	// DominantColor is #rrggbb
+	DominantColor string `json:"dominantColor"`
+}
+
+// PaletteColor is a color of an image or video poster with its share of the
+// pixels
+type PaletteColor struct {
+	Color      string  `json:"color"`
+	Percentage float64 `json:"percentage"`
+}
+
+// ImageMetadata is what the worker read from the EXIF, IPTC and XMP
+// metadata of an image
+type ImageMetadata struct {
+	Camera      *Camera    `json:"camera,omitempty"`
+	Lens        string     `json:"lens,omitempty"`
+	Exposure    *Exposure  `json:"exposure,omitempty"`
+	CapturedAt  *time.Time `json:"capturedAt,omitempty"`
+	Orientation int        `json:"orientation,omitempty"` // EXIF orientation, 1-8
+	GPS         *GPS       `json:"gps,omitempty"`
+	Artist      string     `json:"artist,omitempty"`
+	Copyright   string     `json:"copyright,omitempty"`
+	Title       string     `json:"title,omitempty"`
+	Headline    string     `json:"headline,omitempty"`
+	Caption     string     `json:"caption,omitempty"`
+	Keywords    []string   `json:"keywords,omitempty"`
+}
+
+// Camera is the make and model of the capturing device
+type Camera struct {
+	Make  string `json:"make,omitempty"`
+	Model string `json:"model,omitempty"`
+}
+
+// Exposure is the capture settings of a photo
+type Exposure struct {
+	FNumber      float64 `json:"fNumber,omitempty"`
+	ExposureTime string  `json:"exposureTime,omitempty"` // seconds, e.g. 1/250
+	ISO          int     `json:"iso,omitempty"`
+	FocalLength  float64 `json:"focalLength,omitempty"` // mm
+}
+
+// GPS is where a photo was taken, in decimal degrees and meters
+type GPS struct {
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Altitude  *float64 `json:"altitude,omitempty"`
+}
+
+func (a *Asset) IsReady() bool      { return a.State == StateReady }
+func (a *Asset) IsProcessing() bool { return a.State == StateProcessing }
+func (a *Asset) IsFailed() bool     { return a.State == StateFailed }
+func (a *Asset) IsUploading() bool  { return a.State == StateUploading }
+
+// URL returns the delivery URL with the given name (e.g. "original",
+// "thumbnail", "hls"), or "" if the asset has none
+func (a *Asset) URL(name string) string {
+	s, _ := a.URLs[name].(string)
+	return s
+}
+
+// PresetURL returns the URL of an image rendered with a named preset (e.g.
+// "avatar-sm", "card"), or "" if the asset has no such preset
+func (a *Asset) PresetURL(name string) string {
+	presets, _ := a.URLs["presets"].(map[string]interface{})
+	s, _ := presets[name].(string)
+	return s
+}
+
+// ImageURL holds the signed URLs of an image transformation
+type ImageURL struct {
+	URL string `json:"url"`
+	// ProxyURL serves the same image through the API
+	ProxyURL   string     `json:"proxyUrl"`
+	Operations string     `json:"operations"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+}
+
+// Srcset is a responsive image set. Render Sources as <source> elements of a
+// <picture> and Src, Srcset and Sizes on its <img>.
+type Srcset struct {
+	AssetID    string            `json:"assetId"`
+	Width      *int              `json:"width,omitempty"`
+	Height     *int              `json:"height,omitempty"`
+	Src        string            `json:"src"`
+	Srcset     string            `json:"srcset"`
+	Sizes      string            `json:"sizes"`
+	Sources    []PictureSource   `json:"sources"`
+	Candidates []SrcsetCandidate `json:"candidates"`
+	ExpiresAt  *time.Time        `json:"expiresAt,omitempty"`
+}
+
+// PictureSource describes a <source> element of a <picture>
+type PictureSource struct {
+	Type   string `json:"type"`
+	Srcset string `json:"srcset"`
+	Sizes  string `json:"sizes"`
+}
+
+// SrcsetCandidate is one rendition of a Srcset
+type SrcsetCandidate struct {
+	URL    string `json:"url"`
+	Format string `json:"format"`
+	Width  int    `json:"width"`
+	Height int    `json:"height,omitempty"`
+}
+
+// AssetList represents a page of assets
+type AssetList struct {
+	Assets []Asset `json:"assets"`
+	Total  int     `json:"total"`
+}
+
+// Job is a processing job of an asset
+type Job struct {
+	ID          string     `json:"id"`
+	Type        string     `json:"type"`
+	State       string     `json:"state"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"maxAttempts"`
+	Error       *string    `json:"error,omitempty"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// JobList represents the processing jobs of an asset
+type JobList struct {
+	Jobs  []Job `json:"jobs"`
+	Total int   `json:"total"`
+}
+
+// ReprocessResponse represents the response after queueing reprocessing
+type ReprocessResponse struct {
+	State string `json:"state"`
+	JobID string `json:"jobId"`
+}
+
+// Webhook event names
+const (
+	EventAssetCreated  = "asset.created"
+	EventAssetUploaded = "asset.uploaded"
+	EventAssetReady    = "asset.ready"
+	EventAssetFailed   = "asset.failed"
+	EventAssetDeleted  = "asset.deleted"
+)
+
+// CreateWebhookRequest represents the request to subscribe a webhook
+type CreateWebhookRequest struct {
+	URL string `json:"url"`
+	// Secret is used to sign deliveries; generated when omitted
+	Secret string `json:"secret,omitempty"`
+	// Events to deliver; empty subscribes to all events
+	Events []string `json:"events,omitempty"`
+}
+
+// UpdateWebhookRequest represents a partial update of a webhook
+type UpdateWebhookRequest struct {
+	URL    *string   `json:"url,omitempty"`
+	Events *[]string `json:"events,omitempty"`
+	Active *bool     `json:"active,omitempty"`
+}
+
+// Webhook represents a webhook subscription
+type Webhook struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Active bool     `json:"active"`
+	// Secret is only returned when the webhook is created
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// WebhookList represents all webhook subscriptions
+type WebhookList struct {
+	Webhooks []Webhook `json:"webhooks"`
+	Total    int       `json:"total"`
+}
+
+// WebhookDelivery represents a delivery and its attempt log
+type WebhookDelivery struct {
+	ID             string           `json:"id"`
+	Event          string           `json:"event"`
+	AssetID        *string          `json:"assetId,omitempty"`
+	State          string           `json:"state"`
+	Attempts       int              `json:"attempts"`
+	MaxAttempts    int              `json:"maxAttempts"`
+	NextAttemptAt  *time.Time       `json:"nextAttemptAt,omitempty"`
+	LastStatusCode *int             `json:"lastStatusCode,omitempty"`
+	LastError      *string          `json:"lastError,omitempty"`
+	DeliveredAt    *time.Time       `json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time        `json:"createdAt"`
+	Payload        json.RawMessage  `json:"payload"`
+	AttemptLog     []WebhookAttempt `json:"attemptLog"`
+}
+
+// WebhookAttempt represents a single delivery attempt
+type WebhookAttempt struct {
+	Attempt      int       `json:"attempt"`
+	StatusCode   *int      `json:"statusCode,omitempty"`
+	ResponseBody *string   `json:"responseBody,omitempty"`
+	Error        *string   `json:"error,omitempty"`
+	DurationMs   int       `json:"durationMs"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// WebhookDeliveryList represents the recent deliveries of a webhook
+type WebhookDeliveryList struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	Total      int               `json:"total"`
+}
+
+// CreateAPIKeyRequest represents the request to create an API key
+type CreateAPIKeyRequest struct {
+	// Name describes who or what uses the key
+	Name string `json:"name"`
+}
+
+// APIKey represents an API key
+type APIKey struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Prefix string `json:"prefix"`
+	// Key is only returned when the key is created
+	Key        string     `json:"key,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// APIKeyList represents all API keys
+type APIKeyList struct {
+	Keys  []APIKey `json:"keys"`
+	Total int      `json:"total"`
+}
+
+// Change operations
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeState  = "state"
+	ChangeDelete = "delete"
+)
+
+// Change is one entry of the catalog changefeed
+type Change struct {
+	Cursor    string    `json:"cursor"`
+	Op        string    `json:"op"`
+	AssetID   string    `json:"assetId"`
+	Asset     *Asset    `json:"asset,omitempty"` // nil for deletes
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+// ChangeList is a page of the changefeed
+type ChangeList struct {
+	Changes    []Change `json:"changes"`
+	NextCursor string   `json:"nextCursor"`
+	HasMore    bool     `json:"hasMore"`
+}
+
+// Event types
+const (
+	EventTypeState    = "state"
+	EventTypeProgress = "progress"
+)
+
+// Event is a state change or progress update of an asset
+type Event struct {
+	ID       string    `json:"id,omitempty"`
+	AssetID  string    `json:"assetId"`
+	Type     string    `json:"type"`
+	State    string    `json:"state,omitempty"`
+	Progress *float64  `json:"progress,omitempty"`
+	Message  string    `json:"message,omitempty"`
+	At       time.Time `json:"at"`
+}
@@ -0,0 +1,223 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// UploadOptions configures Upload
+type UploadOptions struct {
+	// Filename of the asset; required by Upload, defaults to the file name in UploadFile
+	Filename string
+	// MimeType is detected from the file extension, then the content, when empty
+	MimeType string
+	// Kind is derived from MimeType when empty
+	Kind string
+	// Visibility is public (default) or private
+	Visibility string
+	// OnProgress is called as the original is sent to storage. sent restarts
+	// from zero when an attempt is retried.
+	OnProgress func(sent, total int64)
+}
+
+// UploadFile uploads the file at path; see Upload
+func (c *Client) UploadFile(ctx context.Context, path string, opts UploadOptions) (*Asset, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if opts.Filename == "" {
+		opts.Filename = filepath.Base(path)
+	}
+	return c.Upload(ctx, f, opts)
+}
+
+// Upload runs the whole upload flow: it creates the asset, sends the content
+// of r to the presigned storage URL (retrying failed attempts) and completes
+// the upload. It returns the asset as it is after completion; use
+// WaitUntilReady for assets that need processing.
+//
+// The API issues a single presigned PUT per asset (it does not offer
+// multipart uploads), so an original is limited to what one PUT to storage
+// accepts. Readers that cannot seek are spooled to a temporary file so
+// attempts can be retried.
+func (c *Client) Upload(ctx context.Context, r io.Reader, opts UploadOptions) (*Asset, error) {
+	if opts.Filename == "" {
+		return nil, fmt.Errorf("mediapod: upload filename is required")
+	}
+
+	body, size, cleanup, err := seekable(r)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	if opts.MimeType == "" {
+		if opts.MimeType, err = detectMimeType(opts.Filename, body); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Kind == "" {
+		opts.Kind = KindForMimeType(opts.MimeType)
+	}
+
+	initResp, err := c.InitUpload(ctx, InitUploadRequest{
+		MimeType:   opts.MimeType,
+		Kind:       opts.Kind,
+		Filename:   opts.Filename,
+		Size:       size,
+		Visibility: opts.Visibility,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.putObject(ctx, initResp, body, size, opts.OnProgress); err != nil {
+		return nil, err
+	}
+
+	if _, err := c.CompleteUpload(ctx, initResp.AssetID); err != nil {
+		return nil, err
+	}
+
+	return c.GetAsset(ctx, initResp.AssetID)
+}
+
+// putObject sends body to the presigned URL, rewinding it for every attempt
+func (c *Client) putObject(ctx context.Context, init *InitUploadResponse, body io.ReadSeeker, size int64, onProgress func(sent, total int64)) error {
+	resp, err := c.send(ctx, c.retry.MaxAttempts, func() (*http.Request, error) {
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+
+		var reader io.Reader = body
+		if onProgress != nil {
+			reader = &progressReader{r: body, total: size, onProgress: onProgress}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, init.PresignedURL, io.NopCloser(reader))
+		if err != nil {
+			return nil, err
+		}
+		req.ContentLength = size
+		for k, v := range init.Headers {
+			req.Header.Set(k, v)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return &Error{StatusCode: resp.StatusCode, Message: "upload to storage failed: " + strings.TrimSpace(string(data))}
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// KindForMimeType maps a MIME type to the asset kind the API expects
+func KindForMimeType(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return KindImage
+	case strings.HasPrefix(mimeType, "video/"):
+		return KindVideo
+	case strings.HasPrefix(mimeType, "audio/"):
+		return KindAudio
+	default:
+		return KindDocument
+	}
+}
+
+func detectMimeType(filename string, body io.ReadSeeker) (string, error) {
+	if t := mime.TypeByExtension(strings.ToLower(filepath.Ext(filename))); t != "" {
+		mediaType, _, err := mime.ParseMediaType(t)
+		if err == nil {
+			return mediaType, nil
+		}
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	return mediaType, nil
+}
+
+// seekable returns r as a ReadSeeker with its size, spooling it to a
+// temporary file when r cannot seek
+func seekable(r io.Reader) (io.ReadSeeker, int64, func(), error) {
+	if rs, ok := r.(io.ReadSeeker); ok {
+		start, err := rs.Seek(0, io.SeekCurrent)
+		if err == nil {
+			end, err := rs.Seek(0, io.SeekEnd)
+			if err == nil {
+				if _, err := rs.Seek(start, io.SeekStart); err == nil {
+					return io.NewSectionReader(readerAt{rs}, start, end-start), end - start, func() {}, nil
+				}
+			}
+		}
+	}
+
+	tmp, err := os.CreateTemp("", "mediapod-upload-*")
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("mediapod: failed to buffer upload: %w", err)
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		cleanup()
+		return nil, 0, nil, fmt.Errorf("mediapod: failed to buffer upload: %w", err)
+	}
+	return io.NewSectionReader(tmp, 0, size), size, cleanup, nil
+}
+
+// readerAt adapts a ReadSeeker for io.SectionReader. Reads are sequential
+// within one attempt, so seeking before every read is cheap.
+type readerAt struct {
+	rs io.ReadSeeker
+}
+
+func (r readerAt) ReadAt(p []byte, off int64) (int, error) {
+	if _, err := r.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.ReadFull(r.rs, p)
+}
+
+type progressReader struct {
+	r          io.Reader
+	sent       int64
+	total      int64
+	onProgress func(sent, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.sent += int64(n)
+		p.onProgress(p.sent, p.total)
+	}
+	return n, err
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// CreateWebhook subscribes a webhook to asset events. The returned webhook
+// carries the signing secret, which is not returned again.
+func (c *Client) CreateWebhook(ctx context.Context, req CreateWebhookRequest) (*Webhook, error) {
+	var webhook Webhook
+	if err := c.do(ctx, http.MethodPost, "/v1/webhooks", nil, req, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// ListWebhooks lists webhook subscriptions
+func (c *Client) ListWebhooks(ctx context.Context) (*WebhookList, error) {
+	var list WebhookList
+	if err := c.do(ctx, http.MethodGet, "/v1/webhooks", nil, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// GetWebhook fetches a webhook subscription
+func (c *Client) GetWebhook(ctx context.Context, webhookID string) (*Webhook, error) {
+	var webhook Webhook
+	if err := c.do(ctx, http.MethodGet, "/v1/webhooks/"+url.PathEscape(webhookID), nil, nil, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// UpdateWebhook applies a partial update to a webhook subscription
+func (c *Client) UpdateWebhook(ctx context.Context, webhookID string, req UpdateWebhookRequest) (*Webhook, error) {
+	var webhook Webhook
+	if err := c.do(ctx, http.MethodPatch, "/v1/webhooks/"+url.PathEscape(webhookID), nil, req, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// DeleteWebhook deletes a webhook subscription
+func (c *Client) DeleteWebhook(ctx context.Context, webhookID string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/webhooks/"+url.PathEscape(webhookID), nil, nil, nil)
+}
+
+// DeliveryListOptions filters ListWebhookDeliveries
+type DeliveryListOptions struct {
+	State string // pending, delivered or failed
+	Limit int
+}
+
+// ListWebhookDeliveries lists recent deliveries of a webhook with their attempts
+func (c *Client) ListWebhookDeliveries(ctx context.Context, webhookID string, opts DeliveryListOptions) (*WebhookDeliveryList, error) {
+	query := url.Values{}
+	if opts.State != "" {
+		query.Set("state", opts.State)
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+
+	var list WebhookDeliveryList
+	path := "/v1/webhooks/" + url.PathEscape(webhookID) + "/deliveries"
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
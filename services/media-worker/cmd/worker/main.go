@@ -8,7 +8,9 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/ancill/mediapod/services/media-worker/internal/events"
 	"github.com/ancill/mediapod/services/media-worker/internal/processor"
+	"github.com/ancill/mediapod/services/media-worker/internal/webhook"
 	"github.com/ancill/mediapod/services/media-worker/internal/worker"
 	"github.com/go-redis/redis/v8"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -60,7 +62,7 @@ func main() {
 	procConfig := &processor.Config{
 		TempDir: cfg.TempDir,
 	}
-	proc := processor.New(dbPool, minioClient, procConfig)
+	proc := processor.New(dbPool, minioClient, events.NewPublisher(redisClient), procConfig)
 
 	// Initialize worker pool
 	workerPool := worker.NewPool(cfg.Concurrency, redisClient, proc)
@@ -70,6 +72,10 @@ func main() {
 
 	log.Info().Int("concurrency", cfg.Concurrency).Msg("Worker pool started")
 
+	// Start webhook dispatcher
+	dispatcher := webhook.NewDispatcher(dbPool, cfg.WebhookAllowPrivateNetworks)
+	dispatcher.Start()
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -79,6 +85,7 @@ func main() {
 
 	// Stop worker pool
 	workerPool.Stop()
+	dispatcher.Stop()
 
 	log.Info().Msg("Worker exited")
 }
@@ -92,6 +99,9 @@ type Config struct {
 	RedisAddr      string
 	Concurrency    int
 	TempDir        string
+	// WebhookAllowPrivateNetworks lets webhooks be delivered to loopback,
+	// link-local and private addresses, for local development
+	WebhookAllowPrivateNetworks bool
 }
 
 func loadConfig() Config {
@@ -127,6 +137,8 @@ func loadConfig() Config {
 		RedisAddr:      redisAddr,
 		Concurrency:    concurrency,
 		TempDir:        getEnv("TEMP_DIR", "/tmp/worker"),
+
+		WebhookAllowPrivateNetworks: getEnv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "false") == "true",
 	}
 }
 
@@ -0,0 +1,88 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Key layout (must match API). Every event is appended to a capped
+// per-asset stream so clients can resume, then announced on a pub/sub
+// channel for live subscribers.
+const (
+	keyPrefix    = "media:events:"
+	StreamMaxLen = 200
+	StreamTTL    = 24 * time.Hour
+)
+
+// Event types
+const (
+	TypeState    = "state"
+	TypeProgress = "progress"
+)
+
+// Event is a state change or progress update of an asset
+type Event struct {
+	ID       string    `json:"id,omitempty"`
+	AssetID  string    `json:"assetId"`
+	Type     string    `json:"type"`
+	State    string    `json:"state,omitempty"`
+	Progress *float64  `json:"progress,omitempty"`
+	Message  string    `json:"message,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// Publisher announces asset events to API event streams
+type Publisher struct {
+	redis *redis.Client
+}
+
+func NewPublisher(redisClient *redis.Client) *Publisher {
+	return &Publisher{redis: redisClient}
+}
+
+// State publishes a state change
+func (p *Publisher) State(ctx context.Context, assetID, state, message string) error {
+	return p.publish(ctx, Event{AssetID: assetID, Type: TypeState, State: state, Message: message})
+}
+
+// Progress publishes processing progress as a percentage
+func (p *Publisher) Progress(ctx context.Context, assetID string, percent float64) error {
+	return p.publish(ctx, Event{AssetID: assetID, Type: TypeProgress, State: "processing", Progress: &percent})
+}
+
+func (p *Publisher) publish(ctx context.Context, ev Event) error {
+	ev.At = time.Now().UTC()
+
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	streamKey := keyPrefix + ev.AssetID + ":log"
+	id, err := p.redis.XAdd(ctx, &redis.XAddArgs{
+		Stream: streamKey,
+		MaxLen: StreamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{"event": data},
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("failed to append event: %w", err)
+	}
+	p.redis.Expire(ctx, streamKey, StreamTTL)
+
+	ev.ID = id
+	data, err = json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	if err := p.redis.Publish(ctx, keyPrefix+ev.AssetID, data).Err(); err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Changefeed operations (must match API)
+const (
+	ChangeUpdate = "update"
+	ChangeState  = "state"
+)
+
+// recordChange appends an asset change to the API's changefeed. The advisory
+// lock (shared with the API) keeps changes committing in cursor order.
+func recordChange(ctx context.Context, tx pgx.Tx, op string, assetID uuid.UUID, data *assetEventData) error {
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('asset_changes'))"); err != nil {
+		return fmt.Errorf("failed to lock changefeed: %w", err)
+	}
+
+	// Failure details are not part of the catalog
+	snapshot := *data
+	snapshot.Error = ""
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to marshal asset: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, "INSERT INTO asset_changes (asset_id, op, asset) VALUES ($1, $2, $3)", assetID, op, payload)
+	if err != nil {
+		return fmt.Errorf("failed to record asset change: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// TIFF tags read from EXIF
+const (
+	tagImageDescription   = 0x010e
+	tagMake               = 0x010f
+	tagModel              = 0x0110
+	tagOrientation        = 0x0112
+	tagDateTime           = 0x0132
+	tagArtist             = 0x013b
+	tagCopyright          = 0x8298
+	tagExposureTime       = 0x829a
+	tagFNumber            = 0x829d
+	tagExifIFD            = 0x8769
+	tagGPSIFD             = 0x8825
+	tagISO                = 0x8827
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+	tagFocalLength        = 0x920a
+	tagLensMake           = 0xa433
+	tagLensModel          = 0xa434
+
+	gpsLatitudeRef  = 1
+	gpsLatitude     = 2
+	gpsLongitudeRef = 3
+	gpsLongitude    = 4
+	gpsAltitudeRef  = 5
+	gpsAltitude     = 6
+)
+
+// maxIFDEntries bounds the entries read from one IFD of a corrupt file
+const maxIFDEntries = 1000
+
+var errInvalidTIFF = errors.New("invalid TIFF structure")
+
+// tiffEntry is a decoded IFD entry
+type tiffEntry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// tiffReader reads IFDs of an EXIF (TIFF) block
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// parseEXIF reads the metadata of a TIFF structure, as found in JPEG APP1,
+// PNG eXIf and WebP EXIF chunks
+func parseEXIF(data []byte, meta *ImageMetadata) error {
+	data = bytes.TrimPrefix(data, []byte("Exif\x00\x00"))
+	if len(data) < 8 {
+		return errInvalidTIFF
+	}
+	r := &tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		r.order = binary.LittleEndian
+	case "MM":
+		r.order = binary.BigEndian
+	default:
+		return errInvalidTIFF
+	}
+	if r.order.Uint16(data[2:]) != 42 {
+		return errInvalidTIFF
+	}
+
+	ifd0, err := r.readIFD(r.order.Uint32(data[4:]))
+	if err != nil {
+		return err
+	}
+	var exifIFD, gpsIFD map[uint16]tiffEntry
+	if e, ok := ifd0[tagExifIFD]; ok {
+		if exifIFD, err = r.readIFD(r.uint(e)); err != nil {
+			return fmt.Errorf("exif IFD: %w", err)
+		}
+	}
+	if e, ok := ifd0[tagGPSIFD]; ok {
+		if gpsIFD, err = r.readIFD(r.uint(e)); err != nil {
+			return fmt.Errorf("GPS IFD: %w", err)
+		}
+	}
+
+	camera := Camera{Make: r.string(ifd0[tagMake]), Model: r.string(ifd0[tagModel])}
+	if camera.Make != "" || camera.Model != "" {
+		meta.Camera = &camera
+	}
+	if o := int(r.uint(ifd0[tagOrientation])); o >= 1 && o <= 8 {
+		meta.Orientation = o
+	}
+	meta.Artist = r.string(ifd0[tagArtist])
+	meta.Copyright = r.string(ifd0[tagCopyright])
+	meta.Caption = r.string(ifd0[tagImageDescription])
+
+	captured := r.string(exifIFD[tagDateTimeOriginal])
+	if captured == "" {
+		captured = r.string(ifd0[tagDateTime])
+	}
+	if t, ok := parseEXIFTime(captured, r.string(exifIFD[tagOffsetTimeOriginal])); ok {
+		meta.CapturedAt = &t
+	}
+
+	lens := r.string(exifIFD[tagLensModel])
+	if lensMake := r.string(exifIFD[tagLensMake]); lensMake != "" && lens != "" && !strings.HasPrefix(lens, lensMake) {
+		lens = lensMake + " " + lens
+	}
+	meta.Lens = lens
+
+	exposure := Exposure{
+		FNumber:     roundTo(r.rational(exifIFD[tagFNumber]), 1),
+		FocalLength: roundTo(r.rational(exifIFD[tagFocalLength]), 1),
+		ISO:         int(r.uint(exifIFD[tagISO])),
+	}
+	if e, ok := exifIFD[tagExposureTime]; ok && e.typ == 5 && len(e.value) >= 8 {
+		num, den := r.order.Uint32(e.value), r.order.Uint32(e.value[4:])
+		switch {
+		case num == 0 || den == 0:
+		case num >= den:
+			exposure.ExposureTime = formatRational(num, den)
+		default:
+			// Fractions of a second are written as 1/n
+			exposure.ExposureTime = fmt.Sprintf("1/%d", int(math.Round(float64(den)/float64(num))))
+		}
+	}
+	if exposure != (Exposure{}) {
+		meta.Exposure = &exposure
+	}
+
+	if gpsIFD != nil {
+		lat, latOK := r.coordinate(gpsIFD[gpsLatitude])
+		lon, lonOK := r.coordinate(gpsIFD[gpsLongitude])
+		if latOK && lonOK && lat <= 90 && lon <= 180 {
+			if r.string(gpsIFD[gpsLatitudeRef]) == "S" {
+				lat = -lat
+			}
+			if r.string(gpsIFD[gpsLongitudeRef]) == "W" {
+				lon = -lon
+			}
+			gps := GPS{Latitude: roundTo(lat, 6), Longitude: roundTo(lon, 6)}
+			if e, ok := gpsIFD[gpsAltitude]; ok {
+				alt := roundTo(r.rational(e), 1)
+				if ref := gpsIFD[gpsAltitudeRef]; len(ref.value) > 0 && ref.value[0] == 1 {
+					alt = -alt
+				}
+				gps.Altitude = &alt
+			}
+			meta.GPS = &gps
+		}
+	}
+	return nil
+}
+
+// readIFD reads the entries of the IFD at offset
+func (r *tiffReader) readIFD(offset uint32) (map[uint16]tiffEntry, error) {
+	if uint64(offset)+2 > uint64(len(r.data)) {
+		return nil, errInvalidTIFF
+	}
+	n := int(r.order.Uint16(r.data[offset:]))
+	if n > maxIFDEntries || uint64(offset)+2+uint64(n)*12 > uint64(len(r.data)) {
+		return nil, errInvalidTIFF
+	}
+
+	entries := make(map[uint16]tiffEntry, n)
+	for i := 0; i < n; i++ {
+		raw := r.data[int(offset)+2+i*12:]
+		tag, typ, count := r.order.Uint16(raw), r.order.Uint16(raw[2:]), r.order.Uint32(raw[4:])
+		size := uint64(count) * uint64(tiffTypeSize(typ))
+		if size == 0 {
+			continue
+		}
+		value := raw[8:12]
+		if size > 4 {
+			start := uint64(r.order.Uint32(raw[8:]))
+			if start+size > uint64(len(r.data)) {
+				// Skip the entry rather than the whole block
+				continue
+			}
+			value = r.data[start : start+size]
+		}
+		entries[tag] = tiffEntry{typ: typ, count: count, value: value[:size]}
+	}
+	return entries, nil
+}
+
+// tiffTypeSize is the size of a value of a TIFF type; 0 for unknown types
+func tiffTypeSize(typ uint16) int {
+	switch typ {
+	case 1, 2, 6, 7: // BYTE, ASCII, SBYTE, UNDEFINED
+		return 1
+	case 3, 8: // SHORT, SSHORT
+		return 2
+	case 4, 9, 11: // LONG, SLONG, FLOAT
+		return 4
+	case 5, 10, 12: // RATIONAL, SRATIONAL, DOUBLE
+		return 8
+	}
+	return 0
+}
+
+// string reads an ASCII value, trimmed of padding
+func (r *tiffReader) string(e tiffEntry) string {
+	if e.typ != 2 && e.typ != 7 {
+		return ""
+	}
+	return cleanText(string(bytes.TrimRight(e.value, "\x00 ")))
+}
+
+// uint reads the first SHORT or LONG of a value
+func (r *tiffReader) uint(e tiffEntry) uint32 {
+	switch {
+	case e.typ == 3 && len(e.value) >= 2:
+		return uint32(r.order.Uint16(e.value))
+	case e.typ == 4 && len(e.value) >= 4:
+		return r.order.Uint32(e.value)
+	}
+	return 0
+}
+
+// rational reads the first RATIONAL or SRATIONAL of a value
+func (r *tiffReader) rational(e tiffEntry) float64 {
+	return r.rationalAt(e, 0)
+}
+
+func (r *tiffReader) rationalAt(e tiffEntry, i int) float64 {
+	if (e.typ != 5 && e.typ != 10) || len(e.value) < (i+1)*8 {
+		return 0
+	}
+	num, den := r.order.Uint32(e.value[i*8:]), r.order.Uint32(e.value[i*8+4:])
+	if den == 0 {
+		return 0
+	}
+	if e.typ == 10 {
+		return float64(int32(num)) / float64(int32(den))
+	}
+	return float64(num) / float64(den)
+}
+
+// coordinate reads degrees, minutes and seconds as decimal degrees
+func (r *tiffReader) coordinate(e tiffEntry) (float64, bool) {
+	if e.typ != 5 || e.count < 3 {
+		return 0, false
+	}
+	return r.rationalAt(e, 0) + r.rationalAt(e, 1)/60 + r.rationalAt(e, 2)/3600, true
+}
+
+// parseEXIFTime reads an EXIF date (2006:01:02 15:04:05) with its optional
+// offset (+01:00). Times without an offset are taken as UTC.
+func parseEXIFTime(value, offset string) (time.Time, bool) {
+	if value == "" || strings.HasPrefix(value, "0000") {
+		return time.Time{}, false
+	}
+	if offset != "" {
+		if t, err := time.Parse("2006:01:02 15:04:05-07:00", value+offset); err == nil {
+			return t, true
+		}
+	}
+	t, err := time.Parse("2006:01:02 15:04:05", value)
+	return t, err == nil
+}
+
+func formatRational(num, den uint32) string {
+	if num%den == 0 {
+		return fmt.Sprint(num / den)
+	}
+	return fmt.Sprint(roundTo(float64(num)/float64(den), 1))
+}
+
+func roundTo(v float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(v*scale) / scale
+}
@@ -0,0 +1,280 @@
+package processor
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// testEntry is an IFD entry of tiffBlock
+type testEntry struct {
+	tag, typ uint16
+	count    uint32
+	value    []byte // stored after the IFDs when longer than 4 bytes
+	offset   uint32 // stored instead of value when set
+}
+
+func asciiEntry(tag uint16, s string) testEntry {
+	return testEntry{tag: tag, typ: 2, count: uint32(len(s) + 1), value: []byte(s + "\x00")}
+}
+
+func shortEntry(tag uint16, v uint16) testEntry {
+	return testEntry{tag: tag, typ: 3, count: 1, value: binary.LittleEndian.AppendUint16(nil, v)}
+}
+
+// rationalEntry takes numerator and denominator pairs
+func rationalEntry(tag uint16, values ...uint32) testEntry {
+	e := testEntry{tag: tag, typ: 5, count: uint32(len(values) / 2)}
+	for _, v := range values {
+		e.value = binary.LittleEndian.AppendUint32(e.value, v)
+	}
+	return e
+}
+
+// tiffBlock writes a little-endian TIFF structure with IFD0 and, when sub
+// has entries, the Exif or GPS IFD that subTag of IFD0 points at
+func tiffBlock(ifd0 []testEntry, subTag uint16, sub []testEntry) []byte {
+	ifdSize := func(entries []testEntry) int { return 2 + len(entries)*12 + 4 }
+	subAt := 0
+	if sub != nil {
+		ifd0 = append(ifd0, testEntry{tag: subTag, typ: 4, count: 1})
+		subAt = 8 + ifdSize(ifd0)
+	}
+	dataAt := 8 + ifdSize(ifd0)
+	if sub != nil {
+		dataAt += ifdSize(sub)
+	}
+
+	out := []byte("II*\x00\x08\x00\x00\x00")
+	var data []byte
+	writeIFD := func(entries []testEntry) {
+		out = binary.LittleEndian.AppendUint16(out, uint16(len(entries)))
+		for _, e := range entries {
+			out = binary.LittleEndian.AppendUint16(out, e.tag)
+			out = binary.LittleEndian.AppendUint16(out, e.typ)
+			out = binary.LittleEndian.AppendUint32(out, e.count)
+			switch {
+			case e.offset != 0:
+				out = binary.LittleEndian.AppendUint32(out, e.offset)
+			case sub != nil && e.tag == subTag && e.value == nil:
+				out = binary.LittleEndian.AppendUint32(out, uint32(subAt))
+			case len(e.value) > 4:
+				out = binary.LittleEndian.AppendUint32(out, uint32(dataAt+len(data)))
+				data = append(data, e.value...)
+			default:
+				out = append(out, make([]byte, 4)...)
+				copy(out[len(out)-4:], e.value)
+			}
+		}
+		out = append(out, 0, 0, 0, 0)
+	}
+	writeIFD(ifd0)
+	if sub != nil {
+		writeIFD(sub)
+	}
+	return append(out, data...)
+}
+
+func TestParseEXIF(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    *ImageMetadata
+		wantErr bool
+	}{
+		{name: "empty", data: nil, wantErr: true},
+		{name: "header only", data: []byte("II*\x00"), wantErr: true},
+		{name: "unknown byte order", data: []byte("XX*\x00\x08\x00\x00\x00\x00\x00"), wantErr: true},
+		{name: "not TIFF", data: []byte("II+\x00\x08\x00\x00\x00\x00\x00"), wantErr: true},
+		{name: "IFD past the end", data: []byte("II*\x00\xff\x00\x00\x00\x00\x00"), wantErr: true},
+		{name: "IFD offset overflowing", data: []byte("II*\x00\xff\xff\xff\xff\x00\x00"), wantErr: true},
+		{name: "entries past the end", data: []byte("II*\x00\x08\x00\x00\x00\x02\x00\x0f\x01"), wantErr: true},
+		{name: "too many entries", data: append([]byte("II*\x00\x08\x00\x00\x00\xe9\x03"), make([]byte, 1001*12+4)...), wantErr: true},
+		{name: "empty IFD", data: []byte("II*\x00\x08\x00\x00\x00\x00\x00\x00\x00\x00\x00"), want: &ImageMetadata{}},
+		{
+			name: "JPEG APP1 prefix",
+			data: append([]byte("Exif\x00\x00"), tiffBlock([]testEntry{asciiEntry(tagModel, "X100V")}, 0, nil)...),
+			want: &ImageMetadata{Camera: &Camera{Model: "X100V"}},
+		},
+		{
+			name: "text padded and with control characters",
+			data: tiffBlock([]testEntry{asciiEntry(tagArtist, "  Ann\x07 Lee  \x00\x00"), asciiEntry(tagCopyright, "\x00")}, 0, nil),
+			want: &ImageMetadata{Artist: "Ann Lee"},
+		},
+		{
+			name: "value past the end is skipped",
+			data: tiffBlock([]testEntry{
+				{tag: tagMake, typ: 2, count: 64, offset: 0xfff0},
+				asciiEntry(tagModel, "EOS R6"),
+			}, 0, nil),
+			want: &ImageMetadata{Camera: &Camera{Model: "EOS R6"}},
+		},
+		{
+			name: "value offset overflowing is skipped",
+			data: tiffBlock([]testEntry{{tag: tagMake, typ: 2, count: 0xffffffff, offset: 0xffffffff}}, 0, nil),
+			want: &ImageMetadata{},
+		},
+		{
+			name: "unknown type is skipped",
+			data: tiffBlock([]testEntry{{tag: tagMake, typ: 99, count: 4, value: []byte("Sony")}}, 0, nil),
+			want: &ImageMetadata{},
+		},
+		{
+			name: "wrong type is ignored",
+			data: tiffBlock([]testEntry{{tag: tagMake, typ: 3, count: 1, value: []byte{1, 0}}, rationalEntry(tagOrientation, 3, 1)}, 0, nil),
+			want: &ImageMetadata{},
+		},
+		{
+			name: "orientation out of range",
+			data: tiffBlock([]testEntry{shortEntry(tagOrientation, 9)}, 0, nil),
+			want: &ImageMetadata{},
+		},
+		{
+			name:    "Exif IFD past the end",
+			data:    tiffBlock([]testEntry{{tag: tagExifIFD, typ: 4, count: 1, offset: 0xffff}}, 0, nil),
+			wantErr: true,
+		},
+		{
+			name: "exposure",
+			data: tiffBlock(nil, tagExifIFD, []testEntry{
+				rationalEntry(tagExposureTime, 10, 4000),
+				rationalEntry(tagFNumber, 56, 10),
+				shortEntry(tagISO, 3200),
+				rationalEntry(tagFocalLength, 355, 10),
+			}),
+			want: &ImageMetadata{Exposure: &Exposure{FNumber: 5.6, ExposureTime: "1/400", ISO: 3200, FocalLength: 35.5}},
+		},
+		{
+			name: "long exposure",
+			data: tiffBlock(nil, tagExifIFD, []testEntry{rationalEntry(tagExposureTime, 3, 2)}),
+			want: &ImageMetadata{Exposure: &Exposure{ExposureTime: "1.5"}},
+		},
+		{
+			name: "zero denominators",
+			data: tiffBlock(nil, tagExifIFD, []testEntry{rationalEntry(tagExposureTime, 1, 0), rationalEntry(tagFNumber, 28, 0)}),
+			want: &ImageMetadata{},
+		},
+		{
+			name: "capture time without offset is UTC",
+			data: tiffBlock(nil, tagExifIFD, []testEntry{asciiEntry(tagDateTimeOriginal, "2022:12:31 23:59:59")}),
+			want: &ImageMetadata{CapturedAt: timePtr("2022-12-31T23:59:59Z")},
+		},
+		{
+			name: "capture time with offset",
+			data: tiffBlock(nil, tagExifIFD, []testEntry{asciiEntry(tagDateTimeOriginal, "2022:12:31 23:59:59"), asciiEntry(tagOffsetTimeOriginal, "-05:00")}),
+			want: &ImageMetadata{CapturedAt: timePtr("2022-12-31T23:59:59-05:00")},
+		},
+		{
+			name: "invalid offset is dropped",
+			data: tiffBlock(nil, tagExifIFD, []testEntry{asciiEntry(tagDateTimeOriginal, "2022:12:31 23:59:59"), asciiEntry(tagOffsetTimeOriginal, "  :  ")}),
+			want: &ImageMetadata{CapturedAt: timePtr("2022-12-31T23:59:59Z")},
+		},
+		{
+			name: "modification time as fallback",
+			data: tiffBlock([]testEntry{asciiEntry(tagDateTime, "2020:02:02 10:00:00")}, 0, nil),
+			want: &ImageMetadata{CapturedAt: timePtr("2020-02-02T10:00:00Z")},
+		},
+		{
+			name: "unset and invalid times",
+			data: tiffBlock([]testEntry{asciiEntry(tagDateTime, "2020-02-02")}, tagExifIFD, []testEntry{asciiEntry(tagDateTimeOriginal, "0000:00:00 00:00:00")}),
+			want: &ImageMetadata{},
+		},
+		{
+			name: "lens model including the make",
+			data: tiffBlock(nil, tagExifIFD, []testEntry{asciiEntry(tagLensMake, "SIGMA"), asciiEntry(tagLensModel, "SIGMA 35mm F1.4 DG")}),
+			want: &ImageMetadata{Lens: "SIGMA 35mm F1.4 DG"},
+		},
+		{
+			name: "lens make alone",
+			data: tiffBlock(nil, tagExifIFD, []testEntry{asciiEntry(tagLensMake, "SIGMA")}),
+			want: &ImageMetadata{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var meta ImageMetadata
+			err := parseEXIF(tt.data, &meta)
+			if tt.wantErr {
+				if !errors.Is(err, errInvalidTIFF) {
+					t.Fatalf("err = %v, want %v", err, errInvalidTIFF)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseEXIF: %v", err)
+			}
+			if got, want := metaJSON(t, &meta), metaJSON(t, tt.want); got != want {
+				t.Errorf("metadata = %s\nwant %s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseEXIFGPS(t *testing.T) {
+	gpsBlock := func(entries ...testEntry) []byte {
+		return tiffBlock(nil, tagGPSIFD, entries)
+	}
+	north := asciiEntry(gpsLatitudeRef, "N")
+	south := asciiEntry(gpsLatitudeRef, "S")
+	west := asciiEntry(gpsLongitudeRef, "W")
+	latitude := rationalEntry(gpsLatitude, 51, 1, 30, 1, 2646, 100)
+	longitude := rationalEntry(gpsLongitude, 0, 1, 7, 1, 3966, 100)
+	below := testEntry{tag: gpsAltitudeRef, typ: 1, count: 1, value: []byte{1}}
+
+	tests := []struct {
+		name string
+		data []byte
+		want *GPS
+	}{
+		{"north east", gpsBlock(north, latitude, longitude), &GPS{Latitude: 51.507350, Longitude: 0.127683}},
+		{"south west", gpsBlock(south, latitude, west, longitude), &GPS{Latitude: -51.507350, Longitude: -0.127683}},
+		{"altitude", gpsBlock(latitude, longitude, rationalEntry(gpsAltitude, 113, 10)), &GPS{Latitude: 51.507350, Longitude: 0.127683, Altitude: floatPtr(11.3)}},
+		{"below sea level", gpsBlock(latitude, longitude, below, rationalEntry(gpsAltitude, 28, 1)), &GPS{Latitude: 51.507350, Longitude: 0.127683, Altitude: floatPtr(-28)}},
+		{"latitude only", gpsBlock(latitude), nil},
+		{"too few components", gpsBlock(rationalEntry(gpsLatitude, 51, 1, 30, 1), longitude), nil},
+		{"latitude out of range", gpsBlock(rationalEntry(gpsLatitude, 91, 1, 0, 1, 0, 1), longitude), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var meta ImageMetadata
+			if err := parseEXIF(tt.data, &meta); err != nil {
+				t.Fatalf("parseEXIF: %v", err)
+			}
+			if got, want := metaJSON(t, &ImageMetadata{GPS: meta.GPS}), metaJSON(t, &ImageMetadata{GPS: tt.want}); got != want {
+				t.Errorf("GPS = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// Every prefix of a real EXIF block fails cleanly or parses
+func TestParseEXIFTruncated(t *testing.T) {
+	for _, blocks := range fixtureBlocks(t) {
+		for n := 0; n < len(blocks.exif); n++ {
+			var meta ImageMetadata
+			parseEXIF(blocks.exif[:n], &meta)
+		}
+	}
+}
+
+func FuzzParseEXIF(f *testing.F) {
+	for _, blocks := range fixtureBlocks(f) {
+		f.Add(blocks.exif)
+	}
+	f.Add(tiffBlock([]testEntry{asciiEntry(tagMake, "Canon"), shortEntry(tagOrientation, 6)}, tagExifIFD, []testEntry{rationalEntry(tagExposureTime, 1, 60)}))
+	f.Add([]byte("MM\x00*\x00\x00\x00\x08\x00\x00"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var meta ImageMetadata
+		if err := parseEXIF(data, &meta); err != nil {
+			return
+		}
+		if meta.Orientation < 0 || meta.Orientation > 8 {
+			t.Errorf("orientation %d", meta.Orientation)
+		}
+		if meta.GPS != nil && (meta.GPS.Latitude < -90 || meta.GPS.Latitude > 90 || meta.GPS.Longitude < -180 || meta.GPS.Longitude > 180) {
+			t.Errorf("GPS %+v out of range", *meta.GPS)
+		}
+	})
+}
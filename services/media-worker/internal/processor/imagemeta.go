@@ -0,0 +1,421 @@
+package processor
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxMetadataBlock bounds a metadata block read from a corrupt file
+	maxMetadataBlock = 16 << 20
+	// maxTagLength is the length of asset_tags.tag
+	maxTagLength = 100
+)
+
+// ImageMetadata is what is read from the EXIF, IPTC and XMP metadata of an
+// image, stored in asset_meta.exif
+type ImageMetadata struct {
+	Camera      *Camera    `json:"camera,omitempty"`
+	Lens        string     `json:"lens,omitempty"`
+	Exposure    *Exposure  `json:"exposure,omitempty"`
+	CapturedAt  *time.Time `json:"capturedAt,omitempty"`
+	Orientation int        `json:"orientation,omitempty"`
+	GPS         *GPS       `json:"gps,omitempty"`
+	Artist      string     `json:"artist,omitempty"`
+	Copyright   string     `json:"copyright,omitempty"`
+	Title       string     `json:"title,omitempty"`
+	Headline    string     `json:"headline,omitempty"`
+	Caption     string     `json:"caption,omitempty"`
+	Keywords    []string   `json:"keywords,omitempty"`
+}
+
+// Camera is the make and model of the capturing device
+type Camera struct {
+	Make  string `json:"make,omitempty"`
+	Model string `json:"model,omitempty"`
+}
+
+// Exposure is the capture settings of a photo
+type Exposure struct {
+	FNumber      float64 `json:"fNumber,omitempty"`
+	ExposureTime string  `json:"exposureTime,omitempty"` // seconds, e.g. 1/250
+	ISO          int     `json:"iso,omitempty"`
+	FocalLength  float64 `json:"focalLength,omitempty"` // mm
+}
+
+// GPS is where a photo was taken, in decimal degrees and meters
+type GPS struct {
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Altitude  *float64 `json:"altitude,omitempty"`
+}
+
+// metadataBlocks are the raw metadata blocks of an image file
+type metadataBlocks struct {
+	exif []byte // TIFF structure
+	iptc []byte // Photoshop image resources
+	xmp  []byte
+}
+
+// readImageMetadata reads the metadata of a JPEG, PNG, WebP or TIFF file.
+// It returns nil when the file has none; other formats have none as far as
+// the worker is concerned. Only the metadata blocks are read, not the pixels.
+func readImageMetadata(path string) (*ImageMetadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return nil, nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var blocks metadataBlocks
+	switch {
+	case bytes.HasPrefix(header, []byte{0xff, 0xd8}):
+		err = readJPEGBlocks(f, &blocks)
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		err = readPNGBlocks(f, &blocks)
+	case string(header[:4]) == "RIFF" && string(header[8:12]) == "WEBP":
+		err = readWebPBlocks(f, &blocks)
+	case string(header[:4]) == "II*\x00" || string(header[:4]) == "MM\x00*":
+		blocks.exif, err = io.ReadAll(io.LimitReader(f, maxMetadataBlock))
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return blocks.parse()
+}
+
+// parse reads each block and merges them: XMP, then IPTC, then EXIF for
+// descriptive text, EXIF first for everything the camera recorded
+func (b *metadataBlocks) parse() (*ImageMetadata, error) {
+	var fromEXIF, fromIPTC, fromXMP ImageMetadata
+	if b.exif != nil {
+		if err := parseEXIF(b.exif, &fromEXIF); err != nil {
+			return nil, fmt.Errorf("failed to read EXIF: %w", err)
+		}
+	}
+	if b.iptc != nil {
+		parsePhotoshopResources(b.iptc, &fromIPTC)
+	}
+	if b.xmp != nil {
+		if err := parseXMP(b.xmp, &fromXMP); err != nil {
+			return nil, fmt.Errorf("failed to read XMP: %w", err)
+		}
+	}
+
+	meta := fromEXIF
+	if meta.CapturedAt == nil {
+		meta.CapturedAt = fromXMP.CapturedAt
+	}
+	meta.Caption = firstNonEmpty(fromXMP.Caption, fromIPTC.Caption, fromEXIF.Caption)
+	meta.Title = firstNonEmpty(fromXMP.Title, fromIPTC.Title)
+	meta.Headline = firstNonEmpty(fromXMP.Headline, fromIPTC.Headline)
+	meta.Copyright = firstNonEmpty(fromXMP.Copyright, fromIPTC.Copyright, fromEXIF.Copyright)
+	meta.Artist = firstNonEmpty(fromXMP.Artist, fromIPTC.Artist, fromEXIF.Artist)
+
+	seen := map[string]bool{}
+	meta.Keywords = nil
+	for _, keyword := range append(fromXMP.Keywords, fromIPTC.Keywords...) {
+		if key := strings.ToLower(keyword); !seen[key] {
+			seen[key] = true
+			meta.Keywords = append(meta.Keywords, keyword)
+		}
+	}
+
+	if meta.isEmpty() {
+		return nil, nil
+	}
+	return &meta, nil
+}
+
+func (m *ImageMetadata) isEmpty() bool {
+	return m.Camera == nil && m.Lens == "" && m.Exposure == nil && m.CapturedAt == nil && m.Orientation == 0 &&
+		m.GPS == nil && m.Artist == "" && m.Copyright == "" && m.Title == "" && m.Headline == "" &&
+		m.Caption == "" && len(m.Keywords) == 0
+}
+
+// readJPEGBlocks reads the APP1 (EXIF, XMP) and APP13 (IPTC) segments that
+// precede the image data
+func readJPEGBlocks(r io.Reader, blocks *metadataBlocks) error {
+	br := newByteReader(r)
+	if _, err := br.skip(2); err != nil {
+		return err
+	}
+	for {
+		marker, err := br.next(2)
+		if err != nil || marker[0] != 0xff {
+			return nil
+		}
+		switch marker[1] {
+		case 0xd8, 0x01:
+			continue
+		case 0xda, 0xd9: // start of scan, end of image
+			return nil
+		}
+		if marker[1] >= 0xd0 && marker[1] <= 0xd7 {
+			continue
+		}
+
+		length, err := br.next(2)
+		if err != nil {
+			return nil
+		}
+		size := int(binary.BigEndian.Uint16(length)) - 2
+		if size < 0 {
+			return nil
+		}
+		if marker[1] != 0xe1 && marker[1] != 0xed {
+			if _, err := br.skip(size); err != nil {
+				return nil
+			}
+			continue
+		}
+
+		segment, err := br.next(size)
+		if err != nil {
+			return nil
+		}
+		switch {
+		case marker[1] == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")):
+			if blocks.exif == nil {
+				blocks.exif = segment
+			}
+		case marker[1] == 0xe1 && bytes.HasPrefix(segment, []byte("http://ns.adobe.com/xap/1.0/\x00")):
+			if blocks.xmp == nil {
+				blocks.xmp = segment[len("http://ns.adobe.com/xap/1.0/\x00"):]
+			}
+		case marker[1] == 0xed && bytes.HasPrefix(segment, []byte("Photoshop 3.0\x00")):
+			// Large IPTC blocks continue in further APP13 segments
+			blocks.iptc = append(blocks.iptc, segment[len("Photoshop 3.0\x00"):]...)
+		}
+	}
+}
+
+// readPNGBlocks reads the eXIf chunk and the XMP iTXt chunk
+func readPNGBlocks(r io.Reader, blocks *metadataBlocks) error {
+	br := newByteReader(r)
+	if _, err := br.skip(8); err != nil {
+		return err
+	}
+	for {
+		header, err := br.next(8)
+		if err != nil {
+			return nil
+		}
+		size := int(binary.BigEndian.Uint32(header))
+		typ := string(header[4:])
+		if typ == "IEND" || size < 0 || size > maxMetadataBlock && (typ == "eXIf" || typ == "iTXt") {
+			return nil
+		}
+		if typ != "eXIf" && typ != "iTXt" {
+			if _, err := br.skip(size + 4); err != nil {
+				return nil
+			}
+			continue
+		}
+
+		chunk, err := br.next(size + 4)
+		if err != nil {
+			return nil
+		}
+		chunk = chunk[:size]
+		if typ == "eXIf" {
+			blocks.exif = chunk
+			continue
+		}
+
+		// iTXt: keyword, NUL, compression flag and method, language tag, NUL,
+		// translated keyword, NUL, text
+		keyword, rest, ok := bytes.Cut(chunk, []byte{0})
+		if !ok || string(keyword) != "XML:com.adobe.xmp" || len(rest) < 2 {
+			continue
+		}
+		compressed := rest[0] == 1
+		parts := bytes.SplitN(rest[2:], []byte{0}, 3)
+		if len(parts) != 3 {
+			continue
+		}
+		text := parts[2]
+		if compressed {
+			zr, err := zlib.NewReader(bytes.NewReader(text))
+			if err != nil {
+				continue
+			}
+			text, err = io.ReadAll(io.LimitReader(zr, maxMetadataBlock))
+			if err != nil {
+				continue
+			}
+		}
+		blocks.xmp = text
+	}
+}
+
+// readWebPBlocks reads the EXIF and XMP chunks of an extended WebP file
+func readWebPBlocks(r io.Reader, blocks *metadataBlocks) error {
+	br := newByteReader(r)
+	if _, err := br.skip(12); err != nil {
+		return err
+	}
+	for {
+		header, err := br.next(8)
+		if err != nil {
+			return nil
+		}
+		size := int(binary.LittleEndian.Uint32(header[4:]))
+		padded := size + size%2
+		typ := string(header[:4])
+		if size < 0 || typ != "EXIF" && typ != "XMP " {
+			if _, err := br.skip(padded); err != nil {
+				return nil
+			}
+			continue
+		}
+		if size > maxMetadataBlock {
+			return nil
+		}
+		chunk, err := br.next(padded)
+		if err != nil {
+			return nil
+		}
+		if typ == "EXIF" {
+			blocks.exif = chunk[:size]
+		} else {
+			blocks.xmp = chunk[:size]
+		}
+	}
+}
+
+// byteReader reads and skips ahead in a stream
+type byteReader struct {
+	r io.Reader
+}
+
+func newByteReader(r io.Reader) *byteReader {
+	return &byteReader{r: r}
+}
+
+// next reads n bytes. Large blocks are read into a growing buffer, so that
+// the size field of a truncated file cannot allocate more than it holds.
+func (b *byteReader) next(n int) ([]byte, error) {
+	if n < 0 || n > maxMetadataBlock {
+		return nil, fmt.Errorf("invalid block size %d", n)
+	}
+	if n <= 64<<10 {
+		buf := make([]byte, n)
+		_, err := io.ReadFull(b.r, buf)
+		return buf, err
+	}
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(io.LimitReader(b.r, int64(n))); err != nil {
+		return nil, err
+	}
+	if buf.Len() < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), nil
+}
+
+// skip discards n bytes, seeking when possible
+func (b *byteReader) skip(n int) (int64, error) {
+	if s, ok := b.r.(io.Seeker); ok {
+		return s.Seek(int64(n), io.SeekCurrent)
+	}
+	return io.CopyN(io.Discard, b.r, int64(n))
+}
+
+// saveImageMetadata stores the metadata in asset_meta and adds the keywords
+// to the asset's tags. Reprocessing replaces the metadata but keeps tags.
+func (p *Processor) saveImageMetadata(ctx context.Context, assetID uuid.UUID, meta *ImageMetadata) error {
+	var data []byte
+	var capturedAt *time.Time
+	if meta != nil {
+		var err error
+		if data, err = json.Marshal(meta); err != nil {
+			return fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+		capturedAt = meta.CapturedAt
+	}
+
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO asset_meta (asset_id, exif, captured_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (asset_id) DO UPDATE SET
+			exif = EXCLUDED.exif,
+			captured_at = EXCLUDED.captured_at
+	`, assetID, data, capturedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save metadata: %w", err)
+	}
+
+	if meta != nil {
+		for _, keyword := range meta.Keywords {
+			tag := []rune(keyword)
+			if len(tag) > maxTagLength {
+				tag = tag[:maxTagLength]
+			}
+			_, err := tx.Exec(ctx, `
+				INSERT INTO asset_tags (asset_id, tag) VALUES ($1, $2)
+				ON CONFLICT (asset_id, tag) DO NOTHING
+			`, assetID, string(tag))
+			if err != nil {
+				return fmt.Errorf("failed to save tags: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit metadata: %w", err)
+	}
+	return nil
+}
+
+// cleanText trims a metadata string and drops control characters
+func cleanText(s string) string {
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' {
+			return -1
+		}
+		return r
+	}, s))
+}
+
+func setIfEmpty(dst *string, value string) {
+	if *dst == "" {
+		*dst = value
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
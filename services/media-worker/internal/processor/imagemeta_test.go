@@ -0,0 +1,241 @@
+package processor
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The fixtures under testdata are written by testdata/fixtures.go
+
+func TestReadImageMetadata(t *testing.T) {
+	tests := []struct {
+		file string
+		want *ImageMetadata
+	}{
+		{"photo.jpg", &ImageMetadata{
+			Camera:      &Camera{Make: "Canon", Model: "Canon EOS R5"},
+			Lens:        "Canon RF 50mm F1.8 STM",
+			Exposure:    &Exposure{FNumber: 2.8, ExposureTime: "1/250", ISO: 400, FocalLength: 50},
+			CapturedAt:  timePtr("2024-05-17T14:30:05+02:00"),
+			Orientation: 6,
+			GPS:         &GPS{Latitude: 48.858222, Longitude: 2.2945, Altitude: floatPtr(35)},
+			// Text from XMP, then IPTC, then EXIF
+			Artist:    "Jane Doe",
+			Copyright: "IPTC copyright",
+			Title:     "XMP title",
+			Headline:  "IPTC headline",
+			Caption:   "XMP caption",
+			// XMP and IPTC keywords without case-insensitive duplicates
+			Keywords: []string{"Paris", "Eiffel Tower", "travel"},
+		}},
+		{"exif.png", &ImageMetadata{
+			Camera:   &Camera{Make: "FUJIFILM", Model: "X-T4"},
+			Lens:     "FUJIFILM XF23mmF1.4 R",
+			Exposure: &Exposure{FNumber: 4, ExposureTime: "2", ISO: 160, FocalLength: 23},
+			// No date in EXIF: the XMP one is taken
+			CapturedAt:  timePtr("2023-01-02T03:04:05+01:00"),
+			Orientation: 1,
+			GPS:         &GPS{Latitude: -22.908333, Longitude: -43.196389, Altitude: floatPtr(-5)},
+			Headline:    "PNG headline",
+		}},
+		{"extended.webp", &ImageMetadata{
+			Camera:     &Camera{Make: "Google", Model: "Pixel 8"},
+			CapturedAt: timePtr("2024-02-29T23:59:59Z"),
+			Keywords:   []string{"Dog", "Beach"},
+		}},
+		{"pages.tiff", &ImageMetadata{
+			Camera:      &Camera{Make: "NIKON CORPORATION", Model: "NIKON D850"},
+			Orientation: 8,
+			Artist:      "TIFF artist",
+			Caption:     "TIFF description",
+		}},
+		{"gray.jpg", nil},
+		{"palette.png", nil},
+		{"lossless.webp", nil},
+		{"animated.gif", nil},
+		{"photo.avif", nil},
+		{"rgb.bmp", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			meta, err := readImageMetadata(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatalf("readImageMetadata: %v", err)
+			}
+			if got, want := metaJSON(t, meta), metaJSON(t, tt.want); got != want {
+				t.Errorf("metadata = %s\nwant %s", got, want)
+			}
+		})
+	}
+}
+
+func TestReadImageMetadataMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"empty", nil, ""},
+		{"shorter than a header", []byte{0xff, 0xd8, 0xff}, ""},
+		{"unknown format", []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n"), ""},
+		{"JPEG without segments", []byte("\xff\xd8\xff\xd9\x00\x00\x00\x00\x00\x00\x00\x00"), ""},
+		{"JPEG segment length below 2", []byte("\xff\xd8\xff\xe1\x00\x01Exif\x00\x00II*\x00"), ""},
+		{"JPEG segment past the end", []byte("\xff\xd8\xff\xe1\xff\xffExif\x00\x00II*\x00\x08\x00"), ""},
+		{"JPEG with invalid EXIF", []byte("\xff\xd8\xff\xe1\x00\x10Exif\x00\x00XX*\x00\x08\x00\x00\x00"), "failed to read EXIF"},
+		{"PNG chunk past the end", pngWithChunk("eXIf", 1000, []byte("MM\x00*")), ""},
+		{"PNG chunk over the limit", pngWithChunk("eXIf", maxMetadataBlock+1, nil), ""},
+		{"PNG iTXt without XMP", pngWithChunk("iTXt", 8, []byte("Comment\x00")), ""},
+		{"PNG iTXt with invalid zlib", pngWithChunk("iTXt", 25, []byte("XML:com.adobe.xmp\x00\x01\x00\x00\x00\xde\xad\xbe\xef")), ""},
+		{"WebP chunk past the end", []byte("RIFF\x00\x00\x00\x00WEBPEXIF\xff\x00\x00\x00MM"), ""},
+		{"WebP chunk over the limit", []byte("RIFF\x00\x00\x00\x00WEBPEXIF\xff\xff\xff\xff"), ""},
+		{"TIFF header only", []byte("II*\x00\x08\x00\x00\x00\x00\x00\x00\x00"), ""},
+		{"TIFF with IFD past the end", []byte("MM\x00*\x7f\xff\xff\xff\x00\x00\x00\x00"), "failed to read EXIF"},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "image")
+			if err := os.WriteFile(path, tt.data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			meta, err := readImageMetadata(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || meta != nil {
+				t.Errorf("readImageMetadata = %+v, %v; want nil, nil", meta, err)
+			}
+		})
+	}
+}
+
+// Every prefix of a fixture is read without panicking, as from a cut off
+// upload
+func TestReadImageMetadataTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "image")
+	for _, file := range []string{"photo.jpg", "exif.png", "extended.webp", "pages.tiff"} {
+		data, err := os.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for n := 0; n < len(data); n++ {
+			if err := os.WriteFile(path, data[:n], 0o600); err != nil {
+				t.Fatal(err)
+			}
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Fatalf("%s cut at %d bytes: panic: %v", file, n, r)
+					}
+				}()
+				readImageMetadata(path)
+			}()
+		}
+	}
+}
+
+// A block size field larger than the file must not allocate the size
+func TestReadImageMetadataBoundsAllocation(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"PNG", pngWithChunk("eXIf", maxMetadataBlock-4, []byte("MM\x00*"))},
+		{"WebP", []byte("RIFF\x00\x00\x00\x00WEBPEXIF\x00\x00\x00\x01MM\x00*")},
+	}
+	path := filepath.Join(t.TempDir(), "image")
+	for _, tt := range tests {
+		if err := os.WriteFile(path, tt.data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		if _, err := readImageMetadata(path); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		runtime.ReadMemStats(&after)
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+			t.Errorf("%s: allocated %d bytes for a %d byte file", tt.name, allocated, len(tt.data))
+		}
+	}
+}
+
+func FuzzParseMetadataBlocks(f *testing.F) {
+	for _, blocks := range fixtureBlocks(f) {
+		f.Add(blocks.exif, blocks.iptc, blocks.xmp)
+	}
+	f.Fuzz(func(t *testing.T, exif, iptc, xmp []byte) {
+		blocks := metadataBlocks{exif: exif, iptc: iptc, xmp: xmp}
+		blocks.parse()
+	})
+}
+
+// fixtureBlocks returns the metadata blocks of the JPEG, PNG and WebP
+// fixtures, which all have EXIF
+func fixtureBlocks(tb testing.TB) []metadataBlocks {
+	tb.Helper()
+	var all []metadataBlocks
+	for _, file := range []string{"photo.jpg", "exif.png", "extended.webp"} {
+		f, err := os.Open(filepath.Join("testdata", file))
+		if err != nil {
+			tb.Fatal(err)
+		}
+		var blocks metadataBlocks
+		switch filepath.Ext(file) {
+		case ".jpg":
+			err = readJPEGBlocks(f, &blocks)
+		case ".png":
+			err = readPNGBlocks(f, &blocks)
+		case ".webp":
+			err = readWebPBlocks(f, &blocks)
+		}
+		f.Close()
+		if err != nil || blocks.exif == nil {
+			tb.Fatalf("%s: no EXIF block (%v)", file, err)
+		}
+		all = append(all, blocks)
+	}
+	return all
+}
+
+// pngWithChunk is a PNG signature and IHDR followed by a chunk whose length
+// field says size, holding data
+func pngWithChunk(typ string, size int, data []byte) []byte {
+	out := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x02\x00\x00\x00\x90\x77\x53\xde")
+	out = binary.BigEndian.AppendUint32(out, uint32(size))
+	out = append(out, typ...)
+	return append(out, data...)
+}
+
+// metaJSON renders metadata for comparison and readable failures
+func metaJSON(t *testing.T, meta *ImageMetadata) string {
+	t.Helper()
+	data, err := json.Marshal(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func timePtr(value string) *time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return &t
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
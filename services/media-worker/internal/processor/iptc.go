@@ -0,0 +1,93 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"unicode/utf8"
+)
+
+// IPTC IIM datasets of the application record (2) that are read
+const (
+	iptcObjectName = 5
+	iptcKeywords   = 25
+	iptcByline     = 80
+	iptcHeadline   = 105
+	iptcCopyright  = 116
+	iptcCaption    = 120
+)
+
+// photoshopIPTC is the Photoshop image resource holding IPTC IIM data
+const photoshopIPTC = 0x0404
+
+// parsePhotoshopResources reads the IPTC block among the image resources of a
+// JPEG APP13 segment (after its "Photoshop 3.0\0" signature)
+func parsePhotoshopResources(data []byte, meta *ImageMetadata) {
+	for len(data) >= 12 && string(data[:4]) == "8BIM" {
+		id := binary.BigEndian.Uint16(data[4:])
+		// The resource name is a Pascal string padded to an even length
+		nameLen := int(data[6]) + 1
+		nameLen += nameLen % 2
+		if 6+nameLen+4 > len(data) {
+			return
+		}
+		size := int(binary.BigEndian.Uint32(data[6+nameLen:]))
+		start := 6 + nameLen + 4
+		if size < 0 || start+size > len(data) {
+			return
+		}
+		if id == photoshopIPTC {
+			parseIPTC(data[start:start+size], meta)
+		}
+		// The padding byte may be missing after the last resource
+		data = data[min(start+size+size%2, len(data)):]
+	}
+}
+
+// parseIPTC reads IPTC IIM datasets. Text is taken as UTF-8, falling back to
+// Latin-1 for older files.
+func parseIPTC(data []byte, meta *ImageMetadata) {
+	for len(data) >= 5 && data[0] == 0x1c {
+		record, dataset := data[1], data[2]
+		size := int(binary.BigEndian.Uint16(data[3:]))
+		if size&0x8000 != 0 {
+			// Extended datasets are not used for text
+			return
+		}
+		if 5+size > len(data) {
+			return
+		}
+		value := iptcText(data[5 : 5+size])
+		data = data[5+size:]
+
+		if record != 2 || value == "" {
+			continue
+		}
+		switch dataset {
+		case iptcKeywords:
+			meta.Keywords = append(meta.Keywords, value)
+		case iptcCaption:
+			setIfEmpty(&meta.Caption, value)
+		case iptcHeadline:
+			setIfEmpty(&meta.Headline, value)
+		case iptcObjectName:
+			setIfEmpty(&meta.Title, value)
+		case iptcCopyright:
+			setIfEmpty(&meta.Copyright, value)
+		case iptcByline:
+			setIfEmpty(&meta.Artist, value)
+		}
+	}
+}
+
+func iptcText(b []byte) string {
+	b = bytes.TrimRight(b, "\x00")
+	if utf8.Valid(b) {
+		return cleanText(string(b))
+	}
+	var sb strings.Builder
+	for _, c := range b {
+		sb.WriteRune(rune(c))
+	}
+	return cleanText(sb.String())
+}
@@ -0,0 +1,142 @@
+package processor
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func iptcDataset(record, number byte, value string) []byte {
+	out := []byte{0x1c, record, number}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(value)))
+	return append(out, value...)
+}
+
+func join(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func TestParseIPTC(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want *ImageMetadata
+	}{
+		{"empty", nil, &ImageMetadata{}},
+		{
+			"datasets",
+			join(
+				iptcDataset(2, iptcObjectName, "Title"),
+				iptcDataset(2, iptcKeywords, "one"),
+				iptcDataset(2, iptcByline, "Photographer"),
+				iptcDataset(2, iptcHeadline, "Headline"),
+				iptcDataset(2, iptcKeywords, "two"),
+				iptcDataset(2, iptcCopyright, "Agency"),
+				iptcDataset(2, iptcCaption, "Caption"),
+			),
+			&ImageMetadata{Title: "Title", Artist: "Photographer", Headline: "Headline", Copyright: "Agency", Caption: "Caption", Keywords: []string{"one", "two"}},
+		},
+		{
+			"the first value wins",
+			join(iptcDataset(2, iptcCaption, "first"), iptcDataset(2, iptcCaption, "second")),
+			&ImageMetadata{Caption: "first"},
+		},
+		{
+			"other records are ignored",
+			join(iptcDataset(1, iptcCaption, "envelope"), iptcDataset(3, iptcCaption, "other"), iptcDataset(2, 200, "unknown")),
+			&ImageMetadata{},
+		},
+		{"UTF-8", iptcDataset(2, iptcCaption, "Zürich, 東京"), &ImageMetadata{Caption: "Zürich, 東京"}},
+		{"Latin-1", iptcDataset(2, iptcCaption, "Z\xfcrich caf\xe9"), &ImageMetadata{Caption: "Zürich café"}},
+		{
+			"padding and empty values",
+			join(iptcDataset(2, iptcKeywords, "  tag\x00\x00"), iptcDataset(2, iptcKeywords, "\x00"), iptcDataset(2, iptcKeywords, "")),
+			&ImageMetadata{Keywords: []string{"tag"}},
+		},
+		{
+			"value past the end",
+			join(iptcDataset(2, iptcKeywords, "kept"), iptcDataset(2, iptcCaption, "cut off")[:9]),
+			&ImageMetadata{Keywords: []string{"kept"}},
+		},
+		{
+			"header cut off",
+			join(iptcDataset(2, iptcKeywords, "kept"), []byte{0x1c, 2, iptcCaption, 0}),
+			&ImageMetadata{Keywords: []string{"kept"}},
+		},
+		{
+			"extended dataset ends the data",
+			join(iptcDataset(2, iptcKeywords, "kept"), []byte{0x1c, 2, iptcCaption, 0x80, 0x04, 0, 0, 0, 4}, []byte("long"), iptcDataset(2, iptcHeadline, "after")),
+			&ImageMetadata{Keywords: []string{"kept"}},
+		},
+		{
+			"no dataset marker",
+			join(iptcDataset(2, iptcKeywords, "kept"), []byte("garbage"), iptcDataset(2, iptcHeadline, "after")),
+			&ImageMetadata{Keywords: []string{"kept"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var meta ImageMetadata
+			parseIPTC(tt.data, &meta)
+			if got, want := metaJSON(t, &meta), metaJSON(t, tt.want); got != want {
+				t.Errorf("metadata = %s\nwant %s", got, want)
+			}
+		})
+	}
+}
+
+// photoshopResource is an image resource with a Pascal string name, padded
+// when pad is set
+func photoshopResource(id uint16, name string, data []byte, pad bool) []byte {
+	out := binary.BigEndian.AppendUint16([]byte("8BIM"), id)
+	out = append(out, byte(len(name)))
+	out = append(out, name...)
+	if len(name)%2 == 0 {
+		out = append(out, 0)
+	}
+	out = binary.BigEndian.AppendUint32(out, uint32(len(data)))
+	out = append(out, data...)
+	if pad && len(data)%2 != 0 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+func TestParsePhotoshopResources(t *testing.T) {
+	iptc := iptcDataset(2, iptcHeadline, "News")
+	odd := iptcDataset(2, iptcHeadline, "Odd!") // 9 bytes
+
+	tests := []struct {
+		name     string
+		data     []byte
+		headline string
+	}{
+		{"IPTC resource", photoshopResource(photoshopIPTC, "", iptc, true), "News"},
+		{
+			"after other resources",
+			join(photoshopResource(0x03ed, "x", make([]byte, 16), true), photoshopResource(0x0409, "thumb", make([]byte, 7), true), photoshopResource(photoshopIPTC, "", iptc, true)),
+			"News",
+		},
+		{"odd size padded", join(photoshopResource(photoshopIPTC, "", odd, true), photoshopResource(0x03ed, "", nil, true)), "Odd!"},
+		{"odd size without padding at the end", photoshopResource(photoshopIPTC, "", odd, false), "Odd!"},
+		{"named resource", photoshopResource(photoshopIPTC, "IPTC", iptc, true), "News"},
+		{"size past the end", photoshopResource(photoshopIPTC, "", iptc, true)[:20], ""},
+		{"name past the end", append([]byte("8BIM\x04\x04\xff"), make([]byte, 10)...), ""},
+		{"not a resource", append([]byte("MeSa\x04\x04\x00\x00\x00\x00\x00\x08"), iptc...), ""},
+		{"header cut off", []byte("8BIM\x04\x04\x00"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var meta ImageMetadata
+			parsePhotoshopResources(tt.data, &meta)
+			if meta.Headline != tt.headline {
+				t.Errorf("headline = %q, want %q", meta.Headline, tt.headline)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// BeginJob marks a job as processing. It returns false when the job has
+// already completed, since the API's outbox relay may deliver a job more
+// than once. Jobs without a processing_jobs row are always run.
+func (p *Processor) BeginJob(ctx context.Context, jobID string) (bool, error) {
+	var state string
+	err := p.db.QueryRow(ctx, `
+		UPDATE processing_jobs
+		SET state = CASE WHEN state = 'completed' THEN state ELSE 'processing' END,
+			attempts = CASE WHEN state = 'completed' THEN attempts ELSE attempts + 1 END,
+			started_at = CASE WHEN state = 'completed' THEN started_at ELSE CURRENT_TIMESTAMP END
+		WHERE id = $1::uuid
+		RETURNING state
+	`, jobID).Scan(&state)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to start job: %w", err)
+	}
+	return state != "completed", nil
+}
+
+// FinishJob records the outcome of a job. Errors are logged since the job
+// itself has already run.
+func (p *Processor) FinishJob(ctx context.Context, jobID string, jobErr error) {
+	var err error
+	if jobErr != nil {
+		_, err = p.db.Exec(ctx, `
+			UPDATE processing_jobs SET state = 'failed', error_message = $2, completed_at = CURRENT_TIMESTAMP
+			WHERE id = $1::uuid
+		`, jobID, jobErr.Error())
+	} else {
+		_, err = p.db.Exec(ctx, `
+			UPDATE processing_jobs SET state = 'completed', error_message = NULL, completed_at = CURRENT_TIMESTAMP
+			WHERE id = $1::uuid
+		`, jobID)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("job_id", jobID).Msg("Failed to record job result")
+	}
+}
@@ -0,0 +1,203 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	// paletteSize is the number of k-means clusters; similar clusters are
+	// merged, so palettes can be shorter
+	paletteSize = 5
+	// paletteIterations bounds the k-means refinement
+	paletteIterations = 20
+	// paletteMergeDistance is the delta-E under which two colors count as one
+	paletteMergeDistance = 5
+)
+
+// PaletteColor is a color of an image with its share of the pixels
+type PaletteColor struct {
+	Color      string  `json:"color"`
+	Percentage float64 `json:"percentage"`
+}
+
+// storedPaletteColor is a palette color as stored in asset_meta; the API
+// searches by its CIELAB coordinates
+type storedPaletteColor struct {
+	PaletteColor
+	Lab [3]float64 `json:"lab"`
+}
+
+// extractPalette clusters the opaque pixels of img with k-means in CIELAB
+// space and returns the clusters, largest first
+func extractPalette(img *image.NRGBA) []storedPaletteColor {
+	type pixel struct {
+		rgb [3]float64
+		lab [3]float64
+	}
+	var pixels []pixel
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			c := img.NRGBAAt(x, y)
+			if c.A < 128 {
+				continue
+			}
+			rgb := [3]float64{float64(c.R), float64(c.G), float64(c.B)}
+			pixels = append(pixels, pixel{rgb: rgb, lab: rgbToLab(rgb)})
+		}
+	}
+	if len(pixels) == 0 {
+		return nil
+	}
+
+	// k-means++ seeding with a fixed seed, so reprocessing gives the same palette
+	rng := rand.New(rand.NewSource(1))
+	centers := [][3]float64{pixels[rng.Intn(len(pixels))].lab}
+	distances := make([]float64, len(pixels))
+	for len(centers) < paletteSize {
+		var total float64
+		for i, p := range pixels {
+			distances[i] = math.Inf(1)
+			for _, c := range centers {
+				distances[i] = math.Min(distances[i], labDistanceSquared(p.lab, c))
+			}
+			total += distances[i]
+		}
+		if total == 0 {
+			// Fewer distinct colors than clusters
+			break
+		}
+		target := rng.Float64() * total
+		next := len(pixels) - 1
+		for i, d := range distances {
+			if target -= d; target <= 0 {
+				next = i
+				break
+			}
+		}
+		centers = append(centers, pixels[next].lab)
+	}
+
+	assignment := make([]int, len(pixels))
+	for iteration := 0; iteration < paletteIterations; iteration++ {
+		changed := iteration == 0
+		for i, p := range pixels {
+			nearest, best := 0, math.Inf(1)
+			for j, c := range centers {
+				if d := labDistanceSquared(p.lab, c); d < best {
+					nearest, best = j, d
+				}
+			}
+			if assignment[i] != nearest {
+				assignment[i] = nearest
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		sums := make([][3]float64, len(centers))
+		counts := make([]int, len(centers))
+		for i, p := range pixels {
+			j := assignment[i]
+			for k := range sums[j] {
+				sums[j][k] += p.lab[k]
+			}
+			counts[j]++
+		}
+		for j := range centers {
+			if counts[j] > 0 {
+				centers[j] = [3]float64{sums[j][0] / float64(counts[j]), sums[j][1] / float64(counts[j]), sums[j][2] / float64(counts[j])}
+			}
+		}
+	}
+
+	// Clusters are reported as the mean sRGB color of their pixels, which
+	// round-trips through hex exactly
+	type cluster struct {
+		rgb   [3]float64
+		count int
+	}
+	clusters := make([]cluster, len(centers))
+	for i, p := range pixels {
+		c := &clusters[assignment[i]]
+		for k := range c.rgb {
+			c.rgb[k] += p.rgb[k]
+		}
+		c.count++
+	}
+
+	var palette []storedPaletteColor
+	var counts []int
+	for _, c := range clusters {
+		if c.count == 0 {
+			continue
+		}
+		rgb := [3]float64{
+			math.Round(c.rgb[0] / float64(c.count)),
+			math.Round(c.rgb[1] / float64(c.count)),
+			math.Round(c.rgb[2] / float64(c.count)),
+		}
+		lab := rgbToLab(rgb)
+
+		merged := false
+		for i := range palette {
+			if math.Sqrt(labDistanceSquared(palette[i].Lab, lab)) < paletteMergeDistance {
+				// Keep the color of the larger cluster
+				if c.count > counts[i] {
+					palette[i].Color, palette[i].Lab = hexColor(rgb), lab
+				}
+				counts[i] += c.count
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			palette = append(palette, storedPaletteColor{PaletteColor: PaletteColor{Color: hexColor(rgb)}, Lab: lab})
+			counts = append(counts, c.count)
+		}
+	}
+
+	for i := range palette {
+		palette[i].Percentage = math.Round(float64(counts[i])*1000/float64(len(pixels))) / 10
+		for k := range palette[i].Lab {
+			palette[i].Lab[k] = math.Round(palette[i].Lab[k]*100) / 100
+		}
+	}
+	sort.SliceStable(palette, func(i, j int) bool { return palette[i].Percentage > palette[j].Percentage })
+	return palette
+}
+
+func hexColor(rgb [3]float64) string {
+	return fmt.Sprintf("#%02x%02x%02x", int(rgb[0]), int(rgb[1]), int(rgb[2]))
+}
+
+// rgbToLab converts an sRGB color (0-255 channels) to CIELAB under D65
+func rgbToLab(rgb [3]float64) [3]float64 {
+	r := srgbToLinear(rgb[0] / 255)
+	g := srgbToLinear(rgb[1] / 255)
+	b := srgbToLinear(rgb[2] / 255)
+
+	x := (0.4124564*r + 0.3575761*g + 0.1804375*b) / 0.95047
+	y := 0.2126729*r + 0.7151522*g + 0.0721750*b
+	z := (0.0193339*r + 0.1191920*g + 0.9503041*b) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return [3]float64{116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)}
+}
+
+// labDistanceSquared is the square of the CIE76 delta-E of two colors
+func labDistanceSquared(a, b [3]float64) float64 {
+	dl, da, db := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dl*dl + da*da + db*db
+}
@@ -0,0 +1,166 @@
+package processor
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// Reference values from Bruce Lindbloom's color calculator (sRGB, D65)
+// unless noted
+var labReferences = []struct {
+	name string
+	rgb  [3]float64
+	lab  [3]float64
+}{
+	{"black", [3]float64{0, 0, 0}, [3]float64{0, 0, 0}},
+	{"white", [3]float64{255, 255, 255}, [3]float64{100, 0, 0}},
+	{"gray", [3]float64{128, 128, 128}, [3]float64{53.585, 0, 0}},
+	{"red", [3]float64{255, 0, 0}, [3]float64{53.2408, 80.0925, 67.2032}},
+	{"green", [3]float64{0, 255, 0}, [3]float64{87.7347, -86.1827, 83.1793}},
+	{"blue", [3]float64{0, 0, 255}, [3]float64{32.2970, 79.1875, -107.8602}},
+	// On the linear segment of both curves: L = 24389/27 × 1/255/12.92
+	{"near black", [3]float64{1, 1, 1}, [3]float64{0.2742, 0, 0}},
+}
+
+func TestRGBToLab(t *testing.T) {
+	for _, ref := range labReferences {
+		lab := rgbToLab(ref.rgb)
+		for i := range lab {
+			if math.Abs(lab[i]-ref.lab[i]) > 0.02 {
+				t.Errorf("%s: rgbToLab(%v) = %.4f, want %.4f", ref.name, ref.rgb, lab, ref.lab)
+				break
+			}
+		}
+	}
+}
+
+// blocksImage is 100 pixels wide with vertical bands of the given widths
+func blocksImage(bands []int, colors []color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 100, 10))
+	x := 0
+	for i, width := range bands {
+		for ; width > 0; width-- {
+			for y := 0; y < 10; y++ {
+				img.SetNRGBA(x, y, colors[i])
+			}
+			x++
+		}
+	}
+	return img
+}
+
+func TestExtractPalette(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+	green := color.NRGBA{G: 255, A: 255}
+	blue := color.NRGBA{B: 255, A: 255}
+	nearRed := color.NRGBA{R: 250, G: 2, A: 255}
+	transparent := color.NRGBA{R: 255, G: 255, B: 0, A: 20}
+
+	tests := []struct {
+		name   string
+		img    *image.NRGBA
+		colors []string
+		shares []float64
+	}{
+		{
+			name:   "largest first",
+			img:    blocksImage([]int{10, 60, 30}, []color.NRGBA{green, red, blue}),
+			colors: []string{"#ff0000", "#0000ff", "#00ff00"},
+			shares: []float64{60, 30, 10},
+		},
+		{
+			name:   "similar colors merge",
+			img:    blocksImage([]int{40, 20, 40}, []color.NRGBA{red, nearRed, blue}),
+			colors: []string{"#ff0000", "#0000ff"},
+			shares: []float64{60, 40},
+		},
+		{
+			name:   "transparent pixels left out",
+			img:    blocksImage([]int{50, 30, 20}, []color.NRGBA{transparent, red, blue}),
+			colors: []string{"#ff0000", "#0000ff"},
+			shares: []float64{60, 40},
+		},
+		{
+			name:   "single color",
+			img:    blocksImage([]int{100}, []color.NRGBA{blue}),
+			colors: []string{"#0000ff"},
+			shares: []float64{100},
+		},
+		{
+			name: "fully transparent",
+			img:  blocksImage([]int{100}, []color.NRGBA{transparent}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			palette := extractPalette(tt.img)
+			if len(palette) != len(tt.colors) {
+				t.Fatalf("palette = %+v, want colors %v", palette, tt.colors)
+			}
+			for i, c := range palette {
+				if c.Color != tt.colors[i] || c.Percentage != tt.shares[i] {
+					t.Errorf("palette[%d] = %s %.1f%%, want %s %.1f%%", i, c.Color, c.Percentage, tt.colors[i], tt.shares[i])
+				}
+				// Lab is stored for the color search of the API
+				want := rgbToLab(parseTestHex(c.Color))
+				for k := range want {
+					if math.Abs(c.Lab[k]-want[k]) > 0.01 {
+						t.Errorf("palette[%d].Lab = %v, want %v", i, c.Lab, want)
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestExtractPaletteIsDeterministic(t *testing.T) {
+	img := fixtureImage(32, 24, false)
+	first := extractPalette(img)
+	if len(first) == 0 || len(first) > paletteSize {
+		t.Fatalf("palette has %d colors, want 1-%d", len(first), paletteSize)
+	}
+
+	var total float64
+	for i, c := range first {
+		total += c.Percentage
+		if i > 0 && c.Percentage > first[i-1].Percentage {
+			t.Errorf("palette not sorted by share: %+v", first)
+		}
+	}
+	if math.Abs(total-100) > 0.5 {
+		t.Errorf("shares add up to %.1f%%", total)
+	}
+
+	for run := 0; run < 3; run++ {
+		again := extractPalette(img)
+		if len(again) != len(first) {
+			t.Fatalf("palette changed between runs: %+v, then %+v", first, again)
+		}
+		for i := range again {
+			if again[i] != first[i] {
+				t.Fatalf("palette changed between runs: %+v, then %+v", first, again)
+			}
+		}
+	}
+}
+
+func parseTestHex(s string) [3]float64 {
+	var rgb [3]float64
+	for i := range rgb {
+		var v int
+		for _, c := range s[1+2*i : 3+2*i] {
+			v *= 16
+			switch {
+			case c >= '0' && c <= '9':
+				v += int(c - '0')
+			default:
+				v += int(c-'a') + 10
+			}
+		}
+		rgb[i] = float64(v)
+	}
+	return rgb
+}
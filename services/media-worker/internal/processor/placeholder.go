@@ -0,0 +1,145 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/png" // decodes the downscaled frames from ffmpeg
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/google/uuid"
+)
+
+// placeholderSize is the bounding box images are downscaled to before
+// hashing; ThumbHash takes at most 100×100
+const placeholderSize = 100
+
+// Placeholder is what clients draw while an image or poster loads
+type Placeholder struct {
+	BlurHash      string `json:"blurHash"`
+	ThumbHash     string `json:"thumbHash"`
+	DominantColor string `json:"dominantColor"`
+}
+
+// savePlaceholder stores the placeholder and palette in asset_meta
+func (p *Processor) savePlaceholder(ctx context.Context, assetID uuid.UUID, placeholder *Placeholder, palette []storedPaletteColor) error {
+	// Fully transparent images have no palette, stored as NULL
+	var paletteJSON []byte
+	if len(palette) > 0 {
+		var err error
+		if paletteJSON, err = json.Marshal(palette); err != nil {
+			return fmt.Errorf("failed to marshal palette: %w", err)
+		}
+	}
+
+	_, err := p.db.Exec(ctx, `
+		INSERT INTO asset_meta (asset_id, blurhash, thumbhash, dominant_color, palette)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (asset_id) DO UPDATE SET
+			blurhash = EXCLUDED.blurhash,
+			thumbhash = EXCLUDED.thumbhash,
+			dominant_color = EXCLUDED.dominant_color,
+			palette = EXCLUDED.palette
+	`, assetID, placeholder.BlurHash, placeholder.ThumbHash, placeholder.DominantColor, paletteJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save placeholder: %w", err)
+	}
+	return nil
+}
+
+// analyzeImage computes the placeholder and palette of the first frame of an
+// image file. FFmpeg does the decoding and downscaling, so every format it
+// reads is supported without holding the full-size pixels in memory.
+func analyzeImage(inputPath, workDir string) (*Placeholder, []storedPaletteColor, error) {
+	small, err := downscaleFrame(inputPath, filepath.Join(workDir, "placeholder.png"), placeholderSize)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	xComp, yComp := 4, 3
+	if small.Rect.Dy() > small.Rect.Dx() {
+		xComp, yComp = 3, 4
+	}
+	placeholder := &Placeholder{
+		BlurHash:      encodeBlurHash(small, xComp, yComp),
+		ThumbHash:     base64.StdEncoding.EncodeToString(encodeThumbHash(small)),
+		DominantColor: dominantColor(small),
+	}
+	return placeholder, extractPalette(small), nil
+}
+
+// downscaleFrame scales the first frame of inputPath to fit in a size×size
+// box and decodes it
+func downscaleFrame(inputPath, outputPath string, size int) (*image.NRGBA, error) {
+	cmd := exec.Command("ffmpeg",
+		"-v", "error",
+		"-i", inputPath,
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease", size, size),
+		"-pix_fmt", "rgba",
+		"-y", outputPath,
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to downscale image: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	f, err := os.Open(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode downscaled image: %w", err)
+	}
+	if nrgba, ok := img.(*image.NRGBA); ok {
+		return nrgba, nil
+	}
+	nrgba := image.NewNRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(nrgba, nrgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return nrgba, nil
+}
+
+// dominantColor returns the most common color of img as #rrggbb: the mean of
+// the fullest bucket of a 4-bit-per-channel histogram. Mostly transparent
+// pixels are left out.
+func dominantColor(img *image.NRGBA) string {
+	type bucket struct {
+		count   int
+		r, g, b int
+	}
+	var buckets [4096]bucket
+	best := -1
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			c := img.NRGBAAt(x, y)
+			if c.A < 128 {
+				continue
+			}
+			i := int(c.R>>4)<<8 | int(c.G>>4)<<4 | int(c.B>>4)
+			b := &buckets[i]
+			b.count++
+			b.r += int(c.R)
+			b.g += int(c.G)
+			b.b += int(c.B)
+			if best < 0 || b.count > buckets[best].count {
+				best = i
+			}
+		}
+	}
+	if best < 0 {
+		// Fully transparent
+		return "#ffffff"
+	}
+	b := buckets[best]
+	return fmt.Sprintf("#%02x%02x%02x", b.r/b.count, b.g/b.count, b.b/b.count)
+}
@@ -0,0 +1,127 @@
+package processor
+
+import (
+	"encoding/base64"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// fixtureImage draws the fixture of testdata/placeholder_golden.js; with
+// alpha, the left quarter is transparent and the next quarter half opaque
+func fixtureImage(w, h int, alpha bool) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			a := uint8(255)
+			if alpha {
+				switch {
+				case x*4 < w:
+					a = 0
+				case x*2 < w:
+					a = 128
+				}
+			}
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8((x*8 + y*2) % 256), G: uint8(y * 11 % 256), B: uint8(x * y * 3 % 256), A: a})
+		}
+	}
+	return img
+}
+
+// Golden values from the reference encoders (testdata/placeholder_golden.js)
+func TestEncodeBlurHash(t *testing.T) {
+	tests := []struct {
+		w, h         int
+		xComp, yComp int
+		want         string
+	}{
+		{32, 24, 4, 3, "L#HVCt6_N?$fl]NawvR+gFbEjuW="},
+		{32, 24, 1, 1, "00HVCt"},
+		{32, 24, 9, 9, "|#HVCt6_N?$fJixCN?s+Wnl]NawvR+sUaha}nmSNgFbEjuW=o3W=o3W=o2niWojufSfRfRfPa{jre-a|jva}jua{jsa{fQobWqa~jua{fOfPa{jvena}jva{jra{fSjva~ova~a}jsa_fRfSa}jreoa~jua_jtfSa~jra_"},
+		{20, 32, 4, 3, "LiD,o[Bgn|kRMyn#jsazdHjFfSe?"},
+		{20, 32, 1, 1, "00D,o["},
+		{20, 32, 9, 9, "|iD,o[Bgn|kRWlouWmt6SJMyn#jsazjua$jva$jvdHjFfSe?fTe?fSe:fPlJbafSf+fRf$fOf#fOa^jufSfRfPfOfOfRfTmpa$fSf6fOf5fSf9fRcPj[fSfhfPflfTfkfOn6a%fRf5fOf9fPf4fOaKjcfOf5fTf9fOf5fT"},
+	}
+	for _, tt := range tests {
+		got := encodeBlurHash(fixtureImage(tt.w, tt.h, false), tt.xComp, tt.yComp)
+		if got != tt.want {
+			t.Errorf("%dx%d %dx%d components: %s, want %s", tt.w, tt.h, tt.xComp, tt.yComp, got, tt.want)
+		}
+		if wantLen := 4 + 2*tt.xComp*tt.yComp; len(got) != wantLen {
+			t.Errorf("%dx%d: length %d, want %d", tt.w, tt.h, len(got), wantLen)
+		}
+	}
+}
+
+func TestEncodeBlurHashTransparent(t *testing.T) {
+	// Transparent pixels are drawn over white, so a transparent image is
+	// a white one
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	white := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for i := range white.Pix {
+		white.Pix[i] = 255
+	}
+	if got, want := encodeBlurHash(img, 4, 3), encodeBlurHash(white, 4, 3); got != want {
+		t.Errorf("transparent %s, white %s", got, want)
+	}
+}
+
+// Golden values from the reference encoder (testdata/placeholder_golden.js)
+func TestEncodeThumbHash(t *testing.T) {
+	tests := []struct {
+		w, h  int
+		alpha bool
+		want  string
+	}{
+		{32, 24, false, "XggOJZpDWGhgeHh2iGiHh3VwPPWG"},
+		{20, 32, false, "2wcKHBRhJncIh4h4WJj1piCH+A=="},
+		{32, 24, true, "okiGHJJJdluga4ZoaAe5dz8IhXiHiIh4CA=="},
+	}
+	for _, tt := range tests {
+		got := base64.StdEncoding.EncodeToString(encodeThumbHash(fixtureImage(tt.w, tt.h, tt.alpha)))
+		if got != tt.want {
+			t.Errorf("%dx%d alpha=%v: %s, want %s", tt.w, tt.h, tt.alpha, got, tt.want)
+		}
+	}
+}
+
+func TestEncode83(t *testing.T) {
+	tests := []struct {
+		value, length int
+		want          string
+	}{
+		{0, 1, "0"},
+		{82, 1, "~"},
+		{83, 2, "10"},
+		{83*83 - 1, 2, "~~"},
+		{0xffffff, 4, "TSUA"},
+	}
+	for _, tt := range tests {
+		if got := encode83(tt.value, tt.length); got != tt.want {
+			t.Errorf("encode83(%d, %d) = %q, want %q", tt.value, tt.length, got, tt.want)
+		}
+	}
+}
+
+func TestDominantColor(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			c := color.NRGBA{R: 200, G: 30, B: 30, A: 255}
+			switch {
+			case x < 3:
+				c = color.NRGBA{R: 20, G: 20, B: 220, A: 255}
+			case y < 2:
+				// Transparent pixels never count
+				c = color.NRGBA{R: 0, G: 255, B: 0, A: 10}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	if got := dominantColor(img); got != "#c81e1e" {
+		t.Errorf("dominantColor = %s, want #c81e1e", got)
+	}
+	if got := dominantColor(image.NewNRGBA(image.Rect(0, 0, 4, 4))); got != "#ffffff" {
+		t.Errorf("dominantColor of a transparent image = %s, want #ffffff", got)
+	}
+}
@@ -0,0 +1,718 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf16"
+
+	"github.com/google/uuid"
+)
+
+// ErrUnsupportedImage is returned by probeImage for files that are not an
+// image in a known format, or whose header is corrupt
+var ErrUnsupportedImage = errors.New("not a supported image")
+
+// ImageProbe is what the header of an image file says about it
+type ImageProbe struct {
+	Format string // jpeg, png, gif, webp, avif, heic, tiff or bmp
+	Width  int
+	Height int
+	// ColorSpace is rgb, gray or cmyk
+	ColorSpace string
+	// ColorProfile names the embedded ICC profile or declared color space,
+	// e.g. sRGB or Display P3; empty when the file does not say
+	ColorProfile string
+	BitDepth     int // bits per channel
+	HasAlpha     bool
+	// FrameCount is the number of frames of animations or pages of TIFFs;
+	// 0 when unknown (AVIF and HEIC sequences)
+	FrameCount int
+}
+
+// ImageInfo is the stored probe of an image, sent with asset events
+type ImageInfo struct {
+	Format       string `json:"format"`
+	ColorSpace   string `json:"colorSpace,omitempty"`
+	ColorProfile string `json:"colorProfile,omitempty"`
+	BitDepth     int    `json:"bitDepth,omitempty"`
+	HasAlpha     bool   `json:"hasAlpha"`
+	FrameCount   int    `json:"frameCount,omitempty"`
+}
+
+// saveImageProbe stores the probe of an image in asset_meta. Images with an
+// EXIF orientation of 5-8 are stored rotated, as imgproxy delivers them
+// upright.
+func (p *Processor) saveImageProbe(ctx context.Context, assetID uuid.UUID, probe *ImageProbe, orientation int) error {
+	width, height := probe.Width, probe.Height
+	if orientation >= 5 && orientation <= 8 {
+		width, height = height, width
+	}
+
+	_, err := p.db.Exec(ctx, `
+		INSERT INTO asset_meta (asset_id, width, height, format, color_space, color_profile, bit_depth, has_alpha, frame_count)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, NULLIF($9, 0))
+		ON CONFLICT (asset_id) DO UPDATE SET
+			width = EXCLUDED.width,
+			height = EXCLUDED.height,
+			format = EXCLUDED.format,
+			color_space = EXCLUDED.color_space,
+			color_profile = EXCLUDED.color_profile,
+			bit_depth = EXCLUDED.bit_depth,
+			has_alpha = EXCLUDED.has_alpha,
+			frame_count = EXCLUDED.frame_count
+	`, assetID, width, height, probe.Format, probe.ColorSpace, probe.ColorProfile, probe.BitDepth, probe.HasAlpha, probe.FrameCount)
+	if err != nil {
+		return fmt.Errorf("failed to save image probe: %w", err)
+	}
+	return nil
+}
+
+// probeImage reads the format, dimensions and color properties of an image
+// from its header, without decoding pixels. Corrupt and unknown files fail
+// with ErrUnsupportedImage.
+func probeImage(path string) (*ImageProbe, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return nil, fmt.Errorf("%w: file too short", ErrUnsupportedImage)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var probe *ImageProbe
+	switch {
+	case bytes.HasPrefix(header, []byte{0xff, 0xd8, 0xff}):
+		probe, err = probeJPEG(f)
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		probe, err = probePNG(f)
+	case bytes.HasPrefix(header, []byte("GIF87a")) || bytes.HasPrefix(header, []byte("GIF89a")):
+		probe, err = probeGIF(bufio.NewReader(f))
+	case string(header[:4]) == "RIFF" && string(header[8:12]) == "WEBP":
+		probe, err = probeWebP(f)
+	case string(header[4:8]) == "ftyp":
+		probe, err = probeISOBMFF(f)
+	case string(header[:4]) == "II*\x00" || string(header[:4]) == "MM\x00*":
+		probe, err = probeTIFF(f)
+	case string(header[:2]) == "BM":
+		probe, err = probeBMP(f)
+	default:
+		return nil, fmt.Errorf("%w: unknown format", ErrUnsupportedImage)
+	}
+	if err != nil {
+		if errors.Is(err, ErrUnsupportedImage) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+	if probe.Width <= 0 || probe.Height <= 0 {
+		return nil, fmt.Errorf("%w: invalid %s dimensions %dx%d", ErrUnsupportedImage, probe.Format, probe.Width, probe.Height)
+	}
+	return probe, nil
+}
+
+// probeJPEG reads the frame header (SOFn) and the ICC profile of a JPEG
+func probeJPEG(r io.Reader) (*ImageProbe, error) {
+	br := newByteReader(r)
+	if _, err := br.skip(2); err != nil {
+		return nil, err
+	}
+
+	probe := &ImageProbe{Format: "jpeg", FrameCount: 1}
+	var icc []byte
+	for {
+		prefix, err := br.next(1)
+		if err != nil {
+			return nil, fmt.Errorf("no frame header: %w", err)
+		}
+		if prefix[0] != 0xff {
+			return nil, errors.New("invalid marker")
+		}
+		marker, err := br.next(1)
+		for err == nil && marker[0] == 0xff {
+			// Fill bytes before a marker
+			marker, err = br.next(1)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m := marker[0]
+		switch {
+		case m == 0x01 || m >= 0xd0 && m <= 0xd8:
+			continue
+		case m == 0xd9 || m == 0xda:
+			return nil, errors.New("no frame header before image data")
+		}
+
+		length, err := br.next(2)
+		if err != nil {
+			return nil, err
+		}
+		size := int(binary.BigEndian.Uint16(length)) - 2
+		if size < 0 {
+			return nil, errors.New("invalid segment length")
+		}
+
+		switch {
+		case m >= 0xc0 && m <= 0xcf && m != 0xc4 && m != 0xc8 && m != 0xcc:
+			sof, err := br.next(size)
+			if err != nil || len(sof) < 6 {
+				return nil, errors.New("truncated frame header")
+			}
+			probe.BitDepth = int(sof[0])
+			probe.Height = int(binary.BigEndian.Uint16(sof[1:]))
+			probe.Width = int(binary.BigEndian.Uint16(sof[3:]))
+			switch sof[5] {
+			case 1:
+				probe.ColorSpace = "gray"
+			case 3:
+				probe.ColorSpace = "rgb"
+			case 4:
+				probe.ColorSpace = "cmyk"
+			default:
+				return nil, fmt.Errorf("unsupported component count %d", sof[5])
+			}
+			probe.ColorProfile = iccDescription(icc)
+			return probe, nil
+		case m == 0xe2:
+			segment, err := br.next(size)
+			if err != nil {
+				return nil, err
+			}
+			if bytes.HasPrefix(segment, []byte("ICC_PROFILE\x00")) && len(segment) > 14 {
+				// Profiles are split across segments in order
+				icc = append(icc, segment[14:]...)
+			}
+		default:
+			if _, err := br.skip(size); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// probePNG reads IHDR and the chunks before the image data that describe
+// color (iCCP, sRGB), transparency (tRNS) and animation (acTL)
+func probePNG(r io.Reader) (*ImageProbe, error) {
+	br := newByteReader(r)
+	if _, err := br.skip(8); err != nil {
+		return nil, err
+	}
+
+	probe := &ImageProbe{Format: "png", FrameCount: 1}
+	for first := true; ; first = false {
+		header, err := br.next(8)
+		if err != nil {
+			return nil, fmt.Errorf("truncated chunk: %w", err)
+		}
+		size := int(binary.BigEndian.Uint32(header))
+		typ := string(header[4:])
+		if first != (typ == "IHDR") {
+			return nil, errors.New("IHDR is not the first chunk")
+		}
+
+		switch typ {
+		case "IDAT", "IEND":
+			if probe.Width == 0 {
+				return nil, errors.New("missing IHDR")
+			}
+			return probe, nil
+		case "IHDR", "acTL", "iCCP", "sRGB", "tRNS":
+		default:
+			if _, err := br.skip(size + 4); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		chunk, err := br.next(size + 4)
+		if err != nil {
+			return nil, fmt.Errorf("truncated %s chunk: %w", typ, err)
+		}
+		chunk = chunk[:size]
+		switch typ {
+		case "IHDR":
+			if len(chunk) < 13 {
+				return nil, errors.New("truncated IHDR")
+			}
+			probe.Width = int(binary.BigEndian.Uint32(chunk))
+			probe.Height = int(binary.BigEndian.Uint32(chunk[4:]))
+			probe.BitDepth = int(chunk[8])
+			switch chunk[9] {
+			case 0:
+				probe.ColorSpace = "gray"
+			case 4:
+				probe.ColorSpace, probe.HasAlpha = "gray", true
+			case 2, 3:
+				probe.ColorSpace = "rgb"
+			case 6:
+				probe.ColorSpace, probe.HasAlpha = "rgb", true
+			default:
+				return nil, fmt.Errorf("invalid color type %d", chunk[9])
+			}
+			if chunk[9] == 3 {
+				// Palette entries are 8-bit RGB
+				probe.BitDepth = 8
+			}
+		case "acTL":
+			if len(chunk) >= 4 {
+				probe.FrameCount = int(binary.BigEndian.Uint32(chunk))
+			}
+		case "tRNS":
+			probe.HasAlpha = true
+		case "sRGB":
+			probe.ColorProfile = "sRGB"
+		case "iCCP":
+			// Profile name, NUL, compression method, zlib stream
+			if _, compressed, ok := bytes.Cut(chunk, []byte{0}); ok && len(compressed) > 1 {
+				if zr, err := zlib.NewReader(bytes.NewReader(compressed[1:])); err == nil {
+					icc, _ := io.ReadAll(io.LimitReader(zr, maxMetadataBlock))
+					probe.ColorProfile = iccDescription(icc)
+				}
+			}
+		}
+	}
+}
+
+// probeGIF reads the logical screen and counts the frames, skipping their
+// compressed data
+func probeGIF(r *bufio.Reader) (*ImageProbe, error) {
+	screen := make([]byte, 13)
+	if _, err := io.ReadFull(r, screen); err != nil {
+		return nil, err
+	}
+	probe := &ImageProbe{
+		Format:     "gif",
+		Width:      int(binary.LittleEndian.Uint16(screen[6:])),
+		Height:     int(binary.LittleEndian.Uint16(screen[8:])),
+		ColorSpace: "rgb",
+		// Palettes hold 8-bit RGB
+		BitDepth: 8,
+	}
+	skipColorTable := func(flags byte) error {
+		if flags&0x80 == 0 {
+			return nil
+		}
+		_, err := r.Discard(3 << ((flags & 7) + 1))
+		return err
+	}
+	skipSubBlocks := func() error {
+		for {
+			size, err := r.ReadByte()
+			if err != nil || size == 0 {
+				return err
+			}
+			if _, err := r.Discard(int(size)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := skipColorTable(screen[10]); err != nil {
+		return nil, err
+	}
+	for {
+		block, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("truncated GIF: %w", err)
+		}
+		switch block {
+		case 0x21: // extension
+			label, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if label == 0xf9 {
+				// Graphic control: the flags tell whether a color is transparent
+				gce := make([]byte, 6)
+				if _, err := io.ReadFull(r, gce); err != nil {
+					return nil, err
+				}
+				if gce[1]&1 != 0 {
+					probe.HasAlpha = true
+				}
+				if gce[5] != 0 {
+					return nil, errors.New("invalid graphic control extension")
+				}
+				continue
+			}
+			if err := skipSubBlocks(); err != nil {
+				return nil, err
+			}
+		case 0x2c: // image descriptor
+			descriptor := make([]byte, 9)
+			if _, err := io.ReadFull(r, descriptor); err != nil {
+				return nil, err
+			}
+			if err := skipColorTable(descriptor[8]); err != nil {
+				return nil, err
+			}
+			// LZW minimum code size, then the image data
+			if _, err := r.ReadByte(); err != nil {
+				return nil, err
+			}
+			if err := skipSubBlocks(); err != nil {
+				return nil, err
+			}
+			probe.FrameCount++
+		case 0x3b: // trailer
+			if probe.FrameCount == 0 {
+				return nil, errors.New("GIF has no frames")
+			}
+			return probe, nil
+		default:
+			if probe.FrameCount > 0 {
+				// Trailing garbage after complete frames, as browsers accept
+				return probe, nil
+			}
+			return nil, fmt.Errorf("invalid GIF block 0x%02x", block)
+		}
+	}
+}
+
+// probeWebP reads the VP8, VP8L or VP8X header, the ICC profile and counts
+// animation frames
+func probeWebP(r io.Reader) (*ImageProbe, error) {
+	br := newByteReader(r)
+	if _, err := br.skip(12); err != nil {
+		return nil, err
+	}
+
+	probe := &ImageProbe{Format: "webp", ColorSpace: "rgb", BitDepth: 8, FrameCount: 1}
+	extended := false
+	for {
+		header, err := br.next(8)
+		if err != nil {
+			if probe.Width > 0 {
+				return probe, nil
+			}
+			return nil, fmt.Errorf("truncated WebP: %w", err)
+		}
+		typ := string(header[:4])
+		size := int(binary.LittleEndian.Uint32(header[4:]))
+		padded := size + size%2
+
+		switch typ {
+		case "VP8X", "ICCP":
+			chunk, err := br.next(padded)
+			if err != nil {
+				return nil, err
+			}
+			if typ == "ICCP" {
+				probe.ColorProfile = iccDescription(chunk[:size])
+				continue
+			}
+			if size < 10 {
+				return nil, errors.New("truncated VP8X")
+			}
+			extended = true
+			probe.HasAlpha = chunk[0]&0x10 != 0
+			if chunk[0]&0x02 != 0 {
+				// Frames are counted from the ANMF chunks
+				probe.FrameCount = 0
+			}
+			probe.Width = int(uint32(chunk[4])|uint32(chunk[5])<<8|uint32(chunk[6])<<16) + 1
+			probe.Height = int(uint32(chunk[7])|uint32(chunk[8])<<8|uint32(chunk[9])<<16) + 1
+		case "ANMF":
+			probe.FrameCount++
+			if _, err := br.skip(padded); err != nil {
+				return nil, err
+			}
+		case "VP8 ", "VP8L":
+			if extended {
+				// The canvas is described by VP8X
+				if _, err := br.skip(padded); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			head, err := br.next(min(padded, 10))
+			if err != nil {
+				return nil, err
+			}
+			if typ == "VP8 " {
+				if len(head) < 10 || !bytes.Equal(head[3:6], []byte{0x9d, 0x01, 0x2a}) {
+					return nil, errors.New("invalid VP8 frame")
+				}
+				probe.Width = int(binary.LittleEndian.Uint16(head[6:]) & 0x3fff)
+				probe.Height = int(binary.LittleEndian.Uint16(head[8:]) & 0x3fff)
+			} else {
+				if len(head) < 5 || head[0] != 0x2f {
+					return nil, errors.New("invalid VP8L header")
+				}
+				bits := binary.LittleEndian.Uint32(head[1:])
+				probe.Width = int(bits&0x3fff) + 1
+				probe.Height = int(bits>>14&0x3fff) + 1
+				probe.HasAlpha = bits>>28&1 != 0
+			}
+			return probe, nil
+		default:
+			if _, err := br.skip(padded); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// probeISOBMFF reads the image properties of an AVIF or HEIF file: ispe
+// (dimensions, of the largest image since thumbnails are smaller), pixi (bit
+// depth), colr (color) and auxC (alpha)
+func probeISOBMFF(r io.ReadSeeker) (*ImageProbe, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxMetadataBlock))
+	if err != nil {
+		return nil, err
+	}
+
+	probe := &ImageProbe{ColorSpace: "rgb", BitDepth: 8, FrameCount: 1}
+	sequence := false
+	var walk func(boxes []byte) error
+	walk = func(boxes []byte) error {
+		for len(boxes) >= 8 {
+			size := int(binary.BigEndian.Uint32(boxes))
+			typ := string(boxes[4:8])
+			headerSize := 8
+			if size == 1 && len(boxes) >= 16 {
+				size, headerSize = int(binary.BigEndian.Uint64(boxes[8:])), 16
+			} else if size == 0 {
+				size = len(boxes)
+			}
+			if size < headerSize || size > len(boxes) {
+				if typ == "mdat" {
+					// Image data may be cut off by the read limit
+					return nil
+				}
+				return fmt.Errorf("invalid %q box", typ)
+			}
+			body := boxes[headerSize:size]
+			boxes = boxes[size:]
+
+			switch typ {
+			case "ftyp":
+				if len(body) < 4 {
+					return errors.New("invalid ftyp box")
+				}
+				brands := [][]byte{body[:4]}
+				for i := 8; i+4 <= len(body); i += 4 {
+					brands = append(brands, body[i:i+4])
+				}
+				for _, brand := range brands {
+					switch string(brand) {
+					case "avif", "avis":
+						probe.Format = "avif"
+					case "heic", "heix", "heim", "heis", "hevc", "hevx":
+						if probe.Format == "" {
+							probe.Format = "heic"
+						}
+					}
+					if string(brand) == "avis" || string(brand) == "msf1" || string(brand) == "hevc" {
+						sequence = true
+					}
+				}
+			case "meta":
+				if len(body) < 4 {
+					return errors.New("invalid meta box")
+				}
+				// Full box: version and flags precede the children
+				if err := walk(body[4:]); err != nil {
+					return err
+				}
+			case "iprp", "ipco":
+				if err := walk(body); err != nil {
+					return err
+				}
+			case "ispe":
+				if len(body) >= 12 {
+					w, h := int(binary.BigEndian.Uint32(body[4:])), int(binary.BigEndian.Uint32(body[8:]))
+					if w*h > probe.Width*probe.Height {
+						probe.Width, probe.Height = w, h
+					}
+				}
+			case "pixi":
+				if len(body) >= 6 && body[4] > 0 {
+					probe.BitDepth = int(body[5])
+					if body[4] == 1 {
+						probe.ColorSpace = "gray"
+					}
+				}
+			case "colr":
+				if len(body) >= 4 {
+					switch string(body[:4]) {
+					case "nclx":
+						if len(body) >= 6 {
+							probe.ColorProfile = colorPrimariesName(binary.BigEndian.Uint16(body[4:]))
+						}
+					case "prof", "rICC":
+						probe.ColorProfile = iccDescription(body[4:])
+					}
+				}
+			case "auxC":
+				if bytes.Contains(body, []byte(":alpha")) {
+					probe.HasAlpha = true
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(data); err != nil {
+		return nil, err
+	}
+	if probe.Format == "" {
+		return nil, fmt.Errorf("%w: not an AVIF or HEIF image", ErrUnsupportedImage)
+	}
+	if sequence {
+		probe.FrameCount = 0
+	}
+	return probe, nil
+}
+
+// colorPrimariesName names the color primaries of an nclx color box (ISO/IEC
+// 23091-2); the common ones only
+func colorPrimariesName(primaries uint16) string {
+	switch primaries {
+	case 1:
+		return "sRGB"
+	case 9:
+		return "BT.2020"
+	case 12:
+		return "Display P3"
+	}
+	return ""
+}
+
+// probeTIFF reads the first IFD of a TIFF and counts its pages
+func probeTIFF(r io.Reader) (*ImageProbe, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxMetadataBlock))
+	if err != nil {
+		return nil, err
+	}
+	t := &tiffReader{data: data, order: binary.LittleEndian}
+	if data[0] == 'M' {
+		t.order = binary.BigEndian
+	}
+
+	offset := t.order.Uint32(data[4:])
+	ifd, err := t.readIFD(offset)
+	if err != nil {
+		return nil, err
+	}
+	probe := &ImageProbe{
+		Format: "tiff",
+		Width:  int(t.uint(ifd[0x0100])),
+		Height: int(t.uint(ifd[0x0101])),
+		// BitsPerSample defaults to 1
+		BitDepth:     1,
+		ColorProfile: iccDescription(ifd[0x8773].value),
+		HasAlpha:     len(ifd[0x0152].value) > 0,
+	}
+	if bits := t.uint(ifd[0x0102]); bits > 0 {
+		probe.BitDepth = int(bits)
+	}
+	switch t.uint(ifd[0x0106]) {
+	case 0, 1:
+		probe.ColorSpace = "gray"
+	case 5:
+		probe.ColorSpace = "cmyk"
+	default:
+		probe.ColorSpace = "rgb"
+	}
+
+	// Pages are a chain of IFDs; the offset of the next follows the entries
+	for pages := 1; pages <= maxIFDEntries; pages++ {
+		probe.FrameCount = pages
+		end := uint64(offset) + 2 + uint64(t.order.Uint16(data[offset:]))*12
+		if end+4 > uint64(len(data)) {
+			break
+		}
+		offset = t.order.Uint32(data[end:])
+		if offset == 0 || uint64(offset)+2 > uint64(len(data)) {
+			break
+		}
+	}
+	return probe, nil
+}
+
+// probeBMP reads the BITMAPINFOHEADER of a BMP
+func probeBMP(r io.Reader) (*ImageProbe, error) {
+	header := make([]byte, 30)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(header[14:]) < 40 {
+		return nil, errors.New("unsupported BMP header")
+	}
+	height := int(int32(binary.LittleEndian.Uint32(header[22:])))
+	if height < 0 {
+		// Stored top-down
+		height = -height
+	}
+	bits := int(binary.LittleEndian.Uint16(header[28:]))
+	probe := &ImageProbe{
+		Format:     "bmp",
+		Width:      int(int32(binary.LittleEndian.Uint32(header[18:]))),
+		Height:     height,
+		ColorSpace: "rgb",
+		BitDepth:   8,
+		HasAlpha:   bits == 32,
+		FrameCount: 1,
+	}
+	if bits == 1 {
+		probe.ColorSpace, probe.BitDepth = "gray", 1
+	}
+	return probe, nil
+}
+
+// iccDescription reads the description of an ICC profile, from its desc
+// (v2) or mluc (v4) tag
+func iccDescription(icc []byte) string {
+	if len(icc) < 132 || string(icc[36:40]) != "acsp" {
+		return ""
+	}
+	count := int(binary.BigEndian.Uint32(icc[128:]))
+	for i := 0; i < count && 132+i*12+12 <= len(icc); i++ {
+		entry := icc[132+i*12:]
+		if string(entry[:4]) != "desc" {
+			continue
+		}
+		offset, size := int(binary.BigEndian.Uint32(entry[4:])), int(binary.BigEndian.Uint32(entry[8:]))
+		if offset < 0 || size < 12 || offset+size > len(icc) || offset+size < offset {
+			return ""
+		}
+		tag := icc[offset : offset+size]
+		switch string(tag[:4]) {
+		case "desc":
+			n := int(binary.BigEndian.Uint32(tag[8:]))
+			if n <= 0 || 12+n > len(tag) {
+				return ""
+			}
+			return cleanText(string(bytes.TrimRight(tag[12:12+n], "\x00")))
+		case "mluc":
+			// The first record: language, country, length and offset of UTF-16BE text
+			if len(tag) < 28 || binary.BigEndian.Uint32(tag[8:]) == 0 {
+				return ""
+			}
+			n, at := int(binary.BigEndian.Uint32(tag[20:])), int(binary.BigEndian.Uint32(tag[24:]))
+			if n < 0 || at < 0 || at+n > len(tag) || at+n < at {
+				return ""
+			}
+			units := make([]uint16, n/2)
+			for j := range units {
+				units[j] = binary.BigEndian.Uint16(tag[at+j*2:])
+			}
+			return cleanText(string(utf16.Decode(units)))
+		}
+		return ""
+	}
+	return ""
+}
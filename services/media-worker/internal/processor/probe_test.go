@@ -0,0 +1,249 @@
+package processor
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+var probeFixtures = []struct {
+	file string
+	want ImageProbe
+}{
+	{"photo.jpg", ImageProbe{Format: "jpeg", Width: 16, Height: 12, ColorSpace: "rgb", ColorProfile: "Test RGB", BitDepth: 8, FrameCount: 1}},
+	{"gray.jpg", ImageProbe{Format: "jpeg", Width: 9, Height: 7, ColorSpace: "gray", BitDepth: 8, FrameCount: 1}},
+	{"exif.png", ImageProbe{Format: "png", Width: 8, Height: 8, ColorSpace: "rgb", ColorProfile: "sRGB", BitDepth: 8, HasAlpha: true, FrameCount: 1}},
+	// 1-bit palette indices of 8-bit colors, one of them transparent
+	{"palette.png", ImageProbe{Format: "png", Width: 5, Height: 3, ColorSpace: "rgb", BitDepth: 8, HasAlpha: true, FrameCount: 1}},
+	{"animated.gif", ImageProbe{Format: "gif", Width: 4, Height: 3, ColorSpace: "rgb", BitDepth: 8, HasAlpha: true, FrameCount: 2}},
+	{"lossy.webp", ImageProbe{Format: "webp", Width: 20, Height: 10, ColorSpace: "rgb", BitDepth: 8, FrameCount: 1}},
+	{"lossless.webp", ImageProbe{Format: "webp", Width: 30, Height: 15, ColorSpace: "rgb", BitDepth: 8, HasAlpha: true, FrameCount: 1}},
+	{"extended.webp", ImageProbe{Format: "webp", Width: 640, Height: 480, ColorSpace: "rgb", ColorProfile: "Display P3", BitDepth: 8, HasAlpha: true, FrameCount: 1}},
+	{"animated.webp", ImageProbe{Format: "webp", Width: 64, Height: 48, ColorSpace: "rgb", BitDepth: 8, HasAlpha: true, FrameCount: 3}},
+	// The primary image, not the thumbnail listed first
+	{"photo.avif", ImageProbe{Format: "avif", Width: 1920, Height: 1080, ColorSpace: "rgb", ColorProfile: "Display P3", BitDepth: 10, HasAlpha: true, FrameCount: 1}},
+	{"sequence.avif", ImageProbe{Format: "avif", Width: 320, Height: 240, ColorSpace: "gray", BitDepth: 8}},
+	{"photo.heic", ImageProbe{Format: "heic", Width: 4032, Height: 3024, ColorSpace: "rgb", ColorProfile: "Test RGB", BitDepth: 8, FrameCount: 1}},
+	{"pages.tiff", ImageProbe{Format: "tiff", Width: 4, Height: 2, ColorSpace: "rgb", ColorProfile: "Test RGB", BitDepth: 8, HasAlpha: true, FrameCount: 2}},
+	{"rgb.bmp", ImageProbe{Format: "bmp", Width: 3, Height: 2, ColorSpace: "rgb", BitDepth: 8, FrameCount: 1}},
+	{"topdown.bmp", ImageProbe{Format: "bmp", Width: 2, Height: 2, ColorSpace: "rgb", BitDepth: 8, HasAlpha: true, FrameCount: 1}},
+}
+
+func TestProbeImage(t *testing.T) {
+	for _, tt := range probeFixtures {
+		t.Run(tt.file, func(t *testing.T) {
+			probe, err := probeImage(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatalf("probeImage: %v", err)
+			}
+			if *probe != tt.want {
+				t.Errorf("probe = %+v\nwant %+v", *probe, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeImageMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"shorter than a header", []byte("\x89PNG\r\n\x1a\n")},
+		{"unknown format", []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")},
+		{"JPEG without frame header", []byte("\xff\xd8\xff\xe0\x00\x04\x00\x00\xff\xda\x00\x02")},
+		{"JPEG segment length below 2", []byte("\xff\xd8\xff\xe0\x00\x01\x00\x00\x00\x00\x00\x00")},
+		{"JPEG garbage between segments", []byte("\xff\xd8\xff\xe0\x00\x02\x00\xff\xc0\x00\x0b\x08")},
+		{"JPEG frame header cut off", []byte("\xff\xd8\xff\xc0\x00\x11\x08\x00\x10\x00")},
+		{"JPEG with 2 components", []byte("\xff\xd8\xff\xc0\x00\x08\x08\x00\x10\x00\x10\x02")},
+		{"JPEG of zero height", []byte("\xff\xd8\xff\xc0\x00\x08\x08\x00\x00\x00\x10\x03")},
+		{"PNG without IHDR", pngChunks("IDAT", nil)},
+		{"PNG with IHDR later", append(pngChunks("sRGB", []byte{0}), pngChunks("IHDR", pngIHDR(1, 1, 8, 2))[8:]...)},
+		{"PNG IHDR cut off", pngChunks("IHDR", []byte{0, 0, 0, 1})},
+		{"PNG with invalid color type", pngChunks("IHDR", pngIHDR(1, 1, 8, 5))},
+		{"PNG of zero width", append(pngChunks("IHDR", pngIHDR(0, 1, 8, 2)), pngChunks("IEND", nil)[8:]...)},
+		{"PNG chunk over the limit", append(pngChunks("IHDR", pngIHDR(1, 1, 8, 2)), "\xff\xff\xff\xffiCCP"...)},
+		{"GIF without frames", []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00\x3b")},
+		{"GIF with an invalid block", []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00\x99")},
+		{"GIF color table cut off", []byte("GIF89a\x01\x00\x01\x00\x87\x00\x00\x00\x00\x00")},
+		{"GIF graphic control without terminator", []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00\x21\xf9\x04\x01\x00\x00\x00\x07")},
+		{"WebP without image", []byte("RIFF\x04\x00\x00\x00WEBP")},
+		{"WebP with invalid VP8 start code", []byte("RIFF\x12\x00\x00\x00WEBPVP8 \x0a\x00\x00\x000\x01\x00\x00\x00\x00\x14\x00\x0a\x00")},
+		{"WebP with invalid VP8L signature", []byte("RIFF\x0e\x00\x00\x00WEBPVP8L\x05\x00\x00\x00\x00\x00\x00\x00\x00\x00")},
+		{"WebP VP8X cut off", []byte("RIFF\x0e\x00\x00\x00WEBPVP8X\x04\x00\x00\x00\x10\x00\x00\x00")},
+		{"WebP chunk over the limit", []byte("RIFF\x0e\x00\x00\x00WEBPVP8X\xff\xff\xff\xff\x10\x00\x00\x00")},
+		{"MP4", []byte("\x00\x00\x00\x14ftypisom\x00\x00\x02\x00isomiso2")},
+		{"AVIF box past the end", []byte("\x00\x00\x00\x14ftypavif\x00\x00\x00\x00mif1\x00\x00\x01\x00meta")},
+		{"AVIF box smaller than its header", []byte("\x00\x00\x00\x14ftypavif\x00\x00\x00\x00mif1\x00\x00\x00\x04meta")},
+		{"AVIF large box past the end", []byte("\x00\x00\x00\x01ftyp\xff\xff\xff\xff\xff\xff\xff\xffavif")},
+		{"AVIF without dimensions", []byte("\x00\x00\x00\x10ftypavif\x00\x00\x00\x00")},
+		{"TIFF header only", []byte("II*\x00\x08\x00\x00\x00\x00\x00\x00\x00")},
+		{"TIFF with IFD past the end", []byte("MM\x00*\x7f\xff\xff\xff\x00\x00\x00\x00")},
+		{"BMP with OS/2 header", append([]byte("BM\x00\x00\x00\x00\x00\x00\x00\x00\x1a\x00\x00\x00\x0c\x00\x00\x00"), make([]byte, 12)...)},
+		{"BMP cut off", []byte("BM\x00\x00\x00\x00\x00\x00\x00\x00\x36\x00\x00\x00\x28\x00")},
+		{"BMP of negative width", bmpHeader(-4, 4, 24)},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "image")
+			if err := os.WriteFile(path, tt.data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			probe, err := probeImage(path)
+			if !errors.Is(err, ErrUnsupportedImage) {
+				t.Errorf("probeImage = %+v, %v; want %v", probe, err, ErrUnsupportedImage)
+			}
+		})
+	}
+}
+
+// Every prefix of a fixture is rejected or probed without panicking, as
+// from a cut off upload
+func TestProbeImageTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "image")
+	for _, tt := range probeFixtures {
+		data, err := os.ReadFile(filepath.Join("testdata", tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for n := 0; n < len(data); n++ {
+			if err := os.WriteFile(path, data[:n], 0o600); err != nil {
+				t.Fatal(err)
+			}
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Fatalf("%s cut at %d bytes: panic: %v", tt.file, n, r)
+					}
+				}()
+				checkProbe(t, path)
+			}()
+		}
+	}
+}
+
+// A chunk size field larger than the file must not allocate the size
+func TestProbeImageBoundsAllocation(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"PNG", append(pngChunks("IHDR", pngIHDR(1, 1, 8, 2)), "\x00\xff\xff\xfciCCP"...)},
+		{"WebP", []byte("RIFF\x00\x00\x00\x00WEBPICCP\x00\x00\x00\x01acsp")},
+	}
+	path := filepath.Join(t.TempDir(), "image")
+	for _, tt := range tests {
+		if err := os.WriteFile(path, tt.data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		if _, err := probeImage(path); !errors.Is(err, ErrUnsupportedImage) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, ErrUnsupportedImage)
+		}
+		runtime.ReadMemStats(&after)
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+			t.Errorf("%s: allocated %d bytes for a %d byte file", tt.name, allocated, len(tt.data))
+		}
+	}
+}
+
+func TestICCDescription(t *testing.T) {
+	profile := func(tag []byte) []byte {
+		icc := make([]byte, 144)
+		copy(icc[36:], "acsp")
+		binary.BigEndian.PutUint32(icc[128:], 1)
+		copy(icc[132:], "desc")
+		binary.BigEndian.PutUint32(icc[136:], 144)
+		binary.BigEndian.PutUint32(icc[140:], uint32(len(tag)))
+		return append(icc, tag...)
+	}
+	desc := func(text string, n uint32) []byte {
+		tag := binary.BigEndian.AppendUint32([]byte("desc\x00\x00\x00\x00"), n)
+		return append(tag, text...)
+	}
+	mluc := []byte("mluc\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x0cenUS\x00\x00\x00\x04\x00\x00\x00\x1c\x00P\x003")
+
+	tests := []struct {
+		name string
+		icc  []byte
+		want string
+	}{
+		{"desc", profile(desc("sRGB IEC61966-2.1\x00", 18)), "sRGB IEC61966-2.1"},
+		{"mluc", profile(mluc), "P3"},
+		{"too short", []byte("acsp"), ""},
+		{"no signature", append(make([]byte, 132), "desc"...), ""},
+		{"text past the tag", profile(desc("sRGB", 0xffffffff)), ""},
+		{"empty text", profile(desc("", 0)), ""},
+		{"tag past the end", profile(desc("sRGB\x00", 5))[:150], ""},
+		{"mluc text past the tag", profile(append(mluc[:20:20], "\x00\x00\x00\x04\xff\xff\xff\xff"...)), ""},
+		{"unknown tag type", profile([]byte("text\x00\x00\x00\x00sRGB")), ""},
+	}
+	for _, tt := range tests {
+		if got := iccDescription(tt.icc); got != tt.want {
+			t.Errorf("%s: iccDescription = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func FuzzProbeImage(f *testing.F) {
+	for _, tt := range probeFixtures {
+		data, err := os.ReadFile(filepath.Join("testdata", tt.file))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	path := filepath.Join(f.TempDir(), "image")
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		checkProbe(t, path)
+	})
+}
+
+// checkProbe probes a file that may be corrupt: it must fail with
+// ErrUnsupportedImage or describe an image
+func checkProbe(t *testing.T, path string) {
+	t.Helper()
+	probe, err := probeImage(path)
+	if err != nil {
+		if !errors.Is(err, ErrUnsupportedImage) {
+			t.Fatalf("err = %v, want %v", err, ErrUnsupportedImage)
+		}
+		return
+	}
+	if probe.Format == "" || probe.Width <= 0 || probe.Height <= 0 || probe.FrameCount < 0 {
+		t.Fatalf("invalid probe %+v", *probe)
+	}
+}
+
+// pngChunks is a PNG signature followed by one chunk (without checking CRCs,
+// as probePNG does not)
+func pngChunks(typ string, data []byte) []byte {
+	out := binary.BigEndian.AppendUint32([]byte("\x89PNG\r\n\x1a\n"), uint32(len(data)))
+	out = append(out, typ...)
+	out = append(out, data...)
+	return append(out, 0, 0, 0, 0)
+}
+
+func pngIHDR(width, height uint32, depth, colorType byte) []byte {
+	ihdr := binary.BigEndian.AppendUint32(nil, width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	return append(ihdr, depth, colorType, 0, 0, 0)
+}
+
+func bmpHeader(width, height int32, bits uint16) []byte {
+	header := []byte("BM\x00\x00\x00\x00\x00\x00\x00\x00\x36\x00\x00\x00\x28\x00\x00\x00")
+	header = binary.LittleEndian.AppendUint32(header, uint32(width))
+	header = binary.LittleEndian.AppendUint32(header, uint32(height))
+	header = binary.LittleEndian.AppendUint16(header, 1)
+	header = binary.LittleEndian.AppendUint16(header, bits)
+	return append(header, make([]byte, 24)...)
+}
@@ -1,25 +1,39 @@
 package processor
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 
+	"github.com/ancill/mediapod/services/media-worker/internal/events"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/minio/minio-go/v7"
 	"github.com/rs/zerolog/log"
 )
 
 type Processor struct {
-	db          *pgxpool.Pool
+	db          database
 	minio       *minio.Client
+	events      *events.Publisher
 	tempDir     string
 	minioConfig MinIOConfig
 }
 
+// database is the part of the connection pool jobs use
+type database interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
 type MinIOConfig struct {
 	BucketOriginals string
 	BucketVOD       string
@@ -30,7 +44,7 @@ type Config struct {
 	TempDir string
 }
 
-func New(db *pgxpool.Pool, minioClient *minio.Client, cfg *Config) *Processor {
+func New(db *pgxpool.Pool, minioClient *minio.Client, publisher *events.Publisher, cfg *Config) *Processor {
 	// Extract MinIO config from main config
 	minioConfig := MinIOConfig{
 		BucketOriginals: "media-originals",
@@ -46,6 +60,7 @@ func New(db *pgxpool.Pool, minioClient *minio.Client, cfg *Config) *Processor {
 	return &Processor{
 		db:          db,
 		minio:       minioClient,
+		events:      publisher,
 		tempDir:     tempDir,
 		minioConfig: minioConfig,
 	}
@@ -56,12 +71,13 @@ func (p *Processor) TranscodeVideo(ctx context.Context, assetID uuid.UUID) error
 	log.Info().Str("asset_id", assetID.String()).Msg("Starting video transcode")
 
 	// Get asset info
-	var bucket, objectKey, filename string
-	err := p.db.QueryRow(ctx, "SELECT bucket, object_key, filename FROM assets WHERE id = $1", assetID).
-		Scan(&bucket, &objectKey, &filename)
+	var bucket, objectKey, filename, visibility string
+	err := p.db.QueryRow(ctx, "SELECT bucket, object_key, filename, visibility FROM assets WHERE id = $1", assetID).
+		Scan(&bucket, &objectKey, &filename, &visibility)
 	if err != nil {
 		return fmt.Errorf("failed to get asset info: %w", err)
 	}
+	prefix := renditionPrefix(assetID, visibility)
 
 	// Create working directory
 	workDir := filepath.Join(p.tempDir, assetID.String())
@@ -102,10 +118,17 @@ func (p *Processor) TranscodeVideo(ctx context.Context, assetID uuid.UUID) error
 		return fmt.Errorf("failed to create HLS directory: %w", err)
 	}
 
-	if err := p.transcodeToHLS(inputPath, hlsDir); err != nil {
+	var duration float64
+	if metadata != nil {
+		duration = metadata.Duration
+	}
+	progress := p.newProgressReporter(ctx, assetID)
+
+	if err := p.transcodeToHLS(inputPath, hlsDir, duration, progress.report); err != nil {
 		// Mark as failed
-		p.db.Exec(ctx, "UPDATE assets SET state = 'failed' WHERE id = $1", assetID)
-		return fmt.Errorf("failed to transcode: %w", err)
+		err = fmt.Errorf("failed to transcode: %w", err)
+		p.markFailed(ctx, assetID, err)
+		return err
 	}
 
 	// Generate poster/thumbnail
@@ -114,21 +137,35 @@ func (p *Processor) TranscodeVideo(ctx context.Context, assetID uuid.UUID) error
 		log.Warn().Err(err).Msg("Failed to generate poster")
 	} else {
 		// Upload poster
-		posterKey := fmt.Sprintf("%s/poster.jpg", assetID.String())
+		posterKey := prefix + "poster.jpg"
 		if err := p.uploadFile(ctx, p.minioConfig.BucketThumbs, posterKey, posterPath, "image/jpeg"); err != nil {
 			log.Warn().Err(err).Msg("Failed to upload poster")
 		}
+
+		// Saved before the asset turns ready, so its ready event carries it
+		placeholder, palette, err := analyzeImage(posterPath, workDir)
+		if err == nil {
+			err = p.savePlaceholder(ctx, assetID, placeholder, palette)
+		}
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to generate placeholder")
+		}
 	}
 
 	// Upload HLS files to MinIO
-	if err := p.uploadDirectory(ctx, hlsDir, p.minioConfig.BucketVOD, assetID.String()+"/hls"); err != nil {
-		p.db.Exec(ctx, "UPDATE assets SET state = 'failed' WHERE id = $1", assetID)
-		return fmt.Errorf("failed to upload HLS files: %w", err)
+	if err := p.uploadDirectory(ctx, hlsDir, p.minioConfig.BucketVOD, prefix+"hls"); err != nil {
+		err = fmt.Errorf("failed to upload HLS files: %w", err)
+		p.markFailed(ctx, assetID, err)
+		return err
+	}
+
+	if err := p.settleRenditions(ctx, assetID, prefix); err != nil {
+		p.markFailed(ctx, assetID, err)
+		return err
 	}
 
 	// Mark asset as ready
-	_, err = p.db.Exec(ctx, "UPDATE assets SET state = 'ready' WHERE id = $1", assetID)
-	if err != nil {
+	if err := p.markReady(ctx, assetID); err != nil {
 		return fmt.Errorf("failed to update asset state: %w", err)
 	}
 
@@ -144,6 +181,77 @@ func (p *Processor) GenerateThumbnail(ctx context.Context, assetID uuid.UUID) er
 	return nil
 }
 
+// AnalyzeImage probes an uploaded image, reads its metadata and computes its
+// placeholder and color palette, then marks it ready. Errors fail the asset,
+// which has been processing since the job was queued, except metadata and
+// placeholder errors, which only warn.
+func (p *Processor) AnalyzeImage(ctx context.Context, assetID uuid.UUID) (err error) {
+	log.Info().Str("asset_id", assetID.String()).Msg("Analyzing image")
+	defer func() {
+		if err != nil {
+			p.markFailed(ctx, assetID, err)
+		}
+	}()
+
+	var bucket, objectKey, filename, kind string
+	err = p.db.QueryRow(ctx, "SELECT bucket, object_key, filename, kind FROM assets WHERE id = $1", assetID).
+		Scan(&bucket, &objectKey, &filename, &kind)
+	if err != nil {
+		return fmt.Errorf("failed to get asset info: %w", err)
+	}
+	if kind != "image" {
+		return fmt.Errorf("only images are analyzed, not %s assets", kind)
+	}
+
+	workDir := filepath.Join(p.tempDir, assetID.String())
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return fmt.Errorf("failed to create work directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, "input"+filepath.Ext(filename))
+	if err := p.downloadFile(ctx, bucket, objectKey, inputPath); err != nil {
+		return fmt.Errorf("failed to download file: %w", err)
+	}
+
+	probe, err := probeImage(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to probe image: %w", err)
+	}
+
+	// Broken metadata does not keep the image from being delivered
+	meta, err := readImageMetadata(inputPath)
+	if err != nil {
+		log.Warn().Err(err).Str("asset_id", assetID.String()).Msg("Failed to read image metadata")
+	} else if err := p.saveImageMetadata(ctx, assetID, meta); err != nil {
+		return err
+	}
+
+	orientation := 0
+	if meta != nil {
+		orientation = meta.Orientation
+	}
+	if err := p.saveImageProbe(ctx, assetID, probe, orientation); err != nil {
+		return err
+	}
+
+	placeholder, palette, err := analyzeImage(inputPath, workDir)
+	if err == nil {
+		err = p.savePlaceholder(ctx, assetID, placeholder, palette)
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("asset_id", assetID.String()).Msg("Failed to generate placeholder")
+	}
+
+	if err := p.markReady(ctx, assetID); err != nil {
+		return fmt.Errorf("failed to update asset state: %w", err)
+	}
+
+	log.Info().Str("asset_id", assetID.String()).Str("format", probe.Format).
+		Int("width", probe.Width).Int("height", probe.Height).Msg("Image analysis completed")
+	return nil
+}
+
 // ExtractMetadata extracts metadata from media files
 func (p *Processor) ExtractMetadata(ctx context.Context, assetID uuid.UUID) error {
 	log.Info().Str("asset_id", assetID.String()).Msg("Extracting metadata")
@@ -184,7 +292,17 @@ func (p *Processor) ExtractMetadata(ctx context.Context, assetID uuid.UUID) erro
 		return err
 	}
 
-	// For images, could use ffprobe or imagemagick here
+	if kind == "image" {
+		meta, err := readImageMetadata(inputPath)
+		if err != nil {
+			return err
+		}
+		if err := p.saveImageMetadata(ctx, assetID, meta); err != nil {
+			return err
+		}
+		return p.recordUpdate(ctx, assetID)
+	}
+
 	return nil
 }
 
@@ -196,6 +314,20 @@ type VideoMetadata struct {
 	Bitrate  int
 }
 
+// ffprobeOutput is the subset of ffprobe's JSON output we use
+type ffprobeOutput struct {
+	Format struct {
+		Duration string `json:"duration"`
+		BitRate  string `json:"bit_rate"`
+	} `json:"format"`
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+		CodecName string `json:"codec_name"`
+		Width     int    `json:"width"`
+		Height    int    `json:"height"`
+	} `json:"streams"`
+}
+
 func (p *Processor) extractVideoMetadata(inputPath string) (*VideoMetadata, error) {
 	// Use ffprobe to extract metadata
 	cmd := exec.Command("ffprobe",
@@ -206,23 +338,35 @@ func (p *Processor) extractVideoMetadata(inputPath string) (*VideoMetadata, erro
 		inputPath,
 	)
 
-	_, err := cmd.Output()
+	output, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("ffprobe failed: %w", err)
 	}
 
-	// Parse JSON output (simplified - in production use proper JSON parsing)
-	// For now, return dummy data
-	return &VideoMetadata{
-		Width:    1920,
-		Height:   1080,
-		Duration: 120.5,
-		Codec:    "h264",
-		Bitrate:  5000000,
-	}, nil
+	var probe ffprobeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	metadata := &VideoMetadata{}
+	metadata.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	metadata.Bitrate, _ = strconv.Atoi(probe.Format.BitRate)
+
+	for _, stream := range probe.Streams {
+		if stream.CodecType == "video" {
+			metadata.Width = stream.Width
+			metadata.Height = stream.Height
+			metadata.Codec = stream.CodecName
+			break
+		}
+	}
+
+	return metadata, nil
 }
 
-func (p *Processor) transcodeToHLS(inputPath, outputDir string) error {
+// transcodeToHLS runs FFmpeg and reports the percentage of duration encoded
+// to onProgress (not called when duration is unknown)
+func (p *Processor) transcodeToHLS(inputPath, outputDir string, duration float64, onProgress func(float64)) error {
 	// Check if video has audio stream
 	hasAudio := p.videoHasAudio(inputPath)
 	log.Debug().Bool("has_audio", hasAudio).Str("input", inputPath).Msg("Detected audio presence")
@@ -231,6 +375,8 @@ func (p *Processor) transcodeToHLS(inputPath, outputDir string) error {
 	// Ladder: 1080p/5M, 720p/3M, 480p/1.5M, 360p/800k
 
 	args := []string{
+		"-progress", "pipe:1",
+		"-nostats",
 		"-i", inputPath,
 		"-c:v", "libx264",
 		"-preset", "fast",
@@ -294,9 +440,21 @@ func (p *Processor) transcodeToHLS(inputPath, outputDir string) error {
 
 	log.Debug().Str("cmd", cmd.String()).Msg("Running FFmpeg")
 
-	output, err := cmd.CombinedOutput()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		log.Error().Str("output", string(output)).Msg("FFmpeg failed")
+		return fmt.Errorf("failed to attach to ffmpeg output: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	parseFFmpegProgress(stdout, duration, onProgress)
+
+	if err := cmd.Wait(); err != nil {
+		log.Error().Str("output", stderr.String()).Msg("FFmpeg failed")
 		return fmt.Errorf("ffmpeg failed: %w", err)
 	}
 
@@ -0,0 +1,284 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// unavailablePool is a pool whose connection attempts all fail; it counts
+// them, so that the queries tried can be told apart without a database
+func unavailablePool(t *testing.T) (*pgxpool.Pool, *atomic.Int32) {
+	t.Helper()
+	config, err := pgxpool.ParseConfig("postgres://worker@localhost/mediapod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var attempts atomic.Int32
+	config.BeforeConnect = func(context.Context, *pgx.ConnConfig) error {
+		attempts.Add(1)
+		return errors.New("database unavailable")
+	}
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	return pool, &attempts
+}
+
+// A failed job must not leave its image processing: after the asset lookup
+// fails, AnalyzeImage still tries to mark the asset failed, also when the
+// job ran out of time
+func TestAnalyzeImageMarksFailed(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		// connection attempts: the lookup's, then markFailed's
+		attempts int32
+	}{
+		{"lookup fails", context.Background(), 2},
+		{"job timed out", canceled, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, attempts := unavailablePool(t)
+			p := New(pool, nil, nil, &Config{TempDir: t.TempDir()})
+
+			err := p.AnalyzeImage(tt.ctx, uuid.New())
+			if err == nil || !strings.Contains(err.Error(), "failed to get asset info") {
+				t.Fatalf("err = %v, want the lookup error", err)
+			}
+			if got := attempts.Load(); got != tt.attempts {
+				t.Errorf("connection attempts = %d, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+// fakeDB answers each query with the row registered under the start of its
+// statement, or a row of NULLs, and accepts every write, recording the
+// statements run
+type fakeDB struct {
+	rows map[string][]any
+
+	mu    sync.Mutex
+	execs []fakeExec
+}
+
+type fakeExec struct {
+	sql  string // whitespace collapsed
+	args []any
+}
+
+func (db *fakeDB) Begin(context.Context) (pgx.Tx, error) {
+	return fakeTx{db: db}, nil
+}
+
+func (db *fakeDB) Exec(_ context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.execs = append(db.execs, fakeExec{sql: strings.Join(strings.Fields(sql), " "), args: args})
+	return pgconn.CommandTag{}, nil
+}
+
+func (db *fakeDB) QueryRow(_ context.Context, sql string, _ ...any) pgx.Row {
+	sql = strings.Join(strings.Fields(sql), " ")
+	for prefix, row := range db.rows {
+		if strings.HasPrefix(sql, prefix) {
+			return fakeRow(row)
+		}
+	}
+	return fakeRow(nil)
+}
+
+// exec returns the arguments of the first statement starting with prefix
+func (db *fakeDB) exec(prefix string) ([]any, bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, e := range db.execs {
+		if strings.HasPrefix(e.sql, prefix) {
+			return e.args, true
+		}
+	}
+	return nil, false
+}
+
+type fakeTx struct {
+	pgx.Tx
+	db *fakeDB
+}
+
+func (tx fakeTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return tx.db.Exec(ctx, sql, args...)
+}
+
+func (tx fakeTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return tx.db.QueryRow(ctx, sql, args...)
+}
+
+func (fakeTx) Commit(context.Context) error   { return nil }
+func (fakeTx) Rollback(context.Context) error { return nil }
+
+// fakeRow scans its values into the leading destinations; nil values and
+// missing ones leave them untouched, as for NULL
+type fakeRow []any
+
+func (r fakeRow) Scan(dest ...any) error {
+	for i, v := range r {
+		if v != nil {
+			reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(v))
+		}
+	}
+	return nil
+}
+
+// fakeStorage is an S3 endpoint serving every object and recording the
+// requests made to it
+func fakeStorage(t *testing.T) (*minio.Client, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+
+		w.Header().Set("ETag", `"0123456789abcdef"`)
+		if r.Method == http.MethodPut {
+			io.Copy(io.Discard, r.Body)
+			return
+		}
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Header().Set("Content-Length", "5")
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		io.WriteString(w, "video")
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := minio.New(strings.TrimPrefix(server.URL, "http://"), &minio.Options{
+		Creds:  credentials.NewStaticV4("worker", "secret", ""),
+		Region: "us-east-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(requests)
+	}
+}
+
+// fakeFFmpeg puts ffmpeg and ffprobe stand-ins first on PATH. ffprobe finds
+// nothing; ffmpeg writes an HLS ladder, or the photo fixture as the poster.
+func fakeFFmpeg(t *testing.T) {
+	t.Helper()
+	poster, err := filepath.Abs(filepath.Join("testdata", "photo.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	scripts := map[string]string{
+		"ffprobe": "#!/bin/sh\nexit 1\n",
+		"ffmpeg": `#!/bin/sh
+for last; do :; done
+case "$*" in
+*-vframes*)
+	cp "` + poster + `" "$last"
+	exit
+	;;
+esac
+hls=$(dirname "$(dirname "$last")")
+for v in 0 1; do
+	mkdir -p "$hls/v$v"
+	echo "#EXTM3U" > "$hls/v$v/playlist.m3u8"
+	echo segment > "$hls/v$v/seg-000.ts"
+done
+echo "#EXTM3U" > "$hls/master.m3u8"
+`,
+	}
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// Renditions are uploaded where the API serves them from: under the public
+// prefix unless the asset is private
+func TestTranscodeVideoRenditionKeys(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the FFmpeg stand-ins are shell scripts")
+	}
+	fakeFFmpeg(t)
+
+	for _, visibility := range []string{"public", "private"} {
+		t.Run(visibility, func(t *testing.T) {
+			assetID := uuid.New()
+			prefix := assetID.String() + "/"
+			if visibility == "public" {
+				prefix = "public/" + prefix
+			}
+
+			client, requests := fakeStorage(t)
+			db := &fakeDB{rows: map[string][]any{
+				"SELECT bucket, object_key, filename, visibility FROM assets": {"media-originals", "uploads/clip.mp4", "clip.mp4", visibility},
+				"SELECT visibility FROM assets":                               {visibility},
+			}}
+			p := New(nil, client, nil, &Config{TempDir: t.TempDir()})
+			p.db = db
+
+			if err := p.TranscodeVideo(context.Background(), assetID); err != nil {
+				t.Fatalf("TranscodeVideo: %v", err)
+			}
+
+			var uploads []string
+			for _, request := range requests() {
+				if key, ok := strings.CutPrefix(request, "PUT /"); ok {
+					uploads = append(uploads, key)
+				}
+			}
+			slices.Sort(uploads)
+			want := []string{
+				"media-thumbs/" + prefix + "poster.jpg",
+				"media-vod/" + prefix + "hls/master.m3u8",
+				"media-vod/" + prefix + "hls/v0/playlist.m3u8",
+				"media-vod/" + prefix + "hls/v0/seg-000.ts",
+				"media-vod/" + prefix + "hls/v1/playlist.m3u8",
+				"media-vod/" + prefix + "hls/v1/seg-000.ts",
+			}
+			if !slices.Equal(uploads, want) {
+				t.Errorf("uploads = %q\nwant %q", uploads, want)
+			}
+
+			if args, _ := db.exec("UPDATE assets SET state"); len(args) == 0 || args[0] != "ready" {
+				t.Errorf("asset not marked ready: %v", args)
+			}
+		})
+	}
+}
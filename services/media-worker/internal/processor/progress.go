@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// progressInterval limits how often progress events are published
+const progressInterval = time.Second
+
+// progressReporter publishes processing progress, throttled so a long
+// transcode does not flood subscribers
+type progressReporter struct {
+	p           *Processor
+	ctx         context.Context
+	assetID     string
+	lastSent    time.Time
+	lastPercent float64
+}
+
+func (p *Processor) newProgressReporter(ctx context.Context, assetID uuid.UUID) *progressReporter {
+	return &progressReporter{p: p, ctx: ctx, assetID: assetID.String(), lastPercent: -1}
+}
+
+func (r *progressReporter) report(percent float64) {
+	if r.p.events == nil {
+		return
+	}
+	if percent <= r.lastPercent || (percent < 100 && time.Since(r.lastSent) < progressInterval) {
+		return
+	}
+
+	if err := r.p.events.Progress(r.ctx, r.assetID, percent); err != nil {
+		log.Warn().Err(err).Str("asset_id", r.assetID).Msg("Failed to publish progress")
+		return
+	}
+	r.lastSent = time.Now()
+	r.lastPercent = percent
+}
+
+// parseFFmpegProgress reads `ffmpeg -progress` key=value output until EOF,
+// reporting the percentage of duration (in seconds) processed so far
+func parseFFmpegProgress(output io.Reader, duration float64, onProgress func(float64)) {
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !found || duration <= 0 || onProgress == nil {
+			continue
+		}
+
+		// out_time_ms is also in microseconds (an FFmpeg quirk)
+		if key != "out_time_us" && key != "out_time_ms" {
+			continue
+		}
+		us, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || us < 0 {
+			continue
+		}
+
+		percent := float64(us) / 1e6 / duration * 100
+		if percent > 100 {
+			percent = 100
+		}
+		onProgress(float64(int(percent*10)) / 10)
+	}
+}
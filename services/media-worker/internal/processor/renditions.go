@@ -0,0 +1,63 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+)
+
+// publicPrefix is the only prefix of the VOD and thumbs buckets that is
+// anonymously readable (must match the API)
+const publicPrefix = "public/"
+
+// renditionPrefix is the key prefix of an asset's HLS files and poster.
+// Renditions of private assets stay outside publicPrefix, where only the
+// API's presigned URLs reach them.
+func renditionPrefix(assetID uuid.UUID, visibility string) string {
+	if visibility == "private" {
+		return assetID.String() + "/"
+	}
+	return publicPrefix + assetID.String() + "/"
+}
+
+// settleRenditions moves renditions uploaded under prefix to where the
+// asset's current visibility puts them, for visibility changes made while
+// the asset was processing
+func (p *Processor) settleRenditions(ctx context.Context, assetID uuid.UUID, prefix string) error {
+	var visibility string
+	if err := p.db.QueryRow(ctx, "SELECT visibility FROM assets WHERE id = $1", assetID).Scan(&visibility); err != nil {
+		return fmt.Errorf("failed to get asset visibility: %w", err)
+	}
+
+	target := renditionPrefix(assetID, visibility)
+	if target == prefix {
+		return nil
+	}
+	for _, bucket := range []string{p.minioConfig.BucketVOD, p.minioConfig.BucketThumbs} {
+		if err := p.movePrefix(ctx, bucket, prefix, target); err != nil {
+			return fmt.Errorf("failed to move renditions in %s: %w", bucket, err)
+		}
+	}
+	return nil
+}
+
+// movePrefix moves every object whose key starts with from to the same key
+// under to, copying before deleting so an interrupted move can be repeated
+func (p *Processor) movePrefix(ctx context.Context, bucket, from, to string) error {
+	for object := range p.minio.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: from, Recursive: true}) {
+		if object.Err != nil {
+			return object.Err
+		}
+		dst := minio.CopyDestOptions{Bucket: bucket, Object: to + strings.TrimPrefix(object.Key, from)}
+		if _, err := p.minio.CopyObject(ctx, dst, minio.CopySrcOptions{Bucket: bucket, Object: object.Key}); err != nil {
+			return err
+		}
+		if err := p.minio.RemoveObject(ctx, bucket, object.Key, minio.RemoveObjectOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
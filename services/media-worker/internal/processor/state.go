@@ -0,0 +1,203 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ancill/mediapod/services/media-worker/internal/webhook"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// assetEventData is the asset snapshot sent with worker-emitted webhook
+// events, in the shape of the API's: the asset as GetAsset returns it, less
+// the urls, which would expire before late retries are delivered
+type assetEventData struct {
+	ID          string          `json:"id"`
+	Kind        string          `json:"kind"`
+	State       string          `json:"state"`
+	Visibility  string          `json:"visibility"`
+	Filename    string          `json:"filename"`
+	MimeType    string          `json:"mimeType"`
+	Size        int64           `json:"size"`
+	Bucket      string          `json:"bucket"`
+	ObjectKey   string          `json:"objectKey"`
+	Width       *int            `json:"width,omitempty"`
+	Height      *int            `json:"height,omitempty"`
+	Duration    *float64        `json:"duration,omitempty"`
+	Image       *ImageInfo      `json:"image,omitempty"`
+	FocalPoint  *FocalPoint     `json:"focalPoint,omitempty"`
+	CropHints   []CropHint      `json:"cropHints,omitempty"`
+	Placeholder *Placeholder    `json:"placeholder,omitempty"`
+	Palette     []PaletteColor  `json:"palette,omitempty"`
+	Metadata    json.RawMessage `json:"metadata,omitempty"`
+	Tags        []string        `json:"tags,omitempty"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// FocalPoint is the point of an image crops keep in view, as fractions of
+// the width and height (set through the API)
+type FocalPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// CropHint is a region of an image, as fractions of its width and height,
+// to crop to for outputs of its aspect ratio
+type CropHint struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// markFailedTimeout bounds marking an asset failed once its job is over
+const markFailedTimeout = 10 * time.Second
+
+// markReady sets an asset to ready and notifies webhook subscribers
+func (p *Processor) markReady(ctx context.Context, assetID uuid.UUID) error {
+	return p.setState(ctx, assetID, "ready", webhook.EventAssetReady, "")
+}
+
+// markFailed sets an asset to failed and notifies webhook subscribers.
+// Errors are logged since the caller is already reporting a failure. It
+// runs even when the job's context is done, as after a timeout.
+func (p *Processor) markFailed(ctx context.Context, assetID uuid.UUID, cause error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), markFailedTimeout)
+	defer cancel()
+
+	if err := p.setState(ctx, assetID, "failed", webhook.EventAssetFailed, cause.Error()); err != nil {
+		log.Error().Err(err).Str("asset_id", assetID.String()).Msg("Failed to mark asset as failed")
+	}
+}
+
+// setState updates the asset state, records it in the changefeed and queues
+// the matching webhook event in one transaction, then announces the change to event stream subscribers
+func (p *Processor) setState(ctx context.Context, assetID uuid.UUID, state, event, errMsg string) error {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "UPDATE assets SET state = $1 WHERE id = $2", state, assetID); err != nil {
+		return fmt.Errorf("failed to update asset state: %w", err)
+	}
+
+	data, err := loadAssetEventData(ctx, tx, assetID)
+	if err != nil {
+		return fmt.Errorf("failed to load asset: %w", err)
+	}
+
+	if err := recordChange(ctx, tx, ChangeState, assetID, data); err != nil {
+		return err
+	}
+
+	data.Error = errMsg
+
+	if err := webhook.Emit(ctx, tx, event, assetID, data); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit state change: %w", err)
+	}
+
+	if p.events != nil {
+		if err := p.events.State(ctx, assetID.String(), state, errMsg); err != nil {
+			log.Warn().Err(err).Str("asset_id", assetID.String()).Msg("Failed to publish state change")
+		}
+	}
+
+	return nil
+}
+
+// recordUpdate records a change to the metadata of an asset in the changefeed
+func (p *Processor) recordUpdate(ctx context.Context, assetID uuid.UUID) error {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	data, err := loadAssetEventData(ctx, tx, assetID)
+	if err != nil {
+		return fmt.Errorf("failed to load asset: %w", err)
+	}
+	if err := recordChange(ctx, tx, ChangeUpdate, assetID, data); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit asset update: %w", err)
+	}
+	return nil
+}
+
+func loadAssetEventData(ctx context.Context, tx pgx.Tx, assetID uuid.UUID) (*assetEventData, error) {
+	var data assetEventData
+	var blurHash, thumbHash, dominantColor *string
+	var focalX, focalY *float64
+	var format, colorSpace, colorProfile *string
+	var bitDepth, frameCount *int
+	var hasAlpha *bool
+	var palette, metadata, cropHints []byte
+	err := tx.QueryRow(ctx, `
+		SELECT
+			a.id, a.kind, a.state, a.visibility, a.filename, a.mime_type, a.size_bytes, a.bucket, a.object_key, a.created_at,
+			m.width, m.height, m.duration_seconds, m.focal_x, m.focal_y, m.crop_hints, m.blurhash, m.thumbhash, m.dominant_color, m.palette, m.exif,
+			m.format, m.color_space, m.color_profile, m.bit_depth, m.has_alpha, m.frame_count,
+			ARRAY(SELECT t.tag FROM asset_tags t WHERE t.asset_id = a.id ORDER BY t.tag)
+		FROM assets a
+		LEFT JOIN asset_meta m ON a.id = m.asset_id
+		WHERE a.id = $1
+	`, assetID).Scan(
+		&data.ID, &data.Kind, &data.State, &data.Visibility, &data.Filename, &data.MimeType, &data.Size,
+		&data.Bucket, &data.ObjectKey, &data.CreatedAt, &data.Width, &data.Height, &data.Duration,
+		&focalX, &focalY, &cropHints, &blurHash, &thumbHash, &dominantColor, &palette, &metadata,
+		&format, &colorSpace, &colorProfile, &bitDepth, &hasAlpha, &frameCount, &data.Tags,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if focalX != nil && focalY != nil {
+		data.FocalPoint = &FocalPoint{X: *focalX, Y: *focalY}
+	}
+	if cropHints != nil {
+		if err := json.Unmarshal(cropHints, &data.CropHints); err != nil {
+			return nil, fmt.Errorf("invalid crop hints: %w", err)
+		}
+	}
+	if blurHash != nil && thumbHash != nil && dominantColor != nil {
+		data.Placeholder = &Placeholder{BlurHash: *blurHash, ThumbHash: *thumbHash, DominantColor: *dominantColor}
+	}
+	if format != nil {
+		data.Image = &ImageInfo{Format: *format}
+		if colorSpace != nil {
+			data.Image.ColorSpace = *colorSpace
+		}
+		if colorProfile != nil {
+			data.Image.ColorProfile = *colorProfile
+		}
+		if bitDepth != nil {
+			data.Image.BitDepth = *bitDepth
+		}
+		if hasAlpha != nil {
+			data.Image.HasAlpha = *hasAlpha
+		}
+		if frameCount != nil {
+			data.Image.FrameCount = *frameCount
+		}
+	}
+	data.Metadata = metadata
+	if palette != nil {
+		// Decoding into PaletteColor leaves out the stored CIELAB coordinates
+		if err := json.Unmarshal(palette, &data.Palette); err != nil {
+			return nil, fmt.Errorf("invalid palette: %w", err)
+		}
+	}
+	return &data, nil
+}
@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ancill/mediapod/services/media-worker/internal/webhook"
+	"github.com/google/uuid"
+)
+
+// State changes record the asset as loaded, focus data included, in the
+// changefeed and in the webhook event; only the event carries the failure
+func TestSetStateSnapshots(t *testing.T) {
+	assetID := uuid.New()
+	focalX, focalY := 0.25, 0.75
+	width, height := 4000, 3000
+	row := make([]any, 28)
+	copy(row, []any{
+		assetID.String(), "image", "failed", "public", "photo.jpg", "image/jpeg", int64(1024),
+		"media-originals", "uploads/photo.jpg", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		&width, &height, nil, &focalX, &focalY, []byte(`[{"x":0.1,"y":0.2,"width":0.5,"height":0.5}]`),
+	})
+
+	db := &fakeDB{rows: map[string][]any{"SELECT a.id, a.kind": row}}
+	p := New(nil, nil, nil, nil)
+	p.db = db
+
+	p.markFailed(context.Background(), assetID, errors.New("decode failed"))
+
+	if args, _ := db.exec("UPDATE assets SET state"); len(args) != 2 || args[0] != "failed" || args[1] != assetID {
+		t.Errorf("state update arguments = %v", args)
+	}
+
+	want := assetEventData{
+		ID: assetID.String(), Kind: "image", State: "failed", Visibility: "public",
+		Filename: "photo.jpg", MimeType: "image/jpeg", Size: 1024,
+		Bucket: "media-originals", ObjectKey: "uploads/photo.jpg", CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Width: &width, Height: &height,
+		FocalPoint: &FocalPoint{X: 0.25, Y: 0.75},
+		CropHints:  []CropHint{{X: 0.1, Y: 0.2, Width: 0.5, Height: 0.5}},
+	}
+
+	args, ok := db.exec("INSERT INTO asset_changes")
+	if !ok {
+		t.Fatal("no change recorded")
+	}
+	if args[1] != ChangeState {
+		t.Errorf("change op = %v, want %s", args[1], ChangeState)
+	}
+	if got, want := string(args[2].([]byte)), snapshotJSON(t, want); got != want {
+		t.Errorf("change snapshot = %s\nwant %s", got, want)
+	}
+
+	args, ok = db.exec("INSERT INTO webhook_deliveries")
+	if !ok {
+		t.Fatal("no webhook event queued")
+	}
+	if args[0] != webhook.EventAssetFailed {
+		t.Errorf("event = %v, want %s", args[0], webhook.EventAssetFailed)
+	}
+	var event struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(args[2].([]byte), &event); err != nil {
+		t.Fatal(err)
+	}
+	want.Error = "decode failed"
+	if got, want := string(event.Data), snapshotJSON(t, want); got != want {
+		t.Errorf("event data = %s\nwant %s", got, want)
+	}
+}
+
+func snapshotJSON(t *testing.T, data assetEventData) string {
+	t.Helper()
+	body, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
@@ -0,0 +1,649 @@
+//go:build ignore
+
+// Generates the image fixtures of imagemeta_test.go and probe_test.go. JPEG,
+// PNG and GIF files are encoded by the standard library and given metadata
+// segments and chunks; TIFF and BMP files are written out uncompressed. Go
+// has no WebP, AVIF or HEIF encoder, so those files have complete headers and
+// metadata but placeholder image data.
+//
+// Run with: go run fixtures.go
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"os"
+	"sort"
+	"unicode/utf16"
+)
+
+func main() {
+	files := map[string][]byte{
+		"photo.jpg":     photoJPEG(),
+		"gray.jpg":      grayJPEG(),
+		"exif.png":      exifPNG(),
+		"palette.png":   palettePNG(),
+		"animated.gif":  animatedGIF(),
+		"lossy.webp":    lossyWebP(),
+		"lossless.webp": losslessWebP(),
+		"extended.webp": extendedWebP(),
+		"animated.webp": animatedWebP(),
+		"photo.avif":    photoAVIF(),
+		"sequence.avif": sequenceAVIF(),
+		"photo.heic":    photoHEIC(),
+		"pages.tiff":    pagesTIFF(),
+		"rgb.bmp":       rgbBMP(),
+		"topdown.bmp":   topDownBMP(),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(name, data, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// photoJPEG has EXIF, XMP, IPTC and an ICC profile, as written by a camera
+// and a photo editor
+func photoJPEG() []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 12))
+	for y := 0; y < 12; y++ {
+		for x := 0; x < 16; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 16), uint8(y * 20), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80}); err != nil {
+		log.Fatal(err)
+	}
+
+	exif := tiff{order: binary.BigEndian, ifds: [][]field{
+		{
+			ascii(0x010e, "EXIF caption"),
+			ascii(0x010f, "Canon"),
+			ascii(0x0110, "Canon EOS R5"),
+			short(0x0112, 6),
+			ascii(0x0132, "2024:05:18 09:00:00"),
+			ascii(0x013b, "EXIF artist"),
+			ascii(0x8298, "(c) 2024 Jane Doe"),
+			link(0x8769, 1),
+			link(0x8825, 2),
+		},
+		{
+			rational(0x829a, 1, 250),
+			rational(0x829d, 28, 10),
+			short(0x8827, 400),
+			ascii(0x9003, "2024:05:17 14:30:05"),
+			ascii(0x9011, "+02:00"),
+			rational(0x920a, 50, 1),
+			ascii(0xa433, "Canon"),
+			ascii(0xa434, "RF 50mm F1.8 STM"),
+		},
+		{
+			ascii(1, "N"),
+			rational(2, 48, 1, 51, 1, 296, 10),
+			ascii(3, "E"),
+			rational(4, 2, 1, 17, 1, 402, 10),
+			byteField(5, 0),
+			rational(6, 35, 1),
+		},
+	}}
+	xmp := xmpPacket(`
+  <rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">
+   <dc:title><rdf:Alt><rdf:li xml:lang="x-default">XMP title</rdf:li></rdf:Alt></dc:title>
+   <dc:description><rdf:Alt><rdf:li xml:lang="x-default">XMP caption</rdf:li><rdf:li xml:lang="fr">Légende</rdf:li></rdf:Alt></dc:description>
+   <dc:creator><rdf:Seq><rdf:li>Jane Doe</rdf:li></rdf:Seq></dc:creator>
+   <dc:subject><rdf:Bag><rdf:li>Paris</rdf:li><rdf:li>Eiffel Tower</rdf:li></rdf:Bag></dc:subject>
+  </rdf:Description>`)
+	iptc := concat(
+		dataset(1, 90, "\x1b%G"),
+		dataset(2, 0, "\x00\x04"),
+		dataset(2, 5, "IPTC title"),
+		dataset(2, 25, "paris"),
+		dataset(2, 25, "travel"),
+		dataset(2, 80, "IPTC byline"),
+		dataset(2, 105, "IPTC headline"),
+		dataset(2, 116, "IPTC copyright"),
+		dataset(2, 120, "IPTC caption"),
+	)
+	resources := concat(
+		resource(0x03ed, "ab", make([]byte, 16)),
+		resource(0x0404, "", iptc),
+	)
+
+	return insertJPEGSegments(buf.Bytes(),
+		segment(0xe1, concat([]byte("Exif\x00\x00"), exif.bytes())),
+		segment(0xe1, concat([]byte("http://ns.adobe.com/xap/1.0/\x00"), xmp)),
+		segment(0xe2, concat([]byte("ICC_PROFILE\x00\x01\x01"), iccProfile("Test RGB", false))),
+		segment(0xed, concat([]byte("Photoshop 3.0\x00"), resources)),
+	)
+}
+
+func grayJPEG() []byte {
+	img := image.NewGray(image.Rect(0, 0, 9, 7))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 4)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		log.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// exifPNG has an eXIf chunk and compressed XMP written as attributes
+func exifPNG() []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 32), uint8(y * 32), 64, uint8(255 - x*16)})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		log.Fatal(err)
+	}
+
+	exif := tiff{order: binary.LittleEndian, ifds: [][]field{
+		{
+			ascii(0x010f, "FUJIFILM"),
+			ascii(0x0110, "X-T4"),
+			short(0x0112, 1),
+			link(0x8769, 1),
+			link(0x8825, 2),
+		},
+		{
+			rational(0x829a, 2, 1),
+			rational(0x829d, 4, 1),
+			short(0x8827, 160),
+			rational(0x920a, 23, 1),
+			ascii(0xa433, "FUJIFILM"),
+			ascii(0xa434, "XF23mmF1.4 R"),
+		},
+		{
+			ascii(1, "S"),
+			rational(2, 22, 1, 54, 1, 30, 1),
+			ascii(3, "W"),
+			rational(4, 43, 1, 11, 1, 47, 1),
+			byteField(5, 1),
+			rational(6, 5, 1),
+		},
+	}}
+	xmp := xmpPacket(`
+  <rdf:Description rdf:about=""
+    xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+    xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/"
+    xmp:CreateDate="2023-01-02T03:04:05+01:00"
+    photoshop:Headline="PNG headline"/>`)
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(xmp)
+	zw.Close()
+
+	return insertPNGChunks(buf.Bytes(),
+		chunk("sRGB", []byte{0}),
+		chunk("eXIf", exif.bytes()),
+		chunk("iTXt", concat([]byte("XML:com.adobe.xmp\x00\x01\x00\x00\x00"), compressed.Bytes())),
+	)
+}
+
+// palettePNG has a transparent palette entry, written as tRNS
+func palettePNG() []byte {
+	img := image.NewPaletted(image.Rect(0, 0, 5, 3), color.Palette{color.NRGBA{}, color.NRGBA{255, 0, 0, 255}})
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i % 2)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		log.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func animatedGIF() []byte {
+	palette := color.Palette{color.RGBA{}, color.RGBA{0, 0, 255, 255}, color.RGBA{255, 255, 0, 255}}
+	anim := &gif.GIF{LoopCount: 0}
+	for i := 0; i < 2; i++ {
+		frame := image.NewPaletted(image.Rect(0, 0, 4, 3), palette)
+		for j := range frame.Pix {
+			frame.Pix[j] = uint8((i + j) % 3)
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		log.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func lossyWebP() []byte {
+	frame := []byte{0x30, 0x01, 0x00, 0x9d, 0x01, 0x2a, 20, 0, 10, 0, 0, 0, 0, 0}
+	return riff(chunk32("VP8 ", frame))
+}
+
+func losslessWebP() []byte {
+	return riff(chunk32("VP8L", vp8l(30, 15, true)))
+}
+
+// extendedWebP has an ICC profile, EXIF and XMP; the EXIF chunk has an odd
+// length and is padded
+func extendedWebP() []byte {
+	exif := tiff{order: binary.BigEndian, ifds: [][]field{
+		{
+			ascii(0x010f, "Google"),
+			ascii(0x0110, "Pixel 8"),
+			link(0x8769, 1),
+		},
+		{
+			ascii(0x9003, "2024:02:29 23:59:59"),
+		},
+	}}.bytes()
+	exif = append(exif, 'x')
+	xmp := xmpPacket(`
+  <rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">
+   <dc:subject><rdf:Bag><rdf:li>Dog</rdf:li><rdf:li>dog</rdf:li><rdf:li>Beach</rdf:li></rdf:Bag></dc:subject>
+  </rdf:Description>`)
+	return riff(
+		chunk32("VP8X", vp8x(0x3c, 640, 480)),
+		chunk32("ICCP", iccProfile("Display P3", true)),
+		chunk32("VP8L", vp8l(640, 480, true)),
+		chunk32("EXIF", exif),
+		chunk32("XMP ", xmp),
+	)
+}
+
+func animatedWebP() []byte {
+	frames := [][]byte{chunk32("VP8X", vp8x(0x12, 64, 48)), chunk32("ANIM", make([]byte, 6))}
+	for i := 0; i < 3; i++ {
+		header := make([]byte, 16)
+		frames = append(frames, chunk32("ANMF", concat(header, chunk32("VP8L", vp8l(64, 48, true)), []byte{1})))
+	}
+	return riff(frames...)
+}
+
+// photoAVIF has a thumbnail before the primary image, 10-bit depth, an
+// nclx Display P3 color box and an alpha plane
+func photoAVIF() []byte {
+	return concat(
+		box("ftyp", []byte("avif\x00\x00\x00\x00mif1avifmiaf")),
+		fullBox("meta", concat(
+			fullBox("hdlr", concat(make([]byte, 4), []byte("pict"), make([]byte, 13))),
+			box("iprp", box("ipco", concat(
+				fullBox("ispe", u32(240), u32(135)),
+				fullBox("ispe", u32(1920), u32(1080)),
+				fullBox("pixi", []byte{3, 10, 10, 10}),
+				box("colr", concat([]byte("nclx"), u16(12), u16(13), u16(1), []byte{0x80})),
+				fullBox("auxC", []byte("urn:mpeg:mpegB:cicp:systems:auxiliary:alpha\x00")),
+			))),
+		)),
+		box("mdat", make([]byte, 32)),
+	)
+}
+
+// sequenceAVIF is an image sequence of grayscale frames
+func sequenceAVIF() []byte {
+	return concat(
+		box("ftyp", []byte("avis\x00\x00\x00\x00avifmsf1")),
+		fullBox("meta", box("iprp", box("ipco", concat(
+			fullBox("ispe", u32(320), u32(240)),
+			fullBox("pixi", []byte{1, 8}),
+		)))),
+		box("moov", make([]byte, 16)),
+		box("mdat", make([]byte, 16)),
+	)
+}
+
+func photoHEIC() []byte {
+	return concat(
+		box("ftyp", []byte("heic\x00\x00\x00\x00mif1heic")),
+		fullBox("meta", concat(
+			fullBox("hdlr", concat(make([]byte, 4), []byte("pict"), make([]byte, 13))),
+			box("iprp", box("ipco", concat(
+				box("colr", concat([]byte("prof"), iccProfile("Test RGB", false))),
+				fullBox("ispe", u32(4032), u32(3024)),
+				fullBox("pixi", []byte{3, 8, 8, 8}),
+			))),
+		)),
+		box("mdat", make([]byte, 32)),
+	)
+}
+
+// pagesTIFF has an RGBA page with camera metadata and a gray page
+func pagesTIFF() []byte {
+	rgba := make([]byte, 4*2*4)
+	for i := range rgba {
+		rgba[i] = uint8(i * 8)
+	}
+	return tiff{order: binary.BigEndian, pages: true, ifds: [][]field{
+		{
+			short(0x0100, 4),
+			short(0x0101, 2),
+			short(0x0102, 8, 8, 8, 8),
+			short(0x0103, 1),
+			short(0x0106, 2),
+			ascii(0x010e, "TIFF description"),
+			ascii(0x010f, "NIKON CORPORATION"),
+			ascii(0x0110, "NIKON D850"),
+			data(0x0111, rgba),
+			short(0x0112, 8),
+			short(0x0115, 4),
+			short(0x0116, 2),
+			long(0x0117, uint32(len(rgba))),
+			short(0x011c, 1),
+			ascii(0x013b, "TIFF artist"),
+			short(0x0152, 2),
+			undefined(0x8773, iccProfile("Test RGB", false)),
+		},
+		{
+			short(0x0100, 2),
+			short(0x0101, 2),
+			short(0x0102, 8),
+			short(0x0103, 1),
+			short(0x0106, 1),
+			data(0x0111, []byte{0, 64, 128, 255}),
+			short(0x0115, 1),
+			short(0x0116, 2),
+			long(0x0117, 4),
+		},
+	}}.bytes()
+}
+
+func rgbBMP() []byte {
+	pixels := make([]byte, 2*12) // rows of 3 pixels padded to 4 bytes
+	for i := range pixels {
+		pixels[i] = uint8(i * 10)
+	}
+	return bmp(3, 2, 24, pixels)
+}
+
+func topDownBMP() []byte {
+	return bmp(2, -2, 32, make([]byte, 2*2*4))
+}
+
+// TIFF
+
+type field struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+	nums  []uint32 // SHORT, LONG and RATIONAL values, encoded in the TIFF's order
+	link  int      // index of the IFD a LONG value points at
+	data  []byte   // data a LONG value points at, stored after the IFDs
+}
+
+type tiff struct {
+	order binary.ByteOrder
+	ifds  [][]field
+	pages bool // chain the IFDs as pages rather than sub-IFDs
+}
+
+func (t tiff) bytes() []byte {
+	valueSize := func(f field) int {
+		if len(f.value) > 4 {
+			return len(f.value) + len(f.value)%2
+		}
+		return 0
+	}
+	for _, fields := range t.ifds {
+		for j := range fields {
+			f := &fields[j]
+			for _, v := range f.nums {
+				if f.typ == 3 {
+					f.value = t.order.(binary.AppendByteOrder).AppendUint16(f.value, uint16(v))
+				} else {
+					f.value = t.order.(binary.AppendByteOrder).AppendUint32(f.value, v)
+				}
+			}
+		}
+	}
+	offsets := make([]int, len(t.ifds))
+	at := 8
+	for i, fields := range t.ifds {
+		sort.Slice(fields, func(a, b int) bool { return fields[a].tag < fields[b].tag })
+		offsets[i] = at
+		at += 2 + len(fields)*12 + 4
+		for _, f := range fields {
+			at += valueSize(f)
+		}
+	}
+	dataOffsets := map[*field]int{}
+	for i := range t.ifds {
+		for j := range t.ifds[i] {
+			if f := &t.ifds[i][j]; f.data != nil {
+				dataOffsets[f] = at
+				at += len(f.data) + len(f.data)%2
+			}
+		}
+	}
+
+	out := make([]byte, at)
+	if t.order == binary.BigEndian {
+		copy(out, "MM\x00*")
+	} else {
+		copy(out, "II*\x00")
+	}
+	t.order.PutUint32(out[4:], 8)
+	for i, fields := range t.ifds {
+		at := offsets[i]
+		t.order.PutUint16(out[at:], uint16(len(fields)))
+		extra := at + 2 + len(fields)*12 + 4
+		for j := range fields {
+			f := &fields[j]
+			entry := out[at+2+j*12:]
+			t.order.PutUint16(entry, f.tag)
+			t.order.PutUint16(entry[2:], f.typ)
+			t.order.PutUint32(entry[4:], f.count)
+			switch {
+			case f.link > 0:
+				t.order.PutUint32(entry[8:], uint32(offsets[f.link]))
+			case f.data != nil:
+				t.order.PutUint32(entry[8:], uint32(dataOffsets[f]))
+				copy(out[dataOffsets[f]:], f.data)
+			case len(f.value) > 4:
+				t.order.PutUint32(entry[8:], uint32(extra))
+				copy(out[extra:], f.value)
+				extra += valueSize(*f)
+			default:
+				copy(entry[8:12], f.value)
+			}
+		}
+		if t.pages && i+1 < len(t.ifds) {
+			t.order.PutUint32(out[at+2+len(fields)*12:], uint32(offsets[i+1]))
+		}
+	}
+	return out
+}
+
+func ascii(tag uint16, s string) field {
+	return field{tag: tag, typ: 2, count: uint32(len(s) + 1), value: []byte(s + "\x00")}
+}
+
+func byteField(tag uint16, v byte) field {
+	return field{tag: tag, typ: 1, count: 1, value: []byte{v}}
+}
+
+func undefined(tag uint16, b []byte) field {
+	return field{tag: tag, typ: 7, count: uint32(len(b)), value: b}
+}
+
+func short(tag uint16, values ...uint32) field {
+	return field{tag: tag, typ: 3, count: uint32(len(values)), nums: values}
+}
+
+func long(tag uint16, v uint32) field {
+	return field{tag: tag, typ: 4, count: 1, nums: []uint32{v}}
+}
+
+// rational takes numerator and denominator pairs
+func rational(tag uint16, values ...uint32) field {
+	return field{tag: tag, typ: 5, count: uint32(len(values) / 2), nums: values}
+}
+
+func link(tag uint16, ifd int) field {
+	return field{tag: tag, typ: 4, count: 1, link: ifd}
+}
+
+func data(tag uint16, b []byte) field {
+	return field{tag: tag, typ: 4, count: 1, data: b}
+}
+
+// JPEG
+
+func segment(marker byte, payload []byte) []byte {
+	return concat([]byte{0xff, marker}, u16(uint16(len(payload)+2)), payload)
+}
+
+func insertJPEGSegments(jpeg []byte, segments ...[]byte) []byte {
+	return concat(jpeg[:2], concat(segments...), jpeg[2:])
+}
+
+// resource is a Photoshop image resource with a padded Pascal string name
+func resource(id uint16, name string, data []byte) []byte {
+	pascal := append([]byte{byte(len(name))}, name...)
+	if len(pascal)%2 != 0 {
+		pascal = append(pascal, 0)
+	}
+	out := concat([]byte("8BIM"), u16(id), pascal, u32(uint32(len(data))), data)
+	if len(data)%2 != 0 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+func dataset(record, number byte, value string) []byte {
+	return concat([]byte{0x1c, record, number}, u16(uint16(len(value))), []byte(value))
+}
+
+// PNG
+
+func chunk(typ string, data []byte) []byte {
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+	return concat(u32(uint32(len(data))), []byte(typ), data, u32(crc.Sum32()))
+}
+
+// insertPNGChunks adds chunks after IHDR
+func insertPNGChunks(png []byte, chunks ...[]byte) []byte {
+	end := 8 + 8 + 13 + 4
+	return concat(png[:end], concat(chunks...), png[end:])
+}
+
+// WebP
+
+func riff(chunks ...[]byte) []byte {
+	body := concat([]byte("WEBP"), concat(chunks...))
+	return concat([]byte("RIFF"), le32(uint32(len(body))), body)
+}
+
+func chunk32(typ string, data []byte) []byte {
+	out := concat([]byte(typ), le32(uint32(len(data))), data)
+	if len(data)%2 != 0 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+func vp8l(width, height int, alpha bool) []byte {
+	bits := uint32(width-1) | uint32(height-1)<<14
+	if alpha {
+		bits |= 1 << 28
+	}
+	return concat([]byte{0x2f}, le32(bits), make([]byte, 4))
+}
+
+func vp8x(flags byte, width, height int) []byte {
+	w, h := width-1, height-1
+	return []byte{flags, 0, 0, 0, byte(w), byte(w >> 8), byte(w >> 16), byte(h), byte(h >> 8), byte(h >> 16)}
+}
+
+// ISOBMFF
+
+func box(typ string, payload ...[]byte) []byte {
+	body := concat(payload...)
+	return concat(u32(uint32(len(body)+8)), []byte(typ), body)
+}
+
+func fullBox(typ string, payload ...[]byte) []byte {
+	return box(typ, concat(append([][]byte{make([]byte, 4)}, payload...)...))
+}
+
+// BMP
+
+func bmp(width, height, bits int, pixels []byte) []byte {
+	header := concat(
+		[]byte("BM"), le32(uint32(54+len(pixels))), make([]byte, 4), le32(54),
+		le32(40), le32(uint32(width)), le32(uint32(int32(height))), []byte{1, 0}, []byte{byte(bits), 0},
+		le32(0), le32(uint32(len(pixels))), le32(2835), le32(2835), le32(0), le32(0),
+	)
+	return concat(header, pixels)
+}
+
+// ICC and XMP
+
+// iccProfile is a minimal ICC profile: a header and a description tag,
+// desc for version 2 profiles and mluc for version 4
+func iccProfile(description string, v4 bool) []byte {
+	var tag []byte
+	if v4 {
+		var text []byte
+		for _, unit := range utf16.Encode([]rune(description)) {
+			text = append(text, u16(unit)...)
+		}
+		tag = concat([]byte("mluc"), make([]byte, 4), u32(1), u32(12), []byte("enUS"), u32(uint32(len(text))), u32(28), text)
+	} else {
+		tag = concat([]byte("desc"), make([]byte, 4), u32(uint32(len(description)+1)), []byte(description+"\x00"), make([]byte, 79))
+	}
+	header := make([]byte, 128)
+	copy(header[4:], "test")
+	header[8] = 2
+	if v4 {
+		header[8] = 4
+	}
+	copy(header[12:], "mntrRGB XYZ ")
+	copy(header[36:], "acsp")
+	profile := concat(header, u32(1), []byte("desc"), u32(144), u32(uint32(len(tag))), tag)
+	binary.BigEndian.PutUint32(profile, uint32(len(profile)))
+	return profile
+}
+
+func xmpPacket(description string) []byte {
+	return []byte(`<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` + description + `
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`)
+}
+
+// Bytes
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func u16(v uint16) []byte {
+	return binary.BigEndian.AppendUint16(nil, v)
+}
+
+func u32(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+func le32(v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, v)
+}
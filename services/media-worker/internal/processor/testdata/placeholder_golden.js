@@ -0,0 +1,176 @@
+// Golden values for placeholder_test.go, from the reference encoders:
+// BlurHash as in woltapp/blurhash C/encode.c, ThumbHash as in
+// evanw/thumbhash js/thumbhash.js. Run with: node placeholder_golden.js
+//
+// The fixtures are generated by formula, identically in fixtureImage.
+
+function fixture(w, h, alpha) {
+  const rgba = new Uint8Array(w * h * 4)
+  for (let y = 0; y < h; y++) {
+    for (let x = 0; x < w; x++) {
+      const i = (y * w + x) * 4
+      rgba[i] = (x * 8 + y * 2) % 256
+      rgba[i + 1] = (y * 11) % 256
+      rgba[i + 2] = (x * y * 3) % 256
+      rgba[i + 3] = alpha ? (x < w / 4 ? 0 : x < w / 2 ? 128 : 255) : 255
+    }
+  }
+  return rgba
+}
+
+// --- BlurHash (C reference) ---
+
+const base83 = '0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~'
+
+function encode83(value, length) {
+  let s = ''
+  for (let i = 1; i <= length; i++) {
+    const digit = Math.floor(value / Math.pow(83, length - i)) % 83
+    s += base83[digit]
+  }
+  return s
+}
+
+function sRGBToLinear(value) {
+  const v = value / 255
+  return v <= 0.04045 ? v / 12.92 : Math.pow((v + 0.055) / 1.055, 2.4)
+}
+
+function linearTosRGB(value) {
+  const v = Math.max(0, Math.min(1, value))
+  return v <= 0.0031308 ? Math.trunc(v * 12.92 * 255 + 0.5) : Math.trunc((1.055 * Math.pow(v, 1 / 2.4) - 0.055) * 255 + 0.5)
+}
+
+function signPow(value, exp) {
+  return Math.sign(value) * Math.pow(Math.abs(value), exp)
+}
+
+function multiplyBasisFunction(xComponent, yComponent, width, height, rgb) {
+  let r = 0, g = 0, b = 0
+  const normalisation = xComponent === 0 && yComponent === 0 ? 1 : 2
+  for (let y = 0; y < height; y++) {
+    for (let x = 0; x < width; x++) {
+      const basis = Math.cos(Math.PI * xComponent * x / width) * Math.cos(Math.PI * yComponent * y / height)
+      const i = (y * width + x) * 4
+      r += basis * sRGBToLinear(rgb[i])
+      g += basis * sRGBToLinear(rgb[i + 1])
+      b += basis * sRGBToLinear(rgb[i + 2])
+    }
+  }
+  const scale = normalisation / (width * height)
+  return [r * scale, g * scale, b * scale]
+}
+
+function blurHash(xComponents, yComponents, width, height, rgb) {
+  const factors = []
+  for (let y = 0; y < yComponents; y++) {
+    for (let x = 0; x < xComponents; x++) {
+      factors.push(multiplyBasisFunction(x, y, width, height, rgb))
+    }
+  }
+  const dc = factors[0]
+  const ac = factors.slice(1)
+
+  let hash = encode83((xComponents - 1) + (yComponents - 1) * 9, 1)
+  let maximumValue
+  if (ac.length > 0) {
+    let actualMaximumValue = 0
+    for (const f of ac) for (const v of f) actualMaximumValue = Math.max(Math.abs(v), actualMaximumValue)
+    const quantisedMaximumValue = Math.max(0, Math.min(82, Math.floor(actualMaximumValue * 166 - 0.5)))
+    maximumValue = (quantisedMaximumValue + 1) / 166
+    hash += encode83(quantisedMaximumValue, 1)
+  } else {
+    maximumValue = 1
+    hash += encode83(0, 1)
+  }
+
+  hash += encode83((linearTosRGB(dc[0]) << 16) + (linearTosRGB(dc[1]) << 8) + linearTosRGB(dc[2]), 4)
+  for (const f of ac) {
+    const quant = v => Math.max(0, Math.min(18, Math.floor(signPow(v / maximumValue, 0.5) * 9 + 9.5)))
+    hash += encode83(quant(f[0]) * 19 * 19 + quant(f[1]) * 19 + quant(f[2]), 2)
+  }
+  return hash
+}
+
+// --- ThumbHash (JS reference) ---
+
+function rgbaToThumbHash(w, h, rgba) {
+  const { PI, round, max, cos, abs } = Math
+  let avg_r = 0, avg_g = 0, avg_b = 0, avg_a = 0
+  for (let i = 0, j = 0; i < w * h; i++, j += 4) {
+    const alpha = rgba[j + 3] / 255
+    avg_r += alpha / 255 * rgba[j]
+    avg_g += alpha / 255 * rgba[j + 1]
+    avg_b += alpha / 255 * rgba[j + 2]
+    avg_a += alpha
+  }
+  if (avg_a) {
+    avg_r /= avg_a
+    avg_g /= avg_a
+    avg_b /= avg_a
+  }
+
+  const hasAlpha = avg_a < w * h
+  const l_limit = hasAlpha ? 5 : 7
+  const lx = max(1, round(l_limit * w / max(w, h)))
+  const ly = max(1, round(l_limit * h / max(w, h)))
+  const l = [], p = [], q = [], a = []
+
+  for (let i = 0, j = 0; i < w * h; i++, j += 4) {
+    const alpha = rgba[j + 3] / 255
+    const r = avg_r * (1 - alpha) + alpha / 255 * rgba[j]
+    const g = avg_g * (1 - alpha) + alpha / 255 * rgba[j + 1]
+    const b = avg_b * (1 - alpha) + alpha / 255 * rgba[j + 2]
+    l[i] = (r + g + b) / 3
+    p[i] = (r + g) / 2 - b
+    q[i] = r - g
+    a[i] = alpha
+  }
+
+  const encodeChannel = (channel, nx, ny) => {
+    let dc = 0, scale = 0
+    const ac = [], fx = []
+    for (let cy = 0; cy < ny; cy++) {
+      for (let cx = 0; cx * ny < nx * (ny - cy); cx++) {
+        let f = 0
+        for (let x = 0; x < w; x++) fx[x] = cos(PI / w * cx * (x + 0.5))
+        for (let y = 0; y < h; y++)
+          for (let x = 0, fy = cos(PI / h * cy * (y + 0.5)); x < w; x++)
+            f += channel[x + y * w] * fx[x] * fy
+        f /= w * h
+        if (cx || cy) {
+          ac.push(f)
+          scale = max(scale, abs(f))
+        } else {
+          dc = f
+        }
+      }
+    }
+    if (scale) for (let i = 0; i < ac.length; i++) ac[i] = 0.5 + 0.5 / scale * ac[i]
+    return [dc, ac, scale]
+  }
+
+  const [l_dc, l_ac, l_scale] = encodeChannel(l, max(3, lx), max(3, ly))
+  const [p_dc, p_ac, p_scale] = encodeChannel(p, 3, 3)
+  const [q_dc, q_ac, q_scale] = encodeChannel(q, 3, 3)
+  const [a_dc, a_ac, a_scale] = hasAlpha ? encodeChannel(a, 5, 5) : []
+
+  const isLandscape = w > h
+  const header24 = round(63 * l_dc) | (round(31.5 + 31.5 * p_dc) << 6) | (round(31.5 + 31.5 * q_dc) << 12) | (round(31 * l_scale) << 18) | (hasAlpha << 23)
+  const header16 = (isLandscape ? ly : lx) | (round(63 * p_scale) << 3) | (round(63 * q_scale) << 9) | (isLandscape << 15)
+  const hash = [header24 & 255, (header24 >> 8) & 255, header24 >> 16, header16 & 255, header16 >> 8]
+  const ac_start = hasAlpha ? 6 : 5
+  let ac_index = 0
+  if (hasAlpha) hash.push(round(15 * a_dc) | (round(15 * a_scale) << 4))
+  for (const ac of hasAlpha ? [l_ac, p_ac, q_ac, a_ac] : [l_ac, p_ac, q_ac])
+    for (const f of ac)
+      hash[ac_start + (ac_index >> 1)] |= round(15 * f) << ((ac_index++ & 1) << 2)
+  return Buffer.from(hash).toString('base64')
+}
+
+for (const [w, h, alpha] of [[32, 24, false], [20, 32, false], [32, 24, true]]) {
+  const rgba = fixture(w, h, alpha)
+  const line = [`${w}x${h}${alpha ? ' alpha' : ''}:`, `thumbhash=${rgbaToThumbHash(w, h, rgba)}`]
+  if (!alpha) line.push(`blurhash4x3=${blurHash(4, 3, w, h, rgba)}`, `blurhash1x1=${blurHash(1, 1, w, h, rgba)}`, `blurhash9x9=${blurHash(9, 9, w, h, rgba)}`)
+  console.log(line.join(' '))
+}
@@ -0,0 +1,134 @@
+package processor
+
+import (
+	"image"
+	"math"
+)
+
+// encodeThumbHash encodes img as a ThumbHash (https://evanw.github.io/thumbhash/).
+// The image must fit in 100×100.
+func encodeThumbHash(img *image.NRGBA) []byte {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	n := w * h
+
+	// Average color, weighted by alpha
+	var avgR, avgG, avgB, avgA float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := img.NRGBAAt(img.Rect.Min.X+x, img.Rect.Min.Y+y)
+			alpha := float64(c.A) / 255
+			avgR += alpha / 255 * float64(c.R)
+			avgG += alpha / 255 * float64(c.G)
+			avgB += alpha / 255 * float64(c.B)
+			avgA += alpha
+		}
+	}
+	if avgA > 0 {
+		avgR /= avgA
+		avgG /= avgA
+		avgB /= avgA
+	}
+
+	hasAlpha := avgA < float64(n)
+	lLimit := 7
+	if hasAlpha {
+		// Fewer luminance bits leave room for alpha
+		lLimit = 5
+	}
+	longest := float64(max(w, h))
+	lx := max(1, int(jsRound(float64(lLimit*w)/longest)))
+	ly := max(1, int(jsRound(float64(lLimit*h)/longest)))
+
+	// Luminance, yellow-blue, red-green and alpha, composited on the average color
+	l, p, q, a := make([]float64, n), make([]float64, n), make([]float64, n), make([]float64, n)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := img.NRGBAAt(img.Rect.Min.X+x, img.Rect.Min.Y+y)
+			alpha := float64(c.A) / 255
+			r := avgR*(1-alpha) + alpha/255*float64(c.R)
+			g := avgG*(1-alpha) + alpha/255*float64(c.G)
+			b := avgB*(1-alpha) + alpha/255*float64(c.B)
+			i := y*w + x
+			l[i] = (r + g + b) / 3
+			p[i] = (r+g)/2 - b
+			q[i] = r - g
+			a[i] = alpha
+		}
+	}
+
+	lDC, lAC, lScale := thumbHashChannel(l, w, h, max(3, lx), max(3, ly))
+	pDC, pAC, pScale := thumbHashChannel(p, w, h, 3, 3)
+	qDC, qAC, qScale := thumbHashChannel(q, w, h, 3, 3)
+
+	isLandscape := w > h
+	header24 := int(jsRound(63*lDC)) | int(jsRound(31.5+31.5*pDC))<<6 | int(jsRound(31.5+31.5*qDC))<<12 | int(jsRound(31*lScale))<<18
+	if hasAlpha {
+		header24 |= 1 << 23
+	}
+	header16 := int(jsRound(63*pScale))<<3 | int(jsRound(63*qScale))<<9
+	if isLandscape {
+		header16 |= ly | 1<<15
+	} else {
+		header16 |= lx
+	}
+	hash := []byte{byte(header24), byte(header24 >> 8), byte(header24 >> 16), byte(header16), byte(header16 >> 8)}
+
+	acs := [][]float64{lAC, pAC, qAC}
+	if hasAlpha {
+		aDC, aAC, aScale := thumbHashChannel(a, w, h, 5, 5)
+		hash = append(hash, byte(int(jsRound(15*aDC))|int(jsRound(15*aScale))<<4))
+		acs = append(acs, aAC)
+	}
+
+	// Two 4-bit factors per byte, low nibble first
+	start, index := len(hash), 0
+	for _, ac := range acs {
+		for _, f := range ac {
+			if start+index/2 >= len(hash) {
+				hash = append(hash, 0)
+			}
+			hash[start+index/2] |= byte(int(jsRound(15*f)) << ((index & 1) * 4))
+			index++
+		}
+	}
+	return hash
+}
+
+// thumbHashChannel encodes a channel with the DCT into its constant term and
+// its varying terms, normalized to 0-1 by their scale
+func thumbHashChannel(channel []float64, w, h, nx, ny int) (dc float64, ac []float64, scale float64) {
+	fx := make([]float64, w)
+	for cy := 0; cy < ny; cy++ {
+		for cx := 0; cx*ny < nx*(ny-cy); cx++ {
+			for x := 0; x < w; x++ {
+				fx[x] = math.Cos(math.Pi / float64(w) * float64(cx) * (float64(x) + 0.5))
+			}
+			var f float64
+			for y := 0; y < h; y++ {
+				fy := math.Cos(math.Pi / float64(h) * float64(cy) * (float64(y) + 0.5))
+				for x := 0; x < w; x++ {
+					f += channel[x+y*w] * fx[x] * fy
+				}
+			}
+			f /= float64(w * h)
+			if cx > 0 || cy > 0 {
+				ac = append(ac, f)
+				scale = math.Max(scale, math.Abs(f))
+			} else {
+				dc = f
+			}
+		}
+	}
+	if scale > 0 {
+		for i := range ac {
+			ac[i] = 0.5 + 0.5/scale*ac[i]
+		}
+	}
+	return dc, ac, scale
+}
+
+// jsRound rounds halves up like JavaScript's Math.round, which the reference
+// encoder uses
+func jsRound(v float64) float64 {
+	return math.Floor(v + 0.5)
+}
@@ -0,0 +1,114 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"time"
+)
+
+// XMP namespaces of the properties that are read
+const (
+	nsRDF       = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	nsDC        = "http://purl.org/dc/elements/1.1/"
+	nsXMP       = "http://ns.adobe.com/xap/1.0/"
+	nsPhotoshop = "http://ns.adobe.com/photoshop/1.0/"
+	nsEXIF      = "http://ns.adobe.com/exif/1.0/"
+)
+
+// xmpTimeLayouts are the date forms XMP allows, most precise first
+var xmpTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// parseXMP reads Dublin Core and capture time properties of an XMP packet.
+// Properties are simple values, language alternatives (the first is taken)
+// or arrays, written as elements or as attributes of rdf:Description.
+func parseXMP(data []byte, meta *ImageMetadata) error {
+	values := map[xml.Name][]string{}
+	var property xml.Name // the property whose value is being read
+	var text strings.Builder
+	// Depths of the rdf:Description and property elements being read, 0 outside
+	depth, descriptionDepth, propertyDepth := 0, 0, 0
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			text.Reset()
+			switch {
+			case propertyDepth > 0:
+				// Inside a value: rdf:Bag, rdf:Seq, rdf:Alt, rdf:li or a structure
+			case t.Name.Space == nsRDF && t.Name.Local == "Description":
+				descriptionDepth = depth
+				for _, attr := range t.Attr {
+					if attr.Name.Space != nsRDF && attr.Name.Space != "xmlns" && attr.Name.Space != "" {
+						values[attr.Name] = append(values[attr.Name], attr.Value)
+					}
+				}
+			case descriptionDepth > 0 && depth == descriptionDepth+1:
+				property, propertyDepth = t.Name, depth
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if propertyDepth > 0 && (t.Name.Space == nsRDF && t.Name.Local == "li" || depth == propertyDepth) {
+				if v := cleanText(text.String()); v != "" {
+					values[property] = append(values[property], v)
+				}
+				text.Reset()
+			}
+			switch depth {
+			case propertyDepth:
+				propertyDepth = 0
+			case descriptionDepth:
+				descriptionDepth = 0
+			}
+			depth--
+		}
+	}
+
+	first := func(space, local string) string {
+		if v := values[xml.Name{Space: space, Local: local}]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	meta.Keywords = append(meta.Keywords, values[xml.Name{Space: nsDC, Local: "subject"}]...)
+	meta.Caption = first(nsDC, "description")
+	meta.Title = first(nsDC, "title")
+	meta.Headline = first(nsPhotoshop, "Headline")
+	meta.Copyright = first(nsDC, "rights")
+	meta.Artist = strings.Join(values[xml.Name{Space: nsDC, Local: "creator"}], ", ")
+
+	for _, name := range []xml.Name{{Space: nsEXIF, Local: "DateTimeOriginal"}, {Space: nsPhotoshop, Local: "DateCreated"}, {Space: nsXMP, Local: "CreateDate"}} {
+		if t, ok := parseXMPTime(first(name.Space, name.Local)); ok {
+			meta.CapturedAt = &t
+			break
+		}
+	}
+	return nil
+}
+
+func parseXMPTime(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range xmpTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
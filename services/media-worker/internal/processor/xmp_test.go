@@ -0,0 +1,119 @@
+package processor
+
+import "testing"
+
+// xmpDocument wraps the attributes and content of an rdf:Description in an
+// XMP packet declaring the namespaces read
+func xmpDocument(attributes, content string) []byte {
+	return []byte(`<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+    xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/"
+    xmlns:exif="http://ns.adobe.com/exif/1.0/"
+    xmlns:Iptc4xmpCore="http://iptc.org/std/Iptc4xmpCore/1.0/xmlns/"
+    ` + attributes + `>` + content + `</rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`)
+}
+
+func TestParseXMP(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want *ImageMetadata
+	}{
+		{"empty", nil, &ImageMetadata{}},
+		{"not XML", []byte("\x00\x01binary\xff"), &ImageMetadata{}},
+		{
+			"elements",
+			xmpDocument("", `
+   <dc:title><rdf:Alt><rdf:li xml:lang="x-default">Title</rdf:li><rdf:li xml:lang="de">Titel</rdf:li></rdf:Alt></dc:title>
+   <dc:description><rdf:Alt><rdf:li xml:lang="x-default">Caption</rdf:li></rdf:Alt></dc:description>
+   <dc:rights><rdf:Alt><rdf:li xml:lang="x-default">© Agency</rdf:li></rdf:Alt></dc:rights>
+   <dc:creator><rdf:Seq><rdf:li>Ann</rdf:li><rdf:li>Bo</rdf:li></rdf:Seq></dc:creator>
+   <dc:subject><rdf:Bag><rdf:li>one</rdf:li><rdf:li> </rdf:li><rdf:li>two</rdf:li></rdf:Bag></dc:subject>
+   <photoshop:Headline>Headline</photoshop:Headline>`),
+			&ImageMetadata{Title: "Title", Caption: "Caption", Copyright: "© Agency", Artist: "Ann, Bo", Headline: "Headline", Keywords: []string{"one", "two"}},
+		},
+		{
+			"attributes",
+			xmpDocument(`photoshop:Headline="Headline &amp; more" xmp:CreateDate="2021-06-01T08:00:00Z"`, ""),
+			&ImageMetadata{Headline: "Headline & more", CapturedAt: timePtr("2021-06-01T08:00:00Z")},
+		},
+		{
+			"structures do not leak into properties",
+			xmpDocument("", `
+   <Iptc4xmpCore:CreatorContactInfo rdf:parseType="Resource">
+    <Iptc4xmpCore:CiEmailWork>ann@example.com</Iptc4xmpCore:CiEmailWork>
+   </Iptc4xmpCore:CreatorContactInfo>
+   <photoshop:Headline>Headline</photoshop:Headline>`),
+			&ImageMetadata{Headline: "Headline"},
+		},
+		{
+			"the capture time is preferred",
+			xmpDocument(`xmp:CreateDate="2020-01-01T00:00:00Z" exif:DateTimeOriginal="2019-05-05T05:05:05.5+09:00"`, ""),
+			&ImageMetadata{CapturedAt: timePtr("2019-05-05T05:05:05.5+09:00")},
+		},
+		{
+			"invalid dates are skipped",
+			xmpDocument(`exif:DateTimeOriginal="yesterday" photoshop:DateCreated="2018-03-04"`, ""),
+			&ImageMetadata{CapturedAt: timePtr("2018-03-04T00:00:00Z")},
+		},
+		{
+			"unclosed elements",
+			[]byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:subject><rdf:Bag><rdf:li>kept</rdf:li><rdf:li>cut`),
+			&ImageMetadata{Keywords: []string{"kept"}},
+		},
+		{
+			"properties outside rdf:Description",
+			[]byte(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Title</dc:title></rdf:RDF>`),
+			&ImageMetadata{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var meta ImageMetadata
+			if err := parseXMP(tt.data, &meta); err != nil {
+				t.Fatalf("parseXMP: %v", err)
+			}
+			if got, want := metaJSON(t, &meta), metaJSON(t, tt.want); got != want {
+				t.Errorf("metadata = %s\nwant %s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseXMPTime(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string // RFC 3339, empty when invalid
+	}{
+		{"2021-06-01T08:30:15.25+02:00", "2021-06-01T08:30:15.25+02:00"},
+		{"2021-06-01T08:30:15Z", "2021-06-01T08:30:15Z"},
+		{"2021-06-01T08:30:15", "2021-06-01T08:30:15Z"},
+		{"2021-06-01T08:30-05:00", "2021-06-01T08:30:00-05:00"},
+		{"2021-06-01T08:30", "2021-06-01T08:30:00Z"},
+		{"2021-06-01", "2021-06-01T00:00:00Z"},
+		{"2021-06", ""},
+		{"2021:06:01 08:30:15", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, ok := parseXMPTime(tt.value)
+		if tt.want == "" {
+			if ok {
+				t.Errorf("parseXMPTime(%q) = %v, want invalid", tt.value, got)
+			}
+			continue
+		}
+		if want := *timePtr(tt.want); !ok || !got.Equal(want) {
+			t.Errorf("parseXMPTime(%q) = %v, %v; want %v", tt.value, got, ok, want)
+		}
+	}
+}
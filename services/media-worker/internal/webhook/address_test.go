@@ -0,0 +1,87 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"100.100.100.200", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"224.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := IsPublicAddress(net.ParseIP(tt.ip)); got != tt.public {
+			t.Errorf("IsPublicAddress(%s) = %v, want %v", tt.ip, got, tt.public)
+		}
+	}
+}
+
+func TestSendBlocksPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	del := delivery{ID: "1", Event: EventAssetReady, Payload: []byte(`{}`), URL: server.URL, Secret: "secret"}
+
+	blocked := &Dispatcher{client: newHTTPClient(false)}
+	if _, _, err := blocked.send(context.Background(), del); !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("send to %s: got error %v, want ErrBlockedAddress", server.URL, err)
+	}
+
+	allowed := &Dispatcher{client: newHTTPClient(true)}
+	status, _, err := allowed.send(context.Background(), del)
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("send with private networks allowed: got %d, %v", status, err)
+	}
+}
+
+func TestSendBlocksRedirectsToPrivateAddresses(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("redirect target was reached")
+	}))
+	defer internal.Close()
+
+	// The receiver itself must be reachable for the redirect to be followed,
+	// so this checks the redirect with a dialer that only blocks the target
+	client := newHTTPClient(false)
+	transport := client.Transport.(*http.Transport)
+	dial := transport.DialContext
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL, http.StatusTemporaryRedirect)
+	}))
+	defer redirector.Close()
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address == redirector.Listener.Addr().String() {
+			return (&net.Dialer{}).DialContext(ctx, network, address)
+		}
+		return dial(ctx, network, address)
+	}
+
+	d := &Dispatcher{client: client}
+	del := delivery{ID: "1", Event: EventAssetReady, Payload: []byte(`{}`), URL: redirector.URL, Secret: "secret"}
+	if _, _, err := d.send(context.Background(), del); !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("redirect to %s: got error %v, want ErrBlockedAddress", internal.URL, err)
+	}
+}
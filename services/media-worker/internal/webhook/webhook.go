@@ -0,0 +1,296 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// Asset lifecycle events emitted by the worker (must match API)
+const (
+	EventAssetReady  = "asset.ready"
+	EventAssetFailed = "asset.failed"
+)
+
+const (
+	// DefaultPollInterval is how often the dispatcher looks for due deliveries
+	DefaultPollInterval = 2 * time.Second
+	// BatchSize is the maximum number of deliveries claimed per poll
+	BatchSize = 20
+	// LeaseDuration is how long a claimed delivery is hidden from other
+	// dispatchers; a crashed dispatcher's deliveries are retried after it
+	LeaseDuration = 5 * time.Minute
+	// RequestTimeout bounds a single delivery attempt
+	RequestTimeout = 10 * time.Second
+	// MaxResponseBody is how much of the receiver's response is logged
+	MaxResponseBody = 4096
+
+	baseBackoff = 10 * time.Second
+	maxBackoff  = time.Hour
+)
+
+// Execer is satisfied by both the connection pool and a transaction
+type Execer interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+}
+
+// Event is the JSON body delivered to webhook endpoints
+type Event struct {
+	Event      string      `json:"event"`
+	OccurredAt time.Time   `json:"occurredAt"`
+	Data       interface{} `json:"data"`
+}
+
+// Emit queues a delivery of an event for every active webhook subscribed to it
+func Emit(ctx context.Context, q Execer, event string, assetID uuid.UUID, data interface{}) error {
+	payload, err := json.Marshal(Event{
+		Event:      event,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook event: %w", err)
+	}
+
+	_, err = q.Exec(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event, asset_id, payload)
+		SELECT id, $1, $2, $3 FROM webhooks
+		WHERE active AND (cardinality(events) = 0 OR $1 = ANY(events))
+	`, event, assetID, payload)
+	if err != nil {
+		return fmt.Errorf("failed to queue webhook deliveries: %w", err)
+	}
+
+	return nil
+}
+
+// Dispatcher delivers queued webhook events, retrying failures with
+// exponential backoff until they succeed or run out of attempts
+type Dispatcher struct {
+	db           *pgxpool.Pool
+	client       *http.Client
+	pollInterval time.Duration
+	wg           sync.WaitGroup
+	ctx          context.Context
+	cancel       context.CancelFunc
+}
+
+type delivery struct {
+	ID          string
+	Event       string
+	Payload     []byte
+	Attempt     int
+	MaxAttempts int
+	URL         string
+	Secret      string
+}
+
+// NewDispatcher returns a dispatcher that delivers to public addresses only,
+// or to any address when allowPrivateNetworks is set (for local development)
+func NewDispatcher(db *pgxpool.Pool, allowPrivateNetworks bool) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		db:           db,
+		client:       newHTTPClient(allowPrivateNetworks),
+		pollInterval: DefaultPollInterval,
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+func (d *Dispatcher) Start() {
+	d.wg.Add(1)
+	go d.run()
+}
+
+func (d *Dispatcher) Stop() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) run() {
+	defer d.wg.Done()
+
+	log.Info().Msg("Webhook dispatcher started")
+
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			log.Info().Msg("Webhook dispatcher stopping")
+			return
+		case <-ticker.C:
+			deliveries, err := d.claim(d.ctx)
+			if err != nil {
+				if d.ctx.Err() == nil {
+					log.Error().Err(err).Msg("Failed to claim webhook deliveries")
+				}
+				continue
+			}
+
+			for _, del := range deliveries {
+				d.deliver(d.ctx, del)
+			}
+		}
+	}
+}
+
+// claim leases due deliveries so concurrent dispatchers never send the same one twice
+func (d *Dispatcher) claim(ctx context.Context) ([]delivery, error) {
+	rows, err := d.db.Query(ctx, `
+		WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE state = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), claimed AS (
+			UPDATE webhook_deliveries d
+			SET attempts = d.attempts + 1,
+				next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $2)
+			FROM due WHERE d.id = due.id
+			RETURNING d.id, d.webhook_id, d.event, d.payload, d.attempts, d.max_attempts
+		)
+		SELECT c.id, c.event, c.payload, c.attempts, c.max_attempts, w.url, w.secret
+		FROM claimed c JOIN webhooks w ON w.id = c.webhook_id
+	`, BatchSize, LeaseDuration.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []delivery
+	for rows.Next() {
+		var del delivery
+		if err := rows.Scan(&del.ID, &del.Event, &del.Payload, &del.Attempt, &del.MaxAttempts, &del.URL, &del.Secret); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, del)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// deliver sends a single attempt and records its outcome
+func (d *Dispatcher) deliver(ctx context.Context, del delivery) {
+	started := time.Now()
+	statusCode, body, sendErr := d.send(ctx, del)
+	duration := time.Since(started)
+
+	var errMsg *string
+	if sendErr != nil {
+		msg := sendErr.Error()
+		errMsg = &msg
+	} else if statusCode < 200 || statusCode > 299 {
+		msg := fmt.Sprintf("unexpected status code %d", statusCode)
+		errMsg = &msg
+	}
+
+	var status *int
+	if statusCode > 0 {
+		status = &statusCode
+	}
+
+	_, err := d.db.Exec(ctx, `
+		INSERT INTO webhook_delivery_attempts (delivery_id, attempt, status_code, response_body, error, duration_ms)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, del.ID, del.Attempt, status, body, errMsg, duration.Milliseconds())
+	if err != nil {
+		log.Error().Err(err).Str("delivery_id", del.ID).Msg("Failed to record webhook attempt")
+	}
+
+	switch {
+	case errMsg == nil:
+		_, err = d.db.Exec(ctx, `
+			UPDATE webhook_deliveries
+			SET state = 'delivered', last_status_code = $2, last_error = NULL, delivered_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+		`, del.ID, status)
+		log.Info().Str("delivery_id", del.ID).Str("event", del.Event).Int("status", statusCode).Msg("Webhook delivered")
+
+	case del.Attempt >= del.MaxAttempts:
+		_, err = d.db.Exec(ctx, `
+			UPDATE webhook_deliveries SET state = 'failed', last_status_code = $2, last_error = $3
+			WHERE id = $1
+		`, del.ID, status, errMsg)
+		log.Warn().Str("delivery_id", del.ID).Str("event", del.Event).Str("error", *errMsg).Msg("Webhook delivery failed permanently")
+
+	default:
+		backoff := Backoff(del.Attempt)
+		_, err = d.db.Exec(ctx, `
+			UPDATE webhook_deliveries
+			SET last_status_code = $2, last_error = $3, next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $4)
+			WHERE id = $1
+		`, del.ID, status, errMsg, backoff.Seconds())
+		log.Warn().Str("delivery_id", del.ID).Str("event", del.Event).Str("error", *errMsg).
+			Dur("retry_in", backoff).Msg("Webhook delivery failed, will retry")
+	}
+	if err != nil {
+		log.Error().Err(err).Str("delivery_id", del.ID).Msg("Failed to update webhook delivery")
+	}
+}
+
+// send posts the signed payload and returns the status code and a truncated response body
+func (d *Dispatcher) send(ctx context.Context, del delivery) (int, *string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, del.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Mediapod-Webhooks/1.0")
+	req.Header.Set("X-Mediapod-Event", del.Event)
+	req.Header.Set("X-Mediapod-Delivery", del.ID)
+	req.Header.Set("X-Mediapod-Timestamp", timestamp)
+	req.Header.Set("X-Mediapod-Signature", "sha256="+Sign(del.Secret, timestamp, del.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, MaxResponseBody))
+	body := string(bytes.ToValidUTF8(data, nil))
+	return resp.StatusCode, &body, nil
+}
+
+// Sign computes the hex HMAC-SHA256 of "{timestamp}.{payload}" with the webhook secret.
+// Receivers recompute it to verify the X-Mediapod-Signature header.
+func Sign(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns the delay before the next attempt: exponential from 10s,
+// capped at one hour, with +/-20% jitter to spread out retries
+func Backoff(attempt int) time.Duration {
+	delay := float64(baseBackoff) * math.Pow(2, float64(attempt-1))
+	if delay > float64(maxBackoff) {
+		delay = float64(maxBackoff)
+	}
+	jitter := 0.8 + 0.4*rand.Float64()
+	return time.Duration(delay * jitter)
+}
@@ -20,7 +20,7 @@ const (
 type Job struct {
 	ID      string `json:"id"`
 	AssetID string `json:"assetId"`
-	Type    string `json:"type"` // transcode, thumbnail, extract_meta
+	Type    string `json:"type"` // transcode, analyze_image, thumbnail, extract_meta
 }
 
 type Pool struct {
@@ -92,6 +92,16 @@ func (p *Pool) worker(id int) {
 				continue
 			}
 
+			// Jobs are delivered at least once; skip any that already completed
+			run, err := p.processor.BeginJob(p.ctx, job.ID)
+			if err != nil {
+				log.Error().Err(err).Str("job_id", job.ID).Msg("Failed to start job")
+			}
+			if !run && err == nil {
+				log.Info().Str("job_id", job.ID).Msg("Skipping already completed job")
+				continue
+			}
+
 			log.Info().
 				Int("worker_id", id).
 				Str("job_id", job.ID).
@@ -104,6 +114,8 @@ func (p *Pool) worker(id int) {
 			err = p.processJob(jobCtx, &job)
 			cancel()
 
+			p.processor.FinishJob(context.Background(), job.ID, err)
+
 			if err != nil {
 				log.Error().
 					Err(err).
@@ -131,6 +143,8 @@ func (p *Pool) processJob(ctx context.Context, job *Job) error {
 	switch job.Type {
 	case "transcode":
 		return p.processor.TranscodeVideo(ctx, assetID)
+	case "analyze_image", "placeholder": // placeholder: queued before images were analyzed as a whole
+		return p.processor.AnalyzeImage(ctx, assetID)
 	case "thumbnail":
 		return p.processor.GenerateThumbnail(ctx, assetID)
 	case "extract_meta":